@@ -5,25 +5,28 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"math/big"
 	"net/http"
+	"net/url"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	builderApiV1 "github.com/attestantio/go-builder-client/api/v1"
 	eth2 "github.com/attestantio/go-eth2-client/api"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/avast/retry-go/v4"
+	"github.com/dappnode/mev-sp-oracle/client"
 	"github.com/dappnode/mev-sp-oracle/config"
 	"github.com/dappnode/mev-sp-oracle/constants"
+	"github.com/dappnode/mev-sp-oracle/contract"
 	"github.com/dappnode/mev-sp-oracle/metrics"
 	"github.com/dappnode/mev-sp-oracle/oracle"
 	"github.com/dappnode/mev-sp-oracle/utils"
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/pkg/errors"
 	"golang.org/x/exp/maps"
@@ -37,8 +40,9 @@ import (
 // slots are still pending to be processed. This is the max number of slots allowed
 var MaxSlotsBehind = uint64(64)
 
-// Note that the api has no paging, so it is not suitable for large queries, but
-// it should be able to scale to a few thousand subscribed validators without any problem
+// Results per page of the block and donation endpoints, if no limit is given, and the max
+var DefaultPageLimit = 100
+var MaxPageLimit = 1000
 
 // Important: These are the retry options when an api call involves external call to
 // the beacon node or execution client. The idea is to try once, and fail fast.
@@ -54,14 +58,16 @@ var SlotsInOneMonth = uint64(216000)
 
 const (
 	// Available endpoints
-	pathStatus            = "/status"
-	pathConfig            = "/config"
-	pathValidatorRelayers = "/registeredrelays/{valpubkey}"
-	pathState             = "/state"
+	pathStatus             = "/status"
+	pathConfig             = "/config"
+	pathValidatorRelayers  = "/registeredrelays/{valpubkey}"
+	pathWithdrawalRelayers = "/registeredrelays/withdrawal/{withdrawalAddress}"
+	pathState              = "/state"
 
 	// Memory endpoints: what the oracle knows
 	pathMemoryValidators             = "/memory/validators"
 	pathMemoryValidatorByIndex       = "/memory/validator/{valindex}"
+	pathMemoryValidatorHistory       = "/memory/validator/{valindex}/history"
 	pathMemoryValidatorsByIndex      = "/memory/validatorsbyindex/{valindices}"
 	pathMemoryValidatorsByWithdrawal = "/memory/validators/{withdrawalAddress}"
 	pathMemoryFeesInfo               = "/memory/feesinfo"
@@ -71,9 +77,24 @@ const (
 	pathMemoryWrongFeeBlocks         = "/memory/wrongfeeblocks"
 	pathMemoryDonations              = "/memory/donations"
 	pathMemoryPoolStatistics         = "/memory/statistics"
+	pathMemorySimulate               = "/memory/simulate"
+	pathMemoryRewardRecipients       = "/memory/rewardrecipients"
+	pathMemoryRewardRecipientsOf     = "/memory/rewardrecipients/{address}"
+	pathMemoryClaims                 = "/memory/claims/{address}"
+	pathMemoryConsolidations         = "/memory/consolidations"
+	pathMemoryConsolidationsOf       = "/memory/consolidations/{valindex}"
 
 	// Onchain endpoints: what is submitted to the contract
-	pathOnchainMerkleProof = "/onchain/proof/{withdrawalAddress}"
+	pathOnchainMerkleProof    = "/onchain/proof/{withdrawalAddress}"
+	pathOnchainReconciliation = "/onchain/reconciliation"
+	pathOnchainVerify         = "/onchain/verify"
+	pathOnchainTree           = "/onchain/tree/{slot}"
+	pathOnchainClaimTx        = "/onchain/claimtx/{address}"
+	pathOnchainCheckpoints    = "/onchain/checkpoints"
+	pathOnchainCheckpoint     = "/onchain/checkpoint/{slot}"
+
+	// Stream of events
+	pathEvents = "/events"
 )
 
 type ApiService struct {
@@ -84,28 +105,48 @@ type ApiService struct {
 	oracle        *oracle.Oracle
 	ApiListenAddr string
 	Network       string
+
+	// ReportConsolidated events seen onchain by slot. See consolidationsBySlot
+	consolidations          map[uint64]*contract.ContractReportConsolidated
+	consolidationsNextBlock uint64
+	consolidationsMutex     sync.Mutex
+
+	// Subscriptions and unsubscriptions not yet processed by the oracle
+	headIndexer *HeadIndexer
+
+	// State at head, served with ?view=head. See viewOracle
+	headPreview *oracle.HeadPreview
+
+	// Registrations of the validators in the relays of the network
+	relays *RelayChecker
 }
 
 func NewApiService(
 	cfg *oracle.Config,
 	cliCfg *config.CliConfig,
-	oracle *oracle.Oracle,
+	oracleInstance *oracle.Oracle,
 	onchain *oracle.Onchain) *ApiService {
 
-	return &ApiService{
+	service := &ApiService{
 		ApiListenAddr: fmt.Sprintf("0.0.0.0:%d", cliCfg.ApiPort),
 		cfg:           cfg,
 		cliCfg:        cliCfg,
-		oracle:        oracle,
+		oracle:        oracleInstance,
 		Onchain:       onchain,
 		Network:       cfg.Network,
+		relays:        NewRelayChecker(cliCfg.RelayersOf(cfg.Network), cfg.Network),
 	}
+	if onchain != nil {
+		service.headIndexer = NewOnchainHeadIndexer(onchain, oracleInstance)
+		service.headPreview = oracle.NewOnchainHeadPreview(oracleInstance, onchain)
+	}
+	return service
 }
 
 func (m *ApiService) respondError(w http.ResponseWriter, code int, message string) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
-	resp := httpErrorResp{code, message}
+	resp := httpErrorResp{Code: code, Message: message}
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
 		log.WithField("response", resp).WithError(err).Error("Couldn't write error response")
 		http.Error(w, "", http.StatusInternalServerError)
@@ -121,6 +162,32 @@ func (m *ApiService) respondOK(w http.ResponseWriter, response any) {
 	}
 }
 
+// Views of the state served by the memory endpoints with ?view=
+const (
+	viewFinalized = "finalized"
+	viewHead      = "head"
+)
+
+// Returns the oracle of the requested view: the finalized state by default, or its preview
+// at head. Responds with an error if the view is not valid or not available
+func (m *ApiService) viewOracle(w http.ResponseWriter, req *http.Request) (*oracle.Oracle, bool) {
+	switch view := req.URL.Query().Get("view"); view {
+	case "", viewFinalized:
+		return m.oracle, true
+	case viewHead:
+		if m.headPreview != nil {
+			if preview, found := m.headPreview.Oracle(); found {
+				return preview, true
+			}
+		}
+		m.respondError(w, http.StatusServiceUnavailable, "state at head not previewed yet, try again later")
+		return nil, false
+	default:
+		m.respondError(w, http.StatusBadRequest, "invalid view: "+view+", must be finalized or head")
+		return nil, false
+	}
+}
+
 type responseWriterDelegator struct {
 	http.ResponseWriter
 	status      int
@@ -143,6 +210,13 @@ func (r *responseWriterDelegator) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Needed to stream responses, see handleEvents
+func (r *responseWriterDelegator) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 func sanitizeMethod(m string) string {
 	return strings.ToLower(m)
 }
@@ -188,27 +262,10 @@ func (m *ApiService) getRouter() http.Handler {
 	// Map endpoints and their handlers
 	r.HandleFunc("/", m.handleRoot).Methods(http.MethodGet)
 
-	// General endpoints
-	r.HandleFunc(pathStatus, m.handleStatus).Methods(http.MethodGet)
-	r.HandleFunc(pathConfig, m.handleConfig).Methods(http.MethodGet)
-	r.HandleFunc(pathValidatorRelayers, m.handleValidatorRelayers).Methods(http.MethodGet)
-	r.HandleFunc(pathState, m.handleState).Methods(http.MethodGet)
-
-	// Memory endpoints
-	r.HandleFunc(pathMemoryValidators, m.handleMemoryValidators).Methods(http.MethodGet)
-	r.HandleFunc(pathMemoryValidatorByIndex, m.handleMemoryValidatorInfo).Methods(http.MethodGet)
-	r.HandleFunc(pathMemoryValidatorsByIndex, m.handleMemoryValidatorsByIndex).Methods(http.MethodGet)
-	r.HandleFunc(pathMemoryValidatorsByWithdrawal, m.handleMemoryValidatorsByWithdrawal).Methods(http.MethodGet)
-	r.HandleFunc(pathMemoryFeesInfo, m.handleMemoryFeesInfo).Methods(http.MethodGet)
-	r.HandleFunc(pathMemoryPoolStatistics, m.handleMemoryStatistics).Methods(http.MethodGet)
-	r.HandleFunc(pathMemoryAllBlocks, m.handleMemoryAllBlocks).Methods(http.MethodGet)
-	r.HandleFunc(pathMemoryProposedBlocks, m.handleMemoryProposedBlocks).Methods(http.MethodGet)
-	r.HandleFunc(pathMemoryMissedBlocks, m.handleMemoryMissedBlocks).Methods(http.MethodGet)
-	r.HandleFunc(pathMemoryWrongFeeBlocks, m.handleMemoryWrongFeeBlocks).Methods(http.MethodGet)
-	r.HandleFunc(pathMemoryDonations, m.handleMemoryDonations).Methods(http.MethodGet)
-
-	// Onchain endpoints
-	r.HandleFunc(pathOnchainMerkleProof, m.handleOnchainMerkleProof).Methods(http.MethodGet)
+	// Api endpoints, also described in its OpenAPI specification
+	for _, route := range m.routes() {
+		r.HandleFunc(route.path, route.handler).Methods(http.MethodGet)
+	}
 
 	// Not strictly necessary but good to have
 	r.Use(mux.CORSMethodMiddleware(r))
@@ -222,6 +279,12 @@ func (m *ApiService) StartHTTPServer() {
 	if m.srv != nil {
 		log.Fatal("HTTP server already started")
 	}
+	if m.headIndexer != nil {
+		go m.headIndexer.Run(context.Background())
+	}
+	if m.headPreview != nil {
+		go m.headPreview.Run(context.Background())
+	}
 
 	m.srv = &http.Server{
 		Addr: m.ApiListenAddr,
@@ -282,7 +345,14 @@ func (m *ApiService) handleMemoryStatistics(w http.ResponseWriter, req *http.Req
 	totalAccumulatedRewards := big.NewInt(0)
 	totalPendingRewards := big.NewInt(0)
 
-	for _, validator := range m.oracle.State().Validators {
+	viewed, ok := m.viewOracle(w, req)
+	if !ok {
+		return
+	}
+
+	// All the statistics are of the same slot, even if the oracle processes another one meanwhile
+	state := viewed.Snapshot()
+	for _, validator := range state.Validators {
 		if validator.ValidatorStatus == oracle.Active {
 			totalActive++
 		} else if validator.ValidatorStatus == oracle.YellowCard {
@@ -305,19 +375,19 @@ func (m *ApiService) handleMemoryStatistics(w http.ResponseWriter, req *http.Req
 	totalDonationsWei := big.NewInt(0)
 
 	// Prevent underflow
-	if uint64(m.oracle.State().LatestProcessedSlot) < SlotsInOneMonth {
+	if uint64(state.LatestProcessedSlot) < SlotsInOneMonth {
 		m.respondError(w, http.StatusInternalServerError, "head slot is lower than slots in a month, this should not happen")
 		return
 	}
 
-	if uint64(m.oracle.State().LatestProcessedBlock) < SlotsInOneMonth {
+	if uint64(state.LatestProcessedBlock) < SlotsInOneMonth {
 		m.respondError(w, http.StatusInternalServerError, "head block is lower than slots in a month, this should not happen")
 		return
 	}
 
 	// Only consider blocks in the last 30 days
-	limitSlot := uint64(m.oracle.State().LatestProcessedSlot) - SlotsInOneMonth
-	limitBlock := uint64(m.oracle.State().LatestProcessedBlock) - SlotsInOneMonth
+	limitSlot := uint64(state.LatestProcessedSlot) - SlotsInOneMonth
+	limitBlock := uint64(state.LatestProcessedBlock) - SlotsInOneMonth
 
 	// Note that in a month we have SlotsInOneMonth slots, but not exactly that amount of blocks. If blocks
 	// are missed we can have less. If blocks are missed we will take into account a time window
@@ -325,7 +395,7 @@ func (m *ApiService) handleMemoryStatistics(w http.ResponseWriter, req *http.Req
 
 	totalOkPoolProposalBlocks := uint64(0)
 
-	for _, block := range m.oracle.State().ProposedBlocks {
+	for _, block := range state.ProposedBlocks {
 		// only consider ok pool proposals, since these are the only type of blocks that are shared
 		// across all validators
 		if block.BlockType == oracle.OkPoolProposal {
@@ -339,7 +409,7 @@ func (m *ApiService) handleMemoryStatistics(w http.ResponseWriter, req *http.Req
 		}
 	}
 
-	for _, donation := range m.oracle.State().Donations {
+	for _, donation := range state.Donations {
 		totalDonationsWei.Add(totalDonationsWei, donation.DonationAmount)
 
 		// Note that rewards also take donations into account
@@ -357,7 +427,7 @@ func (m *ApiService) handleMemoryStatistics(w http.ResponseWriter, req *http.Req
 		rewardsPerValidatorPer30Days.Div(totalRewardsSent30DaysWei, big.NewInt(0).SetUint64(totalValidatorsEarning))
 	}
 
-	totalProposedBlocks := uint64(len(m.oracle.State().ProposedBlocks))
+	totalProposedBlocks := uint64(len(state.ProposedBlocks))
 	avgBlockRewardWei := big.NewInt(0)
 
 	// Avoid division by zero
@@ -369,7 +439,7 @@ func (m *ApiService) handleMemoryStatistics(w http.ResponseWriter, req *http.Req
 	totalEffectiveBalance := uint64(0)
 	effectiveBalances := m.Onchain.Validators()
 
-	for _, validator := range m.oracle.State().Validators {
+	for _, validator := range state.Validators {
 		if validator.ValidatorStatus == oracle.Active ||
 			validator.ValidatorStatus == oracle.YellowCard ||
 			validator.ValidatorStatus == oracle.RedCard {
@@ -395,8 +465,8 @@ func (m *ApiService) handleMemoryStatistics(w http.ResponseWriter, req *http.Req
 		TotalRedCard:                 totalRedCard,
 		TotalBanned:                  totalBanned,
 		TotalNotSubscribed:           totalNotSubscribed,
-		LatestCheckpointSlot:         m.oracle.State().LatestProcessedSlot,
-		NextCheckpointSlot:           m.oracle.State().LatestProcessedSlot + m.cfg.CheckPointSizeInSlots,
+		LatestCheckpointSlot:         state.LatestProcessedSlot,
+		NextCheckpointSlot:           state.LatestProcessedSlot + state.CheckPointSizeInSlots,
 		TotalAccumulatedRewardsWei:   totalAccumulatedRewards.String(),
 		TotalPendingRewaradsWei:      totalPendingRewards.String(),
 		TotalRewardsSentWei:          totalRewardsSentWei.String(),
@@ -405,8 +475,8 @@ func (m *ApiService) handleMemoryStatistics(w http.ResponseWriter, req *http.Req
 		TotalRewardsSent30DaysWei:    totalRewardsSent30DaysWei.String(),
 		RewardsPerValidatorPer30Days: rewardsPerValidatorPer30Days.String(),
 		TotalProposedBlocks:          totalProposedBlocks,
-		TotalMissedBlocks:            uint64(len(m.oracle.State().MissedBlocks)),
-		TotalWrongFeeBlocks:          uint64(len(m.oracle.State().WrongFeeBlocks)),
+		TotalMissedBlocks:            uint64(len(state.MissedBlocks)),
+		TotalWrongFeeBlocks:          uint64(len(state.WrongFeeBlocks)),
 		TotalEffectiveBalanceGwei:    fmt.Sprintf("%d", totalEffectiveBalance),
 		AvgEffectiveBalanceGwei:      fmt.Sprintf("%d", avgEffectiveBalance),
 	})
@@ -462,8 +532,9 @@ func (m *ApiService) handleStatus(w http.ResponseWriter, req *http.Request) {
 
 	finalizedSlot := uint64(finality.Header.Message.Slot)
 
+	state := m.oracle.Snapshot()
 	oracleSync := false
-	if m.oracle.State().LatestProcessedSlot-finalizedSlot == 0 {
+	if state.LatestProcessedSlot-finalizedSlot == 0 {
 		oracleSync = true
 	}
 
@@ -476,20 +547,20 @@ func (m *ApiService) handleStatus(w http.ResponseWriter, req *http.Request) {
 	// If the oracle is not in sync, we cant really calculate the slots till the next checkpoint
 	// because we are behind. So we just set it to 0
 	nextCheckpointInSlots := uint64(0)
-	if finalizedSlot < (onchainSlot + m.cfg.CheckPointSizeInSlots) {
-		nextCheckpointInSlots = onchainSlot + m.cfg.CheckPointSizeInSlots - finalizedSlot
+	if finalizedSlot < (onchainSlot + state.CheckPointSizeInSlots) {
+		nextCheckpointInSlots = onchainSlot + state.CheckPointSizeInSlots - finalizedSlot
 	}
 
 	status := httpOkStatus{
 		IsConsensusInSync:           consInSync,
 		IsExecutionInSync:           execInSync,
 		IsOracleInSync:              oracleSync,
-		LatestProcessedSlot:         m.oracle.State().LatestProcessedSlot,
-		LatestProcessedBlock:        m.oracle.State().LatestProcessedBlock,
+		LatestProcessedSlot:         state.LatestProcessedSlot,
+		LatestProcessedBlock:        state.LatestProcessedBlock,
 		LatestFinalizedEpoch:        finalizedSlot / 32,
 		LatestFinalizedSlot:         finalizedSlot,
-		OracleHeadDistance:          finalizedSlot - m.oracle.State().LatestProcessedSlot,
-		NextCheckpointSlot:          onchainSlot + m.cfg.CheckPointSizeInSlots,
+		OracleHeadDistance:          finalizedSlot - state.LatestProcessedSlot,
+		NextCheckpointSlot:          onchainSlot + state.CheckPointSizeInSlots,
 		NextCheckpointTime:          "", // TODO:
 		NextCheckpointRemaining:     utils.SlotsToTime(nextCheckpointInSlots, constants.SecondsInSlot),
 		NextCheckpointRemainingUnix: nextCheckpointInSlots * constants.SecondsInSlot,
@@ -510,15 +581,17 @@ func (m *ApiService) handleConfig(w http.ResponseWriter, req *http.Request) {
 		m.respondError(w, http.StatusInternalServerError, "no config loaded, nil value")
 		return
 	}
+	current := m.governanceState()
 	m.respondOK(w, httpOkConfig{
 		Network:                  m.cfg.Network,
 		PoolAddress:              m.cfg.PoolAddress,
 		DeployedSlot:             m.cfg.DeployedSlot,
-		CheckPointSizeInSlots:    m.cfg.CheckPointSizeInSlots,
-		PoolFeesPercentOver10000: m.cfg.PoolFeesPercentOver10000,
-		PoolFeesAddress:          m.cfg.PoolFeesAddress,
+		CheckPointSizeInSlots:    current.CheckPointSizeInSlots,
+		PoolFeesPercentOver10000: current.PoolFeesPercentOver10000,
+		PoolFeesAddress:          current.PoolFeesAddress,
 		DryRun:                   m.cfg.DryRun,
-		CollateralInWei:          m.cfg.CollateralInWei.String(),
+		CollateralInWei:          current.CollateralInWei.String(),
+		RequireReconciliation:    m.cfg.RequireReconciliation,
 	})
 }
 
@@ -527,7 +600,11 @@ func (m *ApiService) handleMemoryValidators(w http.ResponseWriter, req *http.Req
 		m.respondError(w, http.StatusServiceUnavailable, "Oracle node is currently syncing and not serving requests")
 		return
 	}
-	validators := maps.Values(m.oracle.State().Validators)
+	viewed, ok := m.viewOracle(w, req)
+	if !ok {
+		return
+	}
+	validators := maps.Values(viewed.Snapshot().Validators)
 
 	// Order by index
 	sort.Slice(validators, func(i, j int) bool { return validators[i].ValidatorIndex < validators[j].ValidatorIndex })
@@ -572,7 +649,11 @@ func (m *ApiService) handleMemoryValidatorInfo(w http.ResponseWriter, req *http.
 		return
 	}
 
-	validator, found := m.oracle.State().Validators[valIndex]
+	viewed, ok := m.viewOracle(w, req)
+	if !ok {
+		return
+	}
+	validator, found := viewed.Snapshot().Validators[valIndex]
 	if !found {
 		m.respondError(w, http.StatusBadRequest, fmt.Sprint("could not find validator with index: ", valIndex))
 		return
@@ -586,6 +667,52 @@ func (m *ApiService) handleMemoryValidatorInfo(w http.ResponseWriter, req *http.
 	m.respondOK(w, validator)
 }
 
+// Returns the status transitions of the validator with what caused them, its blocks and
+// its rewards at each checkpoint, to explain how it got to its current status
+func (m *ApiService) handleMemoryValidatorHistory(w http.ResponseWriter, req *http.Request) {
+	valIndexStr := mux.Vars(req)["valindex"]
+	valIndex, ok := IsValidIndex(valIndexStr)
+	if !ok {
+		m.respondError(w, http.StatusBadRequest, "invalid validator index: "+valIndexStr)
+		return
+	}
+
+	history, found := m.oracle.ValidatorHistory(valIndex)
+	if !found {
+		m.respondError(w, http.StatusNotFound, fmt.Sprint("could not find validator with index: ", valIndex))
+		return
+	}
+
+	response := httpOkValidatorHistory{
+		ValidatorIndex: valIndex,
+		Transitions:    make([]httpOkStateTransition, 0, len(history.Transitions)),
+		Blocks:         make([]httpOkBlock, 0, len(history.Blocks)),
+		Rewards:        make([]httpOkCheckpointRewards, 0, len(history.Rewards)),
+	}
+	for _, transition := range history.Transitions {
+		response.Transitions = append(response.Transitions, httpOkStateTransition{
+			Slot:   transition.Slot,
+			Event:  transition.Event.String(),
+			From:   transition.From.String(),
+			To:     transition.To.String(),
+			Block:  transition.Block,
+			TxHash: transition.TxHash,
+			Reason: transition.Reason,
+		})
+	}
+	for _, block := range history.Blocks {
+		response.Blocks = append(response.Blocks, toHttpBlock(block))
+	}
+	for _, rewards := range history.Rewards {
+		response.Rewards = append(response.Rewards, httpOkCheckpointRewards{
+			Slot:                  rewards.Slot,
+			AccumulatedRewardsWei: rewards.AccumulatedRewardsWei.String(),
+			PendingRewardsWei:     rewards.PendingRewardsWei.String(),
+		})
+	}
+	m.respondOK(w, response)
+}
+
 func (m *ApiService) handleMemoryValidatorsByIndex(w http.ResponseWriter, req *http.Request) {
 	vars := mux.Vars(req)
 	valIndicesStr := vars["valindices"]
@@ -614,13 +741,20 @@ func (m *ApiService) handleMemoryValidatorsByIndex(w http.ResponseWriter, req *h
 		indices = append(indices, index)
 	}
 
+	viewed, ok := m.viewOracle(w, req)
+	if !ok {
+		return
+	}
+
 	// Initialize both response slices
 	var foundValidators []httpOkValidatorInfo
 	var notFoundValidators []uint64
 
+	state := viewed.Snapshot()
+
 	// Check if each validator is in the oracle state. Append to foundValidators or notFoundValidators
 	for _, index := range indices {
-		if validator, found := m.oracle.State().Validators[index]; found {
+		if validator, found := state.Validators[index]; found {
 			// Convert ValidatorInfo to httpOkValidatorInfo. This is done to return strings instead of bigInts
 			beaconState, found := m.Onchain.Validators()[phase0.ValidatorIndex(validator.ValidatorIndex)]
 			if !found {
@@ -676,6 +810,24 @@ func (m *ApiService) handleMemoryValidatorsByWithdrawal(w http.ResponseWriter, r
 		return
 	}
 
+	viewed, ok := m.viewOracle(w, req)
+	if !ok {
+		return
+	}
+
+	state := viewed.Snapshot()
+
+	// The address can be a withdrawal address or the reward recipient of some of them
+	withdrawalAddresses := append([]string{withdrawalAddress}, oracle.WithdrawalAddressesOfRecipient(state, withdrawalAddress)...)
+	isRequested := func(address string) bool {
+		for _, requested := range withdrawalAddresses {
+			if AreAddressEqual(address, requested) {
+				return true
+			}
+		}
+		return false
+	}
+
 	// We return
 	// 1) validators using this withdrawal address but not tracked by the oracle
 	// 2) validators using this withdrawal address and tracked by the oracle (eg already subscribed)
@@ -694,7 +846,7 @@ func (m *ApiService) handleMemoryValidatorsByWithdrawal(w http.ResponseWriter, r
 		}
 
 		// Skip if the address does not match with the requested
-		if !AreAddressEqual(eth1Add, withdrawalAddress) {
+		if !isRequested(eth1Add) {
 			continue
 		}
 
@@ -703,8 +855,14 @@ func (m *ApiService) handleMemoryValidatorsByWithdrawal(w http.ResponseWriter, r
 		//	continue
 		//}
 
+		// Validators that proposed with BLS credentials are subscribed once the oracle sees the rotation
+		status := oracle.Untracked
+		if _, isBlsProposer := viewed.BlsProposer(uint64(valIndex)); isBlsProposer {
+			status = oracle.BlsProposer
+		}
+
 		requestedValidators[uint64(valIndex)] = &oracle.ValidatorInfo{
-			ValidatorStatus:       oracle.Untracked,
+			ValidatorStatus:       status,
 			AccumulatedRewardsWei: big.NewInt(0),
 			PendingRewardsWei:     big.NewInt(0),
 			CollateralWei:         big.NewInt(0),
@@ -715,13 +873,11 @@ func (m *ApiService) handleMemoryValidatorsByWithdrawal(w http.ResponseWriter, r
 	}
 
 	// 2) Get all tracked validators for that withdrawal address (tracked)
-	validatorsCopy := make(map[uint64]*oracle.ValidatorInfo)
-
 	// Imporant! This is a deep copy, otherwise we will modify the state
-	utils.DeepCopy(m.oracle.State().Validators, &validatorsCopy)
+	validatorsCopy := oracle.CopyValidators(state.Validators)
 	for valIndex, validator := range validatorsCopy {
 		// Just overwrite the untracked validators with oracle state
-		if AreAddressEqual(validator.WithdrawalAddress, withdrawalAddress) {
+		if isRequested(validator.WithdrawalAddress) {
 			requestedValidators[valIndex] = validator
 
 			// TODO: Temporal, remove in production.
@@ -743,38 +899,33 @@ func (m *ApiService) handleMemoryValidatorsByWithdrawal(w http.ResponseWriter, r
 	// oracle runs all calculations on finalized states, but the api must report to the
 	// users without this 15 minutes-ish delay.
 	// This applies a non-finalized state to the validators, creating a virtual state
-	// only used for the api.
-
-	if m.oracle.State().LatestProcessedBlock == 0 {
-		m.respondError(w, http.StatusInternalServerError, "latest processed block is 0, try again later")
-		return
-	}
+	// only used for the api. The head view already processed them with the oracle rules.
+	if viewed == m.oracle {
+		if state.LatestProcessedBlock == 0 {
+			m.respondError(w, http.StatusInternalServerError, "latest processed block is 0, try again later")
+			return
+		}
 
-	firstNotProcessedBlock := m.oracle.State().LatestProcessedBlock + 1
+		// Events above the processed block, kept in memory by the head indexer
+		allSubsTillHead, allUnsubsTillHead, indexed := m.nonFinalizedEvents(state.LatestProcessedBlock)
+		if !indexed {
+			m.respondError(w, http.StatusServiceUnavailable, "non finalized events not indexed yet, try again later")
+			return
+		}
 
-	// TODO: Cache this, very inneficient to get it every time
-	allSubsTillHead, err := m.GetSubscriptionsTillHead(firstNotProcessedBlock)
-	if err != nil {
-		m.respondError(w, http.StatusInternalServerError, "could not get subscriptions: "+err.Error())
-		return
-	}
-	allUnsubsTillHead, err := m.GetUnsubscriptionsTillHead(firstNotProcessedBlock)
-	if err != nil {
-		m.respondError(w, http.StatusInternalServerError, "could not get unsubscriptions: "+err.Error())
-		return
+		// Apply latest seen events to the existing state. This is a "virtual" state, just for the api
+		// so that users are aware of the latest events, without waiting for the next finalized state.
+		m.ApplyNonFinalizedState(
+			allSubsTillHead,
+			allUnsubsTillHead,
+			requestedValidators)
 	}
 
-	// Apply latest seen events to the existing state. This is a "virtual" state, just for the api
-	// so that users are aware of the latest events, without waiting for the next finalized state.
-	m.ApplyNonFinalizedState(
-		allSubsTillHead,
-		allUnsubsTillHead,
-		requestedValidators)
-
 	// Sort by index
 	values := maps.Values(requestedValidators)
 	sort.Slice(values, func(i, j int) bool { return values[i].ValidatorIndex < values[j].ValidatorIndex })
 
+	rewardRecipients := state.RewardRecipients
 	validatorsResp := make([]httpOkValidatorInfo, 0)
 	for _, v := range values {
 		beaconState, found := m.Onchain.Validators()[phase0.ValidatorIndex(v.ValidatorIndex)]
@@ -794,197 +945,327 @@ func (m *ApiService) handleMemoryValidatorsByWithdrawal(w http.ResponseWriter, r
 			ValidatorIndex:                      v.ValidatorIndex,
 			ValidatorKey:                        v.ValidatorKey,
 			SubscriptionType:                    v.SubscriptionType.String(),
+			RewardRecipient:                     rewardRecipients[strings.ToLower(v.WithdrawalAddress)],
 		})
 	}
 	m.respondOK(w, validatorsResp)
 }
 
 func (m *ApiService) handleMemoryFeesInfo(w http.ResponseWriter, req *http.Request) {
+	viewed, ok := m.viewOracle(w, req)
+	if !ok {
+		return
+	}
+	state := viewed.Snapshot()
 	m.respondOK(w, httpOkMemoryFeesInfo{
-		PoolFeesPercentOver10000: m.oracle.State().PoolFeesPercentOver10000,
-		PoolFeesAddress:          m.oracle.State().PoolFeesAddress,
-		PoolAccumulatedFees:      m.oracle.State().PoolAccumulatedFees.String(),
+		PoolFeesPercentOver10000: state.PoolFeesPercentOver10000,
+		PoolFeesAddress:          state.PoolFeesAddress,
+		PoolAccumulatedFees:      state.PoolAccumulatedFees.String(),
 	})
 }
 
 func (m *ApiService) handleMemoryAllBlocks(w http.ResponseWriter, req *http.Request) {
-	// Concat all the blocks, order is not guaranteed
-	allBlocks := make([]httpOkBlock, 0)
-
-	for _, block := range m.oracle.State().ProposedBlocks {
-		allBlocks = append(allBlocks, httpOkBlock{
-			Slot:              block.Slot,
-			Block:             block.Block,
-			ValidatorIndex:    block.ValidatorIndex,
-			ValidatorKey:      block.ValidatorKey,
-			BlockType:         block.BlockType.String(),
-			Reward:            block.Reward.String(),
-			RewardType:        block.RewardType.String(),
-			WithdrawalAddress: block.WithdrawalAddress,
-		})
-	}
+	m.respondBlocks(w, req, oracle.AllBlockLists)
+}
 
-	for _, block := range m.oracle.State().MissedBlocks {
-		allBlocks = append(allBlocks, httpOkBlock{
-			Slot:              block.Slot,
-			Block:             block.Block,
-			ValidatorIndex:    block.ValidatorIndex,
-			ValidatorKey:      block.ValidatorKey,
-			BlockType:         block.BlockType.String(),
-			Reward:            block.Reward.String(),
-			RewardType:        block.RewardType.String(),
-			WithdrawalAddress: block.WithdrawalAddress,
-		})
-	}
+func (m *ApiService) handleMemoryProposedBlocks(w http.ResponseWriter, req *http.Request) {
+	m.respondBlocks(w, req, []oracle.BlockList{oracle.ProposedBlockList})
+}
 
-	for _, block := range m.oracle.State().WrongFeeBlocks {
-		allBlocks = append(allBlocks, httpOkBlock{
-			Slot:              block.Slot,
-			Block:             block.Block,
-			ValidatorIndex:    block.ValidatorIndex,
-			ValidatorKey:      block.ValidatorKey,
-			BlockType:         block.BlockType.String(),
-			Reward:            block.Reward.String(),
-			RewardType:        block.RewardType.String(),
-			WithdrawalAddress: block.WithdrawalAddress,
-		})
-	}
+func (m *ApiService) handleMemoryMissedBlocks(w http.ResponseWriter, req *http.Request) {
+	m.respondBlocks(w, req, []oracle.BlockList{oracle.MissedBlockList})
+}
 
-	m.respondOK(w, allBlocks)
+func (m *ApiService) handleMemoryWrongFeeBlocks(w http.ResponseWriter, req *http.Request) {
+	m.respondBlocks(w, req, []oracle.BlockList{oracle.WrongFeeBlockList})
 }
 
-func (m *ApiService) handleMemoryProposedBlocks(w http.ResponseWriter, req *http.Request) {
-	proposedBlocks := make([]httpOkBlock, 0)
-	for _, block := range m.oracle.State().ProposedBlocks {
-		proposedBlocks = append(proposedBlocks, httpOkBlock{
-			Slot:              block.Slot,
-			Block:             block.Block,
-			ValidatorIndex:    block.ValidatorIndex,
-			ValidatorKey:      block.ValidatorKey,
-			BlockType:         block.BlockType.String(),
-			Reward:            block.Reward.String(),
-			RewardType:        block.RewardType.String(),
-			WithdrawalAddress: block.WithdrawalAddress,
-		})
+// Responds with a page of the blocks of the given lists matching the query filters
+func (m *ApiService) respondBlocks(w http.ResponseWriter, req *http.Request, lists []oracle.BlockList) {
+	query := req.URL.Query()
+	filter, err := parseBlockFilter(query)
+	if err != nil {
+		m.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, err := parsePageLimit(query)
+	if err != nil {
+		m.respondError(w, http.StatusBadRequest, err.Error())
+		return
 	}
-	m.respondOK(w, proposedBlocks)
-}
 
-func (m *ApiService) handleMemoryMissedBlocks(w http.ResponseWriter, req *http.Request) {
-	missedBlocks := make([]httpOkBlock, 0)
-	for _, block := range m.oracle.State().MissedBlocks {
-		missedBlocks = append(missedBlocks, httpOkBlock{
-			Slot:              block.Slot,
-			Block:             block.Block,
-			ValidatorIndex:    block.ValidatorIndex,
-			ValidatorKey:      block.ValidatorKey,
-			BlockType:         block.BlockType.String(),
-			Reward:            block.Reward.String(),
-			RewardType:        block.RewardType.String(),
-			WithdrawalAddress: block.WithdrawalAddress,
-		})
+	blocks, nextCursor, err := m.oracle.QueryBlocks(m.oracle.Snapshot(), lists, filter, query.Get("cursor"), limit)
+	if err != nil {
+		m.respondError(w, http.StatusBadRequest, "could not query blocks: "+err.Error())
+		return
+	}
+
+	page := client.Page[httpOkBlock]{Items: make([]httpOkBlock, 0, len(blocks)), NextCursor: nextCursor}
+	for _, block := range blocks {
+		page.Items = append(page.Items, toHttpBlock(block))
 	}
-	m.respondOK(w, missedBlocks)
+	m.respondOK(w, page)
 }
 
-func (m *ApiService) handleMemoryWrongFeeBlocks(w http.ResponseWriter, req *http.Request) {
-	wrongFeeBlocks := make([]httpOkBlock, 0)
-	for _, block := range m.oracle.State().WrongFeeBlocks {
-		wrongFeeBlocks = append(wrongFeeBlocks, httpOkBlock{
-			Slot:              block.Slot,
-			Block:             block.Block,
-			ValidatorIndex:    block.ValidatorIndex,
-			ValidatorKey:      block.ValidatorKey,
-			BlockType:         block.BlockType.String(),
-			Reward:            block.Reward.String(),
-			RewardType:        block.RewardType.String(),
-			WithdrawalAddress: block.WithdrawalAddress,
-		})
+func toHttpBlock(block oracle.SummarizedBlock) httpOkBlock {
+	return httpOkBlock{
+		Slot:              block.Slot,
+		Block:             block.Block,
+		ValidatorIndex:    block.ValidatorIndex,
+		ValidatorKey:      block.ValidatorKey,
+		BlockType:         block.BlockType.String(),
+		Reward:            block.Reward.String(),
+		RewardType:        block.RewardType.String(),
+		WithdrawalAddress: block.WithdrawalAddress,
 	}
-	m.respondOK(w, wrongFeeBlocks)
 }
 
 func (m *ApiService) handleMemoryDonations(w http.ResponseWriter, req *http.Request) {
-	donations := make([]httpOkDonation, 0)
-	for _, donation := range m.oracle.State().Donations {
-		donations = append(donations, httpOkDonation{
+	query := req.URL.Query()
+	filter := oracle.DonationFilter{}
+	var err error
+
+	if fromBlock := query.Get("from_block"); fromBlock != "" {
+		filter.FromBlock, err = strconv.ParseUint(fromBlock, 10, 64)
+		if err != nil {
+			m.respondError(w, http.StatusBadRequest, "invalid from_block: "+fromBlock)
+			return
+		}
+	}
+	if toBlock := query.Get("to_block"); toBlock != "" {
+		block, err := strconv.ParseUint(toBlock, 10, 64)
+		if err != nil {
+			m.respondError(w, http.StatusBadRequest, "invalid to_block: "+toBlock)
+			return
+		}
+		filter.ToBlock = &block
+	}
+	if sender := query.Get("sender"); sender != "" {
+		if !IsValidAddress(sender) {
+			m.respondError(w, http.StatusBadRequest, "invalid sender: "+sender)
+			return
+		}
+		filter.Sender = sender
+	}
+	if minAmount := query.Get("min_amount_wei"); minAmount != "" {
+		amount, ok := new(big.Int).SetString(minAmount, 10)
+		if !ok {
+			m.respondError(w, http.StatusBadRequest, "invalid min_amount_wei: "+minAmount)
+			return
+		}
+		filter.MinAmountWei = amount
+	}
+	limit, err := parsePageLimit(query)
+	if err != nil {
+		m.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	donations, nextCursor, err := m.oracle.QueryDonations(m.oracle.Snapshot(), filter, query.Get("cursor"), limit)
+	if err != nil {
+		m.respondError(w, http.StatusBadRequest, "could not query donations: "+err.Error())
+		return
+	}
+
+	page := client.Page[httpOkDonation]{Items: make([]httpOkDonation, 0, len(donations)), NextCursor: nextCursor}
+	for _, donation := range donations {
+		page.Items = append(page.Items, httpOkDonation{
 			AmountWei: donation.DonationAmount.String(),
 			Block:     donation.Raw.BlockNumber,
 			TxHash:    donation.Raw.TxHash.String(),
 			Sender:    donation.Sender.String(),
 		})
 	}
-	m.respondOK(w, donations)
+	m.respondOK(w, page)
 }
 
-func (m *ApiService) handleOnchainMerkleProof(w http.ResponseWriter, req *http.Request) {
-	if !m.OracleReady(MaxSlotsBehind) {
-		m.respondError(w, http.StatusServiceUnavailable, "Oracle node is currently syncing and not serving requests")
-		return
+// Returns the amount of results per page, DefaultPageLimit if not given
+func parsePageLimit(query url.Values) (int, error) {
+	limitStr := query.Get("limit")
+	if limitStr == "" {
+		return DefaultPageLimit, nil
+	}
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit <= 0 || limit > MaxPageLimit {
+		return 0, errors.New(fmt.Sprintf("invalid limit, must be between 1 and %d: %s", MaxPageLimit, limitStr))
 	}
+	return limit, nil
+}
 
-	vars := mux.Vars(req)
-	withdrawalAddress := vars["withdrawalAddress"]
+func parseBlockFilter(query url.Values) (oracle.BlockFilter, error) {
+	filter := oracle.BlockFilter{}
 
-	if !IsValidAddress(withdrawalAddress) {
-		m.respondError(w, http.StatusBadRequest, "invalid WithdrawalAddress: "+withdrawalAddress)
-		return
+	if fromSlot := query.Get("from_slot"); fromSlot != "" {
+		slot, err := strconv.ParseUint(fromSlot, 10, 64)
+		if err != nil {
+			return filter, errors.New("invalid from_slot: " + fromSlot)
+		}
+		filter.FromSlot = slot
+	}
+	if toSlot := query.Get("to_slot"); toSlot != "" {
+		slot, err := strconv.ParseUint(toSlot, 10, 64)
+		if err != nil {
+			return filter, errors.New("invalid to_slot: " + toSlot)
+		}
+		filter.ToSlot = &slot
+	}
+	if valIndex := query.Get("validator_index"); valIndex != "" {
+		index, err := strconv.ParseUint(valIndex, 10, 64)
+		if err != nil {
+			return filter, errors.New("invalid validator_index: " + valIndex)
+		}
+		filter.ValidatorIndex = &index
 	}
+	if withdrawalAddress := query.Get("withdrawal_address"); withdrawalAddress != "" {
+		if !IsValidAddress(withdrawalAddress) {
+			return filter, errors.New("invalid withdrawal_address: " + withdrawalAddress)
+		}
+		filter.WithdrawalAddress = withdrawalAddress
+	}
+	// Same names as in the responses
+	if blockTypeStr := query.Get("block_type"); blockTypeStr != "" {
+		var blockType oracle.BlockType
+		if blockType.UnmarshalJSON([]byte(strconv.Quote(blockTypeStr))) != nil {
+			return filter, errors.New("invalid block_type: " + blockTypeStr)
+		}
+		filter.BlockType = &blockType
+	}
+	if rewardTypeStr := query.Get("reward_type"); rewardTypeStr != "" {
+		var rewardType oracle.RewardType
+		if rewardType.UnmarshalJSON([]byte(strconv.Quote(rewardTypeStr))) != nil {
+			return filter, errors.New("invalid reward_type: " + rewardTypeStr)
+		}
+		filter.RewardType = &rewardType
+	}
+	if minReward := query.Get("min_reward_wei"); minReward != "" {
+		reward, ok := new(big.Int).SetString(minReward, 10)
+		if !ok {
+			return filter, errors.New("invalid min_reward_wei: " + minReward)
+		}
+		filter.MinRewardWei = reward
+	}
+	return filter, nil
+}
 
-	// Use always lowercase
-	withdrawalAddress = strings.ToLower(withdrawalAddress)
+// Replays the pool history with a different fee, collateral or reward method and returns
+// how the rewards of each withdrawal address and the pool fees would have changed. Its
+// expensive, since the whole history is replayed twice, so results are reused until a new
+// slot is processed and requests are rejected while another simulation is running.
+func (m *ApiService) handleMemorySimulate(w http.ResponseWriter, req *http.Request) {
+	params := oracle.SimulationParams{}
+	query := req.URL.Query()
 
-	contractRoot, contractSlot, err := m.Onchain.GetOnchainSlotAndRoot(apiRetryOpts...)
+	if fee := query.Get("pool_fees_percent"); fee != "" {
+		poolFeesPercent, err := strconv.Atoi(fee)
+		if err != nil {
+			m.respondError(w, http.StatusBadRequest, "invalid pool_fees_percent: "+fee)
+			return
+		}
+		params.PoolFeesPercentOver10000 = &poolFeesPercent
+	}
+
+	if collateral := query.Get("collateral_in_wei"); collateral != "" {
+		collateralInWei, ok := new(big.Int).SetString(collateral, 10)
+		if !ok {
+			m.respondError(w, http.StatusBadRequest, "invalid collateral_in_wei: "+collateral)
+			return
+		}
+		params.CollateralInWei = collateralInWei
+	}
+
+	params.RewardMethod = query.Get("reward_method")
+
+	result, err := m.oracle.Simulate(params)
+	if err == oracle.ErrSimulationRunning {
+		m.respondError(w, http.StatusTooManyRequests, err.Error())
+		return
+	}
+	if errors.Cause(err) == oracle.ErrInvalidSimulationParams {
+		m.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	if err != nil {
-		m.respondError(w, http.StatusInternalServerError, "could not get onchain slot and root: "+err.Error())
+		m.respondError(w, http.StatusInternalServerError, "could not run simulation: "+err.Error())
 		return
 	}
 
-	_, found := m.oracle.State().CommitedStates[contractSlot]
-	if !found {
-		m.respondError(w, http.StatusInternalServerError, "could not find onchain slot in oracle state: "+strconv.FormatUint(contractSlot, 10))
-		return
+	addresses := make([]httpOkSimulatedAddress, 0, len(result.Addresses))
+	for _, address := range result.Addresses {
+		addresses = append(addresses, httpOkSimulatedAddress{
+			WithdrawalAddress:       address.WithdrawalAddress,
+			BaselineAccumulatedWei:  address.BaselineAccumulatedWei.String(),
+			SimulatedAccumulatedWei: address.SimulatedAccumulatedWei.String(),
+			DeltaAccumulatedWei:     address.DeltaAccumulatedWei.String(),
+			BaselinePendingWei:      address.BaselinePendingWei.String(),
+			SimulatedPendingWei:     address.SimulatedPendingWei.String(),
+			DeltaPendingWei:         address.DeltaPendingWei.String(),
+		})
 	}
 
-	// Check if the oracle root matches the one offchain
-	if contractRoot != m.oracle.State().CommitedStates[contractSlot].MerkleRoot {
-		m.respondError(w, http.StatusInternalServerError,
-			"contract merkle root does not match oracle state: "+
-				contractRoot+" vs "+m.oracle.State().CommitedStates[contractSlot].MerkleRoot)
+	m.respondOK(w, httpOkSimulation{
+		PoolFeesPercentOver10000: params.PoolFeesPercentOver10000,
+		CollateralInWei:          query.Get("collateral_in_wei"),
+		RewardMethod:             params.RewardMethod,
+		FromSlot:                 result.FromSlot,
+		ToSlot:                   result.ToSlot,
+		ReplayedSlots:            result.ReplayedSlots,
+		RealPoolFeesWei:          result.RealPoolFeesWei.String(),
+		BaselinePoolFeesWei:      result.BaselinePoolFeesWei.String(),
+		SimulatedPoolFeesWei:     result.SimulatedPoolFeesWei.String(),
+		DeltaPoolFeesWei:         result.DeltaPoolFeesWei.String(),
+		Addresses:                addresses,
+	})
+}
+
+func (m *ApiService) handleOnchainMerkleProof(w http.ResponseWriter, req *http.Request) {
+	if !m.OracleReady(MaxSlotsBehind) {
+		m.respondError(w, http.StatusServiceUnavailable, "Oracle node is currently syncing and not serving requests")
 		return
 	}
 
-	// Get the proofs of this withdrawal address (to be used onchain to claim rewards)
-	proofs, proofFound := m.oracle.State().CommitedStates[contractSlot].Proofs[withdrawalAddress]
-	if !proofFound {
-		m.respondError(w, http.StatusBadRequest, "could not find proof for WithdrawalAddress: "+withdrawalAddress)
-		return
+	// Proofs of past checkpoints can be requested, otherwise the one onchain is used
+	var atSlot *uint64
+	if slotStr := req.URL.Query().Get("slot"); slotStr != "" {
+		slot, err := strconv.ParseUint(slotStr, 10, 64)
+		if err != nil {
+			m.respondError(w, http.StatusBadRequest, "invalid slot: "+slotStr)
+			return
+		}
+		atSlot = &slot
 	}
 
-	// Get the leafs of this withdrawal address (to be used onchain to claim rewards)
-	leafs, leafsFound := m.oracle.State().CommitedStates[contractSlot].Leafs[withdrawalAddress]
-	if !leafsFound {
-		m.respondError(w, http.StatusBadRequest, "could not find leafs for WithdrawalAddress: "+withdrawalAddress)
+	vars := mux.Vars(req)
+	proof, code, err := m.getOnchainProof(vars["withdrawalAddress"], atSlot)
+	if err != nil {
+		m.respondError(w, code, err.Error())
 		return
 	}
+	withdrawalAddress := proof.leaf.WithdrawalAddress
+	contractSlot := proof.slot
+	leafs, proofs := proof.leaf, proof.proofs
+
+	state := proof.state
+
+	// Who receives the rewards when claiming
+	rewardRecipient, hasRecipient := oracle.RewardRecipientOf(state, withdrawalAddress)
+	if !hasRecipient {
+		rewardRecipient = withdrawalAddress
+	}
 
 	// Get validators that are registered to this withdrawal address in the pool
 	registeredValidators := make([]uint64, 0)
-	for valIndex, validator := range m.oracle.State().CommitedStates[contractSlot].Validators {
+	for valIndex, validator := range state.CommitedStates[contractSlot].Validators {
 		if strings.ToLower(validator.WithdrawalAddress) == strings.ToLower(withdrawalAddress) {
 			registeredValidators = append(registeredValidators, valIndex)
 		}
 	}
 
-	claimed, err := m.Onchain.GetContractClaimedBalance(withdrawalAddress, nil, apiRetryOpts...)
-	if err != nil {
-		m.respondError(w, http.StatusInternalServerError, "could not get claimed balance so far from contract: "+err.Error())
-		return
-	}
+	// Claimed up to the latest processed block
+	claimed := oracle.ClaimedRewardsOf(state, withdrawalAddress)
 
 	totalPending := big.NewInt(0)
 
-	for _, validator := range m.oracle.State().CommitedStates[contractSlot].Validators {
+	for _, validator := range state.CommitedStates[contractSlot].Validators {
 		if strings.ToLower(validator.WithdrawalAddress) == strings.ToLower(withdrawalAddress) {
 			totalPending.Add(totalPending, validator.PendingRewardsWei)
 		}
@@ -993,83 +1274,501 @@ func (m *ApiService) handleOnchainMerkleProof(w http.ResponseWriter, req *http.R
 	m.respondOK(w, httpOkProofs{
 		LeafWithdrawalAddress:      leafs.WithdrawalAddress,
 		LeafAccumulatedBalance:     leafs.AccumulatedBalanceWei.String(),
-		MerkleRoot:                 m.oracle.State().CommitedStates[contractSlot].MerkleRoot,
-		CheckpointSlot:             m.oracle.State().CommitedStates[contractSlot].Slot,
+		MerkleRoot:                 state.CommitedStates[contractSlot].MerkleRoot,
+		CheckpointSlot:             state.CommitedStates[contractSlot].Slot,
 		Proofs:                     proofs,
 		RegisteredValidators:       registeredValidators,
 		TotalAccumulatedRewardsWei: leafs.AccumulatedBalanceWei.String(),
 		ClaimableRewardsWei:        new(big.Int).Sub(leafs.AccumulatedBalanceWei, claimed).String(),
 		AlreadyClaimedRewardsWei:   claimed.String(),
 		PendingRewardsWei:          totalPending.String(),
+		RewardRecipient:            rewardRecipient,
 	})
 }
 
-func (m *ApiService) handleValidatorRelayers(w http.ResponseWriter, req *http.Request) {
-	vars := mux.Vars(req)
-	valPubKey := vars["valpubkey"]
-	if !IsValidPubkey(valPubKey) {
-		m.respondError(w, http.StatusInternalServerError, fmt.Sprintf("invalid validator pubkey format"))
+// Leaf and proof of a withdrawal address in the state consolidated onchain, and the
+// snapshot it was found in
+type onchainProof struct {
+	slot   uint64
+	root   string
+	leaf   oracle.RawLeaf
+	proofs []string
+	state  *oracle.OracleState
+}
+
+// Returns the proof of the address in the state consolidated onchain, checked against the
+// onchain root, or in the state commited at the given slot, checked against its root. The
+// address can also be the reward recipient of a single withdrawal address. On errors, the
+// http code to respond with is returned.
+func (m *ApiService) getOnchainProof(address string, atSlot *uint64) (*onchainProof, int, error) {
+	if !IsValidAddress(address) {
+		return nil, http.StatusBadRequest, errors.New("invalid WithdrawalAddress: " + address)
+	}
+
+	// Use always lowercase
+	withdrawalAddress := strings.ToLower(address)
+	state := m.oracle.Snapshot()
+
+	var contractRoot string
+	var contractSlot uint64
+	if atSlot != nil {
+		commited, found := state.CommitedStates[*atSlot]
+		if !found {
+			return nil, http.StatusNotFound, errors.New("no checkpoint at slot: " + strconv.FormatUint(*atSlot, 10))
+		}
+		contractRoot, contractSlot = commited.MerkleRoot, *atSlot
+	} else {
+		var err error
+		contractRoot, contractSlot, err = m.Onchain.GetOnchainSlotAndRoot(apiRetryOpts...)
+		if err != nil {
+			return nil, http.StatusInternalServerError, errors.Wrap(err, "could not get onchain slot and root")
+		}
+
+		commited, found := state.CommitedStates[contractSlot]
+		if !found {
+			return nil, http.StatusInternalServerError,
+				errors.New("could not find onchain slot in oracle state: " + strconv.FormatUint(contractSlot, 10))
+		}
+
+		// Check if the oracle root matches the one offchain
+		if contractRoot != commited.MerkleRoot {
+			return nil, http.StatusInternalServerError,
+				errors.New("contract merkle root does not match oracle state: " + contractRoot + " vs " + commited.MerkleRoot)
+		}
+	}
+
+	// If the address has no proof, it may be the reward recipient of a withdrawal address
+	_, _, proofFound, err := m.oracle.Proof(contractSlot, withdrawalAddress)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.Wrap(err, "could not get proof")
+	}
+	if !proofFound {
+		ofRecipient := oracle.WithdrawalAddressesOfRecipient(state, withdrawalAddress)
+		if len(ofRecipient) > 1 {
+			return nil, http.StatusBadRequest, errors.New("address is the reward recipient of multiple withdrawal addresses, " +
+				"request the proof of each of them: " + strings.Join(ofRecipient, ", "))
+		}
+		if len(ofRecipient) == 1 {
+			withdrawalAddress = ofRecipient[0]
+		}
+	}
+
+	// Get the leaf and proofs of this withdrawal address (to be used onchain to claim rewards)
+	leaf, proofs, proofFound, err := m.oracle.Proof(contractSlot, withdrawalAddress)
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.Wrap(err, "could not get proof")
+	}
+	if !proofFound {
+		return nil, http.StatusBadRequest, errors.New("could not find proof for WithdrawalAddress: " + withdrawalAddress)
+	}
+
+	// Never serve a proof that the contract would reject
+	valid, err := oracle.VerifyProof(contractRoot, leaf.WithdrawalAddress, leaf.AccumulatedBalanceWei, proofs)
+	if err != nil || !valid {
+		return nil, http.StatusInternalServerError,
+			errors.New("generated proof does not verify against the root: " + contractRoot)
+	}
+
+	return &onchainProof{slot: contractSlot, root: contractRoot, leaf: leaf, proofs: proofs, state: state}, http.StatusOK, nil
+}
+
+// Returns the call to claim the rewards of the address as calldata and as a transaction ready
+// to be signed, from the given address or the reward recipient if none
+func (m *ApiService) handleOnchainClaimTx(w http.ResponseWriter, req *http.Request) {
+	if !m.OracleReady(MaxSlotsBehind) {
+		m.respondError(w, http.StatusServiceUnavailable, "Oracle node is currently syncing and not serving requests")
+		return
+	}
+
+	address := mux.Vars(req)["address"]
+	proof, code, err := m.getOnchainProof(address, nil)
+	if err != nil {
+		m.respondError(w, code, err.Error())
+		return
+	}
+
+	rewardRecipient, hasRecipient := oracle.RewardRecipientOf(proof.state, proof.leaf.WithdrawalAddress)
+	if !hasRecipient {
+		rewardRecipient = proof.leaf.WithdrawalAddress
+	}
+
+	// Sent by whoever receives the rewards unless told otherwise
+	from := req.URL.Query().Get("from")
+	if from == "" {
+		from = rewardRecipient
+	}
+	if !IsValidAddress(from) {
+		m.respondError(w, http.StatusBadRequest, "invalid from: "+from)
+		return
+	}
+
+	// The contract knows of claims that the oracle may not have processed yet
+	claimed, err := m.Onchain.GetContractClaimedBalance(proof.leaf.WithdrawalAddress, nil, apiRetryOpts...)
+	if err != nil {
+		m.respondError(w, http.StatusInternalServerError, "could not get claimed balance: "+err.Error())
+		return
+	}
+	claimable := new(big.Int).Sub(proof.leaf.AccumulatedBalanceWei, claimed)
+	if claimable.Sign() <= 0 {
+		m.respondError(w, http.StatusBadRequest, "no rewards to claim for WithdrawalAddress: "+proof.leaf.WithdrawalAddress)
 		return
 	}
-	var correctFeeRelays []httpRelay
-	var wrongFeeRelays []httpRelay
-	var unregisteredRelays []httpRelay
-	registeredCorrectFee := false
 
-	relayers := m.cliCfg.RelayersEndpoints
+	calldata, err := oracle.ClaimRewardsCalldata(proof.leaf.WithdrawalAddress, proof.leaf.AccumulatedBalanceWei, proof.proofs)
+	if err != nil {
+		m.respondError(w, http.StatusInternalServerError, "could not build calldata: "+err.Error())
+		return
+	}
+	tx, err := m.Onchain.BuildUnsignedTx(common.HexToAddress(from), calldata, apiRetryOpts...)
+	if err != nil {
+		m.respondError(w, http.StatusInternalServerError, "could not build tx: "+err.Error())
+		return
+	}
+
+	m.respondOK(w, httpOkClaimTx{
+		WithdrawalAddress:          proof.leaf.WithdrawalAddress,
+		RewardRecipient:            rewardRecipient,
+		ContractAddress:            tx.To.Hex(),
+		MerkleRoot:                 proof.root,
+		CheckpointSlot:             proof.slot,
+		TotalAccumulatedRewardsWei: proof.leaf.AccumulatedBalanceWei.String(),
+		AlreadyClaimedRewardsWei:   claimed.String(),
+		ClaimableRewardsWei:        claimable.String(),
+		Calldata:                   hexutil.Encode(calldata),
+		UnsignedTx:                 (*client.UnsignedTx)(tx),
+	})
+}
+
+// Checks a proof as the contract does when claiming, against the given root or the
+// latest one onchain if none
+func (m *ApiService) handleOnchainVerify(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	withdrawalAddress := query.Get("address")
+	if !IsValidAddress(withdrawalAddress) {
+		m.respondError(w, http.StatusBadRequest, "invalid address: "+withdrawalAddress)
+		return
+	}
+	amountWei, ok := new(big.Int).SetString(query.Get("amount"), 10)
+	if !ok {
+		m.respondError(w, http.StatusBadRequest, "invalid amount: "+query.Get("amount"))
+		return
+	}
+	proofs := make([]string, 0)
+	if query.Get("proof") != "" {
+		proofs = strings.Split(query.Get("proof"), ",")
+	}
 
-	for _, relay := range relayers {
-		url := fmt.Sprintf("%s/relay/v1/data/validator_registration?pubkey=%s", relay, valPubKey)
-		resp, err := http.Get(url)
+	root := query.Get("root")
+	if root == "" {
+		contractRoot, _, err := m.Onchain.GetOnchainSlotAndRoot(apiRetryOpts...)
 		if err != nil {
-			m.respondError(w, http.StatusInternalServerError, "could not call relayer endpoint: "+err.Error())
+			m.respondError(w, http.StatusInternalServerError, "could not get onchain slot and root: "+err.Error())
 			return
 		}
-		defer resp.Body.Close()
+		root = contractRoot
+	}
 
-		if resp.StatusCode == http.StatusOK {
-			signedRegistration := &builderApiV1.SignedValidatorRegistration{}
+	valid, err := oracle.VerifyProof(root, withdrawalAddress, amountWei, proofs)
+	if err != nil {
+		m.respondError(w, http.StatusBadRequest, "could not verify proof: "+err.Error())
+		return
+	}
 
-			bodyBytes, err := io.ReadAll(resp.Body)
-			if err != nil {
-				m.respondError(w, http.StatusInternalServerError, "could not call relayer endpoint: "+err.Error())
-				return
-			}
+	m.respondOK(w, httpOkVerifyProof{
+		MerkleRoot:        root,
+		WithdrawalAddress: strings.ToLower(withdrawalAddress),
+		AmountWei:         amountWei.String(),
+		Proofs:            proofs,
+		Valid:             valid,
+	})
+}
 
-			if err = json.Unmarshal(bodyBytes, signedRegistration); err != nil {
-				m.respondError(w, http.StatusInternalServerError, "could not call relayer endpoint: "+err.Error())
-				return
-			}
+// Returns the tree commited at the given checkpoint slot, to claim without the api
+func (m *ApiService) handleOnchainTree(w http.ResponseWriter, req *http.Request) {
+	slotStr := mux.Vars(req)["slot"]
+	slot, err := strconv.ParseUint(slotStr, 10, 64)
+	if err != nil {
+		m.respondError(w, http.StatusBadRequest, "invalid slot: "+slotStr)
+		return
+	}
+	if _, found := m.oracle.Snapshot().CommitedStates[slot]; !found {
+		m.respondError(w, http.StatusNotFound, "no checkpoint at slot: "+slotStr)
+		return
+	}
 
-			relayRegistration := httpRelay{
-				RelayAddress: relay,
-				FeeRecipient: signedRegistration.Message.FeeRecipient.String(),
-				Timestamp:    fmt.Sprintf("%d", signedRegistration.Message.Timestamp.UnixNano()),
-			}
+	dump, err := m.oracle.StandardTree(slot)
+	if err != nil {
+		m.respondError(w, http.StatusInternalServerError, "could not get tree: "+err.Error())
+		return
+	}
+	m.respondOK(w, dump)
+}
 
-			if utils.Equals(signedRegistration.Message.FeeRecipient.String(), m.Onchain.PoolAddress) {
-				correctFeeRelays = append(correctFeeRelays, relayRegistration)
-			} else {
-				wrongFeeRelays = append(wrongFeeRelays, relayRegistration)
+// Returns the claims where the address is the withdrawal address or received the rewards,
+// oldest first, and the total claimed by the address as withdrawal address.
+func (m *ApiService) handleMemoryClaims(w http.ResponseWriter, req *http.Request) {
+	address := mux.Vars(req)["address"]
+	if !IsValidAddress(address) {
+		m.respondError(w, http.StatusBadRequest, "invalid address: "+address)
+		return
+	}
+	address = strings.ToLower(address)
+
+	state := m.oracle.Snapshot()
+	response := httpOkClaims{
+		ClaimedRewardsWei: oracle.ClaimedRewardsOf(state, address).String(),
+		Claims:            make([]httpOkClaim, 0),
+	}
+	for _, claim := range oracle.ClaimsOf(state, address) {
+		response.Claims = append(response.Claims, httpOkClaim{
+			Slot:              claim.Slot,
+			Block:             claim.Block,
+			TxHash:            claim.TxHash,
+			WithdrawalAddress: claim.WithdrawalAddress,
+			RewardAddress:     claim.RewardAddress,
+			AmountWei:         claim.AmountWei.String(),
+		})
+	}
+	m.respondOK(w, response)
+}
+
+// Returns the consolidations of tracked validators, oldest request first. If a validator
+// index is given, only the ones where it is the source or the target.
+func (m *ApiService) handleMemoryConsolidations(w http.ResponseWriter, req *http.Request) {
+	var validatorIndex *uint64
+	if valIndexStr, filtered := mux.Vars(req)["valindex"]; filtered {
+		valIndex, ok := IsValidIndex(valIndexStr)
+		if !ok {
+			m.respondError(w, http.StatusBadRequest, "invalid validator index: "+valIndexStr)
+			return
+		}
+		validatorIndex = &valIndex
+	}
+
+	response := httpOkConsolidations{
+		Consolidations: make([]httpOkConsolidation, 0),
+	}
+	for _, consolidation := range oracle.ConsolidationsOf(m.oracle.Snapshot(), validatorIndex) {
+		transferred := ""
+		if consolidation.TransferredPendingWei != nil {
+			transferred = consolidation.TransferredPendingWei.String()
+		}
+		response.Consolidations = append(response.Consolidations, httpOkConsolidation{
+			SourceIndex:                consolidation.SourceIndex,
+			SourceKey:                  consolidation.SourceKey,
+			TargetIndex:                consolidation.TargetIndex,
+			TargetKey:                  consolidation.TargetKey,
+			Status:                     string(consolidation.Status),
+			RequestSlot:                consolidation.RequestSlot,
+			RequestBlock:               consolidation.RequestBlock,
+			CompletedSlot:              consolidation.CompletedSlot,
+			TransferSlot:               consolidation.TransferSlot,
+			TransferredPendingWei:      transferred,
+			Target:                     string(consolidation.Target),
+			SourceWithdrawalAddress:    consolidation.SourceWithdrawalAddress,
+			TargetWithdrawalAddress:    consolidation.TargetWithdrawalAddress,
+			DifferentWithdrawalAddress: consolidation.DifferentWithdrawalAddress,
+		})
+	}
+	m.respondOK(w, response)
+}
+
+// Returns the current reward recipients and the history of changes. If an address is
+// given, only the ones where it is the withdrawal address or the recipient.
+func (m *ApiService) handleMemoryRewardRecipients(w http.ResponseWriter, req *http.Request) {
+	address, filtered := mux.Vars(req)["address"]
+	if filtered {
+		if !IsValidAddress(address) {
+			m.respondError(w, http.StatusBadRequest, "invalid address: "+address)
+			return
+		}
+		address = strings.ToLower(address)
+	}
+
+	response := httpOkRewardRecipients{
+		RewardRecipients: make([]httpOkRewardRecipient, 0),
+		History:          make([]httpOkRewardRecipientChange, 0),
+	}
+	state := m.oracle.Snapshot()
+	for withdrawalAddress, recipient := range state.RewardRecipients {
+		if filtered && !AreAddressEqual(withdrawalAddress, address) && !AreAddressEqual(recipient, address) {
+			continue
+		}
+		response.RewardRecipients = append(response.RewardRecipients, httpOkRewardRecipient{
+			WithdrawalAddress: withdrawalAddress,
+			RewardRecipient:   recipient,
+		})
+	}
+	sort.Slice(response.RewardRecipients, func(i, j int) bool {
+		return response.RewardRecipients[i].WithdrawalAddress < response.RewardRecipients[j].WithdrawalAddress
+	})
+
+	for _, change := range oracle.RewardRecipientHistoryOf(state, address) {
+		response.History = append(response.History, httpOkRewardRecipientChange{
+			Slot:              change.Slot,
+			Block:             change.Block,
+			TxHash:            change.TxHash,
+			WithdrawalAddress: change.WithdrawalAddress,
+			RewardRecipient:   change.RewardRecipient,
+		})
+	}
+	m.respondOK(w, response)
+}
+
+// Returns the stored onchain reconciliation reports, most recent first. Each compares the
+// balance of the pool contract with what the oracle owes at the block it was captured.
+func (m *ApiService) handleOnchainReconciliation(w http.ResponseWriter, req *http.Request) {
+	reports := m.oracle.ReconciliationReports()
+
+	response := httpOkReconciliation{
+		RequireReconciliation: m.cfg.RequireReconciliation,
+		Reports:               make([]httpOkReconciliationReport, 0, len(reports)),
+	}
+	for i := len(reports) - 1; i >= 0; i-- {
+		response.Reports = append(response.Reports, toHttpReconciliationReport(reports[i]))
+	}
+	if len(response.Reports) > 0 {
+		response.Latest = &response.Reports[0]
+	}
+	m.respondOK(w, response)
+}
+
+func toHttpReconciliationReport(report oracle.ReconciliationReport) httpOkReconciliationReport {
+	weiString := func(amount *big.Int) string {
+		if amount == nil {
+			return ""
+		}
+		return amount.String()
+	}
+	return httpOkReconciliationReport{
+		Slot:           report.Slot,
+		Block:          report.Block,
+		Timestamp:      report.Timestamp,
+		Success:        report.Success,
+		Error:          report.Error,
+		RewardsWei:     weiString(report.RewardsWei),
+		ClaimedWei:     weiString(report.ClaimedWei),
+		AssetsWei:      weiString(report.AssetsWei),
+		LiabilitiesWei: weiString(report.LiabilitiesWei),
+		DeltaWei:       weiString(report.DeltaWei),
+	}
+}
+
+func (m *ApiService) handleValidatorRelayers(w http.ResponseWriter, req *http.Request) {
+	vars := mux.Vars(req)
+	valPubKey := vars["valpubkey"]
+	if !IsValidPubkey(valPubKey) {
+		m.respondError(w, http.StatusInternalServerError, fmt.Sprintf("invalid validator pubkey format"))
+		return
+	}
+	m.respondOK(w, m.relayersState(valPubKey))
+}
+
+// Checks in one call the relays of all the validators with the withdrawal address
+func (m *ApiService) handleWithdrawalRelayers(w http.ResponseWriter, req *http.Request) {
+	withdrawalAddress := strings.ToLower(mux.Vars(req)["withdrawalAddress"])
+	if !IsValidAddress(withdrawalAddress) {
+		m.respondError(w, http.StatusBadRequest, "invalid withdrawalAddress: "+withdrawalAddress)
+		return
+	}
+	if m.Onchain.Validators() == nil {
+		m.respondError(w, http.StatusInternalServerError, "finalized validators not loaded yet, try again later")
+		return
+	}
+
+	validators := validatorsOfWithdrawalAddress(m.Onchain.Validators(), withdrawalAddress)
+	if len(validators) > MaxRelayersValidators {
+		m.respondError(w, http.StatusBadRequest, fmt.Sprintf("withdrawal address has %d validators, more than the maximum of %d",
+			len(validators), MaxRelayersValidators))
+		return
+	}
+
+	response := make([]httpOkValidatorRelayers, len(validators))
+	var wg sync.WaitGroup
+	for i, validator := range validators {
+		wg.Add(1)
+		go func(i int, validator *v1.Validator) {
+			defer wg.Done()
+			pubkey := hexutil.Encode(validator.Validator.PublicKey[:])
+			response[i] = httpOkValidatorRelayers{
+				ValidatorIndex: uint64(validator.Index),
+				ValidatorKey:   pubkey,
+				Relayers:       m.relayersState(pubkey),
 			}
-		} else {
+		}(i, validator)
+	}
+	wg.Wait()
+	m.respondOK(w, response)
+}
+
+// Classifies the relays by the fee recipient the validator registered in them. Registrations
+// with an invalid signature are listed, but dont count as correct nor wrong
+func (m *ApiService) relayersState(pubkey string) httpOkRelayersState {
+	var correctFeeRelays []httpRelay
+	var wrongFeeRelays []httpRelay
+	var correct, wrong int
+	var unregisteredRelays []httpRelay
+	var unreachableRelays []httpRelay
+
+	for _, relay := range m.relays.Check(pubkey) {
+		if relay.Err != nil {
+			unreachableRelays = append(unreachableRelays, httpRelay{
+				RelayAddress: relay.Relay,
+				Error:        relay.Err.Error(),
+			})
+			continue
+		}
+		if relay.Registration == nil {
 			unregisteredRelays = append(unregisteredRelays, httpRelay{
-				RelayAddress: relay,
+				RelayAddress: relay.Relay,
 			})
+			continue
 		}
-	}
 
-	// Only if there are some correct registrations and no invalid ones, its ok
-	if len(wrongFeeRelays) == 0 && len(correctFeeRelays) > 0 {
-		registeredCorrectFee = true
+		relayRegistration := httpRelay{
+			RelayAddress: relay.Relay,
+			FeeRecipient: relay.Registration.Message.FeeRecipient.String(),
+			Timestamp:    fmt.Sprintf("%d", relay.Registration.Message.Timestamp.UnixNano()),
+			Signature:    relay.Signature,
+		}
+		signed := relay.Signature != SignatureInvalid
+		if utils.Equals(relay.Registration.Message.FeeRecipient.String(), m.cfg.PoolAddress) {
+			correctFeeRelays = append(correctFeeRelays, relayRegistration)
+			if signed {
+				correct++
+			}
+		} else {
+			wrongFeeRelays = append(wrongFeeRelays, relayRegistration)
+			if signed {
+				wrong++
+			}
+		}
 	}
 
-	m.respondOK(w, httpOkRelayersState{
-		CorrectFeeRecipients: registeredCorrectFee,
+	// Only if there are some correct registrations and no wrong ones, its ok. Unreachable
+	// relays and forged registrations are not known to be wrong
+	return httpOkRelayersState{
+		CorrectFeeRecipients: wrong == 0 && correct > 0,
 		CorrectFeeRelays:     correctFeeRelays,
 		WrongFeeRelays:       wrongFeeRelays,
 		UnregisteredRelays:   unregisteredRelays,
-	})
+		UnreachableRelays:    unreachableRelays,
+	}
+}
+
+// Returns the validators with the withdrawal address in their credentials, sorted by index
+func validatorsOfWithdrawalAddress(validators map[phase0.ValidatorIndex]*v1.Validator, withdrawalAddress string) []*v1.Validator {
+	found := make([]*v1.Validator, 0)
+	for _, validator := range validators {
+		address, err := utils.GetCompatibleAddressByte(validator.Validator.WithdrawalCredentials)
+		if err != nil || !AreAddressEqual(address, withdrawalAddress) {
+			continue
+		}
+		found = append(found, validator)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Index < found[j].Index })
+	return found
 }
 
 func (m *ApiService) handleState(w http.ResponseWriter, req *http.Request) {
@@ -1118,56 +1817,47 @@ func AreAddressEqual(address1 string, address2 string) bool {
 	return false
 }
 
-// TODO: unsure if move this somewhere else
-func (m *ApiService) GetSubscriptionsTillHead(latestProcessedBlock uint64) ([]Subscription, error) {
-	// TODO: add check here to ensure its a reasonable amount of blocks. should be around 15-20 minutes in blocks
-	filterOpts := &bind.FilterOpts{Context: context.Background(), Start: latestProcessedBlock, End: nil}
-
-	// Note that this event can be both donations and mev rewards
-	itrSubs, err := m.Onchain.Contract.FilterSubscribeValidator(filterOpts)
-	if err != nil {
-		return nil, errors.Wrap(err, "could not subscribe to validator events")
+// Returns the subscriptions and unsubscriptions after the given block, as indexed by the
+// head indexer. False if they are not indexed yet
+func (m *ApiService) nonFinalizedEvents(latestProcessedBlock uint64) ([]Subscription, []Unsubscription, bool) {
+	if m.headIndexer == nil {
+		return nil, nil, false
+	}
+	subEvents, unsubEvents, indexed := m.headIndexer.EventsAfter(latestProcessedBlock)
+	if !indexed {
+		return nil, nil, false
 	}
 
-	// Loop over all found events. Super inneficient. just Proof of concept
-	blockSubscriptions := make([]Subscription, 0)
-	for itrSubs.Next() {
-		sub := Subscription{
-			Event:     itrSubs.Event,
-			Validator: m.Onchain.Validators()[phase0.ValidatorIndex(itrSubs.Event.ValidatorID)],
-		}
-		blockSubscriptions = append(blockSubscriptions, sub)
+	validators := m.Onchain.Validators()
+	subscriptions := make([]Subscription, 0, len(subEvents))
+	for _, event := range subEvents {
+		subscriptions = append(subscriptions, Subscription{
+			Event:     event,
+			Validator: validators[phase0.ValidatorIndex(event.ValidatorID)],
+		})
 	}
-	err = itrSubs.Close()
-	if err != nil {
-		return nil, errors.Wrap(err, "could not close subscription iterator")
+	unsubscriptions := make([]Unsubscription, 0, len(unsubEvents))
+	for _, event := range unsubEvents {
+		unsubscriptions = append(unsubscriptions, Unsubscription{
+			Event:     event,
+			Validator: validators[phase0.ValidatorIndex(event.ValidatorID)],
+		})
 	}
-	return blockSubscriptions, nil
+	return subscriptions, unsubscriptions, true
 }
 
-func (m *ApiService) GetUnsubscriptionsTillHead(latestProcessedBlock uint64) ([]Unsubscription, error) {
-	// TODO: add check here to ensure its a reasonable amount of blocks. should be around 15-20 minutes in blocks
-	filterOpts := &bind.FilterOpts{Context: context.Background(), Start: latestProcessedBlock, End: nil}
-	// Note that this event can be both donations and mev rewards
-	itrUnsubs, err := m.Onchain.Contract.FilterUnsubscribeValidator(filterOpts)
-	if err != nil {
-		return nil, errors.Wrap(err, "could not subscribe to validator events")
-	}
-
-	// Loop over all found events, TODO: inneficient. only finter events of this validator.
-	blockUnsubscriptions := make([]Unsubscription, 0)
-	for itrUnsubs.Next() {
-		unsub := Unsubscription{
-			Event:     itrUnsubs.Event,
-			Validator: m.Onchain.Validators()[phase0.ValidatorIndex(itrUnsubs.Event.ValidatorID)],
+// Returns the state with the config parameters in effect, which governance can change. The
+// config only has the ones the contract was deployed with, used when there is no oracle
+func (m *ApiService) governanceState() *oracle.OracleState {
+	if m.oracle == nil {
+		return &oracle.OracleState{
+			PoolFeesPercentOver10000: m.cfg.PoolFeesPercentOver10000,
+			PoolFeesAddress:          m.cfg.PoolFeesAddress,
+			CheckPointSizeInSlots:    m.cfg.CheckPointSizeInSlots,
+			CollateralInWei:          m.cfg.CollateralInWei,
 		}
-		blockUnsubscriptions = append(blockUnsubscriptions, unsub)
 	}
-	err = itrUnsubs.Close()
-	if err != nil {
-		return nil, errors.Wrap(err, "could not close subscription iterator")
-	}
-	return blockUnsubscriptions, nil
+	return m.oracle.Snapshot()
 }
 
 func (m *ApiService) ApplyNonFinalizedState(
@@ -1175,6 +1865,8 @@ func (m *ApiService) ApplyNonFinalizedState(
 	unsubs []Unsubscription,
 	validators map[uint64]*oracle.ValidatorInfo) {
 
+	collateral := m.governanceState().CollateralInWei
+
 	eventsBlocksList := make([]uint64, 0)
 
 	for _, sub := range subs {
@@ -1215,9 +1907,10 @@ func (m *ApiService) ApplyNonFinalizedState(
 				valWithdrawalAddress := val.WithdrawalAddress
 				eventAddress := subInBlock.Event.Sender.String()
 				if AreAddressEqual(valWithdrawalAddress, eventAddress) {
-					if subInBlock.Event.SubscriptionCollateral.Cmp(m.cfg.CollateralInWei) >= 0 {
+					if subInBlock.Event.SubscriptionCollateral.Cmp(collateral) >= 0 {
 						if oracle.CanValidatorSubscribeToPool(subInBlock.Validator) {
-							if val.ValidatorStatus == oracle.Untracked || val.ValidatorStatus == oracle.NotSubscribed {
+							if val.ValidatorStatus == oracle.Untracked || val.ValidatorStatus == oracle.BlsProposer ||
+								val.ValidatorStatus == oracle.NotSubscribed {
 								validators[valIndex].ValidatorStatus = oracle.Active
 								validators[valIndex].PendingRewardsWei.Add(validators[valIndex].PendingRewardsWei, subInBlock.Event.SubscriptionCollateral)
 								// Accumulated is not updated, since that has to be done onchain
@@ -1263,7 +1956,7 @@ func (m *ApiService) OracleReady(maxSlotsBehind uint64) bool {
 		return false
 	}
 
-	slotsFromFinalized := finalizedSlot - m.oracle.State().LatestProcessedSlot
+	slotsFromFinalized := finalizedSlot - m.oracle.Snapshot().LatestProcessedSlot
 
 	// Use this if we want full in sync to latest finalized
 	/*oracleInSync := false
@@ -1,17 +1,33 @@
 package api
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	eth2http "github.com/attestantio/go-eth2-client/http"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/dappnode/mev-sp-oracle/client"
 	"github.com/dappnode/mev-sp-oracle/config"
 	"github.com/dappnode/mev-sp-oracle/contract"
 	"github.com/dappnode/mev-sp-oracle/oracle"
+	"github.com/dappnode/mev-sp-oracle/utils"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/require"
 )
 
@@ -300,6 +316,69 @@ func Test_ApplyNonFinalizedState_MultipleEvents(t *testing.T) {
 	require.Equal(t, oracle.NotSubscribed, validators[3].ValidatorStatus)
 }
 
+func Test_HandleOnchainVerify(t *testing.T) {
+	leafs := []oracle.RawLeaf{
+		{WithdrawalAddress: "0x00000000000000000000000000000000000000a1", AccumulatedBalanceWei: big.NewInt(100)},
+		{WithdrawalAddress: "0x00000000000000000000000000000000000000a2", AccumulatedBalanceWei: big.NewInt(50)},
+	}
+	blocks, _, tree, _ := oracle.NewMerklelizer().GenerateTreeFromLeafs(leafs)
+	proof, err := tree.Proof(blocks["0x00000000000000000000000000000000000000a2"])
+	require.NoError(t, err)
+	root := hexutil.Encode(tree.Root)
+	proofs := strings.Join(utils.ByteArrayToArray(proof.Siblings), ",")
+
+	verify := func(query string) (int, httpOkVerifyProof) {
+		w := httptest.NewRecorder()
+		api := &ApiService{}
+		api.handleOnchainVerify(w, httptest.NewRequest(http.MethodGet, "/onchain/verify?"+query, nil))
+		var response httpOkVerifyProof
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	code, response := verify("address=0x00000000000000000000000000000000000000a2&amount=50&root=" + root + "&proof=" + proofs)
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, response.Valid)
+	require.Equal(t, root, response.MerkleRoot)
+
+	code, response = verify("address=0x00000000000000000000000000000000000000a2&amount=51&root=" + root + "&proof=" + proofs)
+	require.Equal(t, http.StatusOK, code)
+	require.False(t, response.Valid)
+
+	code, _ = verify("address=0x00000000000000000000000000000000000000a2&amount=abc&root=" + root + "&proof=" + proofs)
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = verify("address=0x00000000000000000000000000000000000000a2&amount=50&root=0x12&proof=" + proofs)
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func Test_ParseBlockFilter(t *testing.T) {
+	query, _ := url.ParseQuery("from_slot=10&to_slot=20&validator_index=3&withdrawal_address=0xa111b576408b1ccdaca3ef26f22f082c49bcaa55" +
+		"&block_type=missedproposal&reward_type=mev&min_reward_wei=5")
+	filter, err := parseBlockFilter(query)
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), filter.FromSlot)
+	require.Equal(t, uint64(20), *filter.ToSlot)
+	require.Equal(t, uint64(3), *filter.ValidatorIndex)
+	require.Equal(t, "0xa111b576408b1ccdaca3ef26f22f082c49bcaa55", filter.WithdrawalAddress)
+	require.Equal(t, oracle.MissedProposal, *filter.BlockType)
+	require.Equal(t, oracle.MevBlock, *filter.RewardType)
+	require.Equal(t, big.NewInt(5), filter.MinRewardWei)
+
+	for _, invalid := range []string{"from_slot=a", "validator_index=-1", "withdrawal_address=0x12", "block_type=ok", "reward_type=x", "min_reward_wei=1.5"} {
+		query, _ := url.ParseQuery(invalid)
+		_, err := parseBlockFilter(query)
+		require.Error(t, err, invalid)
+	}
+
+	limit, err := parsePageLimit(url.Values{})
+	require.NoError(t, err)
+	require.Equal(t, DefaultPageLimit, limit)
+	_, err = parsePageLimit(url.Values{"limit": {"0"}})
+	require.Error(t, err)
+	_, err = parsePageLimit(url.Values{"limit": {"1001"}})
+	require.Error(t, err)
+}
+
 // Can be used to test the API endpoints, mocking the endpoint
 func Test_ApiEndpoint(t *testing.T) {
 	/*
@@ -314,3 +393,490 @@ func Test_ApiEndpoint(t *testing.T) {
 		require.Equal(t, 1, 1)
 	*/
 }
+
+func Test_HandleEvents(t *testing.T) {
+	oracleInstance := oracle.NewOracle(&oracle.Config{DeployedSlot: 1000})
+	api := NewApiService(&oracle.Config{}, &config.CliConfig{ApiPort: 7300}, oracleInstance, nil)
+	server := httptest.NewServer(api.getRouter())
+	defer server.Close()
+	apiClient := client.NewClient(server.URL)
+
+	validator := func(eventType oracle.StreamEventType, slot uint64, valIndex uint64, address string) oracle.StreamEvent {
+		return oracle.StreamEvent{Type: eventType, Slot: slot, ValidatorIndex: &valIndex, WithdrawalAddress: address, Data: []byte("{}")}
+	}
+	stream := oracleInstance.Events()
+	_, subscription, err := stream.Subscribe("")
+	require.NoError(t, err)
+	stream.Publish(validator(oracle.BlockClassifiedEvent, 1, 1, "0x00000000000000000000000000000000000000a1"))
+	stream.Publish(validator(oracle.BlockClassifiedEvent, 2, 2, "0x00000000000000000000000000000000000000a2"))
+	stream.Publish(oracle.StreamEvent{Type: oracle.ReportSubmittedEvent, Slot: 3, Data: []byte("{}")})
+	stream.Publish(validator(oracle.ValidatorStatusEvent, 4, 1, "0x00000000000000000000000000000000000000a1"))
+	stream.Publish(validator(oracle.BlockClassifiedEvent, 5, 1, "0x00000000000000000000000000000000000000a1"))
+	first := <-subscription.Events
+	stream.Unsubscribe(subscription)
+
+	// Collects the events until the one of the given slot
+	collect := func(query url.Values, untilSlot uint64) ([]uint64, error) {
+		slots := make([]uint64, 0)
+		err := apiClient.Events(query, func(event *client.StreamEvent) error {
+			slots = append(slots, event.Slot)
+			if event.Slot == untilSlot {
+				return io.EOF
+			}
+			return nil
+		})
+		return slots, err
+	}
+
+	// Resuming from the first event, only the ones of the validator and the whole pool
+	slots, err := collect(url.Values{"resume": {first.Id}, "validator_index": {"1"}}, 5)
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, []uint64{3, 4, 5}, slots)
+
+	slots, err = collect(url.Values{"resume": {first.Id}, "withdrawal_address": {"0x00000000000000000000000000000000000000A2"}}, 3)
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, []uint64{2, 3}, slots)
+
+	slots, err = collect(url.Values{"resume": {first.Id}, "types": {"validator_status,block_classified"}}, 5)
+	require.Equal(t, io.EOF, err)
+	require.Equal(t, []uint64{2, 4, 5}, slots)
+
+	// Live events are streamed too
+	done := make(chan []uint64)
+	go func() {
+		slots, _ := collect(url.Values{"types": {"checkpoint_frozen"}}, 6)
+		done <- slots
+	}()
+	require.Eventually(t, func() bool {
+		stream.Publish(oracle.StreamEvent{Type: oracle.CheckpointFrozenEvent, Slot: 6, Data: []byte("{}")})
+		select {
+		case slots := <-done:
+			return len(slots) != 0 && slots[0] == 6
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Tokens no longer buffered are gone, and invalid filters rejected
+	_, err = collect(url.Values{"resume": {"1-1"}}, 0)
+	require.Equal(t, http.StatusGone, err.(*client.Error).Code)
+	_, err = collect(url.Values{"types": {"unknown"}}, 0)
+	require.Equal(t, http.StatusBadRequest, err.(*client.Error).Code)
+	_, err = collect(url.Values{"validator_index": {"-1"}}, 0)
+	require.Equal(t, http.StatusBadRequest, err.(*client.Error).Code)
+}
+
+func Test_HandleConfig_GovernanceChange(t *testing.T) {
+	cfg := &oracle.Config{
+		Network:                  "mainnet",
+		PoolAddress:              "0x0000000000000000000000000000000000000001",
+		PoolFeesAddress:          "0x0000000000000000000000000000000000000002",
+		PoolFeesPercentOver10000: 700,
+		CheckPointSizeInSlots:    100,
+		CollateralInWei:          big.NewInt(1000),
+		DeployedSlot:             1000,
+	}
+	oracleInstance := oracle.NewOracle(cfg)
+	api := NewApiService(cfg, &config.CliConfig{ApiPort: 7300}, oracleInstance, nil)
+	server := httptest.NewServer(api.getRouter())
+	defer server.Close()
+	apiClient := client.NewClient(server.URL)
+
+	missedBlock := func(slot uint64, events *oracle.Events) *oracle.FullBlock {
+		return &oracle.FullBlock{
+			ConsensusDuty: &v1.ProposerDuty{Slot: phase0.Slot(slot)},
+			Validator:     &v1.Validator{Validator: &phase0.Validator{WithdrawalCredentials: make([]byte, 32)}},
+			Events:        events,
+		}
+	}
+	_, err := oracleInstance.AdvanceStateToNextSlot(missedBlock(1000, &oracle.Events{}))
+	require.NoError(t, err)
+	_, err = oracleInstance.AdvanceStateToNextSlot(missedBlock(1001, &oracle.Events{
+		UpdatePoolFee:      []*contract.ContractUpdatePoolFee{{NewPoolFee: big.NewInt(500)}},
+		CheckpointSlotSize: []*contract.ContractUpdateCheckpointSlotSize{{NewCheckpointSlotSize: 50}},
+		UpdateSubscriptionCollateral: []*contract.ContractUpdateSubscriptionCollateral{
+			{NewSubscriptionCollateral: big.NewInt(2000)}},
+	}))
+	require.NoError(t, err)
+
+	// The values in effect are served, the config is kept as deployed
+	served, err := apiClient.Config()
+	require.NoError(t, err)
+	require.Equal(t, 500, served.PoolFeesPercentOver10000)
+	require.Equal(t, uint64(50), served.CheckPointSizeInSlots)
+	require.Equal(t, "2000", served.CollateralInWei)
+	require.Equal(t, 700, cfg.PoolFeesPercentOver10000)
+	require.Equal(t, uint64(100), cfg.CheckPointSizeInSlots)
+	require.Equal(t, big.NewInt(1000), cfg.CollateralInWei)
+}
+
+// Serves the api while slots are processed. Run with -race to detect handlers reading the state
+// that is being modified, instead of the snapshot
+func Test_HandleViewHead(t *testing.T) {
+	cfg := &oracle.Config{
+		Network:                  "mainnet",
+		PoolFeesPercentOver10000: 700,
+		CollateralInWei:          big.NewInt(1000),
+		DeployedSlot:             1000,
+	}
+	oracleInstance := oracle.NewOracle(cfg)
+	api := NewApiService(cfg, &config.CliConfig{ApiPort: 7300}, oracleInstance, nil)
+	server := httptest.NewServer(api.getRouter())
+	defer server.Close()
+	apiClient := client.NewClient(server.URL)
+
+	missedBlock := func(slot uint64) *oracle.FullBlock {
+		return &oracle.FullBlock{
+			ConsensusDuty: &v1.ProposerDuty{Slot: phase0.Slot(slot)},
+			Validator:     &v1.Validator{Validator: &phase0.Validator{WithdrawalCredentials: make([]byte, 32)}},
+			Events:        &oracle.Events{},
+		}
+	}
+	_, err := oracleInstance.AdvanceStateToNextSlot(missedBlock(1000))
+	require.NoError(t, err)
+	head := url.Values{"view": {"head"}}
+
+	// Nothing to serve until the head is previewed
+	_, err = apiClient.FeesInfo(head)
+	require.Equal(t, http.StatusServiceUnavailable, err.(*client.Error).Code)
+	_, err = apiClient.FeesInfo(url.Values{"view": {"latest"}})
+	require.Equal(t, http.StatusBadRequest, err.(*client.Error).Code)
+
+	// The fee changes in a block not finalized yet
+	api.headPreview = oracle.NewHeadPreview(oracleInstance,
+		func() (uint64, error) { return 1001, nil },
+		func() (uint64, error) { return 1000, nil },
+		func(slot uint64, fork *oracle.Oracle) (*oracle.FullBlock, error) {
+			block := missedBlock(slot)
+			block.Events.UpdatePoolFee = []*contract.ContractUpdatePoolFee{{NewPoolFee: big.NewInt(500)}}
+			return block, nil
+		})
+	require.NoError(t, api.headPreview.Update())
+
+	// Its only seen at head
+	fees, err := apiClient.FeesInfo(head)
+	require.NoError(t, err)
+	require.Equal(t, 500, fees.PoolFeesPercentOver10000)
+	for _, query := range []url.Values{nil, {"view": {"finalized"}}} {
+		fees, err = apiClient.FeesInfo(query)
+		require.NoError(t, err)
+		require.Equal(t, 700, fees.PoolFeesPercentOver10000)
+	}
+	require.Equal(t, 700, cfg.PoolFeesPercentOver10000)
+}
+
+func Test_ServeWhileProcessing(t *testing.T) {
+	oracle.StateFolder = t.TempDir()
+	cfg := &oracle.Config{
+		Network:               "mainnet",
+		PoolAddress:           "0x0000000000000000000000000000000000000001",
+		PoolFeesAddress:       "0x0000000000000000000000000000000000000002",
+		CheckPointSizeInSlots: 100,
+		CollateralInWei:       big.NewInt(1000),
+		DeployedSlot:          1000,
+		DeployedBlock:         1000,
+	}
+	oracleInstance := oracle.NewOracle(cfg)
+	withdrawalAddress := "0x00000000000000000000000000000000000000a1"
+	for valIndex := uint64(1); valIndex <= 3; valIndex++ {
+		oracleInstance.State().Validators[valIndex] = &oracle.ValidatorInfo{
+			ValidatorStatus:       oracle.Active,
+			AccumulatedRewardsWei: big.NewInt(0),
+			PendingRewardsWei:     big.NewInt(0),
+			CollateralWei:         big.NewInt(0),
+			WithdrawalAddress:     withdrawalAddress,
+			ValidatorIndex:        valIndex,
+		}
+	}
+	api := NewApiService(cfg, &config.CliConfig{ApiPort: 7300}, oracleInstance, nil)
+	router := api.getRouter()
+
+	// Each validator misses a block in turn, changing its status
+	credentials := append([]byte{1}, make([]byte, 11)...)
+	credentials = append(credentials, common.HexToAddress(withdrawalAddress).Bytes()...)
+	missedBlock := func(slot uint64) *oracle.FullBlock {
+		valIndex := phase0.ValidatorIndex(1 + slot%3)
+		return &oracle.FullBlock{
+			ConsensusDuty: &v1.ProposerDuty{Slot: phase0.Slot(slot), ValidatorIndex: valIndex},
+			Validator:     &v1.Validator{Index: valIndex, Validator: &phase0.Validator{WithdrawalCredentials: credentials}},
+			Events:        &oracle.Events{},
+		}
+	}
+
+	// The modified state is published with the first slot, and copied before serving so
+	// that the handlers never see the empty state of the new oracle
+	_, err := oracleInstance.AdvanceStateToNextSlot(missedBlock(1000))
+	require.NoError(t, err)
+	oracleInstance.Snapshot()
+
+	paths := []string{
+		"/memory/validator/2/history",
+		"/memory/feesinfo",
+		"/memory/allblocks?validator_index=3",
+		"/memory/donations",
+		"/memory/rewardrecipients",
+		"/memory/consolidations",
+		"/memory/claims/" + withdrawalAddress,
+		"/onchain/reconciliation",
+		"/onchain/tree/1150",
+		"/state",
+		"/config",
+	}
+	done := make(chan struct{})
+	errs := make(chan error, len(paths))
+	for _, path := range paths {
+		go func(path string) {
+			for {
+				select {
+				case <-done:
+					errs <- nil
+					return
+				default:
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				// The checkpoint of the tree may not be frozen yet
+				if w.Code != http.StatusOK && !(strings.HasPrefix(path, "/onchain/tree") && w.Code == http.StatusNotFound) {
+					errs <- errors.New(fmt.Sprintf("%s returned %d: %s", path, w.Code, w.Body.String()))
+					return
+				}
+			}
+		}(path)
+	}
+
+	for slot := uint64(1001); slot < 1300; slot++ {
+		block := missedBlock(slot)
+		// The governance changes the fee and the collateral while serving
+		if slot == 1155 {
+			block.Events.UpdatePoolFee = []*contract.ContractUpdatePoolFee{{NewPoolFee: big.NewInt(500)}}
+			block.Events.UpdateSubscriptionCollateral = []*contract.ContractUpdateSubscriptionCollateral{
+				{NewSubscriptionCollateral: big.NewInt(2000)}}
+		}
+		_, err := oracleInstance.AdvanceStateToNextSlot(block)
+		require.NoError(t, err)
+		if slot%10 == 0 {
+			require.True(t, oracleInstance.FreezeCheckpoint())
+			oracleInstance.RecordFailedReconciliation(errors.New("no node"))
+		}
+	}
+	close(done)
+	for range paths {
+		require.NoError(t, <-errs)
+	}
+
+	// Handlers see the processed state
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/memory/validator/1/history", nil))
+	var history httpOkValidatorHistory
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	require.Equal(t, 100, len(history.Blocks))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/onchain/tree/1150", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, oracle.RedCard, oracleInstance.Snapshot().Validators[1].ValidatorStatus)
+	require.Equal(t, uint64(1299), oracleInstance.Snapshot().LatestProcessedSlot)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/config", nil))
+	var served httpOkConfig
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &served))
+	require.Equal(t, 500, served.PoolFeesPercentOver10000)
+	require.Equal(t, "2000", served.CollateralInWei)
+	require.Equal(t, 0, cfg.PoolFeesPercentOver10000)
+}
+
+// Execution client serving the pool contract calls and the ones needed to build a tx
+type fakeExecution struct {
+	rewardsRoot          [32]byte
+	lastConsolidatedSlot uint64
+	claimedBalance       *big.Int
+}
+
+func (f *fakeExecution) Call(args map[string]interface{}, block string) (hexutil.Bytes, error) {
+	contractAbi, err := contract.ContractMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	input, err := hexutil.Decode(args["input"].(string))
+	if err != nil {
+		return nil, err
+	}
+	method, err := contractAbi.MethodById(input[:4])
+	if err != nil {
+		return nil, err
+	}
+	switch method.Name {
+	case "rewardsRoot":
+		return method.Outputs.Pack(f.rewardsRoot)
+	case "lastConsolidatedSlot":
+		return method.Outputs.Pack(f.lastConsolidatedSlot)
+	case "claimedBalance":
+		return method.Outputs.Pack(f.claimedBalance)
+	}
+	return nil, errors.New("unexpected call to " + method.Name)
+}
+
+func (f *fakeExecution) GetTransactionCount(address common.Address, block string) hexutil.Uint64 {
+	return 3
+}
+
+func (f *fakeExecution) EstimateGas(args map[string]interface{}) hexutil.Uint64 {
+	return 90000
+}
+
+func (f *fakeExecution) MaxPriorityFeePerGas() *hexutil.Big {
+	return (*hexutil.Big)(big.NewInt(1e9))
+}
+
+func (f *fakeExecution) GetBlockByNumber(number string, full bool) *types.Header {
+	return &types.Header{Number: big.NewInt(100), Difficulty: big.NewInt(0), BaseFee: big.NewInt(10e9)}
+}
+
+// Onchain with a beacon node finalized at the given slot and the given execution client
+func fakeOnchain(t *testing.T, finalizedSlot uint64, fake *fakeExecution, poolAddress string) *oracle.Onchain {
+	beacon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/eth/v1/node/syncing":
+			fmt.Fprintf(w, `{"data":{"head_slot":"%d","sync_distance":"0","is_syncing":false,"is_optimistic":false,"el_offline":false}}`, finalizedSlot)
+		case "/eth/v1/node/version":
+			fmt.Fprint(w, `{"data":{"version":"fake"}}`)
+		case "/eth/v1/beacon/headers/finalized":
+			root := hexutil.Encode(make([]byte, 32))
+			fmt.Fprintf(w, `{"data":{"root":"%s","canonical":true,"header":{"message":{"slot":"%d","proposer_index":"1",`+
+				`"parent_root":"%s","state_root":"%s","body_root":"%s"},"signature":"%s"}}}`,
+				root, finalizedSlot, root, root, root, hexutil.Encode(make([]byte, 96)))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(beacon.Close)
+	consensusClient, err := eth2http.New(context.Background(),
+		eth2http.WithAddress(beacon.URL),
+		eth2http.WithLogLevel(zerolog.Disabled))
+	require.NoError(t, err)
+
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", fake))
+	executionClient := ethclient.NewClient(rpc.DialInProc(server))
+	t.Cleanup(func() {
+		executionClient.Close()
+		server.Stop()
+	})
+	poolContract, err := contract.NewContract(common.HexToAddress(poolAddress), executionClient)
+	require.NoError(t, err)
+
+	return &oracle.Onchain{
+		ConsensusClient: consensusClient.(*eth2http.Service),
+		ExecutionClient: executionClient,
+		Contract:        poolContract,
+		PoolAddress:     poolAddress,
+		ChainId:         17000,
+	}
+}
+
+func Test_HandleOnchainClaimTx(t *testing.T) {
+	oracle.StateFolder = t.TempDir()
+	cfg := &oracle.Config{
+		Network:               "holesky",
+		PoolAddress:           "0xAdFb8D27671F14f297eE94135e266aAFf8752e35",
+		PoolFeesAddress:       "0x0000000000000000000000000000000000000002",
+		CheckPointSizeInSlots: 100,
+		CollateralInWei:       big.NewInt(1000),
+		DeployedSlot:          1000,
+	}
+	oracleInstance := oracle.NewOracle(cfg)
+	withdrawalAddress := "0x00000000000000000000000000000000000000a1"
+	withRecipient := "0x00000000000000000000000000000000000000a2"
+	recipient := "0x00000000000000000000000000000000000000b2"
+	for valIndex, address := range map[uint64]string{1: withdrawalAddress, 2: withRecipient} {
+		oracleInstance.State().Validators[valIndex] = &oracle.ValidatorInfo{
+			ValidatorStatus:       oracle.Active,
+			AccumulatedRewardsWei: big.NewInt(int64(100 * valIndex)),
+			PendingRewardsWei:     big.NewInt(0),
+			CollateralWei:         big.NewInt(0),
+			WithdrawalAddress:     address,
+			ValidatorIndex:        valIndex,
+		}
+	}
+	oracleInstance.State().RewardRecipients = map[string]string{withRecipient: recipient}
+	require.True(t, oracleInstance.FreezeCheckpoint())
+	checkpoint := oracleInstance.Snapshot().LatestProcessedSlot
+	root, err := hexutil.Decode(oracleInstance.Snapshot().CommitedStates[checkpoint].MerkleRoot)
+	require.NoError(t, err)
+
+	fake := &fakeExecution{lastConsolidatedSlot: checkpoint, claimedBalance: big.NewInt(40)}
+	copy(fake.rewardsRoot[:], root)
+	api := NewApiService(cfg, &config.CliConfig{ApiPort: 7300}, oracleInstance, nil)
+	api.Onchain = fakeOnchain(t, checkpoint+1, fake, cfg.PoolAddress)
+	router := api.getRouter()
+
+	claimTx := func(path string) (int, []byte) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/onchain/claimtx/"+path, nil))
+		return w.Code, w.Body.Bytes()
+	}
+
+	code, body := claimTx(withdrawalAddress)
+	require.Equal(t, http.StatusOK, code, string(body))
+	var response httpOkClaimTx
+	require.NoError(t, json.Unmarshal(body, &response))
+	require.Equal(t, withdrawalAddress, response.WithdrawalAddress)
+	require.Equal(t, "100", response.TotalAccumulatedRewardsWei)
+	require.Equal(t, "40", response.AlreadyClaimedRewardsWei)
+	require.Equal(t, "60", response.ClaimableRewardsWei)
+	require.Equal(t, checkpoint, response.CheckpointSlot)
+	require.Equal(t, common.HexToAddress(cfg.PoolAddress), response.UnsignedTx.To)
+	require.Equal(t, common.HexToAddress(withdrawalAddress), response.UnsignedTx.From)
+	require.Equal(t, big.NewInt(17000), response.UnsignedTx.ChainId.ToInt())
+	require.Equal(t, hexutil.Uint64(3), response.UnsignedTx.Nonce)
+	require.Equal(t, hexutil.Uint64(90000), response.UnsignedTx.Gas)
+	require.Equal(t, big.NewInt(21e9), response.UnsignedTx.MaxFeePerGas.ToInt())
+	require.Equal(t, response.Calldata, response.UnsignedTx.Data.String())
+
+	// Sent by the reward recipient by default, unless another sender is given
+	code, body = claimTx(withRecipient)
+	require.Equal(t, http.StatusOK, code, string(body))
+	response = httpOkClaimTx{}
+	require.NoError(t, json.Unmarshal(body, &response))
+	require.Equal(t, withRecipient, response.WithdrawalAddress)
+	require.Equal(t, recipient, response.RewardRecipient)
+	require.Equal(t, "160", response.ClaimableRewardsWei)
+	require.Equal(t, common.HexToAddress(recipient), response.UnsignedTx.From)
+
+	code, body = claimTx(withRecipient + "?from=" + withdrawalAddress)
+	require.Equal(t, http.StatusOK, code, string(body))
+	response = httpOkClaimTx{}
+	require.NoError(t, json.Unmarshal(body, &response))
+	require.Equal(t, common.HexToAddress(withdrawalAddress), response.UnsignedTx.From)
+
+	// Everything was already claimed
+	fake.claimedBalance = big.NewInt(100)
+	code, body = claimTx(withdrawalAddress)
+	require.Equal(t, http.StatusBadRequest, code)
+	require.Contains(t, string(body), "no rewards to claim")
+}
+
+func Test_HandleMemorySimulate_Errors(t *testing.T) {
+	cfg := &oracle.Config{
+		Network:                  "mainnet",
+		PoolFeesPercentOver10000: 700,
+		CollateralInWei:          big.NewInt(1000),
+		DeployedSlot:             1000,
+	}
+	api := NewApiService(cfg, &config.CliConfig{ApiPort: 7300}, oracle.NewOracle(cfg), nil)
+	router := api.getRouter()
+
+	simulate := func(query string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/memory/simulate?"+query, nil))
+		return w.Code
+	}
+
+	// Invalid params are the caller's fault
+	require.Equal(t, http.StatusBadRequest, simulate("pool_fees_percent=10001"))
+	require.Equal(t, http.StatusBadRequest, simulate("collateral_in_wei=-1"))
+	require.Equal(t, http.StatusBadRequest, simulate("reward_method=unknown"))
+
+	// Valid params that can't be simulated, since nothing was processed yet
+	require.Equal(t, http.StatusInternalServerError, simulate("pool_fees_percent=500"))
+}
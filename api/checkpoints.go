@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/dappnode/mev-sp-oracle/client"
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/dappnode/mev-sp-oracle/oracle"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+// How many of the latest blocks are fetched again on each request, in case they were reorged
+var ConsolidationsReorgDepth = uint64(64)
+
+// Returns the ReportConsolidated events by slot, fetching the ones emitted since the
+// last request. The first request fetches them since the contract was deployed
+func (m *ApiService) consolidationsBySlot() (map[uint64]*contract.ContractReportConsolidated, error) {
+	m.consolidationsMutex.Lock()
+	defer m.consolidationsMutex.Unlock()
+
+	if m.consolidations == nil {
+		m.consolidations = make(map[uint64]*contract.ContractReportConsolidated)
+		m.consolidationsNextBlock = m.cfg.DeployedBlock
+	}
+
+	latestBlock, err := m.Onchain.ExecutionClient.BlockNumber(context.Background())
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get latest block")
+	}
+	if latestBlock < m.consolidationsNextBlock {
+		return m.consolidations, nil
+	}
+	events, err := m.Onchain.GetReportConsolidatedEventsInRange(
+		m.consolidationsNextBlock, latestBlock, m.cliCfg.BlocksPerQuery, apiRetryOpts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get ReportConsolidated events")
+	}
+	for _, event := range events {
+		m.consolidations[event.SlotNumber.Uint64()] = event
+	}
+
+	m.consolidationsNextBlock = latestBlock + 1
+	if latestBlock > m.cfg.DeployedBlock+ConsolidationsReorgDepth {
+		m.consolidationsNextBlock = latestBlock - ConsolidationsReorgDepth
+	}
+	return m.consolidations, nil
+}
+
+func toHttpCheckpoint(summary oracle.CheckpointSummary, consolidation *contract.ContractReportConsolidated) httpOkCheckpoint {
+	checkpoint := httpOkCheckpoint{
+		Slot:                       summary.Slot,
+		MerkleRoot:                 summary.MerkleRoot,
+		NumLeafs:                   summary.Leafs,
+		NumValidators:              summary.Validators,
+		PoolFeesWei:                summary.PoolFeesWei.String(),
+		TotalAccumulatedRewardsWei: summary.TotalAccumulatedWei.String(),
+		TotalPendingRewardsWei:     summary.TotalPendingWei.String(),
+	}
+	if consolidation != nil {
+		checkpoint.Consolidated = true
+		checkpoint.OnchainRoot = hexutil.Encode(consolidation.NewRewardsRoot[:])
+		checkpoint.ConsolidationTxHash = consolidation.Raw.TxHash.String()
+		checkpoint.ConsolidationBlock = consolidation.Raw.BlockNumber
+	}
+	return checkpoint
+}
+
+// Returns the commited states sorted by slot, paged, with the tx that consolidated them onchain
+func (m *ApiService) handleOnchainCheckpoints(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	limit, err := parsePageLimit(query)
+	if err != nil {
+		m.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	summaries, nextCursor, err := m.oracle.QueryCheckpoints(query.Get("cursor"), limit)
+	if err != nil {
+		m.respondError(w, http.StatusBadRequest, "could not query checkpoints: "+err.Error())
+		return
+	}
+	consolidations, err := m.consolidationsBySlot()
+	if err != nil {
+		m.respondError(w, http.StatusInternalServerError, "could not get consolidations: "+err.Error())
+		return
+	}
+
+	page := client.Page[httpOkCheckpoint]{Items: make([]httpOkCheckpoint, 0, len(summaries)), NextCursor: nextCursor}
+	for _, summary := range summaries {
+		page.Items = append(page.Items, toHttpCheckpoint(summary, consolidations[summary.Slot]))
+	}
+	m.respondOK(w, page)
+}
+
+// Returns the commited state at the given slot with all its leafs, in the order of the tree
+func (m *ApiService) handleOnchainCheckpoint(w http.ResponseWriter, req *http.Request) {
+	slotStr := mux.Vars(req)["slot"]
+	slot, err := strconv.ParseUint(slotStr, 10, 64)
+	if err != nil {
+		m.respondError(w, http.StatusBadRequest, "invalid slot: "+slotStr)
+		return
+	}
+	summary, leafs, found := m.oracle.Checkpoint(slot)
+	if !found {
+		m.respondError(w, http.StatusNotFound, "no checkpoint at slot: "+slotStr)
+		return
+	}
+	consolidations, err := m.consolidationsBySlot()
+	if err != nil {
+		m.respondError(w, http.StatusInternalServerError, "could not get consolidations: "+err.Error())
+		return
+	}
+
+	response := httpOkCheckpointLeafs{
+		Checkpoint: toHttpCheckpoint(summary, consolidations[slot]),
+		Leafs:      make([]httpOkLeaf, 0, len(leafs)),
+	}
+	for _, leaf := range leafs {
+		response.Leafs = append(response.Leafs, httpOkLeaf{
+			WithdrawalAddress:     leaf.WithdrawalAddress,
+			AccumulatedBalanceWei: leaf.AccumulatedBalanceWei.String(),
+		})
+	}
+	m.respondOK(w, response)
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dappnode/mev-sp-oracle/oracle"
+	"github.com/pkg/errors"
+)
+
+// How often a comment is sent to keep idle event streams open
+var EventsKeepAlive = 15 * time.Second
+
+// Filters of the event stream. Events of the whole pool, without validator nor address,
+// are always sent unless filtered by type
+type eventFilter struct {
+	types             map[oracle.StreamEventType]bool
+	validatorIndex    *uint64
+	withdrawalAddress string
+}
+
+func parseEventFilter(query url.Values) (eventFilter, error) {
+	filter := eventFilter{types: make(map[oracle.StreamEventType]bool)}
+	if types := query.Get("types"); types != "" {
+		for _, eventType := range strings.Split(types, ",") {
+			known := false
+			for _, candidate := range oracle.AllStreamEventTypes {
+				known = known || candidate == oracle.StreamEventType(eventType)
+			}
+			if !known {
+				return filter, errors.New("invalid event type: " + eventType)
+			}
+			filter.types[oracle.StreamEventType(eventType)] = true
+		}
+	}
+	if valIndexStr := query.Get("validator_index"); valIndexStr != "" {
+		valIndex, ok := IsValidIndex(valIndexStr)
+		if !ok {
+			return filter, errors.New("invalid validator_index: " + valIndexStr)
+		}
+		filter.validatorIndex = &valIndex
+	}
+	if withdrawalAddress := query.Get("withdrawal_address"); withdrawalAddress != "" {
+		if !IsValidAddress(withdrawalAddress) {
+			return filter, errors.New("invalid withdrawal_address: " + withdrawalAddress)
+		}
+		filter.withdrawalAddress = strings.ToLower(withdrawalAddress)
+	}
+	return filter, nil
+}
+
+func (f eventFilter) matches(event oracle.StreamEvent) bool {
+	if len(f.types) != 0 && !f.types[event.Type] {
+		return false
+	}
+	if event.ValidatorIndex == nil && event.WithdrawalAddress == "" {
+		return true
+	}
+	if f.validatorIndex != nil && (event.ValidatorIndex == nil || *event.ValidatorIndex != *f.validatorIndex) {
+		return false
+	}
+	if f.withdrawalAddress != "" && event.WithdrawalAddress != f.withdrawalAddress {
+		return false
+	}
+	return true
+}
+
+// Streams the events of the oracle as Server-Sent Events. Each one has its resume token as
+// id, so clients reconnecting with it (as resume or Last-Event-ID) get the ones they missed
+func (m *ApiService) handleEvents(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	filter, err := parseEventFilter(query)
+	if err != nil {
+		m.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	resumeToken := query.Get("resume")
+	if resumeToken == "" {
+		resumeToken = req.Header.Get("Last-Event-ID")
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		m.respondError(w, http.StatusInternalServerError, "streaming is not supported")
+		return
+	}
+
+	stream := m.oracle.Events()
+	backlog, subscription, err := stream.Subscribe(resumeToken)
+	if err == oracle.ErrResumeExpired {
+		m.respondError(w, http.StatusGone, err.Error())
+		return
+	}
+	if err != nil {
+		m.respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer stream.Unsubscribe(subscription)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event oracle.StreamEvent) error {
+		if !filter.matches(event) {
+			return nil
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data)
+		return err
+	}
+	for _, event := range backlog {
+		if send(event) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(EventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		// Closed if this client was too slow, it can resume from the last event it got
+		case event, open := <-subscription.Events:
+			if !open || send(event) != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/dappnode/mev-sp-oracle/oracle"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// How often the head indexer polls the chain, how many of its latest indexed blocks are
+// fetched again on each poll in case they were reorged, and how far behind the head the
+// oracle can be for the indexer to follow it. Further behind the oracle is still syncing
+var HeadIndexerInterval = 12 * time.Second
+var HeadIndexerReorgDepth = uint64(64)
+var HeadIndexerMaxBlocks = uint64(1000)
+
+// Returns the latest block of the chain
+type LatestBlockFunc func() (uint64, error)
+
+// Returns the events emitted from startBlock to endBlock, both included
+type GetSubscriptionsInRangeFunc func(startBlock uint64, endBlock uint64) ([]*contract.ContractSubscribeValidator, error)
+type GetUnsubscriptionsInRangeFunc func(startBlock uint64, endBlock uint64) ([]*contract.ContractUnsubscribeValidator, error)
+
+// Follows the head of the chain in the background, keeping in memory the subscriptions and
+// unsubscriptions of the blocks the oracle has not processed yet. The ones in blocks the
+// oracle has processed are dropped, since they are already in its finalized state
+type HeadIndexer struct {
+	latestBlock          LatestBlockFunc
+	latestProcessedBlock func() uint64
+	getSubscriptions     GetSubscriptionsInRangeFunc
+	getUnsubscriptions   GetUnsubscriptionsInRangeFunc
+
+	mutex           sync.RWMutex
+	subscriptions   []*contract.ContractSubscribeValidator
+	unsubscriptions []*contract.ContractUnsubscribeValidator
+	// Indexed blocks, from fromBlock to toBlock both included. Nothing is indexed
+	// until the first update succeeds
+	fromBlock uint64
+	toBlock   uint64
+	indexed   bool
+}
+
+func NewHeadIndexer(
+	latestBlock LatestBlockFunc,
+	latestProcessedBlock func() uint64,
+	getSubscriptions GetSubscriptionsInRangeFunc,
+	getUnsubscriptions GetUnsubscriptionsInRangeFunc) *HeadIndexer {
+
+	return &HeadIndexer{
+		latestBlock:          latestBlock,
+		latestProcessedBlock: latestProcessedBlock,
+		getSubscriptions:     getSubscriptions,
+		getUnsubscriptions:   getUnsubscriptions,
+	}
+}
+
+// Indexes the events of the smoothing pool contract above the blocks processed by the oracle
+func NewOnchainHeadIndexer(onchain *oracle.Onchain, oracleInstance *oracle.Oracle) *HeadIndexer {
+	return NewHeadIndexer(
+		func() (uint64, error) {
+			return onchain.ExecutionClient.BlockNumber(context.Background())
+		},
+		func() uint64 {
+			return oracleInstance.Snapshot().LatestProcessedBlock
+		},
+		func(startBlock uint64, endBlock uint64) ([]*contract.ContractSubscribeValidator, error) {
+			return onchain.GetSubscribeValidatorEventsInRange(startBlock, endBlock, apiRetryOpts...)
+		},
+		func(startBlock uint64, endBlock uint64) ([]*contract.ContractUnsubscribeValidator, error) {
+			return onchain.GetUnsubscribeValidatorEventsInRange(startBlock, endBlock, apiRetryOpts...)
+		})
+}
+
+// Updates the indexer every HeadIndexerInterval until the context is done
+func (h *HeadIndexer) Run(ctx context.Context) {
+	ticker := time.NewTicker(HeadIndexerInterval)
+	defer ticker.Stop()
+	for {
+		if err := h.Update(); err != nil {
+			log.WithError(err).Warn("Could not update the head indexer")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Fetches the events from the last indexed blocks up to the head, fetching again the latest
+// HeadIndexerReorgDepth ones, and drops the events of the blocks processed by the oracle
+func (h *HeadIndexer) Update() error {
+	processedBlock := h.latestProcessedBlock()
+	if processedBlock == 0 {
+		return nil
+	}
+	headBlock, err := h.latestBlock()
+	if err != nil {
+		return errors.Wrap(err, "could not get latest block")
+	}
+	if headBlock > processedBlock+HeadIndexerMaxBlocks {
+		log.WithFields(log.Fields{
+			"LatestProcessedBlock": processedBlock,
+			"HeadBlock":            headBlock,
+		}).Debug("Oracle is syncing, not indexing the head yet")
+		h.reset()
+		return nil
+	}
+
+	h.mutex.RLock()
+	startBlock := processedBlock + 1
+	if h.indexed && h.toBlock+1 > startBlock+HeadIndexerReorgDepth {
+		startBlock = h.toBlock + 1 - HeadIndexerReorgDepth
+	}
+	h.mutex.RUnlock()
+
+	subscriptions := make([]*contract.ContractSubscribeValidator, 0)
+	unsubscriptions := make([]*contract.ContractUnsubscribeValidator, 0)
+	if startBlock <= headBlock {
+		subscriptions, err = h.getSubscriptions(startBlock, headBlock)
+		if err != nil {
+			return errors.Wrap(err, "could not get subscriptions")
+		}
+		unsubscriptions, err = h.getUnsubscriptions(startBlock, headBlock)
+		if err != nil {
+			return errors.Wrap(err, "could not get unsubscriptions")
+		}
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	// Keeps the events not processed by the oracle and not fetched again
+	kept := make([]*contract.ContractSubscribeValidator, 0)
+	for _, sub := range h.subscriptions {
+		if sub.Raw.BlockNumber > processedBlock && sub.Raw.BlockNumber < startBlock {
+			kept = append(kept, sub)
+		}
+	}
+	h.subscriptions = append(kept, subscriptions...)
+
+	keptUnsubs := make([]*contract.ContractUnsubscribeValidator, 0)
+	for _, unsub := range h.unsubscriptions {
+		if unsub.Raw.BlockNumber > processedBlock && unsub.Raw.BlockNumber < startBlock {
+			keptUnsubs = append(keptUnsubs, unsub)
+		}
+	}
+	h.unsubscriptions = append(keptUnsubs, unsubscriptions...)
+
+	h.fromBlock = processedBlock + 1
+	h.toBlock = headBlock
+	h.indexed = true
+	return nil
+}
+
+func (h *HeadIndexer) reset() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.subscriptions = nil
+	h.unsubscriptions = nil
+	h.indexed = false
+}
+
+// Returns the subscriptions and unsubscriptions after the given block, as of the latest
+// update. False if the indexed blocks do not cover all the blocks after it
+func (h *HeadIndexer) EventsAfter(block uint64) (
+	[]*contract.ContractSubscribeValidator,
+	[]*contract.ContractUnsubscribeValidator,
+	bool) {
+
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if !h.indexed || h.fromBlock > block+1 {
+		return nil, nil, false
+	}
+	subscriptions := make([]*contract.ContractSubscribeValidator, 0)
+	for _, sub := range h.subscriptions {
+		if sub.Raw.BlockNumber > block {
+			subscriptions = append(subscriptions, sub)
+		}
+	}
+	unsubscriptions := make([]*contract.ContractUnsubscribeValidator, 0)
+	for _, unsub := range h.unsubscriptions {
+		if unsub.Raw.BlockNumber > block {
+			unsubscriptions = append(unsubscriptions, unsub)
+		}
+	}
+	return subscriptions, unsubscriptions, true
+}
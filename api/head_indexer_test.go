@@ -0,0 +1,135 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// Chain with a subscription and an unsubscription of the validator with the same
+// index as the block, for the blocks set in it
+type fakeHeadChain struct {
+	head      uint64
+	processed uint64
+	blocks    map[uint64]bool
+	fetched   [][2]uint64
+	err       error
+}
+
+func (c *fakeHeadChain) indexer() *HeadIndexer {
+	return NewHeadIndexer(
+		func() (uint64, error) { return c.head, c.err },
+		func() uint64 { return c.processed },
+		func(startBlock uint64, endBlock uint64) ([]*contract.ContractSubscribeValidator, error) {
+			c.fetched = append(c.fetched, [2]uint64{startBlock, endBlock})
+			subs := make([]*contract.ContractSubscribeValidator, 0)
+			for block := startBlock; block <= endBlock; block++ {
+				if c.blocks[block] {
+					subs = append(subs, &contract.ContractSubscribeValidator{
+						ValidatorID: block,
+						Raw:         types.Log{BlockNumber: block},
+					})
+				}
+			}
+			return subs, nil
+		},
+		func(startBlock uint64, endBlock uint64) ([]*contract.ContractUnsubscribeValidator, error) {
+			unsubs := make([]*contract.ContractUnsubscribeValidator, 0)
+			for block := startBlock; block <= endBlock; block++ {
+				if c.blocks[block] {
+					unsubs = append(unsubs, &contract.ContractUnsubscribeValidator{
+						ValidatorID: block,
+						Raw:         types.Log{BlockNumber: block},
+					})
+				}
+			}
+			return unsubs, nil
+		})
+}
+
+func subscribedBlocks(subs []*contract.ContractSubscribeValidator) []uint64 {
+	blocks := make([]uint64, 0)
+	for _, sub := range subs {
+		blocks = append(blocks, sub.Raw.BlockNumber)
+	}
+	return blocks
+}
+
+func Test_HeadIndexer(t *testing.T) {
+	defaultDepth := HeadIndexerReorgDepth
+	defer func() { HeadIndexerReorgDepth = defaultDepth }()
+	HeadIndexerReorgDepth = 10
+
+	chain := &fakeHeadChain{head: 1100, processed: 1000, blocks: map[uint64]bool{990: true, 1050: true, 1095: true}}
+	indexer := chain.indexer()
+
+	// Nothing is served until the first update
+	_, _, indexed := indexer.EventsAfter(1000)
+	require.False(t, indexed)
+
+	// Indexes the blocks above the processed one
+	require.NoError(t, indexer.Update())
+	require.Equal(t, [][2]uint64{{1001, 1100}}, chain.fetched)
+	subs, unsubs, indexed := indexer.EventsAfter(1000)
+	require.True(t, indexed)
+	require.Equal(t, []uint64{1050, 1095}, subscribedBlocks(subs))
+	require.Len(t, unsubs, 2)
+
+	// The latest blocks are fetched again, so a reorged event is replaced
+	delete(chain.blocks, 1095)
+	chain.blocks[1096] = true
+	chain.blocks[1120] = true
+	chain.head = 1130
+	require.NoError(t, indexer.Update())
+	require.Equal(t, [2]uint64{1091, 1130}, chain.fetched[1])
+	subs, _, _ = indexer.EventsAfter(1000)
+	require.Equal(t, []uint64{1050, 1096, 1120}, subscribedBlocks(subs))
+
+	// Events processed by the oracle are dropped
+	chain.processed = 1060
+	require.NoError(t, indexer.Update())
+	subs, unsubs, indexed = indexer.EventsAfter(1060)
+	require.True(t, indexed)
+	require.Equal(t, []uint64{1096, 1120}, subscribedBlocks(subs))
+	require.Len(t, unsubs, 2)
+	require.Len(t, indexer.subscriptions, 2)
+
+	// So they can no longer be served for an older processed block
+	_, _, indexed = indexer.EventsAfter(1000)
+	require.False(t, indexed)
+
+	// A failed update keeps the indexed events
+	chain.err = errors.New("node down")
+	require.Error(t, indexer.Update())
+	subs, _, indexed = indexer.EventsAfter(1100)
+	require.True(t, indexed)
+	require.Equal(t, []uint64{1120}, subscribedBlocks(subs))
+}
+
+func Test_HeadIndexer_Syncing(t *testing.T) {
+	chain := &fakeHeadChain{head: 1100, processed: 1000, blocks: map[uint64]bool{1050: true}}
+	indexer := chain.indexer()
+	require.NoError(t, indexer.Update())
+
+	// Too far behind the head the oracle is still syncing, so nothing is fetched
+	chain.head = 1000 + HeadIndexerMaxBlocks + 1
+	require.NoError(t, indexer.Update())
+	_, _, indexed := indexer.EventsAfter(1000)
+	require.False(t, indexed)
+	require.Len(t, chain.fetched, 1)
+
+	// Nor before the oracle processed any block
+	chain.processed = 0
+	require.NoError(t, indexer.Update())
+	require.Len(t, chain.fetched, 1)
+}
+
+func Test_NonFinalizedEvents_NotIndexed(t *testing.T) {
+	// Without head indexer the non finalized state can not be served
+	api := &ApiService{}
+	_, _, indexed := api.nonFinalizedEvents(1000)
+	require.False(t, indexed)
+}
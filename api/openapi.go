@@ -0,0 +1,303 @@
+package api
+
+import (
+	"encoding"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/dappnode/mev-sp-oracle/client"
+	"github.com/dappnode/mev-sp-oracle/oracle"
+)
+
+// Version of the api in its OpenAPI specification
+var ApiVersion = "1.0.0"
+
+const pathOpenApi = "/openapi.json"
+
+type queryParam struct {
+	name        string
+	description string
+}
+
+// Endpoint of the api. The router and the OpenAPI specification are both built from them,
+// and operation is the name of the method of the client calling it
+type route struct {
+	path      string
+	handler   http.HandlerFunc
+	operation string
+	summary   string
+	query     []queryParam
+	// Value of the type encoded in the response, nil if it has no defined schema
+	response any
+}
+
+// Response of the routes streaming events of the type of event as Server-Sent Events
+type eventStream struct {
+	event any
+}
+
+var pageParams = []queryParam{
+	{"cursor", "next_cursor of the previous page"},
+	{"limit", "results per page"},
+}
+
+var viewParams = []queryParam{
+	{"view", "finalized by default, or head to preview the state at the head of the chain"},
+}
+
+var blockParams = append([]queryParam{
+	{"from_slot", "first slot, inclusive"},
+	{"to_slot", "last slot, inclusive"},
+	{"validator_index", "index of the validator"},
+	{"withdrawal_address", "withdrawal address of the validator"},
+	{"block_type", "okpoolproposal, okpoolproposalblskeys, missedproposal or wrongfeerecipient"},
+	{"reward_type", "vanila or mev"},
+	{"min_reward_wei", "minimum reward"},
+}, pageParams...)
+
+var donationParams = append([]queryParam{
+	{"from_block", "first block, inclusive"},
+	{"to_block", "last block, inclusive"},
+	{"sender", "address that sent the donation"},
+	{"min_amount_wei", "minimum amount"},
+}, pageParams...)
+
+func (m *ApiService) routes() []route {
+	return []route{
+		// General endpoints
+		{pathStatus, m.handleStatus, "Status", "Sync status of the oracle and its checkpoints", nil, client.Status{}},
+		{pathConfig, m.handleConfig, "Config", "Configuration of the pool", nil, client.Config{}},
+		{pathValidatorRelayers, m.handleValidatorRelayers, "ValidatorRelayers", "Fee recipient registered in each relay by the validator", nil, client.RelayersState{}},
+		{pathWithdrawalRelayers, m.handleWithdrawalRelayers, "WithdrawalRelayers", "Fee recipient registered in each relay by each validator of the withdrawal address", nil, []client.ValidatorRelayersState{}},
+		{pathState, m.handleState, "State", "Whole state of the oracle, for debugging", nil, nil},
+		{pathOpenApi, m.handleOpenApi, "OpenApi", "This specification", nil, nil},
+
+		// Memory endpoints
+		{pathMemoryValidators, m.handleMemoryValidators, "Validators", "Validators tracked by the pool", viewParams, []client.ValidatorInfo{}},
+		{pathMemoryValidatorByIndex, m.handleMemoryValidatorInfo, "Validator", "Validator as stored by the oracle", viewParams, oracle.ValidatorInfo{}},
+		{pathMemoryValidatorHistory, m.handleMemoryValidatorHistory, "ValidatorHistory", "Status transitions, blocks and rewards of the validator", nil, client.ValidatorHistory{}},
+		{pathMemoryValidatorsByIndex, m.handleMemoryValidatorsByIndex, "ValidatorsByIndex", "Validators of the comma separated indexes", viewParams, client.ValidatorsByIndex{}},
+		{pathMemoryValidatorsByWithdrawal, m.handleMemoryValidatorsByWithdrawal, "ValidatorsByWithdrawal", "Validators of the withdrawal address or reward recipient", viewParams, []client.ValidatorInfo{}},
+		{pathMemoryFeesInfo, m.handleMemoryFeesInfo, "FeesInfo", "Fees taken by the pool", viewParams, client.FeesInfo{}},
+		{pathMemoryPoolStatistics, m.handleMemoryStatistics, "Statistics", "Statistics of the pool", viewParams, client.MemoryStatistics{}},
+		{pathMemoryAllBlocks, m.handleMemoryAllBlocks, "AllBlocks", "Proposed, missed and wrong fee blocks", blockParams, client.Page[client.Block]{}},
+		{pathMemoryProposedBlocks, m.handleMemoryProposedBlocks, "ProposedBlocks", "Blocks proposed to the pool", blockParams, client.Page[client.Block]{}},
+		{pathMemoryMissedBlocks, m.handleMemoryMissedBlocks, "MissedBlocks", "Blocks missed by validators of the pool", blockParams, client.Page[client.Block]{}},
+		{pathMemoryWrongFeeBlocks, m.handleMemoryWrongFeeBlocks, "WrongFeeBlocks", "Blocks of validators of the pool with a wrong fee recipient", blockParams, client.Page[client.Block]{}},
+		{pathMemoryDonations, m.handleMemoryDonations, "Donations", "Donations to the pool", donationParams, client.Page[client.Donation]{}},
+		{pathMemorySimulate, m.handleMemorySimulate, "Simulate", "Rewards replayed with a different config", []queryParam{
+			{"pool_fees_percent", "pool fee over 10000"},
+			{"collateral_in_wei", "collateral to subscribe"},
+			{"reward_method", "pre_fork1, fork1 or electra"},
+		}, client.Simulation{}},
+		{pathMemoryRewardRecipients, m.handleMemoryRewardRecipients, "RewardRecipients", "Reward recipients and their history", nil, client.RewardRecipients{}},
+		{pathMemoryRewardRecipientsOf, m.handleMemoryRewardRecipients, "RewardRecipientsOf", "Reward recipients where the address is the withdrawal address or the recipient", nil, client.RewardRecipients{}},
+		{pathMemoryClaims, m.handleMemoryClaims, "Claims", "Claims of the address", nil, client.Claims{}},
+		{pathMemoryConsolidations, m.handleMemoryConsolidations, "Consolidations", "Consolidations of tracked validators", nil, client.Consolidations{}},
+		{pathMemoryConsolidationsOf, m.handleMemoryConsolidations, "ConsolidationsOf", "Consolidations where the validator is the source or the target", nil, client.Consolidations{}},
+
+		// Onchain endpoints
+		{pathOnchainMerkleProof, m.handleOnchainMerkleProof, "Proof", "Merkle proof to claim the rewards of the address", []queryParam{
+			{"slot", "slot of the checkpoint, the latest one by default"},
+		}, client.Proofs{}},
+		{pathOnchainReconciliation, m.handleOnchainReconciliation, "Reconciliation", "Reconciliation reports of the pool balance, most recent first", nil, client.Reconciliation{}},
+		{pathOnchainVerify, m.handleOnchainVerify, "Verify", "Verifies a merkle proof as the contract does", []queryParam{
+			{"address", "withdrawal address of the leaf"},
+			{"amount", "accumulated balance of the leaf in wei"},
+			{"proof", "comma separated proof"},
+			{"root", "merkle root, the onchain one by default"},
+		}, client.VerifyProof{}},
+		{pathOnchainTree, m.handleOnchainTree, "Tree", "Merkle tree of the checkpoint with the proof of each leaf", nil, oracle.StandardTreeDump{}},
+		{pathOnchainClaimTx, m.handleOnchainClaimTx, "ClaimTx", "Calldata and unsigned tx claiming the rewards of the address", []queryParam{
+			{"from", "sender of the tx, the reward recipient by default"},
+		}, client.ClaimTx{}},
+		{pathOnchainCheckpoints, m.handleOnchainCheckpoints, "Checkpoints", "Checkpoints sorted by slot", pageParams, client.Page[client.Checkpoint]{}},
+		{pathOnchainCheckpoint, m.handleOnchainCheckpoint, "Checkpoint", "Checkpoint with all its leafs", nil, client.CheckpointLeafs{}},
+
+		// Stream of events
+		{pathEvents, m.handleEvents, "Events", "Server-Sent Events of the oracle as they happen", []queryParam{
+			{"types", "comma separated types of events"},
+			{"validator_index", "only events of this validator, and the ones of the whole pool"},
+			{"withdrawal_address", "only events of this withdrawal address, and the ones of the whole pool"},
+			{"resume", "id of the last event received, to get the ones after it. Also read from Last-Event-ID"},
+		}, eventStream{client.StreamEvent{}}},
+	}
+}
+
+func (m *ApiService) handleOpenApi(w http.ResponseWriter, req *http.Request) {
+	m.respondOK(w, OpenApiSpec())
+}
+
+var pathParamRegex = regexp.MustCompile(`{([^}]+)}`)
+
+// Returns the OpenAPI 3 specification of the api, built from its routes and response types
+func OpenApiSpec() map[string]any {
+	schemas := newSchemaBuilder()
+	errorResponse := map[string]any{
+		"description": "Error",
+		"content":     map[string]any{"application/json": map[string]any{"schema": schemas.schema(reflect.TypeOf(client.Error{}))}},
+	}
+
+	paths := make(map[string]any)
+	for _, route := range (&ApiService{}).routes() {
+		params := make([]any, 0)
+		for _, match := range pathParamRegex.FindAllStringSubmatch(route.path, -1) {
+			params = append(params, map[string]any{
+				"name": match[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+			})
+		}
+		for _, param := range route.query {
+			params = append(params, map[string]any{
+				"name": param.name, "in": "query", "description": param.description, "schema": map[string]any{"type": "string"},
+			})
+		}
+
+		contentType := "application/json"
+		schema := map[string]any{"type": "object"}
+		if stream, ok := route.response.(eventStream); ok {
+			contentType = "text/event-stream"
+			schema = schemas.schema(reflect.TypeOf(stream.event))
+		} else if route.response != nil {
+			schema = schemas.schema(reflect.TypeOf(route.response))
+		}
+		paths[route.path] = map[string]any{
+			"get": map[string]any{
+				"operationId": route.operation,
+				"summary":     route.summary,
+				"parameters":  params,
+				"responses": map[string]any{
+					"200":     map[string]any{"description": "OK", "content": map[string]any{contentType: map[string]any{"schema": schema}}},
+					"default": errorResponse,
+				},
+			},
+		}
+	}
+
+	return map[string]any{
+		"openapi":    "3.0.3",
+		"info":       map[string]any{"title": "Smoothing pool oracle api", "version": ApiVersion},
+		"paths":      paths,
+		"components": map[string]any{"schemas": schemas.components},
+	}
+}
+
+// Builds the json schemas of go types as they are encoded. Structs are added to the
+// components and referenced by name
+type schemaBuilder struct {
+	components map[string]any
+	types      map[string]reflect.Type
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{
+		components: make(map[string]any),
+		types:      make(map[string]reflect.Type),
+	}
+}
+
+var (
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	bigIntType    = reflect.TypeOf(big.Int{})
+	rawJsonType   = reflect.TypeOf(json.RawMessage{})
+)
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == bigIntType {
+		return map[string]any{"type": "integer"}
+	}
+	if t == rawJsonType {
+		return map[string]any{}
+	}
+	// Enums, addresses and hex values are encoded as strings
+	ptr := reflect.PointerTo(t)
+	if ptr.Implements(jsonMarshaler) || ptr.Implements(textMarshaler) {
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		name := b.componentName(t)
+		if _, found := b.components[name]; !found {
+			// Set before building it, in case its recursive
+			b.components[name] = nil
+			properties, required := b.properties(t)
+			component := map[string]any{"type": "object", "properties": properties}
+			if len(required) != 0 {
+				component["required"] = required
+			}
+			b.components[name] = component
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+// Properties of a struct and the ones that are always present, as encoding/json does
+func (b *schemaBuilder) properties(t reflect.Type) (map[string]any, []string) {
+	properties := make(map[string]any)
+	required := make([]string, 0)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded, embeddedRequired := b.properties(field.Type)
+			for property, schema := range embedded {
+				properties[property] = schema
+			}
+			required = append(required, embeddedRequired...)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = b.schema(field.Type)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	return properties, required
+}
+
+// Name of the type, or of its package and type if another type has it. Generic pages
+// are named after their items
+func (b *schemaBuilder) componentName(t reflect.Type) string {
+	name := t.Name()
+	if base, arg, generic := strings.Cut(name, "["); generic {
+		arg = strings.TrimSuffix(arg, "]")
+		name = arg[strings.LastIndex(arg, ".")+1:] + base
+	}
+	if other, found := b.types[name]; found && other != t {
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	b.types[name] = t
+	return name
+}
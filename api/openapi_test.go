@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/dappnode/mev-sp-oracle/client"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+// Regenerates api/openapi.json: go test ./api -run Test_OpenApiSpec -update
+var updateSpec = flag.Bool("update", false, "update api/openapi.json")
+
+const specFile = "openapi.json"
+
+func Test_OpenApiSpec(t *testing.T) {
+	generated, err := json.MarshalIndent(OpenApiSpec(), "", "  ")
+	require.NoError(t, err)
+	generated = append(generated, '\n')
+	if *updateSpec {
+		require.NoError(t, os.WriteFile(specFile, generated, 0644))
+	}
+
+	committed, err := os.ReadFile(specFile)
+	require.NoError(t, err)
+	require.Equal(t, string(committed), string(generated),
+		"api/openapi.json is outdated, regenerate it with: go test ./api -run Test_OpenApiSpec -update")
+}
+
+func Test_OpenApiRoutes(t *testing.T) {
+	committed, err := os.ReadFile(specFile)
+	require.NoError(t, err)
+	var spec struct {
+		Paths map[string]any `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(committed, &spec))
+
+	// Every route of the router is in the specification, and the other way around
+	routes := make([]string, 0)
+	router := (&ApiService{}).getRouter().(*mux.Router)
+	require.NoError(t, router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err == nil && path != "/" {
+			routes = append(routes, path)
+		}
+		return nil
+	}))
+	specPaths := make([]string, 0)
+	for path := range spec.Paths {
+		specPaths = append(specPaths, path)
+	}
+	sort.Strings(routes)
+	sort.Strings(specPaths)
+	require.Equal(t, specPaths, routes)
+
+	// And its served
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, string(committed), w.Body.String())
+}
+
+func Test_OpenApiClient(t *testing.T) {
+	clientType := reflect.TypeOf(&client.Client{})
+	rawMessage := reflect.TypeOf(json.RawMessage{})
+	routes := (&ApiService{}).routes()
+
+	// Each operation has a method in the client decoding its response
+	for _, route := range routes {
+		method, found := clientType.MethodByName(route.operation)
+		require.True(t, found, "client has no method for operation %s", route.operation)
+		returned := method.Type.Out(0)
+		response := route.response
+		// Streams call back with each event
+		if stream, ok := response.(eventStream); ok {
+			response = stream.event
+			returned = method.Type.In(method.Type.NumIn() - 1).In(0)
+		}
+		if response == nil {
+			require.Equal(t, rawMessage, returned, route.operation)
+			continue
+		}
+
+		server := newSchemaBuilder()
+		serverSchema := expandSchema(server.schema(reflect.TypeOf(response)), server.components)
+		decoded := newSchemaBuilder()
+		clientSchema := expandSchema(decoded.schema(returned), decoded.components)
+		require.Equal(t, serverSchema, clientSchema, "response of %s differs in the client", route.operation)
+	}
+	require.Equal(t, len(routes), clientType.NumMethod())
+}
+
+// Replaces the references to the components with their schemas
+func expandSchema(schema any, components map[string]any) any {
+	switch value := schema.(type) {
+	case map[string]any:
+		if ref, found := value["$ref"]; found {
+			name := strings.TrimPrefix(ref.(string), "#/components/schemas/")
+			return expandSchema(components[name], components)
+		}
+		expanded := make(map[string]any)
+		for key, inner := range value {
+			expanded[key] = expandSchema(inner, components)
+		}
+		return expanded
+	}
+	return schema
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	builderApiV1 "github.com/attestantio/go-builder-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/dappnode/mev-sp-oracle/oracle"
+	"github.com/pkg/errors"
+	blst "github.com/supranational/blst/bindings/go"
+)
+
+// Timeout of each call to a relay, for how long its answer is cached, how many answers
+// are cached before dropping the expired ones, and how many calls to the relays can be
+// in flight at once
+var RelayTimeout = 5 * time.Second
+var RelayCacheTTL = 5 * time.Minute
+var RelayMaxCachedRegistrations = 10000
+var RelayMaxConcurrentCalls = 32
+
+// Maximum validators of a withdrawal address checked at once in the relays
+var MaxRelayersValidators = 100
+
+// Result of verifying the signature of a registration. Its unverified if the fork version
+// of the network is not known
+const (
+	SignatureValid      = "valid"
+	SignatureInvalid    = "invalid_signature"
+	SignatureUnverified = "unverified"
+)
+
+// Domain type of the builder api, whose messages are signed with the genesis fork version
+var DomainApplicationBuilder = phase0.DomainType{0x00, 0x00, 0x00, 0x01}
+
+// Genesis fork version of each network
+var GenesisForkVersions = map[string]phase0.Version{
+	oracle.Mainnet: {0x00, 0x00, 0x00, 0x00},
+	oracle.Goerli:  {0x00, 0x00, 0x10, 0x20},
+	oracle.Holesky: {0x01, 0x01, 0x70, 0x00},
+	oracle.Hoodi:   {0x10, 0x00, 0x09, 0x10},
+}
+
+// Ciphersuite of the consensus layer signatures, with pubkeys in G1 and signatures in G2
+var blsDst = []byte("BLS_SIG_BLS12381G2_XMD:SHA-256_SSWU_RO_POP_")
+
+// Registration of a validator in a relay. Registration is nil if the validator is not
+// registered, and Err is set if the relay could not be reached or answered with an error.
+// Signature tells if the registration was signed by the validator
+type RelayRegistration struct {
+	Relay        string
+	Registration *builderApiV1.SignedValidatorRegistration
+	Signature    string
+	Err          error
+}
+
+type cachedRegistration struct {
+	registration *builderApiV1.SignedValidatorRegistration
+	signature    string
+	expires      time.Time
+}
+
+// Checks the registrations of validators in a set of relays, calling them concurrently.
+// Answers are cached for RelayCacheTTL, except errors so that the relay is asked again
+type RelayChecker struct {
+	relays     []string
+	network    string
+	httpClient *http.Client
+	calls      chan struct{}
+
+	mutex sync.Mutex
+	cache map[string]cachedRegistration
+}
+
+// Checks the relays of the network, whose fork version the registrations are verified with
+func NewRelayChecker(relays []string, network string) *RelayChecker {
+	return &RelayChecker{
+		relays:     relays,
+		network:    network,
+		httpClient: &http.Client{Timeout: RelayTimeout},
+		calls:      make(chan struct{}, RelayMaxConcurrentCalls),
+		cache:      make(map[string]cachedRegistration),
+	}
+}
+
+// Returns the registration of the validator in each relay, in the order of the relays
+func (c *RelayChecker) Check(pubkey string) []RelayRegistration {
+	pubkey = strings.ToLower(pubkey)
+	registrations := make([]RelayRegistration, len(c.relays))
+	var wg sync.WaitGroup
+	for i, relay := range c.relays {
+		wg.Add(1)
+		go func(i int, relay string) {
+			defer wg.Done()
+			registration, signature, err := c.registration(relay, pubkey)
+			registrations[i] = RelayRegistration{Relay: relay, Registration: registration, Signature: signature, Err: err}
+		}(i, relay)
+	}
+	wg.Wait()
+	return registrations
+}
+
+// Returns the registration of the validator in the relay and the result of verifying its
+// signature, from the cache if not expired
+func (c *RelayChecker) registration(relay string, pubkey string) (*builderApiV1.SignedValidatorRegistration, string, error) {
+	key := relay + "/" + pubkey
+	c.mutex.Lock()
+	cached, found := c.cache[key]
+	c.mutex.Unlock()
+	if found && time.Now().Before(cached.expires) {
+		return cached.registration, cached.signature, nil
+	}
+
+	c.calls <- struct{}{}
+	registration, err := c.fetchRegistration(relay, pubkey)
+	<-c.calls
+	if err != nil {
+		return nil, "", err
+	}
+	signature := ""
+	if registration != nil {
+		signature = VerifyRegistration(registration, pubkey, c.network)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cache[key] = cachedRegistration{registration: registration, signature: signature, expires: time.Now().Add(RelayCacheTTL)}
+	// Drops the expired ones, so that the cache does not grow with every validator ever checked
+	if len(c.cache) > RelayMaxCachedRegistrations {
+		for key, cached := range c.cache {
+			if time.Now().After(cached.expires) {
+				delete(c.cache, key)
+			}
+		}
+	}
+	return registration, signature, nil
+}
+
+// Calls the relay data api. Relays answer with an error status when the validator is not
+// registered, so only server errors are considered errors
+func (c *RelayChecker) fetchRegistration(relay string, pubkey string) (*builderApiV1.SignedValidatorRegistration, error) {
+	url := fmt.Sprintf("%s/relay/v1/data/validator_registration?pubkey=%s", relay, pubkey)
+	resp, err := c.httpClient.Get(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not call relay")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, errors.New(fmt.Sprintf("relay answered with status %d", resp.StatusCode))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil
+	}
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read relay response")
+	}
+	signedRegistration := &builderApiV1.SignedValidatorRegistration{}
+	if err = json.Unmarshal(bodyBytes, signedRegistration); err != nil {
+		return nil, errors.Wrap(err, "could not decode relay response")
+	}
+	return signedRegistration, nil
+}
+
+// Returns the builder domain of the network, false if its fork version is not known
+func BuilderDomain(network string) (phase0.Domain, bool) {
+	forkVersion, found := GenesisForkVersions[network]
+	if !found {
+		return phase0.Domain{}, false
+	}
+	forkData := &phase0.ForkData{CurrentVersion: forkVersion, GenesisValidatorsRoot: phase0.Root{}}
+	forkDataRoot, err := forkData.HashTreeRoot()
+	if err != nil {
+		return phase0.Domain{}, false
+	}
+	var domain phase0.Domain
+	copy(domain[:4], DomainApplicationBuilder[:])
+	copy(domain[4:], forkDataRoot[:28])
+	return domain, true
+}
+
+// Verifies that the registration is of the validator with the pubkey, and that it signed it
+// with the builder domain of the network. Relays could otherwise return any fee recipient
+func VerifyRegistration(registration *builderApiV1.SignedValidatorRegistration, pubkey string, network string) string {
+	if registration.Message == nil || !strings.EqualFold(registration.Message.Pubkey.String(), pubkey) {
+		return SignatureInvalid
+	}
+	domain, found := BuilderDomain(network)
+	if !found {
+		return SignatureUnverified
+	}
+	messageRoot, err := registration.Message.HashTreeRoot()
+	if err != nil {
+		return SignatureInvalid
+	}
+	signingData := &phase0.SigningData{ObjectRoot: messageRoot, Domain: domain}
+	signingRoot, err := signingData.HashTreeRoot()
+	if err != nil {
+		return SignatureInvalid
+	}
+	if !verifyBlsSignature(registration.Message.Pubkey, registration.Signature, signingRoot[:]) {
+		return SignatureInvalid
+	}
+	return SignatureValid
+}
+
+// Returns true if the signature of the message is valid for the pubkey, with the ciphersuite
+// of the consensus layer
+func verifyBlsSignature(pubkey phase0.BLSPubKey, signature phase0.BLSSignature, message []byte) bool {
+	blsPubkey := new(blst.P1Affine).Uncompress(pubkey[:])
+	blsSignature := new(blst.P2Affine).Uncompress(signature[:])
+	if blsPubkey == nil || blsSignature == nil {
+		return false
+	}
+	return blsSignature.Verify(true, blsPubkey, true, message, blsDst)
+}
@@ -0,0 +1,212 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	builderApiV1 "github.com/attestantio/go-builder-client/api/v1"
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/dappnode/mev-sp-oracle/client"
+	"github.com/dappnode/mev-sp-oracle/config"
+	"github.com/dappnode/mev-sp-oracle/oracle"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+	blst "github.com/supranational/blst/bindings/go"
+)
+
+// Key of the validator registered in the test relays
+var relayKey = blst.KeyGen([]byte("mev-sp-oracle relay registration test key"))
+var relayPubkey = hexutil.Encode(new(blst.P1Affine).From(relayKey).Compress())
+
+// Registration of the validator to the fee recipient, signed with the builder domain of the network
+func signedRegistration(t *testing.T, feeRecipient string, network string) *builderApiV1.SignedValidatorRegistration {
+	registration := &builderApiV1.ValidatorRegistration{
+		FeeRecipient: bellatrix.ExecutionAddress(common.HexToAddress(feeRecipient)),
+		GasLimit:     30000000,
+		Timestamp:    time.Unix(1700000000, 0),
+		Pubkey:       phase0.BLSPubKey(hexutil.MustDecode(relayPubkey)),
+	}
+	messageRoot, err := registration.HashTreeRoot()
+	require.NoError(t, err)
+	domain, found := BuilderDomain(network)
+	require.True(t, found)
+	signingRoot, err := (&phase0.SigningData{ObjectRoot: messageRoot, Domain: domain}).HashTreeRoot()
+	require.NoError(t, err)
+
+	signed := &builderApiV1.SignedValidatorRegistration{Message: registration}
+	copy(signed.Signature[:], new(blst.P2Affine).Sign(relayKey, signingRoot[:], blsDst).Compress())
+	return signed
+}
+
+// Relay that answers with the given status, and with the registration if ok
+func testRelay(t *testing.T, status int, registration *builderApiV1.SignedValidatorRegistration, delay time.Duration) (*httptest.Server, *atomic.Int32) {
+	calls := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+		require.Equal(t, "/relay/v1/data/validator_registration", req.URL.Path)
+		require.Equal(t, relayPubkey, req.URL.Query().Get("pubkey"))
+		time.Sleep(delay)
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			require.NoError(t, json.NewEncoder(w).Encode(registration))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, calls
+}
+
+func Test_RelayChecker(t *testing.T) {
+	defaultTimeout := RelayTimeout
+	defer func() { RelayTimeout = defaultTimeout }()
+	RelayTimeout = 100 * time.Millisecond
+
+	pool := "0x0000000000000000000000000000000000000001"
+	registration := signedRegistration(t, pool, "mainnet")
+	registered, registeredCalls := testRelay(t, http.StatusOK, registration, 0)
+	unregistered, unregisteredCalls := testRelay(t, http.StatusBadRequest, nil, 0)
+	failing, failingCalls := testRelay(t, http.StatusInternalServerError, nil, 0)
+	slow, _ := testRelay(t, http.StatusOK, registration, 500*time.Millisecond)
+	slower, _ := testRelay(t, http.StatusOK, registration, 500*time.Millisecond)
+	checker := NewRelayChecker([]string{registered.URL, unregistered.URL, failing.URL, slow.URL, slower.URL}, "mainnet")
+
+	// The relays are called at once, so the slow ones only take a timeout
+	start := time.Now()
+	registrations := checker.Check(strings.ToUpper(relayPubkey[:2]) + relayPubkey[2:])
+	require.Less(t, time.Since(start), 2*RelayTimeout)
+
+	require.Len(t, registrations, 5)
+	require.Equal(t, registered.URL, registrations[0].Relay)
+	require.NoError(t, registrations[0].Err)
+	require.Equal(t, common.HexToAddress(pool).Bytes(), registrations[0].Registration.Message.FeeRecipient[:])
+	require.Equal(t, SignatureValid, registrations[0].Signature)
+	require.NoError(t, registrations[1].Err)
+	require.Nil(t, registrations[1].Registration)
+	require.Empty(t, registrations[1].Signature)
+	require.ErrorContains(t, registrations[2].Err, "status 500")
+	require.Error(t, registrations[3].Err)
+	require.Error(t, registrations[4].Err)
+
+	// Answers are cached, except the errors
+	checker.Check(relayPubkey)
+	require.Equal(t, int32(1), registeredCalls.Load())
+	require.Equal(t, int32(1), unregisteredCalls.Load())
+	require.Equal(t, int32(2), failingCalls.Load())
+
+	// Until they expire
+	for key, cached := range checker.cache {
+		cached.expires = time.Now()
+		checker.cache[key] = cached
+	}
+	checker.Check(relayPubkey)
+	require.Equal(t, int32(2), registeredCalls.Load())
+}
+
+func Test_HandleValidatorRelayers(t *testing.T) {
+	pool := "0x0000000000000000000000000000000000000001"
+	other := "0x00000000000000000000000000000000000000a1"
+	correct, _ := testRelay(t, http.StatusOK, signedRegistration(t, pool, "mainnet"), 0)
+	wrong, _ := testRelay(t, http.StatusOK, signedRegistration(t, other, "mainnet"), 0)
+	unregistered, _ := testRelay(t, http.StatusNoContent, nil, 0)
+	failing, _ := testRelay(t, http.StatusBadGateway, nil, 0)
+
+	// A relay claiming the pool as fee recipient of a registration signed to another one
+	forgedRegistration := signedRegistration(t, other, "mainnet")
+	forgedRegistration.Message.FeeRecipient = bellatrix.ExecutionAddress(common.HexToAddress(pool))
+	forged, _ := testRelay(t, http.StatusOK, forgedRegistration, 0)
+	// And one returning a registration signed for another network
+	otherNetwork, _ := testRelay(t, http.StatusOK, signedRegistration(t, pool, "hoodi"), 0)
+
+	cfg := &oracle.Config{Network: "mainnet", PoolAddress: pool}
+	relays := func(endpoints ...string) *config.CliConfig {
+		return &config.CliConfig{RelayersEndpoints: map[string][]string{"mainnet": endpoints, "hoodi": {wrong.URL}}}
+	}
+	get := func(cliCfg *config.CliConfig) *client.RelayersState {
+		server := httptest.NewServer(NewApiService(cfg, cliCfg, oracle.NewOracle(cfg), nil).getRouter())
+		defer server.Close()
+		state, err := client.NewClient(server.URL).ValidatorRelayers(relayPubkey)
+		require.NoError(t, err)
+		return state
+	}
+
+	// Unreachable relays are reported apart, and dont make the fee recipient wrong
+	state := get(relays(correct.URL, unregistered.URL, failing.URL))
+	require.True(t, state.CorrectFeeRecipients)
+	require.Equal(t, []client.Relay{{RelayAddress: correct.URL, FeeRecipient: pool, Timestamp: "1700000000000000000", Signature: "valid"}}, state.CorrectFeeRelays)
+	require.Equal(t, []client.Relay{{RelayAddress: unregistered.URL}}, state.UnregisteredRelays)
+	require.Len(t, state.UnreachableRelays, 1)
+	require.Equal(t, failing.URL, state.UnreachableRelays[0].RelayAddress)
+	require.Contains(t, state.UnreachableRelays[0].Error, "status 502")
+	require.Empty(t, state.WrongFeeRelays)
+
+	// But a wrong fee recipient in any relay does
+	state = get(relays(correct.URL, wrong.URL))
+	require.False(t, state.CorrectFeeRecipients)
+	require.Len(t, state.WrongFeeRelays, 1)
+	require.Equal(t, wrong.URL, state.WrongFeeRelays[0].RelayAddress)
+
+	// Registrations with invalid signatures are reported, but not trusted
+	state = get(relays(forged.URL, otherNetwork.URL))
+	require.False(t, state.CorrectFeeRecipients)
+	require.Len(t, state.CorrectFeeRelays, 2)
+	require.Equal(t, "invalid_signature", state.CorrectFeeRelays[0].Signature)
+	require.Equal(t, "invalid_signature", state.CorrectFeeRelays[1].Signature)
+	state = get(relays(forged.URL, correct.URL))
+	require.True(t, state.CorrectFeeRecipients)
+}
+
+func Test_VerifyRegistration(t *testing.T) {
+	// Builder domain used by mev-boost on mainnet
+	domain, found := BuilderDomain("mainnet")
+	require.True(t, found)
+	require.Equal(t, "0x00000001f5a5fd42d16a20302798ef6ed309979b43003d2320d9f0e8ea9831a9", hexutil.Encode(domain[:]))
+
+	registration := signedRegistration(t, "0x00000000000000000000000000000000000000a1", "holesky")
+	require.Equal(t, SignatureValid, VerifyRegistration(registration, relayPubkey, "holesky"))
+	require.Equal(t, SignatureInvalid, VerifyRegistration(registration, relayPubkey, "mainnet"))
+	require.Equal(t, SignatureUnverified, VerifyRegistration(registration, relayPubkey, "unknown"))
+
+	// Registrations of other validators are never valid
+	otherPubkey := "0xb1ce83f50ba296bdfedba0e4a42a65f8cee1bdeb2ba78aaa61b452141684930406412bbef6c0f65b4121f8fc82dbb6ba"
+	require.Equal(t, SignatureInvalid, VerifyRegistration(registration, otherPubkey, "holesky"))
+
+	// Nor malformed signatures
+	registration.Signature = phase0.BLSSignature{}
+	require.Equal(t, SignatureInvalid, VerifyRegistration(registration, relayPubkey, "holesky"))
+}
+
+func Test_VerifyBlsSignature_KnownAnswer(t *testing.T) {
+	// Case sign_case_84d45c9c69d2e2b0 of the bls tests of the consensus specs, signed by its
+	// private key 0x263dbd792f5b1be47ed85f8938c0f29586af0d3ac7b977f21c278fe1462040e3
+	pubkey := phase0.BLSPubKey(hexutil.MustDecode("0xa491d1b0ecd9bb917989f0e74f0dea0422eac4a873e5e2644f368dffb9a6e20fd6e10c1b77654d067c0618f6e5a7f79a"))
+	signature := phase0.BLSSignature(hexutil.MustDecode("0xb6ed936746e01f8ecf281f020953fbf1f01debd5657c4a383940b020b26507f6076334f91e2366c96e9ab279fb5158090352ea1c5b0c9274504f4f0e7053af24802e51e4568d164fe986834f41e55c8e850ce1f98458c0cfc9ab380b55285a55"))
+	message := make([]byte, 32)
+	require.True(t, verifyBlsSignature(pubkey, signature, message))
+
+	message[31] = 1
+	require.False(t, verifyBlsSignature(pubkey, signature, message))
+}
+
+func Test_ValidatorsOfWithdrawalAddress(t *testing.T) {
+	validator := func(index phase0.ValidatorIndex, credentials string) *v1.Validator {
+		return &v1.Validator{Index: index, Validator: &phase0.Validator{WithdrawalCredentials: common.FromHex(credentials)}}
+	}
+	validators := map[phase0.ValidatorIndex]*v1.Validator{
+		7: validator(7, "0x01000000000000000000000000000000000000000000000000000000000000a1"),
+		3: validator(3, "0x02000000000000000000000000000000000000000000000000000000000000A1"),
+		5: validator(5, "0x01000000000000000000000000000000000000000000000000000000000000a2"),
+		4: validator(4, "0x00000000000000000000000000000000000000000000000000000000000000a1"),
+	}
+
+	found := validatorsOfWithdrawalAddress(validators, "0x00000000000000000000000000000000000000a1")
+	require.Len(t, found, 2)
+	require.Equal(t, phase0.ValidatorIndex(3), found[0].Index)
+	require.Equal(t, phase0.ValidatorIndex(7), found[1].Index)
+}
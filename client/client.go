@@ -0,0 +1,238 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Client of the oracle api. Each method calls the endpoint of the same operationId in
+// api/openapi.json. Filters and paging are passed as query parameters
+type Client struct {
+	baseUrl    string
+	httpClient *http.Client
+}
+
+// Returns a client of the api served at baseUrl, for example http://localhost:7300
+func NewClient(baseUrl string) *Client {
+	return &Client{
+		baseUrl:    strings.TrimSuffix(baseUrl, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Error returned by the api
+func (e *Error) Error() string {
+	return fmt.Sprintf("api error %d: %s", e.Code, e.Message)
+}
+
+func get[T any](c *Client, path string, query url.Values) (T, error) {
+	var result T
+	endpoint := c.baseUrl + path
+	if len(query) != 0 {
+		endpoint += "?" + query.Encode()
+	}
+	resp, err := c.httpClient.Get(endpoint)
+	if err != nil {
+		return result, errors.Wrap(err, "could not call "+path)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return result, errors.Wrap(err, "could not read response of "+path)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &Error{}
+		if err := json.Unmarshal(body, apiErr); err != nil || apiErr.Code == 0 {
+			return result, errors.New(fmt.Sprintf("unexpected status %d calling %s", resp.StatusCode, path))
+		}
+		return result, apiErr
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return result, errors.Wrap(err, "could not decode response of "+path)
+	}
+	return result, nil
+}
+
+func index(value uint64) string {
+	return strconv.FormatUint(value, 10)
+}
+
+func (c *Client) Status() (*Status, error) {
+	return get[*Status](c, "/status", nil)
+}
+
+func (c *Client) Config() (*Config, error) {
+	return get[*Config](c, "/config", nil)
+}
+
+func (c *Client) ValidatorRelayers(validatorKey string) (*RelayersState, error) {
+	return get[*RelayersState](c, "/registeredrelays/"+validatorKey, nil)
+}
+
+func (c *Client) WithdrawalRelayers(withdrawalAddress string) ([]ValidatorRelayersState, error) {
+	return get[[]ValidatorRelayersState](c, "/registeredrelays/withdrawal/"+withdrawalAddress, nil)
+}
+
+// Whole state of the oracle, as stored by it
+func (c *Client) State() (json.RawMessage, error) {
+	return get[json.RawMessage](c, "/state", nil)
+}
+
+// OpenAPI specification of the api
+func (c *Client) OpenApi() (json.RawMessage, error) {
+	return get[json.RawMessage](c, "/openapi.json", nil)
+}
+
+func (c *Client) Validators(query url.Values) ([]ValidatorInfo, error) {
+	return get[[]ValidatorInfo](c, "/memory/validators", query)
+}
+
+func (c *Client) Validator(validatorIndex uint64, query url.Values) (*StoredValidator, error) {
+	return get[*StoredValidator](c, "/memory/validator/"+index(validatorIndex), query)
+}
+
+func (c *Client) ValidatorHistory(validatorIndex uint64) (*ValidatorHistory, error) {
+	return get[*ValidatorHistory](c, "/memory/validator/"+index(validatorIndex)+"/history", nil)
+}
+
+func (c *Client) ValidatorsByIndex(validatorIndexes []uint64, query url.Values) (*ValidatorsByIndex, error) {
+	indexes := make([]string, 0, len(validatorIndexes))
+	for _, validatorIndex := range validatorIndexes {
+		indexes = append(indexes, index(validatorIndex))
+	}
+	return get[*ValidatorsByIndex](c, "/memory/validatorsbyindex/"+strings.Join(indexes, ","), query)
+}
+
+func (c *Client) ValidatorsByWithdrawal(withdrawalAddress string, query url.Values) ([]ValidatorInfo, error) {
+	return get[[]ValidatorInfo](c, "/memory/validators/"+withdrawalAddress, query)
+}
+
+func (c *Client) FeesInfo(query url.Values) (*FeesInfo, error) {
+	return get[*FeesInfo](c, "/memory/feesinfo", query)
+}
+
+func (c *Client) Statistics(query url.Values) (*MemoryStatistics, error) {
+	return get[*MemoryStatistics](c, "/memory/statistics", query)
+}
+
+func (c *Client) AllBlocks(query url.Values) (*Page[Block], error) {
+	return get[*Page[Block]](c, "/memory/allblocks", query)
+}
+
+func (c *Client) ProposedBlocks(query url.Values) (*Page[Block], error) {
+	return get[*Page[Block]](c, "/memory/proposedblocks", query)
+}
+
+func (c *Client) MissedBlocks(query url.Values) (*Page[Block], error) {
+	return get[*Page[Block]](c, "/memory/missedblocks", query)
+}
+
+func (c *Client) WrongFeeBlocks(query url.Values) (*Page[Block], error) {
+	return get[*Page[Block]](c, "/memory/wrongfeeblocks", query)
+}
+
+func (c *Client) Donations(query url.Values) (*Page[Donation], error) {
+	return get[*Page[Donation]](c, "/memory/donations", query)
+}
+
+func (c *Client) Simulate(query url.Values) (*Simulation, error) {
+	return get[*Simulation](c, "/memory/simulate", query)
+}
+
+func (c *Client) RewardRecipients() (*RewardRecipients, error) {
+	return get[*RewardRecipients](c, "/memory/rewardrecipients", nil)
+}
+
+func (c *Client) RewardRecipientsOf(address string) (*RewardRecipients, error) {
+	return get[*RewardRecipients](c, "/memory/rewardrecipients/"+address, nil)
+}
+
+func (c *Client) Claims(address string) (*Claims, error) {
+	return get[*Claims](c, "/memory/claims/"+address, nil)
+}
+
+func (c *Client) Consolidations() (*Consolidations, error) {
+	return get[*Consolidations](c, "/memory/consolidations", nil)
+}
+
+func (c *Client) ConsolidationsOf(validatorIndex uint64) (*Consolidations, error) {
+	return get[*Consolidations](c, "/memory/consolidations/"+index(validatorIndex), nil)
+}
+
+func (c *Client) Proof(withdrawalAddress string, query url.Values) (*Proofs, error) {
+	return get[*Proofs](c, "/onchain/proof/"+withdrawalAddress, query)
+}
+
+func (c *Client) Reconciliation() (*Reconciliation, error) {
+	return get[*Reconciliation](c, "/onchain/reconciliation", nil)
+}
+
+func (c *Client) Verify(query url.Values) (*VerifyProof, error) {
+	return get[*VerifyProof](c, "/onchain/verify", query)
+}
+
+func (c *Client) Tree(slot uint64) (*StandardTreeDump, error) {
+	return get[*StandardTreeDump](c, "/onchain/tree/"+index(slot), nil)
+}
+
+func (c *Client) ClaimTx(address string, query url.Values) (*ClaimTx, error) {
+	return get[*ClaimTx](c, "/onchain/claimtx/"+address, query)
+}
+
+func (c *Client) Checkpoints(query url.Values) (*Page[Checkpoint], error) {
+	return get[*Page[Checkpoint]](c, "/onchain/checkpoints", query)
+}
+
+func (c *Client) Checkpoint(slot uint64) (*CheckpointLeafs, error) {
+	return get[*CheckpointLeafs](c, "/onchain/checkpoint/"+index(slot), nil)
+}
+
+// Streams the events of the oracle, calling onEvent with each one until it returns an
+// error or the connection ends. To resume, pass the id of the last event as "resume"
+func (c *Client) Events(query url.Values, onEvent func(*StreamEvent) error) error {
+	endpoint := c.baseUrl + "/events"
+	if len(query) != 0 {
+		endpoint += "?" + query.Encode()
+	}
+	// The stream is long lived, so it has no timeout
+	resp, err := (&http.Client{Transport: c.httpClient.Transport}).Get(endpoint)
+	if err != nil {
+		return errors.Wrap(err, "could not call /events")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &Error{}
+		if err := json.NewDecoder(resp.Body).Decode(apiErr); err != nil || apiErr.Code == 0 {
+			return errors.New(fmt.Sprintf("unexpected status %d calling /events", resp.StatusCode))
+		}
+		return apiErr
+	}
+
+	// Each event has its json in a single data line, the rest of the lines are redundant
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		data, found := strings.CutPrefix(scanner.Text(), "data: ")
+		if !found {
+			continue
+		}
+		event := &StreamEvent{}
+		if err := json.Unmarshal([]byte(data), event); err != nil {
+			return errors.Wrap(err, "could not decode event")
+		}
+		if err := onEvent(event); err != nil {
+			return err
+		}
+	}
+	return errors.Wrap(scanner.Err(), "could not read /events")
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Client(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch req.URL.Path {
+		case "/memory/proposedblocks":
+			require.Equal(t, "1234", req.URL.Query().Get("validator_index"))
+			w.Write([]byte(`{"items":[{"slot":10,"validator_index":1234,"reward_wei":"100"}],"next_cursor":"10-0-0"}`))
+		case "/memory/validator/5":
+			require.Equal(t, "head", req.URL.Query().Get("view"))
+			w.Write([]byte(`{"status":"active","accumulated_rewards_wei":1000000000000000000000,"validator_index":5}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":400,"message":"invalid address"}`))
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL + "/")
+
+	page, err := client.ProposedBlocks(url.Values{"validator_index": {"1234"}})
+	require.NoError(t, err)
+	require.Equal(t, "10-0-0", page.NextCursor)
+	require.Equal(t, []Block{{Slot: 10, ValidatorIndex: 1234, Reward: "100"}}, page.Items)
+
+	validator, err := client.Validator(5, url.Values{"view": {"head"}})
+	require.NoError(t, err)
+	require.Equal(t, "active", validator.ValidatorStatus)
+	require.Equal(t, "1000000000000000000000", validator.AccumulatedRewardsWei.String())
+
+	_, err = client.Claims("0x12")
+	require.Equal(t, &Error{Code: 400, Message: "invalid address"}, err)
+}
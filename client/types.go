@@ -0,0 +1,377 @@
+package client
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// Types of the responses of the oracle api. They are the ones the api encodes, so they
+// can be imported by anyone consuming it. See api/openapi.json for its specification.
+
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type Status struct {
+	IsConsensusInSync           bool   `json:"is_consensus_in_sync"`
+	IsExecutionInSync           bool   `json:"is_execution_in_sync"`
+	IsOracleInSync              bool   `json:"is_oracle_in_sync"`
+	LatestProcessedSlot         uint64 `json:"latest_processed_slot"`
+	LatestProcessedBlock        uint64 `json:"latest_processed_block"`
+	LatestFinalizedEpoch        uint64 `json:"latest_finalized_epoch"`
+	LatestFinalizedSlot         uint64 `json:"latest_finalized_slot"`
+	OracleHeadDistance          uint64 `json:"oracle_sync_distance_slots"`
+	NextCheckpointSlot          uint64 `json:"next_checkpoint_slot"`
+	NextCheckpointTime          string `json:"next_checkpoint_time"`
+	NextCheckpointRemaining     string `json:"next_checkpoint_remaining"`
+	NextCheckpointRemainingUnix uint64 `json:"next_checkpoint_remaining_unix"`
+	PreviousCheckpointSlot      uint64 `json:"previous_checkpoint_slot"`
+	PreviousCheckpointTime      string `json:"previous_checkpoint_time"`
+	PreviousCheckpointAge       string `json:"previous_checkpoint_age"`
+	PreviousCheckpointAgeUnix   uint64 `json:"previous_checkpoint_age_unix"`
+	ConsensusChainId            string `json:"consensus_chainid"`
+	ExecutionChainId            string `json:"execution_chainid"`
+	DepositContact              string `json:"depositcontract"`
+}
+
+type Config struct {
+	Network                  string `json:"network"`
+	PoolAddress              string `json:"pool_address"`
+	DeployedSlot             uint64 `json:"deployed_slot"`
+	CheckPointSizeInSlots    uint64 `json:"checkpoint_size"`
+	PoolFeesPercentOver10000 int    `json:"pool_fees_percent"`
+	PoolFeesAddress          string `json:"pool_fees_address"`
+	DryRun                   bool   `json:"dry_run"`
+	CollateralInWei          string `json:"collateral_in_wei"`
+	RequireReconciliation    bool   `json:"require_reconciliation"`
+}
+
+type RelayersState struct {
+	CorrectFeeRecipients bool    `json:"correct_fee_recipients"`
+	CorrectFeeRelays     []Relay `json:"correct_fee_relayers"`
+	WrongFeeRelays       []Relay `json:"wrong_fee_relayers"`
+	UnregisteredRelays   []Relay `json:"unregistered_relayers"`
+	UnreachableRelays    []Relay `json:"unreachable_relayers"`
+}
+
+type Relay struct {
+	RelayAddress string `json:"relay_address"`
+	FeeRecipient string `json:"fee_recipient"`
+	Timestamp    string `json:"timestamp"`
+	Signature    string `json:"signature,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+type ValidatorRelayersState struct {
+	ValidatorIndex uint64        `json:"validator_index"`
+	ValidatorKey   string        `json:"validator_key"`
+	Relayers       RelayersState `json:"relayers"`
+}
+
+type MemoryStatistics struct {
+	TotalSubscribed              uint64 `json:"total_subscribed_validators"`
+	TotalActive                  uint64 `json:"total_active_validators"`
+	TotalYellowCard              uint64 `json:"total_yellowcard_validators"`
+	TotalRedCard                 uint64 `json:"total_redcard_validators"`
+	TotalBanned                  uint64 `json:"total_banned_validators"`
+	TotalNotSubscribed           uint64 `json:"total_notsubscribed_validators"`
+	LatestCheckpointSlot         uint64 `json:"latest_checkpoint_slot"`
+	NextCheckpointSlot           uint64 `json:"next_checkpoint_slot"`
+	TotalAccumulatedRewardsWei   string `json:"total_accumulated_rewards_wei"`
+	TotalPendingRewaradsWei      string `json:"total_pending_rewards_wei"`
+	TotalRewardsSentWei          string `json:"total_rewards_sent_wei"`
+	TotalRewardsSent30DaysWei    string `json:"total_rewards_sent_30days_wei"`
+	RewardsPerValidatorPer30Days string `json:"rewards_per_validator_per_30days_wei"`
+	TotalDonationsWei            string `json:"total_donations_wei"`
+	AvgBlockRewardWei            string `json:"avg_block_reward_wei"`
+	TotalProposedBlocks          uint64 `json:"total_proposed_blocks"`
+	TotalMissedBlocks            uint64 `json:"total_missed_blocks"`
+	TotalWrongFeeBlocks          uint64 `json:"total_wrongfee_blocks"`
+	TotalEffectiveBalanceGwei    string `json:"total_effective_balance_gwei"`
+	AvgEffectiveBalanceGwei      string `json:"avg_effective_balance_gwei"`
+}
+
+type FeesInfo struct {
+	PoolFeesPercentOver10000 int    `json:"pool_fee_percent"`
+	PoolFeesAddress          string `json:"pool_fee_address"`
+	PoolAccumulatedFees      string `json:"pool_accumulated_fees"`
+}
+
+// Page of results sorted by slot, or block for donations. Pass NextCursor as the
+// cursor to get the next one, its empty when there are no more
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor"`
+}
+
+type Block struct {
+	Slot              uint64 `json:"slot"`
+	Block             uint64 `json:"block"`
+	ValidatorIndex    uint64 `json:"validator_index"`
+	ValidatorKey      string `json:"validator_key"`
+	BlockType         string `json:"block_type"`
+	Reward            string `json:"reward_wei"`
+	RewardType        string `json:"reward_type"`
+	WithdrawalAddress string `json:"withdrawal_address"`
+}
+
+type Donation struct {
+	AmountWei string `json:"amount_wei"`
+	Block     uint64 `json:"block_number"`
+	TxHash    string `json:"tx_hash"`
+	Sender    string `json:"sender"`
+}
+
+type ValidatorInfo struct {
+	ValidatorStatus                     string `json:"status"`
+	BeaconValidatorStatus               string `json:"beacon_status"`
+	BeaconValidatorBalanceGwei          string `json:"beacon_balance_gwei"`
+	BeaconValidatorEffectiveBalanceGwei string `json:"beacon_effective_balance_gwei"`
+	AccumulatedRewardsWei               string `json:"accumulated_rewards_wei"`
+	PendingRewardsWei                   string `json:"pending_rewards_wei"`
+	CollateralWei                       string `json:"collateral_wei"`
+	WithdrawalAddress                   string `json:"withdrawal_address"`
+	ValidatorIndex                      uint64 `json:"validator_index"`
+	ValidatorKey                        string `json:"validator_key"`
+	SubscriptionType                    string `json:"subscription_type"`
+	RewardRecipient                     string `json:"reward_recipient,omitempty"`
+}
+
+type ValidatorsByIndex struct {
+	Found    []ValidatorInfo `json:"found_validators"`
+	NotFound []uint64        `json:"not_found_validators"`
+}
+
+// Validator as stored by the oracle, without its beacon chain info
+type StoredValidator struct {
+	ValidatorStatus       string   `json:"status"`
+	AccumulatedRewardsWei *big.Int `json:"accumulated_rewards_wei"`
+	PendingRewardsWei     *big.Int `json:"pending_rewards_wei"`
+	CollateralWei         *big.Int `json:"collateral_wei"`
+	WithdrawalAddress     string   `json:"withdrawal_address"`
+	ValidatorIndex        uint64   `json:"validator_index"`
+	ValidatorKey          string   `json:"validator_key"`
+	SubscriptionType      string   `json:"subscription_type"`
+}
+
+type StateTransition struct {
+	Slot   uint64 `json:"slot"`
+	Event  string `json:"event"`
+	From   string `json:"from_status"`
+	To     string `json:"to_status"`
+	Block  uint64 `json:"block,omitempty"`
+	TxHash string `json:"tx_hash,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type CheckpointRewards struct {
+	Slot                  uint64 `json:"slot"`
+	AccumulatedRewardsWei string `json:"accumulated_rewards_wei"`
+	PendingRewardsWei     string `json:"pending_rewards_wei"`
+}
+
+type ValidatorHistory struct {
+	ValidatorIndex uint64              `json:"validator_index"`
+	Transitions    []StateTransition   `json:"transitions"`
+	Blocks         []Block             `json:"blocks"`
+	Rewards        []CheckpointRewards `json:"rewards"`
+}
+
+type SimulatedAddress struct {
+	WithdrawalAddress       string `json:"withdrawal_address"`
+	BaselineAccumulatedWei  string `json:"baseline_accumulated_wei"`
+	SimulatedAccumulatedWei string `json:"simulated_accumulated_wei"`
+	DeltaAccumulatedWei     string `json:"delta_accumulated_wei"`
+	BaselinePendingWei      string `json:"baseline_pending_wei"`
+	SimulatedPendingWei     string `json:"simulated_pending_wei"`
+	DeltaPendingWei         string `json:"delta_pending_wei"`
+}
+
+type Simulation struct {
+	PoolFeesPercentOver10000 *int               `json:"pool_fees_percent,omitempty"`
+	CollateralInWei          string             `json:"collateral_in_wei,omitempty"`
+	RewardMethod             string             `json:"reward_method,omitempty"`
+	FromSlot                 uint64             `json:"from_slot"`
+	ToSlot                   uint64             `json:"to_slot"`
+	ReplayedSlots            uint64             `json:"replayed_slots"`
+	RealPoolFeesWei          string             `json:"real_pool_fees_wei"`
+	BaselinePoolFeesWei      string             `json:"baseline_pool_fees_wei"`
+	SimulatedPoolFeesWei     string             `json:"simulated_pool_fees_wei"`
+	DeltaPoolFeesWei         string             `json:"delta_pool_fees_wei"`
+	Addresses                []SimulatedAddress `json:"addresses"`
+}
+
+type RewardRecipient struct {
+	WithdrawalAddress string `json:"withdrawal_address"`
+	RewardRecipient   string `json:"reward_recipient"`
+}
+
+type RewardRecipientChange struct {
+	Slot              uint64 `json:"slot"`
+	Block             uint64 `json:"block"`
+	TxHash            string `json:"tx_hash"`
+	WithdrawalAddress string `json:"withdrawal_address"`
+	RewardRecipient   string `json:"reward_recipient"`
+}
+
+type RewardRecipients struct {
+	RewardRecipients []RewardRecipient       `json:"reward_recipients"`
+	History          []RewardRecipientChange `json:"history"`
+}
+
+type Claim struct {
+	Slot              uint64 `json:"slot"`
+	Block             uint64 `json:"block"`
+	TxHash            string `json:"tx_hash"`
+	WithdrawalAddress string `json:"withdrawal_address"`
+	RewardAddress     string `json:"reward_address"`
+	AmountWei         string `json:"amount_wei"`
+}
+
+type Claims struct {
+	ClaimedRewardsWei string  `json:"claimed_rewards_wei"`
+	Claims            []Claim `json:"claims"`
+}
+
+type Consolidation struct {
+	SourceIndex                uint64 `json:"source_index"`
+	SourceKey                  string `json:"source_key"`
+	TargetIndex                uint64 `json:"target_index"`
+	TargetKey                  string `json:"target_key"`
+	Status                     string `json:"status"`
+	RequestSlot                uint64 `json:"request_slot"`
+	RequestBlock               uint64 `json:"request_block"`
+	CompletedSlot              uint64 `json:"completed_slot"`
+	TransferSlot               uint64 `json:"transfer_slot"`
+	TransferredPendingWei      string `json:"transferred_pending_wei"`
+	Target                     string `json:"target"`
+	SourceWithdrawalAddress    string `json:"source_withdrawal_address"`
+	TargetWithdrawalAddress    string `json:"target_withdrawal_address"`
+	DifferentWithdrawalAddress bool   `json:"different_withdrawal_address"`
+}
+
+type Consolidations struct {
+	Consolidations []Consolidation `json:"consolidations"`
+}
+
+type Proofs struct {
+	LeafWithdrawalAddress      string   `json:"leaf_withdrawal_address"`
+	LeafAccumulatedBalance     string   `json:"leaf_accumulated_balance"`
+	MerkleRoot                 string   `json:"merkleroot"`
+	CheckpointSlot             uint64   `json:"checkpoint_slot"`
+	Proofs                     []string `json:"merkle_proofs"`
+	RegisteredValidators       []uint64 `json:"registered_validators"`
+	TotalAccumulatedRewardsWei string   `json:"total_accumulated_rewards_wei"`
+	AlreadyClaimedRewardsWei   string   `json:"already_claimed_rewards_wei"`
+	ClaimableRewardsWei        string   `json:"claimable_rewards_wei"`
+	PendingRewardsWei          string   `json:"pending_rewards_wei"`
+	RewardRecipient            string   `json:"reward_recipient"`
+}
+
+type VerifyProof struct {
+	MerkleRoot        string   `json:"merkleroot"`
+	WithdrawalAddress string   `json:"withdrawal_address"`
+	AmountWei         string   `json:"amount_wei"`
+	Proofs            []string `json:"merkle_proofs"`
+	Valid             bool     `json:"valid"`
+}
+
+// Unsigned EIP-1559 transaction, in the format of eth_signTransaction
+type UnsignedTx struct {
+	Type                 hexutil.Uint64 `json:"type"`
+	ChainId              *hexutil.Big   `json:"chainId"`
+	From                 common.Address `json:"from"`
+	To                   common.Address `json:"to"`
+	Nonce                hexutil.Uint64 `json:"nonce"`
+	Gas                  hexutil.Uint64 `json:"gas"`
+	MaxFeePerGas         *hexutil.Big   `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big   `json:"maxPriorityFeePerGas"`
+	Value                *hexutil.Big   `json:"value"`
+	Data                 hexutil.Bytes  `json:"data"`
+}
+
+type ClaimTx struct {
+	WithdrawalAddress          string      `json:"withdrawal_address"`
+	RewardRecipient            string      `json:"reward_recipient"`
+	ContractAddress            string      `json:"contract_address"`
+	MerkleRoot                 string      `json:"merkleroot"`
+	CheckpointSlot             uint64      `json:"checkpoint_slot"`
+	TotalAccumulatedRewardsWei string      `json:"total_accumulated_rewards_wei"`
+	AlreadyClaimedRewardsWei   string      `json:"already_claimed_rewards_wei"`
+	ClaimableRewardsWei        string      `json:"claimable_rewards_wei"`
+	Calldata                   string      `json:"calldata"`
+	UnsignedTx                 *UnsignedTx `json:"unsigned_tx"`
+}
+
+// Merkle tree of a checkpoint with the proof of each leaf
+type StandardTreeDump struct {
+	Format       string              `json:"format"`
+	LeafEncoding []string            `json:"leafEncoding"`
+	Slot         uint64              `json:"slot"`
+	Root         string              `json:"root"`
+	Tree         []string            `json:"tree"`
+	Values       []StandardTreeValue `json:"values"`
+}
+
+type StandardTreeValue struct {
+	Value     []string `json:"value"`
+	TreeIndex int      `json:"treeIndex"`
+	Proof     []string `json:"proof"`
+}
+
+type Checkpoint struct {
+	Slot                       uint64 `json:"slot"`
+	MerkleRoot                 string `json:"merkleroot"`
+	Consolidated               bool   `json:"consolidated"`
+	OnchainRoot                string `json:"onchain_root"`
+	ConsolidationTxHash        string `json:"consolidation_tx_hash"`
+	ConsolidationBlock         uint64 `json:"consolidation_block"`
+	NumLeafs                   int    `json:"num_leafs"`
+	NumValidators              int    `json:"num_validators"`
+	PoolFeesWei                string `json:"pool_fees_wei"`
+	TotalAccumulatedRewardsWei string `json:"total_accumulated_rewards_wei"`
+	TotalPendingRewardsWei     string `json:"total_pending_rewards_wei"`
+}
+
+type Leaf struct {
+	WithdrawalAddress     string `json:"withdrawal_address"`
+	AccumulatedBalanceWei string `json:"accumulated_balance_wei"`
+}
+
+type CheckpointLeafs struct {
+	Checkpoint
+	Leafs []Leaf `json:"leafs"`
+}
+
+type ReconciliationReport struct {
+	Slot           uint64 `json:"slot"`
+	Block          uint64 `json:"block"`
+	Timestamp      int64  `json:"timestamp"`
+	Success        bool   `json:"success"`
+	Error          string `json:"error,omitempty"`
+	RewardsWei     string `json:"rewards_wei"`
+	ClaimedWei     string `json:"claimed_wei"`
+	AssetsWei      string `json:"assets_wei"`
+	LiabilitiesWei string `json:"liabilities_wei"`
+	DeltaWei       string `json:"delta_wei"`
+}
+
+type Reconciliation struct {
+	RequireReconciliation bool                   `json:"require_reconciliation"`
+	Latest                *ReconciliationReport  `json:"latest"`
+	Reports               []ReconciliationReport `json:"reports"`
+}
+
+// Event of the oracle, see Client.Events. Data depends on the type
+type StreamEvent struct {
+	Id                string          `json:"id"`
+	Type              string          `json:"type"`
+	Slot              uint64          `json:"slot"`
+	ValidatorIndex    *uint64         `json:"validator_index,omitempty"`
+	WithdrawalAddress string          `json:"withdrawal_address,omitempty"`
+	Data              json.RawMessage `json:"data"`
+}
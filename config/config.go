@@ -12,20 +12,33 @@ import (
 )
 
 type CliConfig struct {
-	DryRun            bool
-	UpdaterKeyFile    string
-	UpdaterKeyPass    string
-	NumRetries        int
-	ConsensusEndpoint string
-	ExecutionEndpoint string
-	PoolAddress       string
-	LogLevel          string
-	ApiPort           int
-	MetricsPort       int
-	CheckPointSyncUrl string
-	RelayersEndpoints []string
+	DryRun                bool
+	UpdaterKeyFile        string
+	UpdaterKeyPass        string
+	NumRetries            int
+	ConsensusEndpoint     string
+	ExecutionEndpoint     string
+	PoolAddress           string
+	LogLevel              string
+	ApiPort               int
+	MetricsPort           int
+	CheckPointSyncUrl     string
+	RelayersEndpoints     map[string][]string
+	RequireReconciliation bool
+	BlsCreditSlot         uint64
+	BlocksPerQuery        uint64
 }
 
+// Key of the relayers endpoints used in every network
+const AllNetworks = ""
+
+// Usage of the bls-credit-slot flag, also needed by the verify subcommand since it changes the roots
+const blsCreditSlotUsage = "Slot from which BLS proposers that rotate get the rewards of their past proposals. " +
+	"All oracles must use the same value. 0 disables it"
+
+// Usage of the blocks-per-query flag, also used by the verify subcommand
+const blocksPerQueryUsage = "Blocks per query when fetching events in a range, lower it if the execution client limits the range of logs"
+
 // By default the release is a custom build. CI takes care of upgrading it with
 // go build -v -ldflags="-X 'github.com/dappnode/mev-sp-oracle/config.ReleaseVersion=x.y.z'"
 var ReleaseVersion = "custom-build-your-own-risk"
@@ -41,12 +54,15 @@ func NewCliConfig() (*CliConfig, error) {
 	var apiPort = flag.Int("api-port", 7300, "Port for the API server")
 	var metricsPort = flag.Int("metrics-port", 8008, "Port for the metrics server")
 	var checkPointSyncUrl = flag.String("checkpoint-sync-url", "", "URL for the checkpoint sync server: http://url:port/state")
+	var blsCreditSlot = flag.Uint64("bls-credit-slot", 0, blsCreditSlotUsage)
+	var blocksPerQuery = flag.Uint64("blocks-per-query", 10000, blocksPerQueryUsage)
+	var requireReconciliation = flag.Bool("require-reconciliation", false, "If enabled, checkpoints are only submitted if an onchain reconciliation succeeded within the last checkpoint")
 
 	// Mandatory flags:
 	var consensusEndpoint = flag.String("consensus-endpoint", "", "Ethereum consensus endpoint")
 	var executionEndpoint = flag.String("execution-endpoint", "", "Ethereum execution endpoint")
 	var poolAddress = flag.String("pool-address", "", "Address of the smoothing pool contract")
-	var relayersEndpointsStr = flag.String("relayers-endpoints", "", "Comma-separated list of relayers endpoints")
+	var relayersEndpointsStr = flag.String("relayers-endpoints", "", "Comma-separated list of relayers endpoints. Prefix them with network= to use them only in that network")
 
 	flag.Parse()
 
@@ -77,15 +93,54 @@ func NewCliConfig() (*CliConfig, error) {
 		return nil, errors.New("pool-address: " + *poolAddress + " is not a valid address")
 	}
 
-	// Post process the relayers endpoints, make it a slice
-	relayersEndpoints := strings.Split(*relayersEndpointsStr, ",")
+	if *blocksPerQuery == 0 {
+		return nil, errors.New("blocks-per-query must be greater than 0")
+	}
+
+	relayersEndpoints, err := parseRelayersEndpoints(*relayersEndpointsStr)
+	if err != nil {
+		return nil, err
+	}
+
+	cliConf := &CliConfig{
+		DryRun:                *dryRun,
+		UpdaterKeyFile:        *updaterKeystoreFile,
+		UpdaterKeyPass:        *updaterKeystorePass,
+		NumRetries:            *numRetries,
+		ConsensusEndpoint:     *consensusEndpoint,
+		ExecutionEndpoint:     *executionEndpoint,
+		PoolAddress:           *poolAddress,
+		LogLevel:              *logLevel,
+		ApiPort:               *apiPort,
+		MetricsPort:           *metricsPort,
+		CheckPointSyncUrl:     *checkPointSyncUrl,
+		RelayersEndpoints:     relayersEndpoints,
+		RequireReconciliation: *requireReconciliation,
+		BlsCreditSlot:         *blsCreditSlot,
+		BlocksPerQuery:        *blocksPerQuery,
+	}
+	logConfig(cliConf)
+	return cliConf, nil
+}
 
-	if len(relayersEndpoints) == 0 || (len(relayersEndpoints) == 1 && relayersEndpoints[0] == "") {
+// Parses the comma separated relayers endpoints by network. Endpoints without a network=
+// prefix are used in every network, see RelayersOf
+func parseRelayersEndpoints(value string) (map[string][]string, error) {
+	if value == "" {
 		return nil, errors.New("relayers-endpoints is a mandatory flag and cant be empty")
 	}
 
-	// Validate the relayers endpoints, they must be valid URLs, not empty and start with https://.
-	for _, endpoint := range relayersEndpoints {
+	relayersEndpoints := make(map[string][]string)
+	for _, entry := range strings.Split(value, ",") {
+		network, endpoint := AllNetworks, entry
+		if prefix, rest, found := strings.Cut(entry, "="); found && !strings.Contains(prefix, "/") {
+			network, endpoint = strings.ToLower(prefix), rest
+			if network == "" {
+				return nil, errors.New("relayer endpoint network cannot be empty: " + entry)
+			}
+		}
+
+		// Validate the relayers endpoints, they must be valid URLs, not empty and start with https://.
 		if endpoint == "" {
 			return nil, errors.New("relayer endpoint URL cannot be empty")
 		}
@@ -95,39 +150,110 @@ func NewCliConfig() (*CliConfig, error) {
 		if _, err := url.Parse(endpoint); err != nil {
 			return nil, errors.New("invalid relayer endpoint URL: " + endpoint)
 		}
+		relayersEndpoints[network] = append(relayersEndpoints[network], endpoint)
 	}
+	return relayersEndpoints, nil
+}
 
-	cliConf := &CliConfig{
-		DryRun:            *dryRun,
-		UpdaterKeyFile:    *updaterKeystoreFile,
-		UpdaterKeyPass:    *updaterKeystorePass,
-		NumRetries:        *numRetries,
-		ConsensusEndpoint: *consensusEndpoint,
-		ExecutionEndpoint: *executionEndpoint,
-		PoolAddress:       *poolAddress,
-		LogLevel:          *logLevel,
-		ApiPort:           *apiPort,
-		MetricsPort:       *metricsPort,
-		CheckPointSyncUrl: *checkPointSyncUrl,
-		RelayersEndpoints: relayersEndpoints,
+// Returns the relayers endpoints of the network, followed by the ones of every network
+func (c *CliConfig) RelayersOf(network string) []string {
+	relayers := make([]string, 0)
+	if network != AllNetworks {
+		relayers = append(relayers, c.RelayersEndpoints[strings.ToLower(network)]...)
 	}
-	logConfig(cliConf)
-	return cliConf, nil
+	return append(relayers, c.RelayersEndpoints[AllNetworks]...)
 }
 
 func logConfig(cfg *CliConfig) {
 	log.WithFields(log.Fields{
-		"DryRun":            cfg.DryRun,
-		"UpdaterKeyFile":    cfg.UpdaterKeyFile,
-		"UpdaterKeyPass":    "hidden",
-		"NumRetries":        cfg.NumRetries,
-		"ConsensusEndpoint": cfg.ConsensusEndpoint,
-		"ExecutionEndpoint": cfg.ExecutionEndpoint,
-		"PoolAddress":       cfg.PoolAddress,
-		"LogLevel":          cfg.LogLevel,
-		"ApiPort":           cfg.ApiPort,
-		"MetricsPort":       cfg.MetricsPort,
-		"CheckPointSyncUrl": cfg.CheckPointSyncUrl,
-		"RelayersEndpoints": cfg.RelayersEndpoints,
+		"DryRun":                cfg.DryRun,
+		"UpdaterKeyFile":        cfg.UpdaterKeyFile,
+		"UpdaterKeyPass":        "hidden",
+		"NumRetries":            cfg.NumRetries,
+		"ConsensusEndpoint":     cfg.ConsensusEndpoint,
+		"ExecutionEndpoint":     cfg.ExecutionEndpoint,
+		"PoolAddress":           cfg.PoolAddress,
+		"LogLevel":              cfg.LogLevel,
+		"ApiPort":               cfg.ApiPort,
+		"MetricsPort":           cfg.MetricsPort,
+		"CheckPointSyncUrl":     cfg.CheckPointSyncUrl,
+		"RequireReconciliation": cfg.RequireReconciliation,
+		"BlsCreditSlot":         cfg.BlsCreditSlot,
+		"BlocksPerQuery":        cfg.BlocksPerQuery,
+		"RelayersEndpoints":     cfg.RelayersEndpoints,
 	}).Info("Cli Config:")
 }
+
+// Config of the verify subcommand, that replays the pool from the deployed slot
+// and checks every root consolidated onchain
+type VerifyCliConfig struct {
+	CliConfig
+	BlocksDir string
+	Replay    bool
+	ToSlot    uint64
+}
+
+func NewVerifyCliConfig(args []string) (*VerifyCliConfig, error) {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+
+	// Optional flags:
+	var numRetries = flags.Int("num-retries", 0, "Number of retries for each interaction (consensus, execution): 0 infinite")
+	var logLevel = flags.String("log-level", "warn", "Logging verbosity (trace, debug, info, warn=default, error, fatal, panic)")
+	var blocksDir = flags.String("blocks-dir", "", "Folder where the fetched blocks are recorded and read from in later runs")
+	var replay = flags.Bool("replay", false, "If enabled, blocks are only read from blocks-dir and never fetched")
+	var toSlot = flags.Uint64("to-slot", 0, "Last slot to verify. Defaults to the last slot consolidated onchain")
+	var blsCreditSlot = flags.Uint64("bls-credit-slot", 0, blsCreditSlotUsage)
+	var blocksPerQuery = flags.Uint64("blocks-per-query", 10000, blocksPerQueryUsage)
+
+	// Mandatory flags:
+	var consensusEndpoint = flags.String("consensus-endpoint", "", "Ethereum consensus endpoint")
+	var executionEndpoint = flags.String("execution-endpoint", "", "Ethereum execution endpoint")
+	var poolAddress = flags.String("pool-address", "", "Address of the smoothing pool contract")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	if !common.IsHexAddress(*poolAddress) {
+		return nil, errors.New("pool-address: " + *poolAddress + " is not a valid address")
+	}
+
+	if *blocksPerQuery == 0 {
+		return nil, errors.New("blocks-per-query must be greater than 0")
+	}
+
+	if *replay && *blocksDir == "" {
+		return nil, errors.New("replay requires a blocks-dir to read the blocks from")
+	}
+
+	verifyConf := &VerifyCliConfig{
+		CliConfig: CliConfig{
+			DryRun:            true,
+			NumRetries:        *numRetries,
+			ConsensusEndpoint: *consensusEndpoint,
+			ExecutionEndpoint: *executionEndpoint,
+			PoolAddress:       *poolAddress,
+			LogLevel:          *logLevel,
+			BlsCreditSlot:     *blsCreditSlot,
+			BlocksPerQuery:    *blocksPerQuery,
+		},
+		BlocksDir: *blocksDir,
+		Replay:    *replay,
+		ToSlot:    *toSlot,
+	}
+
+	log.WithFields(log.Fields{
+		"NumRetries":        verifyConf.NumRetries,
+		"ConsensusEndpoint": verifyConf.ConsensusEndpoint,
+		"ExecutionEndpoint": verifyConf.ExecutionEndpoint,
+		"PoolAddress":       verifyConf.PoolAddress,
+		"LogLevel":          verifyConf.LogLevel,
+		"BlsCreditSlot":     verifyConf.BlsCreditSlot,
+		"BlocksPerQuery":    verifyConf.BlocksPerQuery,
+		"BlocksDir":         verifyConf.BlocksDir,
+		"Replay":            verifyConf.Replay,
+		"ToSlot":            verifyConf.ToSlot,
+	}).Info("Verify Cli Config:")
+	return verifyConf, nil
+}
@@ -12,3 +12,45 @@ func Test_NewCliConfig(t *testing.T) {
 	_ = cliConf
 	require.Error(t, err)
 }
+
+func Test_NewVerifyCliConfig(t *testing.T) {
+	pool := "0xAdFb8D27671F14f297eE94135e266aAFf8752e35"
+
+	verifyConf, err := NewVerifyCliConfig([]string{"--pool-address", pool, "--blocks-dir", "blocks", "--replay", "--to-slot", "100"})
+	require.NoError(t, err)
+	require.True(t, verifyConf.DryRun)
+	require.Equal(t, pool, verifyConf.PoolAddress)
+	require.Equal(t, "blocks", verifyConf.BlocksDir)
+	require.True(t, verifyConf.Replay)
+	require.Equal(t, uint64(100), verifyConf.ToSlot)
+
+	// Replay needs a folder to read from
+	_, err = NewVerifyCliConfig([]string{"--pool-address", pool, "--replay"})
+	require.Error(t, err)
+
+	_, err = NewVerifyCliConfig([]string{"--pool-address", "invalid"})
+	require.Error(t, err)
+
+	require.Equal(t, uint64(10000), verifyConf.BlocksPerQuery)
+	_, err = NewVerifyCliConfig([]string{"--pool-address", pool, "--blocks-per-query", "0"})
+	require.Error(t, err)
+}
+
+func Test_parseRelayersEndpoints(t *testing.T) {
+	relayers, err := parseRelayersEndpoints("https://all.relay,mainnet=https://mainnet.relay,Hoodi=https://hoodi.relay/path?a=b")
+	require.NoError(t, err)
+	cfg := &CliConfig{RelayersEndpoints: relayers}
+	require.Equal(t, []string{"https://mainnet.relay", "https://all.relay"}, cfg.RelayersOf("mainnet"))
+	require.Equal(t, []string{"https://hoodi.relay/path?a=b", "https://all.relay"}, cfg.RelayersOf("hoodi"))
+	require.Equal(t, []string{"https://all.relay"}, cfg.RelayersOf("holesky"))
+
+	// Endpoints with a query are not taken as a network
+	relayers, err = parseRelayersEndpoints("https://relay/path?network=mainnet")
+	require.NoError(t, err)
+	require.Equal(t, []string{"https://relay/path?network=mainnet"}, relayers[AllNetworks])
+
+	for _, invalid := range []string{"", "mainnet=", "=https://relay", "http://relay", "mainnet=http://relay", "https://relay,"} {
+		_, err = parseRelayersEndpoints(invalid)
+		require.Error(t, err, invalid)
+	}
+}
@@ -5,3 +5,6 @@ var SlotsInEpoch = uint64(32)
 
 // Seconds in a slot
 var SecondsInSlot = uint64(12)
+
+// Exit epoch of validators that are not exiting
+var FarFutureEpoch = uint64(0xffffffffffffffff)
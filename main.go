@@ -22,6 +22,7 @@ import (
 	"github.com/dappnode/mev-sp-oracle/oracle"
 	"github.com/dappnode/mev-sp-oracle/utils"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/pkg/errors"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -36,7 +37,17 @@ const LogsFolder = "oracle-logs"
 // How often in hours we run onchain reconciliation
 const ReconciliationEveryHours = int64(3)
 
+// Retry options when capturing the reconciliation inputs. Bounded, since a non archival
+// node may have pruned the block if finality is lost
+var reconciliationRetryOpts = []retry.Option{
+	retry.Attempts(5),
+	retry.Delay(5 * time.Second),
+}
+
 func main() {
+	// The verify subcommand has its own flags, see verify.go
+	maybeRunVerify()
+
 	// Load config from cli
 	cliCfg, err := config.NewCliConfig()
 	if err != nil {
@@ -166,6 +177,12 @@ func main() {
 		}
 	}
 
+	// Reports of the reconciliations this oracle ran, not part of the state
+	err = oracleInstance.LoadReconciliationReports()
+	if err != nil {
+		log.Fatal("Could not load reconciliation reports: ", err)
+	}
+
 	// Get onchain root and slot
 	_, onchainSlot, err := onchain.GetOnchainSlotAndRoot()
 	if err != nil {
@@ -190,6 +207,39 @@ func main() {
 		}
 	}
 
+	// States created before reward recipients were tracked are backfilled with the
+	// events of the blocks they already processed
+	if !oracleInstance.RewardRecipientsIndexed() {
+		log.Info("State does not track reward recipients, backfilling them")
+		events, err := onchain.GetSetRewardRecipientEventsInRange(
+			oracleInstance.State().DeployedBlock,
+			oracleInstance.State().LatestProcessedBlock,
+			cliCfg.BlocksPerQuery)
+		if err != nil {
+			log.Fatal("Could not get reward recipient events to backfill: ", err)
+		}
+		err = oracleInstance.BackfillRewardRecipients(events)
+		if err != nil {
+			log.Fatal("Could not backfill reward recipients: ", err)
+		}
+	}
+
+	// Same for claims
+	if !oracleInstance.ClaimsIndexed() {
+		log.Info("State does not track claims, backfilling them")
+		events, err := onchain.GetClaimRewardsEventsInRange(
+			oracleInstance.State().DeployedBlock,
+			oracleInstance.State().LatestProcessedBlock,
+			cliCfg.BlocksPerQuery)
+		if err != nil {
+			log.Fatal("Could not get claim rewards events to backfill: ", err)
+		}
+		err = oracleInstance.BackfillClaims(events)
+		if err != nil {
+			log.Fatal("Could not backfill claims: ", err)
+		}
+	}
+
 	api := api.NewApiService(cfg, cliCfg, oracleInstance, onchain)
 
 	metrics.RunMetrics(cliCfg.MetricsPort)
@@ -283,26 +333,7 @@ func mainLoop(oracleInstance *oracle.Oracle, onchain *oracle.Onchain, cfg *oracl
 			// From time to time we do onchain reconciliation to ensure our assets match our liablities
 			if time.Now().Unix()-lastReconciliationTime > (ReconciliationEveryHours * 3600) {
 				log.Info("Running onchain reconciliation. Last one was: ", lastReconciliationTime)
-
-				// If we are up to date, this is the latest finalized block. Run this only in the last block, otherwise
-				// a non archival node will error "missing trie node". Non archival nodes don't store much before last
-				// finalized block.
-				finalizedBlock := big.NewInt(0).SetUint64(oracleInstance.State().LatestProcessedBlock)
-				uniqueAddresses := oracleInstance.GetUniqueWithdrawalAddresses()
-
-				// If EL is not in archival mode, this wont work in longs periods of non finality.
-				retryOption := retry.Attempts(1)
-				poolEthBalanceWei, err1 := onchain.GetPoolEthBalance(finalizedBlock, retryOption)
-				claimedPerAccount, err2 := onchain.GetClaimedPerWithdrawalAddress(uniqueAddresses, finalizedBlock, retryOption)
-				if err1 != nil || err2 != nil {
-					log.Warn("Could not get pool eth balance for reconciliation, normal when no finality: ", err1, err2)
-				} else {
-					// If we could fetch the data, run onchain reconciliation
-					err = oracleInstance.RunOnchainReconciliation(poolEthBalanceWei, claimedPerAccount)
-					if err != nil {
-						log.Fatal("Reconciliation failed, state was not commited: ", err)
-					}
-				}
+				runOnchainReconciliation(oracleInstance, onchain)
 				lastReconciliationTime = time.Now().Unix()
 			}
 
@@ -324,29 +355,17 @@ func mainLoop(oracleInstance *oracle.Oracle, onchain *oracle.Onchain, cfg *oracl
 		if isCheckpoint {
 			log.WithFields(log.Fields{
 				"LatestProcessedSlot":   oracleInstance.State().LatestProcessedSlot,
-				"CheckPointSizeInSlots": cfg.CheckPointSizeInSlots,
+				"CheckPointSizeInSlots": oracleInstance.Snapshot().CheckPointSizeInSlots,
 				"DeployedSlot":          oracleInstance.State().DeployedSlot,
 			}).Info("Checkpoint reached")
 
-			// TODO: Temporally commented. Doesn't work well with non archival node
-			//log.Info("Running onchain reconciliation. Last one was: ", lastReconciliationTime)
-			//lastReconciliationTime = time.Now().Unix()
-
-			// If we are up to date, this is the latest finalized block. Run this only in the last block, otherwise
-			// a non archival node will error "missing trie node". Non archival nodes don't store much before last
-			// finalized block.
-			//finalizedBlock := big.NewInt(0).SetUint64(oracleInstance.State().LatestProcessedBlock)
-			//uniqueAddresses := oracleInstance.GetUniqueWithdrawalAddresses()
-			//poolEthBalanceWei, err := onchain.GetPoolEthBalance(finalizedBlock)
-			//if err != nil {
-			//	log.Fatal("Could not get pool eth balance for reconciliation: ", err)
-			//}
-
-			//claimedPerAccount := onchain.GetClaimedPerWithdrawalAddress(uniqueAddresses, finalizedBlock)
-			//err = oracleInstance.RunOnchainReconciliation(poolEthBalanceWei, claimedPerAccount)
-			//if err != nil {
-			//	log.Fatal("Reconciliation failed, state was not commited: ", err)
-			//}
+			// If the checkpoint is the latest finalized slot, its inputs are still available even
+			// in non archival nodes, so we reconcile the state that is about to be frozen.
+			if oracleInstance.State().LatestProcessedSlot == finalizedSlot {
+				log.Info("Running onchain reconciliation at checkpoint. Last one was: ", lastReconciliationTime)
+				runOnchainReconciliation(oracleInstance, onchain)
+				lastReconciliationTime = time.Now().Unix()
+			}
 
 			err = oracleInstance.RunOffchainReconciliation()
 			if err != nil {
@@ -360,8 +379,9 @@ func mainLoop(oracleInstance *oracle.Oracle, onchain *oracle.Onchain, cfg *oracl
 				continue
 			}
 
-			// Get new state
+			// Get new state, and the checkpoint size in effect that governance may have changed
 			newState := oracleInstance.LatestCommitedState()
+			checkpointSize := oracleInstance.Snapshot().CheckPointSizeInSlots
 
 			// Update metrics
 			metrics.KnownRootAndSlot.WithLabelValues(
@@ -395,19 +415,30 @@ func mainLoop(oracleInstance *oracle.Oracle, onchain *oracle.Onchain, cfg *oracl
 				time.Sleep(1 * time.Minute)
 			}
 
+			// If required, the checkpoint is only submitted if the onchain balance of the pool
+			// was successfully reconciled within the last checkpoint
+			reconciled := true
+			if cfg.RequireReconciliation && !oracleInstance.HasFreshReconciliation(newState.Slot, checkpointSize) {
+				reconciled = false
+				log.WithFields(log.Fields{
+					"Root": newState.MerkleRoot,
+					"Slot": newState.Slot,
+				}).Warn("No successful onchain reconciliation within the last checkpoint, the checkpoint wont be submitted")
+			}
+
 			// If so we are ready to update the contract, but multiple oracles will be racing here.
 			// Lets say we have m oracles with a quorum on n (n/m). The oracles will be racing to update the root
 			// and only n txs will go through and (m-n) will be reverted, as the new state will be consolidated.
 			// In order to avoid txs being reverted (which costs gas), we add a random sleep between 0 and 15 minutes
 			// to avoid a collision. This is not perfect, but it should be good enough. Statistically, it would be
 			// very improbable that n+1 oracles will wait the same amount of time producing a collision.
-			if !cfg.DryRun && enoughData {
+			if !cfg.DryRun && enoughData && reconciled {
 				// Get onchain root and slot
 				_, onchainSlot, err := onchain.GetOnchainSlotAndRoot()
 				if err != nil {
 					log.Fatal("Could not get onchain slot and root: ", err)
 				}
-				if newState.Slot == (onchainSlot + cfg.CheckPointSizeInSlots) {
+				if newState.Slot == (onchainSlot + checkpointSize) {
 					r := rand.Intn(16 * 60)
 					log.Info("Waiting ", r, " seconds before updating the contract")
 					time.Sleep(time.Duration(r) * time.Second)
@@ -428,12 +459,12 @@ func mainLoop(oracleInstance *oracle.Oracle, onchain *oracle.Onchain, cfg *oracl
 			}).Info("Local vs onchain roots and slots")
 
 			// If the oracle has permission to update the contract root (!dryRun), we have enough data
-			// to construct a merkle tree.
-			if !cfg.DryRun && enoughData {
+			// to construct a merkle tree and the state was reconciled (if required).
+			if !cfg.DryRun && enoughData && reconciled {
 				// If the new state is the one onchain + checkpoint size then its time to update the root
 				// Then we can update the new merkle root. onchainSlot == 0 is an special case when the
 				// contract was just initialized and there is no root yet.
-				if newState.Slot == onchainSlot+cfg.CheckPointSizeInSlots {
+				if newState.Slot == onchainSlot+checkpointSize {
 					log.WithFields(log.Fields{
 						"Root": newState.MerkleRoot,
 						"Slot": newState.Slot,
@@ -494,3 +525,33 @@ func mainLoop(oracleInstance *oracle.Oracle, onchain *oracle.Onchain, cfg *oracl
 		}
 	}
 }
+
+// Captures the pool balance at the latest processed block and reconciles it with the state, storing
+// the report. Must be called when the latest processed block is the latest finalized one, which non
+// archival nodes still keep. Failures are logged and stored as failed reports.
+func runOnchainReconciliation(oracleInstance *oracle.Oracle, onchain *oracle.Onchain) {
+	latestBlock := oracleInstance.State().LatestProcessedBlock
+	if latestBlock == 0 {
+		log.Warn("No block processed yet, skipping onchain reconciliation")
+		return
+	}
+	blockNumber := new(big.Int).SetUint64(latestBlock)
+
+	poolEthBalanceWei, err := onchain.GetPoolEthBalance(blockNumber, reconciliationRetryOpts...)
+	if err != nil {
+		oracleInstance.RecordFailedReconciliation(errors.Wrap(err, "could not get pool eth balance"))
+		log.Error("Could not get pool eth balance for reconciliation at block ", latestBlock, ": ", err)
+		return
+	}
+
+	report, err := oracleInstance.RunOnchainReconciliation(poolEthBalanceWei)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"Slot":           report.Slot,
+			"Block":          report.Block,
+			"AssetsWei":      report.AssetsWei,
+			"LiabilitiesWei": report.LiabilitiesWei,
+			"DeltaWei":       report.DeltaWei,
+		}).Error("Onchain reconciliation failed: ", err)
+	}
+}
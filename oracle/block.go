@@ -9,6 +9,8 @@ import (
 	v1 "github.com/attestantio/go-eth2-client/api/v1"
 	"github.com/attestantio/go-eth2-client/spec"
 	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/electra"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/dappnode/mev-sp-oracle/contract"
 	"github.com/dappnode/mev-sp-oracle/utils"
@@ -80,19 +82,19 @@ func NewFullBlock(
 }
 
 // Add consensus data the the full block. Done always unless when the block is missed
-func (b *FullBlock) SetConsensusBlock(consensusBlock *spec.VersionedSignedBeaconBlock) {
+func (b *FullBlock) SetConsensusBlock(consensusBlock *spec.VersionedSignedBeaconBlock) error {
 	if consensusBlock == nil {
-		log.Fatal("consensus block can't be nil")
+		return errors.New("consensus block can't be nil")
 	}
 
 	cBlockSlot, err := consensusBlock.Slot()
 	if err != nil {
-		log.Fatal("failed to get slot from consensus block: ", err)
+		return errors.Wrap(err, "failed to get slot from consensus block")
 	}
 
 	if b.ConsensusDuty.Slot != cBlockSlot {
-		log.Fatal("Slot mismatch between consensus duty and consensus block: ",
-			b.ConsensusDuty.Slot, " vs ", cBlockSlot)
+		return errors.New(fmt.Sprintf("slot mismatch between consensus duty and consensus block: %d vs %d",
+			b.ConsensusDuty.Slot, cBlockSlot))
 	}
 
 	// Expand for upcoming forks
@@ -110,22 +112,23 @@ func (b *FullBlock) SetConsensusBlock(consensusBlock *spec.VersionedSignedBeacon
 	} else if consensusBlock.Fulu != nil {
 		proposerIndex = uint64(consensusBlock.Fulu.Message.ProposerIndex)
 	} else {
-		log.Fatal("Block was empty, cant get proposer index")
+		return errors.New("block was empty, cant get proposer index")
 	}
 
 	// Sanity check
 	if uint64(b.ConsensusDuty.ValidatorIndex) != proposerIndex {
-		log.Fatal("Proposer index mismatch between consensus duty and consensus block: ",
-			b.ConsensusDuty.ValidatorIndex, " vs ", proposerIndex)
+		return errors.New(fmt.Sprintf("proposer index mismatch between consensus duty and consensus block: %d vs %d",
+			b.ConsensusDuty.ValidatorIndex, proposerIndex))
 	}
 
 	b.ConsensusBlock = consensusBlock
+	return nil
 }
 
 // Add header and receipts. Only needeed when the block i) sends reward to pool (auto/manual sub)
 // or ii) the block belongs to a member of the pool. In blocks we are not interested, this can be
 // skipped as fecthing this information is too expensive to do it for every single block.
-func (b *FullBlock) SetHeaderAndReceipts(header *types.Header, receipts []*types.Receipt) {
+func (b *FullBlock) SetHeaderAndReceipts(header *types.Header, receipts []*types.Receipt) error {
 	// Some sanity checks
 	if header == nil || receipts == nil {
 		log.Fatal("header or receipts can't be nil",
@@ -141,24 +144,96 @@ func (b *FullBlock) SetHeaderAndReceipts(header *types.Header, receipts []*types
 	}
 
 	if b.GetBlockNumberBigInt().Uint64() != header.Number.Uint64() {
-		log.Fatal("Block number mismatch with header: ",
-			b.GetBlockNumberBigInt().Uint64(), " vs ", header.Number.Uint64())
+		return errors.New(fmt.Sprintf("block number mismatch with header: %d vs %d",
+			b.GetBlockNumberBigInt().Uint64(), header.Number.Uint64()))
 	}
 
 	if len(receipts) != 0 {
 		if b.GetBlockNumberBigInt().Uint64() != receipts[0].BlockNumber.Uint64() {
-			log.Fatal("Block number mismatch with receipts: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", receipts[0].BlockNumber.Uint64())
+			return errors.New(fmt.Sprintf("block number mismatch with receipts: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), receipts[0].BlockNumber.Uint64()))
 		}
 	}
 
 	b.ExecutionHeader = header
 	b.ExecutionReceipts = receipts
+	return nil
+}
+
+// Returns an error if the header or the receipts, fetched by block number, are not the ones of the
+// execution payload of this block. Only possible with blocks that can be reorged
+func (b *FullBlock) CheckHeaderBlockHash(header *types.Header, receipts []*types.Receipt) error {
+	blockHash := b.GetBlockHash()
+	if header != nil && header.Hash() != blockHash {
+		return errors.New(fmt.Sprintf("block hash mismatch with header at block %d: %s vs %s",
+			b.GetBlockNumber(), blockHash.String(), header.Hash().String()))
+	}
+	for _, receipt := range receipts {
+		if receipt.BlockHash != blockHash {
+			return errors.New(fmt.Sprintf("block hash mismatch with receipts at block %d: %s vs %s",
+				b.GetBlockNumber(), blockHash.String(), receipt.BlockHash.String()))
+		}
+	}
+	return nil
+}
+
+// Returns an error if any of the events, fetched by block number, was emitted in a block other
+// than the execution payload of this block. Only possible with blocks that can be reorged
+func (b *FullBlock) CheckEventsBlockHash(events *Events) error {
+	logs := make([]types.Log, 0)
+	for _, event := range events.EtherReceived {
+		logs = append(logs, event.Raw)
+	}
+	for _, event := range events.SubscribeValidator {
+		logs = append(logs, event.Raw)
+	}
+	for _, event := range events.ClaimRewards {
+		logs = append(logs, event.Raw)
+	}
+	for _, event := range events.SetRewardRecipient {
+		logs = append(logs, event.Raw)
+	}
+	for _, event := range events.UnsubscribeValidator {
+		logs = append(logs, event.Raw)
+	}
+	for _, event := range events.UpdatePoolFee {
+		logs = append(logs, event.Raw)
+	}
+	for _, event := range events.PoolFeeRecipient {
+		logs = append(logs, event.Raw)
+	}
+	for _, event := range events.CheckpointSlotSize {
+		logs = append(logs, event.Raw)
+	}
+	for _, event := range events.UpdateSubscriptionCollateral {
+		logs = append(logs, event.Raw)
+	}
+	for _, event := range events.SubmitReport {
+		logs = append(logs, event.Raw)
+	}
+	for _, event := range events.ReportConsolidated {
+		logs = append(logs, event.Raw)
+	}
+	for _, event := range events.BanValidator {
+		logs = append(logs, event.Raw)
+	}
+	for _, event := range events.UnbanValidator {
+		logs = append(logs, event.Raw)
+	}
+
+	blockHash := b.GetBlockHash()
+	for _, raw := range logs {
+		if raw.BlockHash != blockHash {
+			return errors.New(fmt.Sprintf("block hash mismatch in events at block %d: %s vs %s, tx: %s",
+				b.GetBlockNumber(), blockHash.String(), raw.BlockHash.String(), raw.TxHash.String()))
+		}
+	}
+	return nil
 }
 
 // Set the events that were triggered in this block. This shall be done always unless the block
 // was missed.
-func (b *FullBlock) SetEvents(events *Events) {
+func (b *FullBlock) SetEvents(events *Events) error {
 	// Some sanity checks
 	if events == nil {
 		log.Fatal("events can't be nil")
@@ -167,120 +242,120 @@ func (b *FullBlock) SetEvents(events *Events) {
 	// More sanity checks, boilerplate but safe
 	for _, event := range events.EtherReceived {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in etherReceived events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in etherReceived events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
 	for _, event := range events.SubscribeValidator {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in subscribeValidator events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in subscribeValidator events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
 	for _, event := range events.ClaimRewards {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in claimRewards events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in claimRewards events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
 	for _, event := range events.SetRewardRecipient {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in setRewardRecipient events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in setRewardRecipient events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
 	for _, event := range events.UnsubscribeValidator {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in unsubscribeValidator events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in unsubscribeValidator events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
 	for _, event := range events.InitSmoothingPool {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in initSmoothingPool events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in initSmoothingPool events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
 	for _, event := range events.UpdatePoolFee {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in updatePoolFee events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in updatePoolFee events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
 	for _, event := range events.PoolFeeRecipient {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in poolFeeRecipient events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in poolFeeRecipient events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
 	for _, event := range events.CheckpointSlotSize {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in checkpointSlotSize events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in checkpointSlotSize events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
 	for _, event := range events.UpdateSubscriptionCollateral {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in updateSubscriptionCollateral events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in updateSubscriptionCollateral events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
 	for _, event := range events.SubmitReport {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in submitReport events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in submitReport events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
 	for _, event := range events.ReportConsolidated {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in reportConsolidated events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in reportConsolidated events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
 	for _, event := range events.UpdateQuorum {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in updateQuorum events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in updateQuorum events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
 	for _, event := range events.AddOracleMember {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in addOracleMember events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in addOracleMember events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
 	for _, event := range events.RemoveOracleMember {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in removeOracleMember events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in removeOracleMember events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
 	for _, event := range events.TransferGovernance {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in transferGovernance events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in transferGovernance events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
 	for _, event := range events.AcceptGovernance {
 		if b.GetBlockNumberBigInt().Uint64() != event.Raw.BlockNumber {
-			log.Fatal("Block number mismatch in acceptGovernance events: ",
-				b.GetBlockNumberBigInt().Uint64(), " vs ", event.Raw.BlockNumber)
+			return errors.New(fmt.Sprintf("block number mismatch in acceptGovernance events: %d vs %d",
+				b.GetBlockNumberBigInt().Uint64(), event.Raw.BlockNumber))
 		}
 	}
 
@@ -307,6 +382,7 @@ func (b *FullBlock) SetEvents(events *Events) {
 			},
 		})
 	}
+	return nil
 }
 
 // Returns if there was an mev reward and its amount and fee recipient if any
@@ -402,7 +478,7 @@ func (b *FullBlock) MevRewardInWei() (*big.Int, bool, string) {
 // For the oracle, a reward is either one type or the other. It cannot be both
 func (b *FullBlock) GetSentRewardAndType(
 	poolAddress string,
-	isSubscriber bool) (*big.Int, bool, RewardType) {
+	isSubscriber bool) (*big.Int, bool, RewardType, error) {
 
 	var reward *big.Int = big.NewInt(0)
 	var txType RewardType = UnknownRewardType
@@ -422,7 +498,7 @@ func (b *FullBlock) GetSentRewardAndType(
 			wasRewardSent = true
 		}
 
-		return reward, wasRewardSent, txType
+		return reward, wasRewardSent, txType, nil
 	}
 
 	// ii) check if vanila reward (calculating this is expensive as requires headers)
@@ -431,7 +507,7 @@ func (b *FullBlock) GetSentRewardAndType(
 	if utils.Equals(b.GetFeeRecipient(), poolAddress) || isSubscriber {
 		vanilaReward, err := b.GetProposerTip()
 		if err != nil {
-			log.Fatal("could not get proposer tip: ", err)
+			return nil, false, UnknownRewardType, errors.Wrap(err, "could not get proposer tip")
 		}
 
 		if utils.Equals(b.GetFeeRecipient(), poolAddress) {
@@ -444,7 +520,7 @@ func (b *FullBlock) GetSentRewardAndType(
 		reward = vanilaReward
 	}
 
-	return reward, wasRewardSent, txType
+	return reward, wasRewardSent, txType, nil
 }
 
 func (b *FullBlock) isAddressRewarded(address string) bool {
@@ -525,11 +601,11 @@ func (b *FullBlock) GetProposerTip() (*big.Int, error) {
 // normal tx: https://goerli.etherscan.io/tx/0xfeda23c2e9db46e69615a8bec74c4a9f3f9f7eb650659a13c9ad1f394c13698d
 // via sc: https://goerli.etherscan.io/tx/0x277cec5bcb60852b160a29dc9082b7e18a44333194cbe9c7d7b664e4b89b8c46
 // This fuction detects both by checking the tx and the EtherReceived event
-func (b *FullBlock) GetDonations(poolAddress string) []*contract.ContractEtherReceived {
+func (b *FullBlock) GetDonations(poolAddress string) ([]*contract.ContractEtherReceived, error) {
 
 	// If the block was missed, there cant be any donations
 	if b.ConsensusBlock == nil {
-		return []*contract.ContractEtherReceived{}
+		return []*contract.ContractEtherReceived{}, nil
 	}
 
 	// Leaving for reference. Donations via "normal tx" are detected with this
@@ -569,7 +645,7 @@ func (b *FullBlock) GetDonations(poolAddress string) []*contract.ContractEtherRe
 	if !isMev || !utils.Equals(mevRec, poolAddress) {
 		// In this case we dont expect any etherReceived event due to MEV
 		// All events are donations
-		return b.Events.EtherReceived
+		return b.Events.EtherReceived, nil
 	}
 
 	// If the pool got an mev reward, we must filter the mev reward
@@ -586,15 +662,15 @@ func (b *FullBlock) GetDonations(poolAddress string) []*contract.ContractEtherRe
 
 	// Sanity check
 	if !foundMev {
-		log.Fatal("An mev reward was expected but could not find it. "+
-			"Wanted reward: ", mevReward, " Events: ", b.Events.EtherReceived)
+		return nil, errors.New(fmt.Sprintf("an mev reward was expected but could not find it. wanted reward: %s, events: %d",
+			mevReward.String(), len(b.Events.EtherReceived)))
 	}
 
-	return filteredEvents
+	return filteredEvents, nil
 }
 
 // Since storing the full block is expensive, we store a summarized version of it
-func (b *FullBlock) SummarizedBlock(oracle *Oracle, poolAddress string) SummarizedBlock {
+func (b *FullBlock) SummarizedBlock(oracle *Oracle, poolAddress string) (SummarizedBlock, error) {
 
 	// Get the withdrawal credentials and type of the validator that should propose the block
 	withdrawalAddress, withdrawalType := GetWithdrawalAndType(b.Validator)
@@ -611,14 +687,17 @@ func (b *FullBlock) SummarizedBlock(oracle *Oracle, poolAddress string) Summariz
 	if b.ConsensusBlock == nil {
 		// nil means missed proposal
 		poolBlock.BlockType = MissedProposal
-		return poolBlock
+		return poolBlock, nil
 
 	} else {
 		// Check if the proposer is subscribed to the pool
 		isFromSubscriber := oracle.isSubscribed(b.GetProposerIndexUint64())
 
 		// Fetch block information
-		reward, correctFeeRec, rewardType := b.GetSentRewardAndType(poolAddress, isFromSubscriber)
+		reward, correctFeeRec, rewardType, err := b.GetSentRewardAndType(poolAddress, isFromSubscriber)
+		if err != nil {
+			return SummarizedBlock{}, err
+		}
 
 		// Populate common parameters
 		poolBlock.Reward = reward
@@ -635,7 +714,7 @@ func (b *FullBlock) SummarizedBlock(oracle *Oracle, poolAddress string) Summariz
 			} else if withdrawalType == ElectraWithdrawal {
 				poolBlock.BlockType = OkPoolProposal
 			} else {
-				log.Fatal("Unknown withdrawal type: ", withdrawalType)
+				return SummarizedBlock{}, errors.New(fmt.Sprintf("unknown withdrawal type: %d", withdrawalType))
 			}
 		} else {
 			// If the fee recipient was wrong
@@ -643,7 +722,7 @@ func (b *FullBlock) SummarizedBlock(oracle *Oracle, poolAddress string) Summariz
 		}
 	}
 
-	return poolBlock
+	return poolBlock, nil
 }
 
 // Returns the fee recipient of the block, depending on the fork version
@@ -712,6 +791,28 @@ func (b *FullBlock) GetBlockNumber() uint64 {
 	return blockNumber
 }
 
+// Returns the execution block hash depending on the fork version
+func (b *FullBlock) GetBlockHash() common.Hash {
+	var blockHash common.Hash
+
+	if b.ConsensusBlock.Altair != nil {
+		log.Fatal("Altair block has no block hash")
+	} else if b.ConsensusBlock.Bellatrix != nil {
+		blockHash = common.Hash(b.ConsensusBlock.Bellatrix.Message.Body.ExecutionPayload.BlockHash)
+	} else if b.ConsensusBlock.Capella != nil {
+		blockHash = common.Hash(b.ConsensusBlock.Capella.Message.Body.ExecutionPayload.BlockHash)
+	} else if b.ConsensusBlock.Deneb != nil {
+		blockHash = common.Hash(b.ConsensusBlock.Deneb.Message.Body.ExecutionPayload.BlockHash)
+	} else if b.ConsensusBlock.Electra != nil {
+		blockHash = common.Hash(b.ConsensusBlock.Electra.Message.Body.ExecutionPayload.BlockHash)
+	} else if b.ConsensusBlock.Fulu != nil {
+		blockHash = common.Hash(b.ConsensusBlock.Fulu.Message.Body.ExecutionPayload.BlockHash)
+	} else {
+		log.Fatal("Block was empty, cant get block hash")
+	}
+	return blockHash
+}
+
 // Returns the block number depending on the fork version (as big.Int)
 func (b *FullBlock) GetBlockNumberBigInt() *big.Int {
 	return new(big.Int).SetUint64(b.GetBlockNumber())
@@ -829,3 +930,104 @@ func (b *FullBlock) GetBaseFeePerGas() [32]byte {
 	}
 	return baseFeePerGas
 }
+
+// Returns the EIP-7251 consolidation requests of the block. Empty for missed blocks
+// and blocks before Electra, which have no execution requests.
+func (b *FullBlock) GetConsolidationRequests() []*electra.ConsolidationRequest {
+	if b.ConsensusBlock == nil {
+		return nil
+	}
+	executionRequests, err := b.ConsensusBlock.ExecutionRequests()
+	if err != nil || executionRequests == nil {
+		return nil
+	}
+	return executionRequests.Consolidations
+}
+
+// Returns the indices of the validators slashed by the proposer and attester slashings of
+// the block. Attester slashings slash the validators that signed both attestations.
+func (b *FullBlock) GetSlashedIndices() []uint64 {
+	slashed := make([]uint64, 0)
+	if b.ConsensusBlock == nil {
+		return slashed
+	}
+	proposerSlashings, err := b.ConsensusBlock.ProposerSlashings()
+	if err == nil {
+		for _, slashing := range proposerSlashings {
+			slashed = append(slashed, uint64(slashing.SignedHeader1.Message.ProposerIndex))
+		}
+	}
+	attesterSlashings, err := b.ConsensusBlock.AttesterSlashings()
+	if err == nil {
+		for _, slashing := range attesterSlashings {
+			attestation1, err1 := slashing.Attestation1()
+			attestation2, err2 := slashing.Attestation2()
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			indices1, err1 := attestation1.AttestingIndices()
+			indices2, err2 := attestation2.AttestingIndices()
+			if err1 != nil || err2 != nil {
+				continue
+			}
+			inBoth := make(map[uint64]bool, len(indices1))
+			for _, index := range indices1 {
+				inBoth[index] = true
+			}
+			for _, index := range indices2 {
+				if inBoth[index] {
+					slashed = append(slashed, index)
+					inBoth[index] = false
+				}
+			}
+		}
+	}
+	return slashed
+}
+
+// Returns the indices of the validators that requested a voluntary exit in the block
+func (b *FullBlock) GetVoluntaryExitIndices() []uint64 {
+	exits := make([]uint64, 0)
+	if b.ConsensusBlock == nil {
+		return exits
+	}
+	voluntaryExits, err := b.ConsensusBlock.VoluntaryExits()
+	if err != nil {
+		return exits
+	}
+	for _, exit := range voluntaryExits {
+		exits = append(exits, uint64(exit.Message.ValidatorIndex))
+	}
+	return exits
+}
+
+// Returns the EIP-7002 withdrawal requests of the block that request a full exit (zero
+// amount). Partial withdrawals dont exit the validator. The beacon chain may still reject them.
+func (b *FullBlock) GetFullExitRequests() []*electra.WithdrawalRequest {
+	fullExits := make([]*electra.WithdrawalRequest, 0)
+	if b.ConsensusBlock == nil {
+		return fullExits
+	}
+	executionRequests, err := b.ConsensusBlock.ExecutionRequests()
+	if err != nil || executionRequests == nil {
+		return fullExits
+	}
+	for _, request := range executionRequests.Withdrawals {
+		if request.Amount == 0 {
+			fullExits = append(fullExits, request)
+		}
+	}
+	return fullExits
+}
+
+// Returns the BLS to execution credentials changes of the block. Empty before Capella.
+func (b *FullBlock) GetBlsToExecutionChanges() []*capella.SignedBLSToExecutionChange {
+	if b.ConsensusBlock == nil {
+		return nil
+	}
+	changes, err := b.ConsensusBlock.BLSToExecutionChanges()
+	if err != nil {
+		return nil
+	}
+	return changes
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/attestantio/go-eth2-client/spec/altair"
 	"github.com/attestantio/go-eth2-client/spec/bellatrix"
 	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/electra"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
 	"github.com/dappnode/mev-sp-oracle/contract"
 	"github.com/ethereum/go-ethereum/common"
@@ -50,7 +51,7 @@ func Test_Getters_Bellatrix(t *testing.T) {
 			Index: 12,
 		},
 		uint64(0))
-	fullBlock.SetConsensusBlock(block)
+	require.NoError(t, fullBlock.SetConsensusBlock(block))
 
 	require.Equal(t, [32]uint8([32]uint8{0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0}), fullBlock.GetBaseFeePerGas())
 	require.Equal(t, uint64(0), fullBlock.GetGasUsed())
@@ -91,7 +92,7 @@ func Test_Getters_Capella(t *testing.T) {
 			Index: 12,
 		},
 		uint64(0))
-	fullBlock.SetConsensusBlock(block)
+	require.NoError(t, fullBlock.SetConsensusBlock(block))
 
 	require.Equal(t, [32]uint8([32]uint8{0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x0}), fullBlock.GetBaseFeePerGas())
 	require.Equal(t, uint64(0), fullBlock.GetGasUsed())
@@ -178,8 +179,10 @@ func Test_FullBlock_All(t *testing.T) {
 			require.NoError(t, err)
 			feeRecipient := fullBlock.GetFeeRecipient()
 			proposerIndex := fullBlock.GetProposerIndex()
-			donations := fullBlock.GetDonations(tt.PoolAddress)
-			sentReward, sent, rewardType := fullBlock.GetSentRewardAndType(tt.PoolAddress, tt.WithHeeaders)
+			donations, err := fullBlock.GetDonations(tt.PoolAddress)
+			require.NoError(t, err)
+			sentReward, sent, rewardType, err := fullBlock.GetSentRewardAndType(tt.PoolAddress, tt.WithHeeaders)
+			require.NoError(t, err)
 			mevReward, mevFound, mevRecipient := fullBlock.MevRewardInWei()
 
 			// Assert
@@ -274,12 +277,13 @@ func Test_SummarizedBlock(t *testing.T) {
 			oracle.state.PoolAddress = tt.PoolAddress
 
 			if tt.ProposerSubscribed {
-				oracle.addSubscription(tt.ExpectedValidatorIndex, "0x", "0x")
+				oracle.addSubscription(tt.ExpectedValidatorIndex, "0x", "0x", TransitionCause{})
 			}
 
 			fullBlock, err := LoadFullBlock(tt.Slot, "5", tt.ProposerSubscribed)
 			require.NoError(t, err)
-			block := fullBlock.SummarizedBlock(oracle, tt.PoolAddress)
+			block, err := fullBlock.SummarizedBlock(oracle, tt.PoolAddress)
+			require.NoError(t, err)
 
 			require.Equal(t, tt.Slot, block.Slot)
 			require.Equal(t, tt.ExpectedBlock, block.Block)
@@ -373,9 +377,9 @@ func Test_Marashal_FullBlock(t *testing.T) {
 
 	// Creates the full block with above data
 	fullBlock := NewFullBlock(proposalDuty, validator, uint64(0))
-	fullBlock.SetConsensusBlock(block)
-	fullBlock.SetEvents(events)
-	fullBlock.SetHeaderAndReceipts(header, receipts)
+	require.NoError(t, fullBlock.SetConsensusBlock(block))
+	require.NoError(t, fullBlock.SetEvents(events))
+	require.NoError(t, fullBlock.SetHeaderAndReceipts(header, receipts))
 
 	// Serialize the fullblock
 	jsonData, err := json.MarshalIndent(fullBlock, "", " ")
@@ -448,3 +452,32 @@ func LoadValidators() (map[phase0.ValidatorIndex]*v1.Validator, error) {
 
 	return validators, nil
 }
+
+func Test_CheckBlockHash(t *testing.T) {
+	header := &types.Header{Number: big.NewInt(1001), Difficulty: big.NewInt(0)}
+	block := proposedBlockWithBody(1001, &electra.BeaconBlockBody{})
+	block.ConsensusBlock.Electra.Message.Body.ExecutionPayload.BlockHash = phase0.Hash32(header.Hash())
+	require.Equal(t, header.Hash(), block.GetBlockHash())
+
+	// Data of the proposed block
+	require.NoError(t, block.CheckHeaderBlockHash(header, []*types.Receipt{{BlockHash: header.Hash()}}))
+	require.NoError(t, block.CheckEventsBlockHash(&Events{
+		EtherReceived: []*contract.ContractEtherReceived{{Raw: types.Log{BlockHash: header.Hash()}}},
+	}))
+	require.NoError(t, block.CheckEventsBlockHash(&Events{}))
+
+	// Data of another block at the same height, after a reorg
+	reorged := common.HexToHash("0x01")
+	err := block.CheckHeaderBlockHash(&types.Header{Number: big.NewInt(1001), Difficulty: big.NewInt(1)}, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "block hash mismatch with header")
+	err = block.CheckHeaderBlockHash(header, []*types.Receipt{{BlockHash: reorged}})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "block hash mismatch with receipts")
+	err = block.CheckEventsBlockHash(&Events{
+		EtherReceived:      []*contract.ContractEtherReceived{{Raw: types.Log{BlockHash: header.Hash()}}},
+		SubscribeValidator: []*contract.ContractSubscribeValidator{{Raw: types.Log{BlockHash: reorged}}},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "block hash mismatch in events")
+}
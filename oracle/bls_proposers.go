@@ -0,0 +1,176 @@
+package oracle
+
+import (
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/dappnode/mev-sp-oracle/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Remembers a correct proposal of a validator with BLS credentials, whose reward went to the
+// pool. Only since the bls rotation fork
+func (or *Oracle) rememberBlsProposal(block SummarizedBlock) {
+	if !isForkActive(SlotBlsRotationFork, or.cfg.Network, block.Slot) {
+		return
+	}
+	or.state.BlsProposers = addBlsProposal(or.state.BlsProposers, block)
+}
+
+// Auto subscribes the BLS proposers that rotate to execution credentials in the block
+func (or *Oracle) handleBlsToExecutionChanges(fullBlock *FullBlock) {
+	for _, change := range fullBlock.GetBlsToExecutionChanges() {
+		valIndex := uint64(change.Message.ValidatorIndex)
+		proposer, found := or.state.BlsProposers[valIndex]
+		if !found || proposer.RotationSlot != 0 {
+			continue
+		}
+		or.rotateBlsProposer(valIndex, strings.ToLower(change.Message.ToExecutionAddress.String()))
+	}
+}
+
+// Checks the credentials of the BLS proposers that did not rotate yet. Safety net for
+// rotations that were not seen in a processed block, eg done before the proposal was remembered.
+func (or *Oracle) checkBlsProposersRotation(slot uint64) error {
+	indices := make([]phase0.ValidatorIndex, 0)
+	for valIndex, proposer := range or.state.BlsProposers {
+		if proposer.RotationSlot == 0 {
+			indices = append(indices, phase0.ValidatorIndex(valIndex))
+		}
+	}
+	if len(indices) == 0 {
+		return nil
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+
+	validators, err := or.getSetOfValidators(indices, strconv.FormatUint(slot, 10))
+	if err != nil {
+		return errors.Wrap(err, "could not get bls proposers info")
+	}
+	for _, valIndex := range indices {
+		validator, found := validators[valIndex]
+		if !found || validator.Validator == nil || !validator.Status.IsActive() {
+			continue
+		}
+		withdrawalAddress, err := utils.GetCompatibleAddressByte(validator.Validator.WithdrawalCredentials)
+		if err != nil {
+			continue
+		}
+		or.rotateBlsProposer(uint64(valIndex), withdrawalAddress)
+	}
+	return nil
+}
+
+// Auto subscribes a BLS proposer that now has an execution withdrawal address. Since the bls
+// credit slot, the rewards of its past proposals are moved from the pool fees to its pending
+// rewards, up to what the pool fees addresses have not claimed yet.
+func (or *Oracle) rotateBlsProposer(valIndex uint64, withdrawalAddress string) {
+	proposer := or.state.BlsProposers[valIndex]
+	proposer.RotationSlot = or.state.NextSlotToProcess
+	proposer.WithdrawalAddress = withdrawalAddress
+
+	if or.isTracked(valIndex) {
+		log.WithFields(log.Fields{
+			"ValidatorIndex":  valIndex,
+			"ValidatorStatus": or.state.Validators[valIndex].ValidatorStatus,
+		}).Warn("[BLS] Rotated bls proposer is already tracked, not subscribing it")
+		return
+	}
+	or.addSubscription(valIndex, withdrawalAddress, proposer.ValidatorKey,
+		TransitionCause{Reason: "bls proposer rotated to execution credentials"})
+
+	if config, _ := configAtSlot(or.state.ConfigHistory, proposer.RotationSlot); config.BlsCredit {
+		unclaimedFees := new(big.Int).Sub(or.state.PoolAccumulatedFees, or.claimedPoolFeesLockFree())
+		credit := new(big.Int).Set(proposer.RewardsWei)
+		if credit.Cmp(unclaimedFees) > 0 {
+			credit.Set(unclaimedFees)
+		}
+		if credit.Sign() > 0 {
+			or.state.PoolAccumulatedFees.Sub(or.state.PoolAccumulatedFees, credit)
+			or.trackLiabilities(nil, nil, new(big.Int).Neg(credit))
+			or.increaseValidatorPendingRewards(valIndex, credit)
+			proposer.CreditedWei = credit
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"ValidatorIndex":    valIndex,
+		"WithdrawalAddress": withdrawalAddress,
+		"Proposals":         len(proposer.ProposalSlots),
+		"RewardsWei":        proposer.RewardsWei,
+		"CreditedWei":       proposer.CreditedWei,
+		"Slot":              proposer.RotationSlot,
+	}).Info("[BLS] Bls proposer rotated to execution credentials, auto subscribing it")
+}
+
+// Returns what all the addresses that received the pool fees have claimed. The pool fees
+// address can be changed by governance, and the previous ones may have claimed some
+func (or *Oracle) claimedPoolFeesLockFree() *big.Int {
+	claimed := big.NewInt(0)
+	seen := make(map[string]bool)
+	for _, change := range or.state.ConfigHistory {
+		address := strings.ToLower(change.PoolFeesAddress)
+		if seen[address] {
+			continue
+		}
+		seen[address] = true
+		claimed.Add(claimed, ClaimedRewardsOf(or.state, address))
+	}
+	return claimed
+}
+
+// Returns the bls proposer info of the validator, if it proposed to the pool with BLS credentials
+func (or *Oracle) BlsProposer(valIndex uint64) (BlsProposerInfo, bool) {
+	or.mutex.RLock()
+	defer or.mutex.RUnlock()
+
+	proposer, found := or.state.BlsProposers[valIndex]
+	if !found {
+		return BlsProposerInfo{}, false
+	}
+	return copyBlsProposer(proposer), true
+}
+
+func copyBlsProposer(proposer *BlsProposerInfo) BlsProposerInfo {
+	proposerCopy := *proposer
+	proposerCopy.ProposalSlots = append([]uint64{}, proposer.ProposalSlots...)
+	proposerCopy.RewardsWei = copyBigInt(proposer.RewardsWei)
+	proposerCopy.CreditedWei = copyBigInt(proposer.CreditedWei)
+	return proposerCopy
+}
+
+// Rebuilds the bls proposers of states created before they were remembered, from their
+// proposed blocks since the bls rotation fork. Rotations already done are found by the next cleanup.
+func backfillBlsProposers(state *OracleState) {
+	if state.BlsProposers != nil {
+		return
+	}
+	for _, block := range state.ProposedBlocks {
+		if block.BlockType == OkPoolProposalBlsKeys && isForkActive(SlotBlsRotationFork, state.Network, block.Slot) {
+			state.BlsProposers = addBlsProposal(state.BlsProposers, block)
+		}
+	}
+}
+
+func addBlsProposal(proposers map[uint64]*BlsProposerInfo, block SummarizedBlock) map[uint64]*BlsProposerInfo {
+	if proposers == nil {
+		proposers = make(map[uint64]*BlsProposerInfo)
+	}
+	proposer, found := proposers[block.ValidatorIndex]
+	if !found {
+		proposer = &BlsProposerInfo{
+			ValidatorIndex: block.ValidatorIndex,
+			ValidatorKey:   block.ValidatorKey,
+			ProposalSlots:  make([]uint64, 0),
+			RewardsWei:     big.NewInt(0),
+		}
+		proposers[block.ValidatorIndex] = proposer
+	}
+	proposer.ProposalSlots = append(proposer.ProposalSlots, block.Slot)
+	proposer.RewardsWei.Add(proposer.RewardsWei, block.Reward)
+	return proposers
+}
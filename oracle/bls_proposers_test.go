@@ -0,0 +1,209 @@
+package oracle
+
+import (
+	"math/big"
+	"testing"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/bellatrix"
+	"github.com/attestantio/go-eth2-client/spec/capella"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+// Helper to create a correct proposal of a validator with BLS credentials
+func blsProposalBlock(slot uint64, valIndex uint64, reward int64) SummarizedBlock {
+	return SummarizedBlock{
+		Slot:           slot,
+		ValidatorIndex: valIndex,
+		ValidatorKey:   "0x" + common.Bytes2Hex([]byte{byte(valIndex)}),
+		BlockType:      OkPoolProposalBlsKeys,
+		RewardType:     VanilaBlock,
+		Reward:         big.NewInt(reward),
+	}
+}
+
+// Helper to create a FullBlock with BLS to execution changes
+func fullBlockWithBlsChanges(slot uint64, valIndex uint64, address string) *FullBlock {
+	return fullBlockWithBody(slot, &electra.BeaconBlockBody{
+		BLSToExecutionChanges: []*capella.SignedBLSToExecutionChange{{
+			Message: &capella.BLSToExecutionChange{
+				ValidatorIndex:     phase0.ValidatorIndex(valIndex),
+				ToExecutionAddress: bellatrix.ExecutionAddress(common.HexToAddress(address)),
+			},
+		}},
+	})
+}
+
+func Test_BlsProposer_RotationWithoutCredit(t *testing.T) {
+	activateFork(t, SlotBlsRotationFork, Mainnet, 1000)
+	cold := "0x00000000000000000000000000000000000000a1"
+
+	oracle := testOracle(Mainnet, 0)
+	oracle.handleBlsCorrectBlockProposal(blsProposalBlock(1001, 7, 100))
+	oracle.handleBlsCorrectBlockProposal(blsProposalBlock(1050, 7, 50))
+	require.Equal(t, big.NewInt(150), oracle.state.PoolAccumulatedFees)
+	require.False(t, oracle.isTracked(7))
+
+	proposer, found := oracle.BlsProposer(7)
+	require.True(t, found)
+	require.Equal(t, []uint64{1001, 1050}, proposer.ProposalSlots)
+	require.Equal(t, big.NewInt(150), proposer.RewardsWei)
+
+	// Changes of other validators are ignored
+	oracle.state.NextSlotToProcess = 1100
+	oracle.handleBlsToExecutionChanges(fullBlockWithBlsChanges(1100, 8, cold))
+	require.False(t, oracle.isTracked(8))
+
+	oracle.handleBlsToExecutionChanges(fullBlockWithBlsChanges(1100, 7, cold))
+	require.Equal(t, Active, oracle.state.Validators[7].ValidatorStatus)
+	require.Equal(t, Auto, oracle.state.Validators[7].SubscriptionType)
+	require.Equal(t, cold, oracle.state.Validators[7].WithdrawalAddress)
+	require.Equal(t, big.NewInt(0), oracle.state.Validators[7].PendingRewardsWei)
+	require.Equal(t, big.NewInt(150), oracle.state.PoolAccumulatedFees)
+
+	proposer, _ = oracle.BlsProposer(7)
+	require.Equal(t, uint64(1100), proposer.RotationSlot)
+	require.Equal(t, cold, proposer.WithdrawalAddress)
+	require.Nil(t, proposer.CreditedWei)
+}
+
+func Test_BlsProposer_RotationWithCredit(t *testing.T) {
+	activateFork(t, SlotBlsRotationFork, Mainnet, 1000)
+	cold := "0x00000000000000000000000000000000000000a1"
+
+	cfg := testConfig(Mainnet, 0)
+	cfg.BlsCreditSlot = 1000
+	oracle := NewOracle(cfg)
+	oracle.handleBlsCorrectBlockProposal(blsProposalBlock(1001, 7, 100))
+	oracle.handleBlsCorrectBlockProposal(blsProposalBlock(1002, 8, 60))
+
+	// The pool fees addresses already claimed part of the fees, which cant be credited back. Also
+	// the ones claimed by the address that received them before governance changed it
+	newFeesAddress := "0x00000000000000000000000000000000000000f2"
+	change := oracle.state.ConfigHistory[0]
+	change.Slot = 1050
+	change.PoolFeesAddress = newFeesAddress
+	oracle.state.ConfigHistory = append(oracle.state.ConfigHistory, change)
+	oracle.applyClaim(Claim{WithdrawalAddress: oracle.cfg.PoolFeesAddress, AmountWei: big.NewInt(70)})
+	oracle.applyClaim(Claim{WithdrawalAddress: newFeesAddress, AmountWei: big.NewInt(50)})
+
+	oracle.handleBlsToExecutionChanges(fullBlockWithBlsChanges(1100, 7, cold))
+	require.Equal(t, big.NewInt(40), oracle.state.Validators[7].PendingRewardsWei)
+	require.Equal(t, big.NewInt(120), oracle.state.PoolAccumulatedFees)
+	proposer, _ := oracle.BlsProposer(7)
+	require.Equal(t, big.NewInt(40), proposer.CreditedWei)
+
+	// Nothing left to credit
+	oracle.handleBlsToExecutionChanges(fullBlockWithBlsChanges(1101, 8, cold))
+	require.Equal(t, Active, oracle.state.Validators[8].ValidatorStatus)
+	require.Equal(t, big.NewInt(0), oracle.state.Validators[8].PendingRewardsWei)
+	require.Equal(t, big.NewInt(120), oracle.state.PoolAccumulatedFees)
+}
+
+func Test_BlsProposer_RotationFoundByCleanup(t *testing.T) {
+	activateFork(t, SlotBlsRotationFork, Mainnet, 1000)
+	oracle := testOracle(Mainnet, 0)
+	oracle.handleBlsCorrectBlockProposal(blsProposalBlock(1001, 7, 100))
+	oracle.handleBlsCorrectBlockProposal(blsProposalBlock(1002, 8, 100))
+
+	mockValidators(oracle, map[phase0.ValidatorIndex]*v1.Validator{
+		7: {Index: 7, Status: v1.ValidatorStateActiveOngoing, Validator: &phase0.Validator{
+			WithdrawalCredentials: hexutil.MustDecode("0x01000000000000000000000000000000000000000000000000000000000000a1"),
+		}},
+		8: {Index: 8, Status: v1.ValidatorStateActiveOngoing, Validator: &phase0.Validator{
+			WithdrawalCredentials: make([]byte, 32),
+		}},
+	})
+
+	require.NoError(t, oracle.checkBlsProposersRotation(1200))
+	require.Equal(t, Active, oracle.state.Validators[7].ValidatorStatus)
+	require.Equal(t, "0x00000000000000000000000000000000000000a1", oracle.state.Validators[7].WithdrawalAddress)
+	require.False(t, oracle.isTracked(8))
+}
+
+func Test_LoadFromBytes_BackfillsBlsProposers(t *testing.T) {
+	activateFork(t, SlotBlsRotationFork, Mainnet, 1000)
+	oracle := testOracle(Mainnet, 0)
+	oracle.state.NextSlotToProcess = 1100
+	oracle.handleBlsCorrectBlockProposal(blsProposalBlock(1001, 7, 100))
+
+	// State created before bls proposers were remembered
+	oracle.state.BlsProposers = nil
+
+	reloaded, err := reloadOracle(t, oracle)
+	require.NoError(t, err)
+	proposer, found := reloaded.BlsProposer(7)
+	require.True(t, found)
+	require.Equal(t, []uint64{1001}, proposer.ProposalSlots)
+	require.Equal(t, big.NewInt(100), proposer.RewardsWei)
+}
+
+func Test_BlsRotationFork(t *testing.T) {
+	cold := "0x00000000000000000000000000000000000000a1"
+	activateFork(t, SlotBlsRotationFork, Mainnet, 1050)
+
+	// Proposals before the fork are not remembered, nor rotations handled
+	oracle := testOracle(Mainnet, 0)
+	oracle.handleBlsCorrectBlockProposal(blsProposalBlock(1001, 7, 100))
+	_, found := oracle.BlsProposer(7)
+	require.False(t, found)
+
+	// From the fork they are, but without credit unless configured
+	oracle.handleBlsCorrectBlockProposal(blsProposalBlock(1051, 8, 100))
+	oracle.state.NextSlotToProcess = 1100
+	oracle.handleBlsToExecutionChanges(fullBlockWithBlsChanges(1100, 8, cold))
+	require.Equal(t, Active, oracle.state.Validators[8].ValidatorStatus)
+	require.Equal(t, big.NewInt(0), oracle.state.Validators[8].PendingRewardsWei)
+
+	// States loaded are only backfilled with the proposals since the fork
+	oracle.state.ProposedBlocks = []SummarizedBlock{blsProposalBlock(1001, 7, 100), blsProposalBlock(1051, 8, 100)}
+	oracle.state.BlsProposers = nil
+	backfillBlsProposers(oracle.state)
+	_, found = oracle.BlsProposer(7)
+	require.False(t, found)
+	_, found = oracle.BlsProposer(8)
+	require.True(t, found)
+}
+
+func Test_BlsRotationFork_Scheduled(t *testing.T) {
+	for _, network := range []string{Mainnet, Holesky, Hoodi} {
+		forkSlot := SlotBlsRotationFork[network]
+		oracle := testOracle(network, 0)
+		oracle.handleBlsCorrectBlockProposal(blsProposalBlock(forkSlot-1, 7, 100))
+		oracle.handleBlsCorrectBlockProposal(blsProposalBlock(forkSlot, 8, 100))
+		_, found := oracle.BlsProposer(7)
+		require.False(t, found, network)
+		_, found = oracle.BlsProposer(8)
+		require.True(t, found, network)
+	}
+}
+
+func Test_BlsCredit_FromSlot(t *testing.T) {
+	activateFork(t, SlotBlsRotationFork, Mainnet, 1000)
+	cold := "0x00000000000000000000000000000000000000a1"
+	cfg := testConfig(Mainnet, 0)
+	cfg.BlsCreditSlot = 1100
+	oracle := NewOracle(cfg)
+	oracle.handleBlsCorrectBlockProposal(blsProposalBlock(1001, 7, 100))
+	oracle.handleBlsCorrectBlockProposal(blsProposalBlock(1002, 8, 60))
+
+	// Rotations before the bls credit slot are not credited
+	oracle.state.NextSlotToProcess = 1099
+	require.NoError(t, oracle.validateFullBlockConfig(fullBlockWithBlsChanges(1099, 7, cold)))
+	oracle.handleBlsToExecutionChanges(fullBlockWithBlsChanges(1099, 7, cold))
+	require.Equal(t, big.NewInt(0), oracle.state.Validators[7].PendingRewardsWei)
+
+	// From it they are, and its recorded in the config history
+	oracle.state.NextSlotToProcess = 1100
+	require.NoError(t, oracle.validateFullBlockConfig(fullBlockWithBlsChanges(1100, 8, cold)))
+	oracle.handleBlsToExecutionChanges(fullBlockWithBlsChanges(1100, 8, cold))
+	require.Equal(t, big.NewInt(60), oracle.state.Validators[8].PendingRewardsWei)
+	require.Equal(t, 2, len(oracle.state.ConfigHistory))
+	require.False(t, oracle.state.ConfigHistory[0].BlsCredit)
+	require.Equal(t, uint64(1100), oracle.state.ConfigHistory[1].Slot)
+	require.True(t, oracle.state.ConfigHistory[1].BlsCredit)
+}
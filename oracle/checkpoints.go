@@ -0,0 +1,116 @@
+package oracle
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Summary of a commited state, without its validators nor leafs
+type CheckpointSummary struct {
+	Slot                uint64
+	MerkleRoot          string
+	Leafs               int
+	Validators          int
+	PoolFeesWei         *big.Int
+	TotalAccumulatedWei *big.Int
+	TotalPendingWei     *big.Int
+}
+
+// Returns up to limit summaries of the commited states sorted by slot, starting after the
+// cursor, if any. The returned cursor is empty if there are no more.
+func (or *Oracle) QueryCheckpoints(cursor string, limit int) ([]CheckpointSummary, string, error) {
+	if limit <= 0 {
+		return nil, "", errors.New(fmt.Sprintf("invalid limit: %d", limit))
+	}
+	var after *uint64
+	if cursor != "" {
+		slot, err := strconv.ParseUint(cursor, 10, 64)
+		if err != nil {
+			return nil, "", errors.New("invalid cursor: " + cursor)
+		}
+		after = &slot
+	}
+
+	or.mutex.RLock()
+	defer or.mutex.RUnlock()
+
+	slots := make([]uint64, 0, len(or.state.CommitedStates))
+	for slot := range or.state.CommitedStates {
+		if after == nil || slot > *after {
+			slots = append(slots, slot)
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+
+	nextCursor := ""
+	if len(slots) > limit {
+		slots = slots[:limit]
+		nextCursor = strconv.FormatUint(slots[limit-1], 10)
+	}
+	summaries := make([]CheckpointSummary, 0, len(slots))
+	for _, slot := range slots {
+		summaries = append(summaries, or.checkpointSummaryLockFree(or.state.CommitedStates[slot]))
+	}
+	return summaries, nextCursor, nil
+}
+
+// Returns the summary and the leafs of the state commited at the given slot, false if none
+func (or *Oracle) Checkpoint(slot uint64) (CheckpointSummary, []RawLeaf, bool) {
+	or.mutex.RLock()
+	defer or.mutex.RUnlock()
+	commited, found := or.state.CommitedStates[slot]
+	if !found {
+		return CheckpointSummary{}, nil, false
+	}
+	return or.checkpointSummaryLockFree(commited), commited.SortedLeafs(), true
+}
+
+func (or *Oracle) checkpointSummaryLockFree(commited *OnchainState) CheckpointSummary {
+	leafs := commited.SortedLeafs()
+	summary := CheckpointSummary{
+		Slot:                commited.Slot,
+		MerkleRoot:          commited.MerkleRoot,
+		Leafs:               len(leafs),
+		Validators:          len(commited.Validators),
+		PoolFeesWei:         big.NewInt(0),
+		TotalAccumulatedWei: big.NewInt(0),
+		TotalPendingWei:     big.NewInt(0),
+	}
+	for _, leaf := range leafs {
+		summary.TotalAccumulatedWei.Add(summary.TotalAccumulatedWei, leaf.AccumulatedBalanceWei)
+	}
+	for _, validator := range commited.Validators {
+		if validator != nil && validator.PendingRewardsWei != nil {
+			summary.TotalPendingWei.Add(summary.TotalPendingWei, validator.PendingRewardsWei)
+		}
+	}
+
+	// The pool fees leaf is the first one, old states are looked up by the address at that slot
+	if len(commited.OrderedLeafs) != 0 {
+		summary.PoolFeesWei = commited.OrderedLeafs[0].AccumulatedBalanceWei
+	} else {
+		config, _ := configAtSlot(or.state.ConfigHistory, commited.Slot)
+		if poolFeesLeaf, found := commited.Leafs[strings.ToLower(config.PoolFeesAddress)]; found {
+			summary.PoolFeesWei = poolFeesLeaf.AccumulatedBalanceWei
+		}
+	}
+	return summary
+}
+
+// Returns the leafs of the commited state in the order of the tree. Old states that could
+// not be upgraded dont know the order, so their leafs are sorted by withdrawal address
+func (s *OnchainState) SortedLeafs() []RawLeaf {
+	if len(s.OrderedLeafs) != 0 {
+		return s.OrderedLeafs
+	}
+	leafs := make([]RawLeaf, 0, len(s.Leafs))
+	for _, leaf := range s.Leafs {
+		leafs = append(leafs, leaf)
+	}
+	return NewMerklelizer().OrderByWithdrawalAddress(leafs)
+}
@@ -0,0 +1,59 @@
+package oracle
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_QueryCheckpoints(t *testing.T) {
+	oracle := proofsTestOracle(t, 1100, 1200, 1300)
+
+	summaries, cursor, err := oracle.QueryCheckpoints("", 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(summaries))
+	require.Equal(t, uint64(1100), summaries[0].Slot)
+	require.Equal(t, uint64(1200), summaries[1].Slot)
+	require.Equal(t, "1200", cursor)
+
+	summaries, cursor, err = oracle.QueryCheckpoints(cursor, 2)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(summaries))
+	require.Equal(t, "", cursor)
+
+	summary := summaries[0]
+	require.Equal(t, uint64(1300), summary.Slot)
+	require.Equal(t, oracle.state.CommitedStates[1300].MerkleRoot, summary.MerkleRoot)
+	// Pool fees leaf and the 2 withdrawal addresses
+	require.Equal(t, 3, summary.Leafs)
+	require.Equal(t, 2, summary.Validators)
+	require.Equal(t, big.NewInt(0), summary.PoolFeesWei)
+	require.Equal(t, big.NewInt(150), summary.TotalAccumulatedWei)
+	require.Equal(t, big.NewInt(0), summary.TotalPendingWei)
+
+	_, _, err = oracle.QueryCheckpoints("abc", 2)
+	require.Error(t, err)
+}
+
+func Test_Checkpoint(t *testing.T) {
+	oracle := proofsTestOracle(t, 1100)
+	oracle.state.CommitedStates[1100].OrderedLeafs[0].AccumulatedBalanceWei = big.NewInt(7)
+	expectedLeafs := oracle.state.CommitedStates[1100].OrderedLeafs
+
+	summary, leafs, found := oracle.Checkpoint(1100)
+	require.True(t, found)
+	require.Equal(t, expectedLeafs, leafs)
+	require.Equal(t, big.NewInt(7), summary.PoolFeesWei)
+
+	// Old states are sorted by address, finding the pool fees by its address at that slot
+	toLegacyCommitedState(oracle.state.CommitedStates[1100])
+	summary, leafs, found = oracle.Checkpoint(1100)
+	require.True(t, found)
+	require.Equal(t, 3, len(leafs))
+	require.Equal(t, "0x0000000000000000000000000000000000000002", leafs[0].WithdrawalAddress)
+	require.Equal(t, big.NewInt(7), summary.PoolFeesWei)
+
+	_, _, found = oracle.Checkpoint(1200)
+	require.False(t, found)
+}
@@ -0,0 +1,111 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Transaction ready to be signed and sent by a wallet, with the fields of eth_sendTransaction
+type UnsignedTx struct {
+	Type                 hexutil.Uint64 `json:"type"`
+	ChainId              *hexutil.Big   `json:"chainId"`
+	From                 common.Address `json:"from"`
+	To                   common.Address `json:"to"`
+	Nonce                hexutil.Uint64 `json:"nonce"`
+	Gas                  hexutil.Uint64 `json:"gas"`
+	MaxFeePerGas         *hexutil.Big   `json:"maxFeePerGas"`
+	MaxPriorityFeePerGas *hexutil.Big   `json:"maxPriorityFeePerGas"`
+	Value                *hexutil.Big   `json:"value"`
+	Data                 hexutil.Bytes  `json:"data"`
+}
+
+// Returns the abi encoded call to claimRewards(withdrawalAddress, accumulatedBalance, merkleProof)
+func ClaimRewardsCalldata(withdrawalAddress string, accumulatedBalanceWei *big.Int, proofs []string) ([]byte, error) {
+	if !common.IsHexAddress(withdrawalAddress) {
+		return nil, errors.New("invalid withdrawal address: " + withdrawalAddress)
+	}
+	merkleProof := make([][32]byte, 0, len(proofs))
+	for _, proof := range proofs {
+		proofBytes, err := hexutil.Decode(proof)
+		if err != nil || len(proofBytes) != 32 {
+			return nil, errors.New("invalid proof element: " + proof)
+		}
+		var node [32]byte
+		copy(node[:], proofBytes)
+		merkleProof = append(merkleProof, node)
+	}
+
+	contractAbi, err := contract.ContractMetaData.GetAbi()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse contract abi")
+	}
+	calldata, err := contractAbi.Pack("claimRewards", common.HexToAddress(withdrawalAddress), accumulatedBalanceWei, merkleProof)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not encode claimRewards call")
+	}
+	return calldata, nil
+}
+
+// Builds an EIP-1559 transaction from the given address calling the pool contract with the
+// calldata. The gas is estimated against the node, so it fails if the call would revert. The
+// max fee leaves room for the base fee to double, as most wallets do.
+func (o *Onchain) BuildUnsignedTx(from common.Address, calldata []byte, opts ...retry.Option) (*UnsignedTx, error) {
+	to := common.HexToAddress(o.PoolAddress)
+	var nonce, gas uint64
+	var tipCap *big.Int
+	var header *types.Header
+
+	err := retry.Do(func() error {
+		var err error
+		ctx := context.Background()
+		nonce, err = o.ExecutionClient.PendingNonceAt(ctx, from)
+		if err != nil {
+			return errors.Wrap(err, "could not get pending nonce")
+		}
+		gas, err = o.ExecutionClient.EstimateGas(ctx, ethereum.CallMsg{From: from, To: &to, Data: calldata})
+		if err != nil {
+			return errors.Wrap(err, "could not estimate gas")
+		}
+		tipCap, err = o.ExecutionClient.SuggestGasTipCap(ctx)
+		if err != nil {
+			return errors.Wrap(err, "could not get gas tip cap suggestion")
+		}
+		header, err = o.ExecutionClient.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return errors.Wrap(err, "could not get latest header")
+		}
+		return nil
+	}, o.GetRetryOpts(opts)...)
+	if err != nil {
+		log.Warn("Could not build unsigned tx: ", err.Error())
+		return nil, errors.Wrap(err, fmt.Sprintf("could not build tx from %s", from.Hex()))
+	}
+
+	if header.BaseFee == nil {
+		return nil, errors.New("latest header has no base fee")
+	}
+	feeCap := new(big.Int).Add(tipCap, new(big.Int).Mul(header.BaseFee, big.NewInt(2)))
+
+	return &UnsignedTx{
+		Type:                 hexutil.Uint64(types.DynamicFeeTxType),
+		ChainId:              (*hexutil.Big)(new(big.Int).SetUint64(o.ChainId)),
+		From:                 from,
+		To:                   to,
+		Nonce:                hexutil.Uint64(nonce),
+		Gas:                  hexutil.Uint64(gas),
+		MaxFeePerGas:         (*hexutil.Big)(feeCap),
+		MaxPriorityFeePerGas: (*hexutil.Big)(tipCap),
+		Value:                (*hexutil.Big)(big.NewInt(0)),
+		Data:                 calldata,
+	}, nil
+}
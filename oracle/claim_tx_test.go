@@ -0,0 +1,124 @@
+package oracle
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// Execution client answering the calls needed to build a tx, served in process
+type fakeExecution struct {
+	nonce       uint64
+	gas         uint64
+	tipCap      *big.Int
+	baseFee     *big.Int
+	estimateErr error
+	estimated   []map[string]interface{}
+}
+
+func (f *fakeExecution) GetTransactionCount(address common.Address, block string) hexutil.Uint64 {
+	return hexutil.Uint64(f.nonce)
+}
+
+func (f *fakeExecution) EstimateGas(args map[string]interface{}) (hexutil.Uint64, error) {
+	f.estimated = append(f.estimated, args)
+	return hexutil.Uint64(f.gas), f.estimateErr
+}
+
+func (f *fakeExecution) MaxPriorityFeePerGas() *hexutil.Big {
+	return (*hexutil.Big)(f.tipCap)
+}
+
+func (f *fakeExecution) GetBlockByNumber(number string, full bool) *types.Header {
+	return &types.Header{Number: big.NewInt(100), Difficulty: big.NewInt(0), BaseFee: f.baseFee}
+}
+
+func fakeExecutionClient(t *testing.T, fake *fakeExecution) *ethclient.Client {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("eth", fake))
+	executionClient := ethclient.NewClient(rpc.DialInProc(server))
+	t.Cleanup(func() {
+		executionClient.Close()
+		server.Stop()
+	})
+	return executionClient
+}
+
+func Test_ClaimRewardsCalldata(t *testing.T) {
+	oracle := proofsTestOracle(t, 1100)
+	leaf, proof, _, err := oracle.Proof(1100, "0x00000000000000000000000000000000000000a1")
+	require.NoError(t, err)
+
+	calldata, err := ClaimRewardsCalldata(leaf.WithdrawalAddress, leaf.AccumulatedBalanceWei, proof)
+	require.NoError(t, err)
+	require.Equal(t, "0xd64bc331", hexutil.Encode(calldata[:4]))
+
+	// Decodes to the same arguments
+	contractAbi, err := contract.ContractMetaData.GetAbi()
+	require.NoError(t, err)
+	args, err := contractAbi.Methods["claimRewards"].Inputs.Unpack(calldata[4:])
+	require.NoError(t, err)
+	require.Equal(t, common.HexToAddress(leaf.WithdrawalAddress), args[0])
+	require.Equal(t, big.NewInt(100), args[1])
+	merkleProof := args[2].([][32]byte)
+	require.Equal(t, len(proof), len(merkleProof))
+	for i := range proof {
+		require.Equal(t, proof[i], hexutil.Encode(merkleProof[i][:]))
+	}
+
+	_, err = ClaimRewardsCalldata("0x1234", leaf.AccumulatedBalanceWei, proof)
+	require.Error(t, err)
+	_, err = ClaimRewardsCalldata(leaf.WithdrawalAddress, leaf.AccumulatedBalanceWei, []string{"0x1234"})
+	require.Error(t, err)
+}
+
+func Test_BuildUnsignedTx(t *testing.T) {
+	fake := &fakeExecution{nonce: 7, gas: 85000, tipCap: big.NewInt(2e9), baseFee: big.NewInt(30e9)}
+	onchain := &Onchain{
+		ExecutionClient: fakeExecutionClient(t, fake),
+		PoolAddress:     "0xAdFb8D27671F14f297eE94135e266aAFf8752e35",
+		ChainId:         17000,
+	}
+	from := common.HexToAddress("0x00000000000000000000000000000000000000a1")
+	calldata := []byte{0xd6, 0x4b, 0xc3, 0x31, 0x01}
+	retryOnce := []retry.Option{retry.Attempts(1)}
+
+	tx, err := onchain.BuildUnsignedTx(from, calldata, retryOnce...)
+	require.NoError(t, err)
+	require.Equal(t, hexutil.Uint64(types.DynamicFeeTxType), tx.Type)
+	require.Equal(t, big.NewInt(17000), tx.ChainId.ToInt())
+	require.Equal(t, from, tx.From)
+	require.Equal(t, common.HexToAddress(onchain.PoolAddress), tx.To)
+	require.Equal(t, hexutil.Uint64(7), tx.Nonce)
+	require.Equal(t, hexutil.Uint64(85000), tx.Gas)
+	require.Equal(t, big.NewInt(2e9), tx.MaxPriorityFeePerGas.ToInt())
+	// Tip plus twice the base fee
+	require.Equal(t, big.NewInt(62e9), tx.MaxFeePerGas.ToInt())
+	require.Zero(t, tx.Value.ToInt().Sign())
+	require.Equal(t, hexutil.Bytes(calldata), tx.Data)
+
+	// The gas is estimated for the same call
+	require.Equal(t, 1, len(fake.estimated))
+	require.Equal(t, "0x00000000000000000000000000000000000000a1", fake.estimated[0]["from"])
+	require.Equal(t, "0xadfb8d27671f14f297ee94135e266aaff8752e35", fake.estimated[0]["to"])
+	require.Equal(t, "0xd64bc33101", fake.estimated[0]["input"])
+
+	// Calls that would revert cant be built
+	fake.estimateErr = errors.New("execution reverted")
+	_, err = onchain.BuildUnsignedTx(from, calldata, retryOnce...)
+	require.Error(t, err)
+
+	fake.estimateErr = nil
+	fake.baseFee = nil
+	_, err = onchain.BuildUnsignedTx(from, calldata, retryOnce...)
+	require.Error(t, err)
+}
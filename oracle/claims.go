@@ -0,0 +1,139 @@
+package oracle
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/dappnode/mev-sp-oracle/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Handles the ClaimRewards events of a block, adding them to the claims history
+// and to the claimed rewards of each withdrawal address
+func (or *Oracle) handleClaimRewards(events []*contract.ContractClaimRewards) {
+	for _, event := range events {
+		claim := claimFromEvent(event)
+		claim.Slot = or.state.NextSlotToProcess
+		or.applyClaim(claim)
+
+		log.WithFields(log.Fields{
+			"Slot":              claim.Slot,
+			"Block":             claim.Block,
+			"WithdrawalAddress": claim.WithdrawalAddress,
+			"RewardAddress":     claim.RewardAddress,
+			"AmountWei":         claim.AmountWei,
+			"TxHash":            claim.TxHash,
+		}).Info("[Claim] Rewards claimed")
+	}
+}
+
+// Applies the ClaimRewards events processed by a state that did not track them.
+// Events must be the ones in the blocks the state processed, in order.
+func (or *Oracle) BackfillClaims(events []*contract.ContractClaimRewards) error {
+	or.mutex.Lock()
+	defer or.mutex.Unlock()
+
+	if or.state.ClaimsIndexed {
+		return errors.New("claims are already indexed")
+	}
+	for _, event := range events {
+		if event.Raw.BlockNumber > or.state.LatestProcessedBlock {
+			return errors.New(fmt.Sprintf("event at block %d is after the latest processed block %d",
+				event.Raw.BlockNumber, or.state.LatestProcessedBlock))
+		}
+		or.applyClaim(claimFromEvent(event))
+	}
+	or.state.ClaimsIndexed = true
+	or.updateSnapshotLockFree()
+
+	log.WithFields(log.Fields{
+		"Claims":               len(events),
+		"LatestProcessedBlock": or.state.LatestProcessedBlock,
+	}).Info("Backfilled claims")
+	return nil
+}
+
+// Returns false if the state was created before claims were tracked
+func (or *Oracle) ClaimsIndexed() bool {
+	or.mutex.RLock()
+	defer or.mutex.RUnlock()
+	return or.state.ClaimsIndexed
+}
+
+// Returns the rewards claimed by the withdrawal address, up to the latest processed block
+// of the state. The state is the oracle's or one of its snapshots.
+func ClaimedRewardsOf(state *OracleState, withdrawalAddress string) *big.Int {
+	claimed, found := state.ClaimedRewards[strings.ToLower(withdrawalAddress)]
+	if !found {
+		return big.NewInt(0)
+	}
+	return new(big.Int).Set(claimed)
+}
+
+// Returns the claims of the state where the address is either the withdrawal address or
+// the address that received the rewards, oldest first
+func ClaimsOf(state *OracleState, address string) []Claim {
+	claims := make([]Claim, 0)
+	for _, claim := range state.Claims {
+		if utils.Equals(claim.WithdrawalAddress, address) || utils.Equals(claim.RewardAddress, address) {
+			claims = append(claims, claim)
+		}
+	}
+	return claims
+}
+
+func claimFromEvent(event *contract.ContractClaimRewards) Claim {
+	return Claim{
+		Block:             event.Raw.BlockNumber,
+		TxHash:            event.Raw.TxHash.String(),
+		WithdrawalAddress: strings.ToLower(event.WithdrawalAddress.String()),
+		RewardAddress:     strings.ToLower(event.RewardAddress.String()),
+		AmountWei:         new(big.Int).Set(event.ClaimableBalance),
+	}
+}
+
+func (or *Oracle) applyClaim(claim Claim) {
+	or.state.Claims = append(or.state.Claims, claim)
+	if or.state.ClaimedRewards == nil {
+		or.state.ClaimedRewards = make(map[string]*big.Int)
+	}
+	claimed, found := or.state.ClaimedRewards[claim.WithdrawalAddress]
+	if !found {
+		claimed = big.NewInt(0)
+		or.state.ClaimedRewards[claim.WithdrawalAddress] = claimed
+	}
+	claimed.Add(claimed, claim.AmountWei)
+}
+
+// Validates that the claimed rewards of the state are the sum of its claims
+func validateClaims(state *OracleState) error {
+	expected := make(map[string]*big.Int)
+	for _, claim := range state.Claims {
+		if claim.Block > state.LatestProcessedBlock {
+			return errors.New(fmt.Sprintf("claim at block %d is after the latest processed block %d",
+				claim.Block, state.LatestProcessedBlock))
+		}
+		if claim.AmountWei == nil || claim.AmountWei.Sign() < 0 {
+			return errors.New(fmt.Sprintf("invalid claim amount in tx %s", claim.TxHash))
+		}
+		if _, found := expected[claim.WithdrawalAddress]; !found {
+			expected[claim.WithdrawalAddress] = big.NewInt(0)
+		}
+		expected[claim.WithdrawalAddress].Add(expected[claim.WithdrawalAddress], claim.AmountWei)
+	}
+	if len(expected) != len(state.ClaimedRewards) {
+		return errors.New(fmt.Sprintf("number of claimed rewards mismatch, recovered: %d, expected: %d",
+			len(state.ClaimedRewards), len(expected)))
+	}
+	for withdrawalAddress, claimed := range expected {
+		recovered, found := state.ClaimedRewards[withdrawalAddress]
+		if !found || recovered.Cmp(claimed) != 0 {
+			return errors.New(fmt.Sprintf("claimed rewards of %s mismatch, recovered: %d, expected: %d",
+				withdrawalAddress, recovered, claimed))
+		}
+	}
+	return nil
+}
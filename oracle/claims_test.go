@@ -0,0 +1,120 @@
+package oracle
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+// Helper to create a ClaimRewards event
+func claimRewardsEvent(withdrawalAddress string, rewardAddress string, amount int64, block uint64) *contract.ContractClaimRewards {
+	return &contract.ContractClaimRewards{
+		WithdrawalAddress: common.HexToAddress(withdrawalAddress),
+		RewardAddress:     common.HexToAddress(rewardAddress),
+		ClaimableBalance:  big.NewInt(amount),
+		Raw:               types.Log{BlockNumber: block, TxHash: common.Hash{byte(block)}},
+	}
+}
+
+func Test_handleClaimRewards(t *testing.T) {
+	oracle := testOracle(Mainnet, 700)
+	cold := "0x00000000000000000000000000000000000000a1"
+	hot := "0x00000000000000000000000000000000000000bb"
+	other := "0x00000000000000000000000000000000000000a2"
+
+	oracle.state.NextSlotToProcess = 1100
+	oracle.handleClaimRewards([]*contract.ContractClaimRewards{
+		claimRewardsEvent(cold, cold, 100, 900),
+		claimRewardsEvent(other, other, 5, 900),
+	})
+	oracle.state.NextSlotToProcess = 1200
+	oracle.handleClaimRewards([]*contract.ContractClaimRewards{
+		claimRewardsEvent(cold, hot, 50, 1000),
+	})
+
+	// Claimed rewards are per withdrawal address
+	require.Equal(t, big.NewInt(150), ClaimedRewardsOf(oracle.state, cold))
+	require.Equal(t, big.NewInt(150), ClaimedRewardsOf(oracle.state, "0x00000000000000000000000000000000000000A1"))
+	require.Equal(t, big.NewInt(5), ClaimedRewardsOf(oracle.state, other))
+	require.Equal(t, big.NewInt(0), ClaimedRewardsOf(oracle.state, hot))
+
+	// Claims are listed for both the withdrawal and the reward address
+	claims := ClaimsOf(oracle.state, cold)
+	require.Equal(t, 2, len(claims))
+	require.Equal(t, uint64(1100), claims[0].Slot)
+	require.Equal(t, big.NewInt(100), claims[0].AmountWei)
+	require.Equal(t, uint64(1200), claims[1].Slot)
+	require.Equal(t, hot, claims[1].RewardAddress)
+	require.Equal(t, claims[1:], ClaimsOf(oracle.state, hot))
+
+	// Returned amounts cant modify the state
+	ClaimedRewardsOf(oracle.state, cold).SetInt64(0)
+	require.Equal(t, big.NewInt(150), ClaimedRewardsOf(oracle.state, cold))
+}
+
+func Test_LoadFromBytes_WithClaims(t *testing.T) {
+	cold := "0x00000000000000000000000000000000000000a1"
+
+	newOracleWithClaims := func() *Oracle {
+		oracle := testOracle(Mainnet, 700)
+		oracle.state.NextSlotToProcess = 1100
+		oracle.state.LatestProcessedBlock = 1000
+		oracle.handleClaimRewards([]*contract.ContractClaimRewards{
+			claimRewardsEvent(cold, cold, 100, 900),
+			claimRewardsEvent(cold, cold, 20, 1000),
+		})
+		return oracle
+	}
+
+	reloaded, err := reloadOracle(t, newOracleWithClaims())
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(120), ClaimedRewardsOf(reloaded.state, cold))
+	require.Equal(t, 2, len(ClaimsOf(reloaded.state, cold)))
+	require.True(t, reloaded.ClaimsIndexed())
+
+	// Claimed rewards that dont match the claims
+	oracle := newOracleWithClaims()
+	oracle.state.ClaimedRewards[cold] = big.NewInt(100)
+	_, err = reloadOracle(t, oracle)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "claimed rewards of "+cold+" mismatch")
+
+	// Claim after the latest processed block
+	oracle = newOracleWithClaims()
+	oracle.state.LatestProcessedBlock = 999
+	_, err = reloadOracle(t, oracle)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "after the latest processed block")
+}
+
+func Test_BackfillClaims(t *testing.T) {
+	cold := "0x00000000000000000000000000000000000000a1"
+
+	// State created before claims were tracked
+	oracle := testOracle(Mainnet, 700)
+	oracle.state.ClaimsIndexed = false
+	oracle.state.LatestProcessedBlock = 1000
+
+	err := oracle.BackfillClaims([]*contract.ContractClaimRewards{
+		claimRewardsEvent(cold, cold, 100, 1001),
+	})
+	require.Error(t, err)
+	require.False(t, oracle.ClaimsIndexed())
+
+	err = oracle.BackfillClaims([]*contract.ContractClaimRewards{
+		claimRewardsEvent(cold, cold, 100, 900),
+		claimRewardsEvent(cold, cold, 30, 1000),
+	})
+	require.NoError(t, err)
+	require.True(t, oracle.ClaimsIndexed())
+	require.Equal(t, big.NewInt(130), ClaimedRewardsOf(oracle.state, cold))
+	require.Equal(t, uint64(0), ClaimsOf(oracle.state, cold)[0].Slot)
+
+	// Only done once
+	err = oracle.BackfillClaims([]*contract.ContractClaimRewards{})
+	require.Error(t, err)
+}
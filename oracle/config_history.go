@@ -0,0 +1,178 @@
+package oracle
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Returns the config the contract was deployed with as the first entry of the history
+func initialConfigChange(cfg *Config) ConfigChange {
+	change := ConfigChange{
+		Slot:                     cfg.DeployedSlot,
+		Block:                    cfg.DeployedBlock,
+		PoolFeesPercentOver10000: cfg.PoolFeesPercentOver10000,
+		PoolFeesAddress:          cfg.PoolFeesAddress,
+		CheckPointSizeInSlots:    cfg.CheckPointSizeInSlots,
+		CheckpointAnchorSlot:     cfg.DeployedSlot,
+		BlsCredit:                blsCreditAtSlot(cfg, cfg.DeployedSlot),
+	}
+	if cfg.CollateralInWei != nil {
+		change.CollateralInWei = new(big.Int).Set(cfg.CollateralInWei)
+	}
+	return change
+}
+
+// Returns true if the rewards of the past proposals of BLS proposers are credited when they
+// rotate at the slot. Its configured by the operators and changes the roots, so all oracles
+// must use the same bls credit slot. Its recorded in the config history when it starts.
+func blsCreditAtSlot(cfg *Config, slot uint64) bool {
+	return cfg.BlsCreditSlot != 0 && slot >= cfg.BlsCreditSlot
+}
+
+// Returns the config in effect at the given slot, which is the latest change
+// with a slot lower or equal. False if the slot is before the first entry
+func configAtSlot(history []ConfigChange, slot uint64) (ConfigChange, bool) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Slot <= slot {
+			return history[i], true
+		}
+	}
+	return ConfigChange{}, false
+}
+
+// Returns the latest checkpoint of the given config strictly before slot. When the
+// checkpoint size changes, the contract expects the next report at the last consolidated
+// slot plus the new size, so the new schedule is counted from there.
+func lastCheckpointBefore(config ConfigChange, slot uint64) uint64 {
+	if slot <= config.CheckpointAnchorSlot {
+		return config.CheckpointAnchorSlot
+	}
+	elapsed := slot - 1 - config.CheckpointAnchorSlot
+	return config.CheckpointAnchorSlot + (elapsed/config.CheckPointSizeInSlots)*config.CheckPointSizeInSlots
+}
+
+// Returns true if the slot is a checkpoint according to the config history
+func isCheckpointSlot(history []ConfigChange, slot uint64) bool {
+	config, found := configAtSlot(history, slot)
+	if !found || config.CheckPointSizeInSlots == 0 {
+		return false
+	}
+	return (slot-config.CheckpointAnchorSlot)%config.CheckPointSizeInSlots == 0
+}
+
+// Builds the config history of states created before it was stored. The only changes these
+// states could have processed are the ones in feeSchedule, the rest made the oracle crash.
+func backfillConfigHistory(initialCfg *Config, upToSlot uint64) []ConfigChange {
+	history := []ConfigChange{initialConfigChange(initialCfg)}
+
+	schedule := getFeeSchedule(initialCfg.Network)
+	slots := make([]uint64, 0, len(schedule))
+	for slot := range schedule {
+		if slot > initialCfg.DeployedSlot && slot <= upToSlot {
+			slots = append(slots, slot)
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i] < slots[j] })
+
+	for _, slot := range slots {
+		change := history[len(history)-1]
+		change.Slot = slot
+		change.Block = 0 // Unknown
+		change.PoolFeesPercentOver10000 = schedule[slot]
+		history = append(history, change)
+	}
+	return history
+}
+
+// Validates that the config history of the state starts with the initial config, is ordered
+// and well formed, and that its latest entry matches the config parameters of the state.
+func validateConfigHistory(initialCfg *Config, state *OracleState) error {
+	history := state.ConfigHistory
+	if len(history) == 0 {
+		return errors.New("config history is empty")
+	}
+
+	initial := initialConfigChange(initialCfg)
+	first := history[0]
+	if first.Slot != initial.Slot || first.CheckpointAnchorSlot != initial.CheckpointAnchorSlot {
+		return errors.New(fmt.Sprintf("config history does not start at the deployed slot. first: %d, deployed: %d",
+			first.Slot, initial.Slot))
+	}
+	if first.PoolFeesPercentOver10000 != initial.PoolFeesPercentOver10000 {
+		return errors.New(fmt.Sprintf("initial pool fees percent mismatch, recovered: %d, expected: %d",
+			first.PoolFeesPercentOver10000, initial.PoolFeesPercentOver10000))
+	}
+	if first.PoolFeesAddress != initial.PoolFeesAddress {
+		return errors.New(fmt.Sprintf("initial pool fees address mismatch, recovered: %s, expected: %s",
+			first.PoolFeesAddress, initial.PoolFeesAddress))
+	}
+	if first.CheckPointSizeInSlots != initial.CheckPointSizeInSlots {
+		return errors.New(fmt.Sprintf("initial check point size mismatch, recovered: %d, expected: %d",
+			first.CheckPointSizeInSlots, initial.CheckPointSizeInSlots))
+	}
+	if first.CollateralInWei == nil || first.CollateralInWei.Cmp(initial.CollateralInWei) != 0 {
+		return errors.New(fmt.Sprintf("initial collateral mismatch, recovered: %d, expected: %d",
+			first.CollateralInWei, initial.CollateralInWei))
+	}
+
+	for i, change := range history {
+		if change.Slot > state.LatestProcessedSlot && change.Slot != state.DeployedSlot {
+			return errors.New(fmt.Sprintf("config change at slot %d is after the latest processed slot %d",
+				change.Slot, state.LatestProcessedSlot))
+		}
+		if change.BlsCredit != blsCreditAtSlot(initialCfg, change.Slot) {
+			return errors.New(fmt.Sprintf("bls credit mismatch at slot %d, recovered: %t, bls credit slot: %d",
+				change.Slot, change.BlsCredit, initialCfg.BlsCreditSlot))
+		}
+		if change.CheckPointSizeInSlots == 0 || change.PoolFeesPercentOver10000 < 0 ||
+			change.PoolFeesPercentOver10000 > 100*100 || change.CollateralInWei == nil {
+			return errors.New(fmt.Sprintf("invalid config change at slot %d", change.Slot))
+		}
+		if i == 0 {
+			continue
+		}
+		prev := history[i-1]
+		if change.Slot <= prev.Slot {
+			return errors.New(fmt.Sprintf("config history is not ordered, slot %d after %d",
+				change.Slot, prev.Slot))
+		}
+		expectedAnchor := prev.CheckpointAnchorSlot
+		if change.CheckPointSizeInSlots != prev.CheckPointSizeInSlots {
+			expectedAnchor = lastCheckpointBefore(prev, change.Slot)
+		}
+		if change.CheckpointAnchorSlot != expectedAnchor {
+			return errors.New(fmt.Sprintf("checkpoint anchor mismatch at slot %d, recovered: %d, expected: %d",
+				change.Slot, change.CheckpointAnchorSlot, expectedAnchor))
+		}
+	}
+
+	// The state cant be processed past the bls credit slot without recording it
+	if initialCfg.BlsCreditSlot != 0 && initialCfg.BlsCreditSlot <= state.LatestProcessedSlot {
+		if config, found := configAtSlot(history, initialCfg.BlsCreditSlot); !found || !config.BlsCredit {
+			return errors.New(fmt.Sprintf("bls credit slot %d was processed without the bls credit",
+				initialCfg.BlsCreditSlot))
+		}
+	}
+
+	latest := history[len(history)-1]
+	if state.PoolFeesPercentOver10000 != latest.PoolFeesPercentOver10000 {
+		return errors.New(fmt.Sprintf("pool fees percent mismatch, recovered: %d, expected: %d",
+			state.PoolFeesPercentOver10000, latest.PoolFeesPercentOver10000))
+	}
+	if state.PoolFeesAddress != latest.PoolFeesAddress {
+		return errors.New(fmt.Sprintf("pool fees address mismatch, recovered: %s, expected: %s",
+			state.PoolFeesAddress, latest.PoolFeesAddress))
+	}
+	if state.CheckPointSizeInSlots != latest.CheckPointSizeInSlots {
+		return errors.New(fmt.Sprintf("check point size mismatch, recovered: %d, expected: %d",
+			state.CheckPointSizeInSlots, latest.CheckPointSizeInSlots))
+	}
+	if state.CollateralInWei == nil || state.CollateralInWei.Cmp(latest.CollateralInWei) != 0 {
+		return errors.New(fmt.Sprintf("collateral mismatch, recovered: %d, expected: %d",
+			state.CollateralInWei, latest.CollateralInWei))
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+package oracle
+
+import (
+	"math/big"
+	"testing"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+// Helper to create a FullBlock with the given governance events at a given slot
+func fullBlockWithConfigEvents(slot uint64, events *Events) *FullBlock {
+	return &FullBlock{
+		ConsensusDuty: &v1.ProposerDuty{
+			Slot: phase0.Slot(slot),
+		},
+		Events: events,
+	}
+}
+
+// Helper that saves the state of the oracle and loads it in a new one with the same config
+func reloadOracle(t *testing.T, oracle *Oracle) (*Oracle, error) {
+	oracle.state.LatestProcessedSlot = oracle.state.NextSlotToProcess - 1
+	rawBytes, err := serializeStateWithHash(oracle.state)
+	require.NoError(t, err)
+
+	reloaded := NewOracle(testConfig(oracle.cfg.Network, oracle.cfg.PoolFeesPercentOver10000))
+	_, err = reloaded.LoadFromBytes(rawBytes)
+	return reloaded, err
+}
+
+func Test_validateFullBlockConfig_AllConfigChanges(t *testing.T) {
+	oracle := testOracle(Mainnet, 700)
+	newRecipient := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+
+	block := fullBlockWithConfigEvents(1250, &Events{
+		UpdatePoolFee: []*contract.ContractUpdatePoolFee{
+			{NewPoolFee: big.NewInt(500), Raw: types.Log{BlockNumber: 1200}},
+		},
+		PoolFeeRecipient: []*contract.ContractUpdatePoolFeeRecipient{
+			{NewPoolFeeRecipient: newRecipient, Raw: types.Log{BlockNumber: 1200}},
+		},
+		CheckpointSlotSize: []*contract.ContractUpdateCheckpointSlotSize{
+			{NewCheckpointSlotSize: 50, Raw: types.Log{BlockNumber: 1200}},
+		},
+		UpdateSubscriptionCollateral: []*contract.ContractUpdateSubscriptionCollateral{
+			{NewSubscriptionCollateral: big.NewInt(2000), Raw: types.Log{BlockNumber: 1200}},
+		},
+	})
+	err := oracle.validateFullBlockConfig(block)
+	require.NoError(t, err)
+
+	// The state is updated, the config is kept as deployed
+	require.Equal(t, 700, oracle.cfg.PoolFeesPercentOver10000)
+	require.Equal(t, "0x0000000000000000000000000000000000000002", oracle.cfg.PoolFeesAddress)
+	require.Equal(t, uint64(100), oracle.cfg.CheckPointSizeInSlots)
+	require.Equal(t, big.NewInt(1000), oracle.cfg.CollateralInWei)
+	require.Equal(t, 500, oracle.state.PoolFeesPercentOver10000)
+	require.Equal(t, newRecipient.Hex(), oracle.state.PoolFeesAddress)
+	require.Equal(t, uint64(50), oracle.state.CheckPointSizeInSlots)
+	require.Equal(t, big.NewInt(2000), oracle.state.CollateralInWei)
+
+	// Recorded as a single change, the previous config is kept
+	require.Equal(t, 2, len(oracle.state.ConfigHistory))
+	change := oracle.state.ConfigHistory[1]
+	require.Equal(t, uint64(1250), change.Slot)
+	require.Equal(t, uint64(1200), change.Block)
+	require.Equal(t, "0x0000000000000000000000000000000000000002", oracle.state.ConfigHistory[0].PoolFeesAddress)
+	require.Equal(t, big.NewInt(1000), oracle.state.ConfigHistory[0].CollateralInWei)
+
+	// New checkpoints are counted from the last one before the change
+	require.Equal(t, uint64(1200), change.CheckpointAnchorSlot)
+
+	// Subscriptions now require the new collateral
+	require.False(t, oracle.isCollateralEnough(big.NewInt(1000)))
+	require.True(t, oracle.isCollateralEnough(big.NewInt(2000)))
+}
+
+func Test_validateFullBlockConfig_InvalidCheckpointSize(t *testing.T) {
+	oracle := testOracle(Mainnet, 700)
+
+	block := fullBlockWithConfigEvents(1250, &Events{
+		CheckpointSlotSize: []*contract.ContractUpdateCheckpointSlotSize{
+			{NewCheckpointSlotSize: 0},
+		},
+	})
+	err := oracle.validateFullBlockConfig(block)
+	require.Error(t, err)
+	require.Equal(t, uint64(100), oracle.state.CheckPointSizeInSlots)
+	require.Equal(t, 1, len(oracle.state.ConfigHistory))
+}
+
+func Test_IsCheckpoint_CheckpointSizeChanges(t *testing.T) {
+	oracle := testOracle(Mainnet, 700)
+
+	// Deployed at 1000 with size 100: checkpoints at 1000, 1100, 1200...
+	for slot, expected := range map[uint64]bool{1000: true, 1050: false, 1100: true, 1200: true} {
+		oracle.state.LatestProcessedSlot = slot
+		isCheckpoint, err := oracle.IsCheckpoint()
+		require.NoError(t, err)
+		require.Equal(t, expected, isCheckpoint, "slot %d", slot)
+	}
+
+	// Size changes to 30 at slot 1250, counted from 1200: checkpoints at 1260, 1290...
+	block := fullBlockWithConfigEvents(1250, &Events{
+		CheckpointSlotSize: []*contract.ContractUpdateCheckpointSlotSize{{NewCheckpointSlotSize: 30}},
+	})
+	require.NoError(t, oracle.validateFullBlockConfig(block))
+
+	// Size changes to 200 at slot 1290, which was a checkpoint of the previous size.
+	// Its report is submitted after the change, so its counted from 1260: checkpoints at 1460, 1660...
+	block = fullBlockWithConfigEvents(1290, &Events{
+		CheckpointSlotSize: []*contract.ContractUpdateCheckpointSlotSize{{NewCheckpointSlotSize: 200}},
+	})
+	require.NoError(t, oracle.validateFullBlockConfig(block))
+	require.Equal(t, uint64(1260), oracle.state.ConfigHistory[2].CheckpointAnchorSlot)
+
+	for slot, expected := range map[uint64]bool{
+		1200: true, 1230: false, 1260: true, 1289: false,
+		1290: false, 1300: false, 1400: false, 1460: true, 1560: false, 1660: true} {
+		oracle.state.LatestProcessedSlot = slot
+		isCheckpoint, err := oracle.IsCheckpoint()
+		require.NoError(t, err)
+		require.Equal(t, expected, isCheckpoint, "slot %d", slot)
+	}
+}
+
+func Test_LoadFromBytes_WithConfigHistory(t *testing.T) {
+	oracle := testOracle(Mainnet, 700)
+	newRecipient := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+
+	require.NoError(t, oracle.validateFullBlockConfig(fullBlockWithConfigEvents(1250, &Events{
+		UpdatePoolFee:      []*contract.ContractUpdatePoolFee{{NewPoolFee: big.NewInt(300)}},
+		CheckpointSlotSize: []*contract.ContractUpdateCheckpointSlotSize{{NewCheckpointSlotSize: 30}},
+	})))
+	require.NoError(t, oracle.validateFullBlockConfig(fullBlockWithConfigEvents(1400, &Events{
+		PoolFeeRecipient: []*contract.ContractUpdatePoolFeeRecipient{{NewPoolFeeRecipient: newRecipient}},
+		UpdateSubscriptionCollateral: []*contract.ContractUpdateSubscriptionCollateral{
+			{NewSubscriptionCollateral: big.NewInt(5000)}},
+	})))
+	oracle.state.NextSlotToProcess = 1500
+
+	// The config in effect is restored from the history, even if it differs from the initial one
+	reloaded, err := reloadOracle(t, oracle)
+	require.NoError(t, err)
+	require.Equal(t, 300, reloaded.state.PoolFeesPercentOver10000)
+	require.Equal(t, newRecipient.Hex(), reloaded.state.PoolFeesAddress)
+	require.Equal(t, uint64(30), reloaded.state.CheckPointSizeInSlots)
+	require.Equal(t, big.NewInt(5000), reloaded.state.CollateralInWei)
+	require.Equal(t, 3, len(reloaded.state.ConfigHistory))
+	require.Equal(t, 700, reloaded.cfg.PoolFeesPercentOver10000)
+}
+
+func Test_LoadFromBytes_WithConfigHistory_Rejected(t *testing.T) {
+	newOracleWithChange := func() *Oracle {
+		oracle := testOracle(Mainnet, 700)
+		require.NoError(t, oracle.validateFullBlockConfig(fullBlockWithConfigEvents(1250, &Events{
+			UpdatePoolFee:      []*contract.ContractUpdatePoolFee{{NewPoolFee: big.NewInt(300)}},
+			CheckpointSlotSize: []*contract.ContractUpdateCheckpointSlotSize{{NewCheckpointSlotSize: 30}},
+		})))
+		oracle.state.NextSlotToProcess = 1500
+		return oracle
+	}
+
+	// Config parameters dont match the latest change
+	oracle := newOracleWithChange()
+	oracle.state.PoolFeesPercentOver10000 = 500
+	_, err := reloadOracle(t, oracle)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "pool fees percent mismatch")
+
+	// History does not start with the deployed config
+	oracle = newOracleWithChange()
+	oracle.state.ConfigHistory[0].CollateralInWei = big.NewInt(1)
+	_, err = reloadOracle(t, oracle)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "initial collateral mismatch")
+
+	// Checkpoints are not counted from the last one before the size change
+	oracle = newOracleWithChange()
+	oracle.state.ConfigHistory[1].CheckpointAnchorSlot = 1250
+	_, err = reloadOracle(t, oracle)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "checkpoint anchor mismatch")
+
+	// Change after the latest processed slot
+	oracle = newOracleWithChange()
+	oracle.state.NextSlotToProcess = 1200
+	_, err = reloadOracle(t, oracle)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "after the latest processed slot")
+}
+
+func Test_LoadFromBytes_BlsCreditSlot(t *testing.T) {
+	cfg := testConfig(Mainnet, 700)
+	cfg.BlsCreditSlot = 1200
+	oracle := NewOracle(cfg)
+	require.NoError(t, oracle.validateFullBlockConfig(fullBlockNoEvents(1100)))
+	require.Equal(t, 1, len(oracle.state.ConfigHistory))
+	require.NoError(t, oracle.validateFullBlockConfig(fullBlockNoEvents(1200)))
+	oracle.state.LatestProcessedSlot = 1300
+	oracle.state.NextSlotToProcess = 1301
+	rawBytes, err := serializeStateWithHash(oracle.state)
+	require.NoError(t, err)
+
+	load := func(blsCreditSlot uint64) error {
+		cfg := testConfig(Mainnet, 700)
+		cfg.BlsCreditSlot = blsCreditSlot
+		_, err := NewOracle(cfg).LoadFromBytes(rawBytes)
+		return err
+	}
+	require.NoError(t, load(1200))
+
+	// Oracles with another bls credit slot would compute other roots
+	require.Error(t, load(0))
+	require.Error(t, load(1250))
+	require.Error(t, load(1150))
+
+	// Not reached yet, so it can still be set
+	oracle = NewOracle(testConfig(Mainnet, 700))
+	oracle.state.LatestProcessedSlot = 1300
+	oracle.state.NextSlotToProcess = 1301
+	rawBytes, err = serializeStateWithHash(oracle.state)
+	require.NoError(t, err)
+	require.NoError(t, load(1400))
+	require.Error(t, load(1250))
+}
+
+func Test_validateFullBlockConfig_InvalidCollateral(t *testing.T) {
+	for _, collateral := range []*big.Int{nil, big.NewInt(-1)} {
+		oracle := testOracle(Mainnet, 700)
+
+		block := fullBlockWithConfigEvents(1250, &Events{
+			UpdateSubscriptionCollateral: []*contract.ContractUpdateSubscriptionCollateral{
+				{NewSubscriptionCollateral: collateral, Raw: types.Log{BlockNumber: 1200}},
+			},
+		})
+		err := oracle.validateFullBlockConfig(block)
+
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid subscription collateral")
+		require.Equal(t, big.NewInt(1000), oracle.state.CollateralInWei, "state should be unchanged")
+		require.Equal(t, 1, len(oracle.state.ConfigHistory))
+	}
+}
+
+func Test_validateFullBlockConfig_CollateralIsCopied(t *testing.T) {
+	oracle := testOracle(Mainnet, 700)
+	collateral := big.NewInt(2000)
+
+	block := fullBlockWithConfigEvents(1250, &Events{
+		UpdateSubscriptionCollateral: []*contract.ContractUpdateSubscriptionCollateral{
+			{NewSubscriptionCollateral: collateral, Raw: types.Log{BlockNumber: 1200}},
+		},
+	})
+	require.NoError(t, oracle.validateFullBlockConfig(block))
+
+	// Modifying the event does not modify the recorded config
+	collateral.SetInt64(1)
+	require.Equal(t, big.NewInt(2000), oracle.state.CollateralInWei)
+	require.Equal(t, big.NewInt(2000), oracle.state.ConfigHistory[1].CollateralInWei)
+}
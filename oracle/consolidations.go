@@ -0,0 +1,382 @@
+package oracle
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/dappnode/mev-sp-oracle/utils"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Records the EIP-7251 consolidation requests of a block whose source is tracked by the
+// oracle. Requests with the same source and target just switch the validator to compounding
+// credentials, so they are ignored. No balance changes until the beacon chain processes them.
+func (or *Oracle) handleConsolidationRequests(requests []*electra.ConsolidationRequest, block uint64) {
+	for _, request := range requests {
+		sourceKey := strings.ToLower(request.SourcePubkey.String())
+		targetKey := strings.ToLower(request.TargetPubkey.String())
+		if sourceKey == targetKey {
+			continue
+		}
+		sourceIndex, found := or.validatorIndexByKey(sourceKey)
+		if !found {
+			continue
+		}
+		if or.openConsolidation(sourceIndex) >= 0 {
+			log.WithFields(log.Fields{
+				"SourceIndex": sourceIndex,
+				"TargetKey":   targetKey,
+				"Slot":        or.state.NextSlotToProcess,
+			}).Warn("[CONSOLIDATION] Source already has an open consolidation, skipping request")
+			continue
+		}
+		or.state.Consolidations = append(or.state.Consolidations, Consolidation{
+			SourceIndex:  sourceIndex,
+			SourceKey:    sourceKey,
+			TargetKey:    targetKey,
+			Status:       ConsolidationRequested,
+			RequestSlot:  or.state.NextSlotToProcess,
+			RequestBlock: block,
+		})
+
+		log.WithFields(log.Fields{
+			"SourceIndex": sourceIndex,
+			"TargetKey":   targetKey,
+			"Slot":        or.state.NextSlotToProcess,
+			"Block":       block,
+		}).Info("[CONSOLIDATION] Consolidation requested")
+	}
+}
+
+// Fetches the pending consolidations of the beacon chain at the slot and updates the
+// consolidations that are not finished yet
+func (or *Oracle) trackConsolidations(slot uint64) error {
+	pendingConsolidations, err := or.getPendingConsolidations(strconv.FormatUint(slot, 10))
+	if err != nil {
+		return errors.Wrap(err, "could not fetch pending consolidations")
+	}
+	return or.updateConsolidations(slot, pendingConsolidations)
+}
+
+// Moves requested consolidations to pending or rejected and completes the pending ones that
+// are no longer in the beacon chain. Pending consolidations of tracked validators that were
+// never requested in a processed block (eg done before they were tracked) are added too.
+func (or *Oracle) updateConsolidations(slot uint64, pendingConsolidations *PendingConsolidationsResponse) error {
+	sourceToTarget := make(map[uint64]uint64)
+	for _, c := range pendingConsolidations.Data {
+		sourceToTarget[uint64(c.SourceIndex)] = uint64(c.TargetIndex)
+	}
+
+	for i := range or.state.Consolidations {
+		consolidation := &or.state.Consolidations[i]
+		targetIndex, inQueue := sourceToTarget[consolidation.SourceIndex]
+
+		switch consolidation.Status {
+		case ConsolidationRequested:
+			if inQueue {
+				consolidation.Status = ConsolidationPending
+				consolidation.TargetIndex = targetIndex
+				log.WithFields(log.Fields{
+					"SourceIndex": consolidation.SourceIndex,
+					"TargetIndex": targetIndex,
+					"Slot":        slot,
+				}).Info("[CONSOLIDATION] Consolidation accepted by the beacon chain")
+			} else {
+				consolidation.Status = ConsolidationRejected
+				log.WithFields(log.Fields{
+					"SourceIndex": consolidation.SourceIndex,
+					"TargetKey":   consolidation.TargetKey,
+					"Slot":        slot,
+				}).Warn("[CONSOLIDATION] Consolidation rejected by the beacon chain")
+			}
+		case ConsolidationPending:
+			if !inQueue {
+				err := or.completeConsolidation(i, slot)
+				if err != nil {
+					return errors.Wrap(err, "could not complete consolidation")
+				}
+			}
+		}
+	}
+
+	for _, c := range pendingConsolidations.Data {
+		sourceIndex := uint64(c.SourceIndex)
+		if !or.isTracked(sourceIndex) || or.openConsolidation(sourceIndex) >= 0 {
+			continue
+		}
+		or.state.Consolidations = append(or.state.Consolidations, Consolidation{
+			SourceIndex: sourceIndex,
+			SourceKey:   or.state.Validators[sourceIndex].ValidatorKey,
+			TargetIndex: uint64(c.TargetIndex),
+			Status:      ConsolidationPending,
+		})
+		log.WithFields(log.Fields{
+			"SourceIndex": sourceIndex,
+			"TargetIndex": c.TargetIndex,
+			"Slot":        slot,
+		}).Info("[CONSOLIDATION] Found pending consolidation of a tracked validator")
+	}
+	return nil
+}
+
+// Completes a consolidation that left the pending consolidations of the beacon chain. Its
+// balance was moved to the target unless the source was slashed, in which case the source
+// is handled as any other exited validator by the cleanup.
+func (or *Oracle) completeConsolidation(i int, slot uint64) error {
+	consolidation := &or.state.Consolidations[i]
+	validators, err := or.getSetOfValidators([]phase0.ValidatorIndex{
+		phase0.ValidatorIndex(consolidation.SourceIndex),
+		phase0.ValidatorIndex(consolidation.TargetIndex)},
+		strconv.FormatUint(slot, 10))
+	if err != nil {
+		return errors.Wrap(err, "could not get consolidation validators info")
+	}
+
+	consolidation.CompletedSlot = slot
+	source, found := validators[phase0.ValidatorIndex(consolidation.SourceIndex)]
+	if found && source.Validator != nil && source.Validator.Slashed {
+		consolidation.Status = ConsolidationCancelled
+		log.WithFields(log.Fields{
+			"SourceIndex": consolidation.SourceIndex,
+			"TargetIndex": consolidation.TargetIndex,
+			"Slot":        slot,
+		}).Warn("[CONSOLIDATION] Source was slashed, consolidation cancelled")
+		return nil
+	}
+
+	consolidation.Status = ConsolidationCompleted
+	log.WithFields(log.Fields{
+		"SourceIndex": consolidation.SourceIndex,
+		"TargetIndex": consolidation.TargetIndex,
+		"Slot":        slot,
+	}).Info("[CONSOLIDATION] Consolidation completed")
+
+	// Pending rewards may have been transferred already by the cleanup when the source exited
+	if consolidation.TransferredPendingWei != nil {
+		return nil
+	}
+	toPool, err := or.transferConsolidatedRewards(i, slot, validators[phase0.ValidatorIndex(consolidation.TargetIndex)])
+	if err != nil {
+		return err
+	}
+	if toPool.Cmp(big.NewInt(0)) != 0 {
+		return or.increaseAllPendingRewards(toPool)
+	}
+	return nil
+}
+
+// Transfers the pending rewards of the source of a consolidation to its target, unsubscribing
+// the source. Rewards follow the balance even if the target has a different withdrawal address.
+// Returns the rewards that have to be shared among the pool instead, if the target is banned.
+// The target info is fetched if the target is not tracked and it is not provided.
+func (or *Oracle) transferConsolidatedRewards(i int, slot uint64, target *v1.Validator) (*big.Int, error) {
+	consolidation := &or.state.Consolidations[i]
+	source := or.state.Validators[consolidation.SourceIndex]
+	targetIndex := consolidation.TargetIndex
+	pending := new(big.Int).Set(source.PendingRewardsWei)
+	toPool := big.NewInt(0)
+
+	if or.isTracked(targetIndex) {
+		consolidation.TargetKey = or.state.Validators[targetIndex].ValidatorKey
+		consolidation.TargetWithdrawalAddress = or.state.Validators[targetIndex].WithdrawalAddress
+		if or.isBanned(targetIndex) {
+			consolidation.Target = TargetBanned
+			toPool.Set(pending)
+		} else {
+			consolidation.Target = TargetNotSubscribed
+			if or.isSubscribed(targetIndex) {
+				consolidation.Target = TargetSubscribed
+			}
+			or.increaseValidatorPendingRewards(targetIndex, pending)
+		}
+	} else {
+		if target == nil {
+			validators, err := or.getSetOfValidators([]phase0.ValidatorIndex{
+				phase0.ValidatorIndex(targetIndex)},
+				strconv.FormatUint(slot, 10))
+			if err != nil {
+				return nil, errors.Wrap(err, "could not get target validator info")
+			}
+			target = validators[phase0.ValidatorIndex(targetIndex)]
+		}
+		if target == nil || target.Validator == nil {
+			return nil, errors.New(fmt.Sprintf("consolidation target %d not found", targetIndex))
+		}
+		consolidation.Target = TargetUntracked
+		consolidation.TargetKey = hexutil.Encode(target.Validator.PublicKey[:])
+		withdrawalAddress, err := utils.GetCompatibleAddressByte(target.Validator.WithdrawalCredentials)
+		if err != nil {
+			// Targets must have execution credentials, but if not there is no one to owe the rewards to
+			log.WithFields(log.Fields{
+				"TargetIndex":     targetIndex,
+				"PendingGoToPool": pending,
+				"Error":           err,
+			}).Warn("[CONSOLIDATION] Target has no execution withdrawal address, rewards go to pool")
+			toPool.Set(pending)
+		} else {
+			consolidation.TargetWithdrawalAddress = withdrawalAddress
+			or.state.Validators[targetIndex] = &ValidatorInfo{
+				ValidatorStatus:       NotSubscribed,
+				AccumulatedRewardsWei: big.NewInt(0),
+				PendingRewardsWei:     new(big.Int).Set(pending),
+				CollateralWei:         big.NewInt(0),
+				WithdrawalAddress:     withdrawalAddress,
+				ValidatorIndex:        targetIndex,
+				ValidatorKey:          consolidation.TargetKey,
+				// Note that 0 = Manual. But it is NotSubscribed. May be confusing.
+				SubscriptionType: Manual,
+			}
+			or.trackLiabilities(pending, nil, nil)
+		}
+	}
+
+	consolidation.SourceWithdrawalAddress = source.WithdrawalAddress
+	if consolidation.SourceWithdrawalAddress != "" && consolidation.TargetWithdrawalAddress != "" &&
+		!strings.EqualFold(consolidation.SourceWithdrawalAddress, consolidation.TargetWithdrawalAddress) {
+		consolidation.DifferentWithdrawalAddress = true
+		log.WithFields(log.Fields{
+			"SourceIndex":             consolidation.SourceIndex,
+			"TargetIndex":             targetIndex,
+			"SourceWithdrawalAddress": consolidation.SourceWithdrawalAddress,
+			"TargetWithdrawalAddress": consolidation.TargetWithdrawalAddress,
+		}).Warn("[CONSOLIDATION] Target has a different withdrawal address, rewards follow the balance")
+	}
+	consolidation.TransferSlot = slot
+	consolidation.TransferredPendingWei = pending
+
+	if or.isSubscribed(consolidation.SourceIndex) {
+		or.advanceStateMachine(consolidation.SourceIndex, Unsubscribe,
+			TransitionCause{Reason: fmt.Sprintf("consolidated into validator %d", targetIndex)})
+	}
+	or.resetPendingRewards(consolidation.SourceIndex)
+
+	log.WithFields(log.Fields{
+		"SourceIndex":        consolidation.SourceIndex,
+		"TargetIndex":        targetIndex,
+		"Target":             consolidation.Target,
+		"PendingTransferred": pending,
+		"PendingGoToPool":    toPool,
+		"Slot":               slot,
+	}).Info("[CONSOLIDATION] Transferred pending rewards of consolidated source")
+	return toPool, nil
+}
+
+// Transfers the pending rewards of an exited source that is in the pending consolidations of
+// the beacon chain to its target, as the cleanup did before the consolidations fork. Returns
+// the rewards that have to be shared among the pool instead, if the target is banned.
+func (or *Oracle) transferToConsolidationTarget(sourceIdx uint64, targetIdx uint64, slot uint64) (*big.Int, error) {
+	pending := or.state.Validators[sourceIdx].PendingRewardsWei
+	log.WithFields(log.Fields{
+		"SourceIndex":        sourceIdx,
+		"TargetIndex":        targetIdx,
+		"SourceState":        or.state.Validators[sourceIdx].ValidatorStatus,
+		"IsTargetTracked":    or.isTracked(targetIdx),
+		"IsTargetBanned":     or.isBanned(targetIdx),
+		"IsTargetSubscribed": or.isSubscribed(targetIdx),
+		"PendingTransferred": pending,
+	}).Info("[CONSOLIDATION] of exited source found")
+
+	if or.isBanned(targetIdx) {
+		return new(big.Int).Set(pending), nil
+	}
+	if or.isTracked(targetIdx) {
+		or.increaseValidatorPendingRewards(targetIdx, pending)
+		return big.NewInt(0), nil
+	}
+
+	targetValidator, err := or.getSetOfValidators([]phase0.ValidatorIndex{
+		phase0.ValidatorIndex(targetIdx)},
+		strconv.FormatUint(slot, 10))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get target validator info")
+	}
+	target, found := targetValidator[phase0.ValidatorIndex(targetIdx)]
+	if !found || target.Validator == nil {
+		return nil, errors.New(fmt.Sprintf("expected validator not found. got: %v", targetValidator))
+	}
+	withdrawalAddress, err := utils.GetCompatibleAddressByte(target.Validator.WithdrawalCredentials)
+	if err != nil {
+		// A validator with BLS cant be consolidated
+		return nil, errors.Wrap(err, fmt.Sprintf("consolidation target %d has BLS keys", targetIdx))
+	}
+	or.state.Validators[targetIdx] = &ValidatorInfo{
+		ValidatorStatus:       NotSubscribed,
+		AccumulatedRewardsWei: big.NewInt(0),
+		PendingRewardsWei:     new(big.Int).Set(pending),
+		CollateralWei:         big.NewInt(0),
+		WithdrawalAddress:     withdrawalAddress,
+		ValidatorIndex:        targetIdx,
+		ValidatorKey:          hexutil.Encode(target.Validator.PublicKey[:]),
+		// Note that 0 = Manual. But it is NotSubscribed. May be confusing.
+		SubscriptionType: Manual,
+	}
+	or.trackLiabilities(pending, nil, nil)
+	return big.NewInt(0), nil
+}
+
+// Returns the position of the requested or pending consolidation of the source, or -1
+func (or *Oracle) openConsolidation(sourceIndex uint64) int {
+	for i := len(or.state.Consolidations) - 1; i >= 0; i-- {
+		consolidation := or.state.Consolidations[i]
+		if consolidation.SourceIndex == sourceIndex &&
+			(consolidation.Status == ConsolidationRequested || consolidation.Status == ConsolidationPending) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns true if any consolidation is waiting for the beacon chain
+func (or *Oracle) hasOpenConsolidations() bool {
+	for _, consolidation := range or.state.Consolidations {
+		if consolidation.Status == ConsolidationRequested || consolidation.Status == ConsolidationPending {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the index of the tracked validator with the given key. Validators are never removed
+// nor change their key, so the index by key is only rebuilt when some are added or the state
+// is replaced
+func (or *Oracle) validatorIndexByKey(validatorKey string) (uint64, bool) {
+	if or.validatorsByKeyState != or.state || or.validatorsByKeyCount != len(or.state.Validators) {
+		or.validatorsByKey = make(map[string]uint64, len(or.state.Validators))
+		for valIndex, validator := range or.state.Validators {
+			if validator.ValidatorKey != "" {
+				or.validatorsByKey[strings.ToLower(validator.ValidatorKey)] = valIndex
+			}
+		}
+		or.validatorsByKeyState = or.state
+		or.validatorsByKeyCount = len(or.state.Validators)
+	}
+	valIndex, found := or.validatorsByKey[strings.ToLower(validatorKey)]
+	return valIndex, found
+}
+
+// Returns the consolidations of the state where the validator is either the source or the
+// target, oldest request first. All of them if the validator index is nil.
+func ConsolidationsOf(state *OracleState, validatorIndex *uint64) []Consolidation {
+	consolidations := make([]Consolidation, 0)
+	for _, consolidation := range state.Consolidations {
+		// The target index is only known once the beacon chain accepted the request
+		targetKnown := consolidation.Status != ConsolidationRequested && consolidation.Status != ConsolidationRejected
+		if validatorIndex != nil &&
+			consolidation.SourceIndex != *validatorIndex &&
+			!(targetKnown && consolidation.TargetIndex == *validatorIndex) {
+			continue
+		}
+		if consolidation.TransferredPendingWei != nil {
+			consolidation.TransferredPendingWei = new(big.Int).Set(consolidation.TransferredPendingWei)
+		}
+		consolidations = append(consolidations, consolidation)
+	}
+	return consolidations
+}
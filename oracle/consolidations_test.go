@@ -0,0 +1,366 @@
+package oracle
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/avast/retry-go/v4"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+// Helper to create a consolidation request between two validator keys
+func consolidationRequest(sourceKey byte, targetKey byte) *electra.ConsolidationRequest {
+	return &electra.ConsolidationRequest{
+		SourcePubkey: phase0.BLSPubKey{sourceKey},
+		TargetPubkey: phase0.BLSPubKey{targetKey},
+	}
+}
+
+// Helper to create a tracked validator whose key is derived from its index
+func trackedValidator(index uint64, status ValidatorStatus, pending int64, withdrawalAddress string) *ValidatorInfo {
+	key := phase0.BLSPubKey{byte(index)}
+	return &ValidatorInfo{
+		ValidatorStatus:       status,
+		AccumulatedRewardsWei: big.NewInt(0),
+		PendingRewardsWei:     big.NewInt(pending),
+		CollateralWei:         big.NewInt(0),
+		WithdrawalAddress:     withdrawalAddress,
+		ValidatorIndex:        index,
+		ValidatorKey:          hexutil.Encode(key[:]),
+	}
+}
+
+// Helper to mock the pending consolidations of the beacon chain
+func mockPendingConsolidations(oracle *Oracle, pending *[]PendingConsolidation) {
+	oracle.GetPendingConsolidationsFunc(func(stateID string, opts ...retry.Option) (*PendingConsolidationsResponse, error) {
+		return &PendingConsolidationsResponse{Data: *pending}, nil
+	})
+}
+
+// Helper to mock the beacon chain validators
+func mockValidators(oracle *Oracle, validators map[phase0.ValidatorIndex]*v1.Validator) {
+	oracle.SetGetSetOfValidatorsFunc(func(valIndices []phase0.ValidatorIndex, _ string, _ ...retry.Option) (map[phase0.ValidatorIndex]*v1.Validator, error) {
+		result := make(map[phase0.ValidatorIndex]*v1.Validator)
+		for _, index := range valIndices {
+			if validator, found := validators[index]; found {
+				result[index] = validator
+			}
+		}
+		return result, nil
+	})
+}
+
+func Test_GetConsolidationRequests(t *testing.T) {
+	block := fullBlockNoEvents(1000)
+	require.Empty(t, block.GetConsolidationRequests())
+
+	block.ConsensusBlock = &spec.VersionedSignedBeaconBlock{Version: spec.DataVersionDeneb}
+	require.Empty(t, block.GetConsolidationRequests())
+
+	block.ConsensusBlock = &spec.VersionedSignedBeaconBlock{
+		Version: spec.DataVersionElectra,
+		Electra: &electra.SignedBeaconBlock{Message: &electra.BeaconBlock{Body: &electra.BeaconBlockBody{
+			ExecutionRequests: &electra.ExecutionRequests{
+				Consolidations: []*electra.ConsolidationRequest{consolidationRequest(1, 2)},
+			},
+		}}},
+	}
+	require.Equal(t, []*electra.ConsolidationRequest{consolidationRequest(1, 2)}, block.GetConsolidationRequests())
+}
+
+func Test_Consolidations_Lifecycle(t *testing.T) {
+	cold := "0x00000000000000000000000000000000000000a1"
+	other := "0x00000000000000000000000000000000000000a2"
+
+	oracle := testOracle(Mainnet, 0)
+	oracle.state.Validators[1] = trackedValidator(1, Active, 100, cold)
+	oracle.state.Validators[2] = trackedValidator(2, Active, 10, other)
+	oracle.state.Validators[3] = trackedValidator(3, Active, 30, cold)
+
+	pending := []PendingConsolidation{}
+	mockPendingConsolidations(oracle, &pending)
+	mockValidators(oracle, map[phase0.ValidatorIndex]*v1.Validator{
+		1: {Index: 1, Status: v1.ValidatorStateWithdrawalPossible, Validator: &phase0.Validator{}},
+		2: {Index: 2, Status: v1.ValidatorStateActiveOngoing, Validator: &phase0.Validator{}},
+	})
+
+	// Switch to compounding, untracked source and a tracked one. Only the last is recorded
+	oracle.state.NextSlotToProcess = 1010
+	oracle.handleConsolidationRequests([]*electra.ConsolidationRequest{
+		consolidationRequest(3, 3),
+		consolidationRequest(9, 2),
+		consolidationRequest(1, 2),
+	}, 900)
+	require.Equal(t, []Consolidation{{
+		SourceIndex:  1,
+		SourceKey:    oracle.state.Validators[1].ValidatorKey,
+		TargetKey:    oracle.state.Validators[2].ValidatorKey,
+		Status:       ConsolidationRequested,
+		RequestSlot:  1010,
+		RequestBlock: 900,
+	}}, ConsolidationsOf(oracle.state, nil))
+
+	// Accepted by the beacon chain
+	pending = []PendingConsolidation{{SourceIndex: 1, TargetIndex: 2}}
+	require.NoError(t, oracle.trackConsolidations(1024))
+	require.Equal(t, ConsolidationPending, oracle.state.Consolidations[0].Status)
+	require.Equal(t, uint64(2), oracle.state.Consolidations[0].TargetIndex)
+	require.Equal(t, big.NewInt(100), oracle.state.Validators[1].PendingRewardsWei)
+
+	// Completed, pending rewards follow the balance even with a different withdrawal address
+	pending = []PendingConsolidation{}
+	require.NoError(t, oracle.trackConsolidations(1056))
+	consolidation := ConsolidationsOf(oracle.state, nil)[0]
+	require.Equal(t, ConsolidationCompleted, consolidation.Status)
+	require.Equal(t, uint64(1056), consolidation.CompletedSlot)
+	require.Equal(t, big.NewInt(100), consolidation.TransferredPendingWei)
+	require.Equal(t, TargetSubscribed, consolidation.Target)
+	require.True(t, consolidation.DifferentWithdrawalAddress)
+	require.Equal(t, big.NewInt(0), oracle.state.Validators[1].PendingRewardsWei)
+	require.Equal(t, NotSubscribed, oracle.state.Validators[1].ValidatorStatus)
+	require.Equal(t, big.NewInt(110), oracle.state.Validators[2].PendingRewardsWei)
+	require.False(t, oracle.hasOpenConsolidations())
+
+	// Listed for both the source and the target
+	source, target, unrelated := uint64(1), uint64(2), uint64(3)
+	require.Equal(t, 1, len(ConsolidationsOf(oracle.state, &source)))
+	require.Equal(t, 1, len(ConsolidationsOf(oracle.state, &target)))
+	require.Equal(t, 0, len(ConsolidationsOf(oracle.state, &unrelated)))
+
+	// Not accepted by the beacon chain
+	oracle.handleConsolidationRequests([]*electra.ConsolidationRequest{consolidationRequest(3, 2)}, 950)
+	require.NoError(t, oracle.trackConsolidations(1088))
+	require.Equal(t, ConsolidationRejected, oracle.state.Consolidations[1].Status)
+	require.Equal(t, big.NewInt(30), oracle.state.Validators[3].PendingRewardsWei)
+	require.Equal(t, Active, oracle.state.Validators[3].ValidatorStatus)
+}
+
+func Test_Consolidations_Targets(t *testing.T) {
+	cold := "0x00000000000000000000000000000000000000a1"
+
+	t.Run("Untracked target is tracked as not subscribed", func(t *testing.T) {
+		oracle := testOracle(Mainnet, 0)
+		oracle.state.Validators[1] = trackedValidator(1, Active, 100, cold)
+		pending := []PendingConsolidation{{SourceIndex: 1, TargetIndex: 5}}
+		mockPendingConsolidations(oracle, &pending)
+		mockValidators(oracle, map[phase0.ValidatorIndex]*v1.Validator{
+			1: {Index: 1, Status: v1.ValidatorStateWithdrawalPossible, Validator: &phase0.Validator{}},
+			5: {Index: 5, Status: v1.ValidatorStateActiveOngoing, Validator: &phase0.Validator{
+				PublicKey:             phase0.BLSPubKey{5},
+				WithdrawalCredentials: append([]byte{2}, hexutil.MustDecode("0x0000000000000000000000"+cold[2:])...),
+			}},
+		})
+
+		// Found in the beacon chain without seeing the request
+		require.NoError(t, oracle.trackConsolidations(1024))
+		require.Equal(t, uint64(0), oracle.state.Consolidations[0].RequestSlot)
+		require.Equal(t, ConsolidationPending, oracle.state.Consolidations[0].Status)
+
+		pending = []PendingConsolidation{}
+		require.NoError(t, oracle.trackConsolidations(1056))
+		require.Equal(t, TargetUntracked, oracle.state.Consolidations[0].Target)
+		require.False(t, oracle.state.Consolidations[0].DifferentWithdrawalAddress)
+		require.Equal(t, NotSubscribed, oracle.state.Validators[5].ValidatorStatus)
+		require.Equal(t, cold, oracle.state.Validators[5].WithdrawalAddress)
+		require.Equal(t, big.NewInt(100), oracle.state.Validators[5].PendingRewardsWei)
+		require.Equal(t, big.NewInt(0), oracle.state.Validators[1].PendingRewardsWei)
+	})
+
+	t.Run("Banned target sends the rewards to the pool", func(t *testing.T) {
+		oracle := testOracle(Mainnet, 0)
+		oracle.state.Validators[1] = trackedValidator(1, Active, 100, cold)
+		oracle.state.Validators[2] = trackedValidator(2, Banned, 0, cold)
+		oracle.state.Validators[3] = trackedValidator(3, Active, 0, cold)
+		pending := []PendingConsolidation{{SourceIndex: 1, TargetIndex: 2}}
+		mockPendingConsolidations(oracle, &pending)
+		mockValidators(oracle, map[phase0.ValidatorIndex]*v1.Validator{})
+
+		require.NoError(t, oracle.trackConsolidations(1024))
+		pending = []PendingConsolidation{}
+		require.NoError(t, oracle.trackConsolidations(1056))
+		require.Equal(t, TargetBanned, oracle.state.Consolidations[0].Target)
+		require.Equal(t, big.NewInt(0), oracle.state.Validators[2].PendingRewardsWei)
+		require.Equal(t, big.NewInt(100), oracle.state.Validators[3].PendingRewardsWei)
+	})
+
+	t.Run("Slashed source cancels the consolidation", func(t *testing.T) {
+		oracle := testOracle(Mainnet, 0)
+		oracle.state.Validators[1] = trackedValidator(1, Active, 100, cold)
+		oracle.state.Validators[2] = trackedValidator(2, Active, 0, cold)
+		pending := []PendingConsolidation{{SourceIndex: 1, TargetIndex: 2}}
+		mockPendingConsolidations(oracle, &pending)
+		mockValidators(oracle, map[phase0.ValidatorIndex]*v1.Validator{
+			1: {Index: 1, Status: v1.ValidatorStateExitedSlashed, Validator: &phase0.Validator{Slashed: true}},
+			2: {Index: 2, Status: v1.ValidatorStateActiveOngoing, Validator: &phase0.Validator{}},
+		})
+
+		require.NoError(t, oracle.trackConsolidations(1024))
+		pending = []PendingConsolidation{}
+		require.NoError(t, oracle.trackConsolidations(1056))
+		require.Equal(t, ConsolidationCancelled, oracle.state.Consolidations[0].Status)
+		require.Nil(t, oracle.state.Consolidations[0].TransferredPendingWei)
+		require.Equal(t, big.NewInt(0), oracle.state.Validators[2].PendingRewardsWei)
+		require.Equal(t, big.NewInt(100), oracle.state.Validators[1].PendingRewardsWei)
+	})
+}
+
+func Test_AdvanceStateToNextSlot_TracksConsolidations(t *testing.T) {
+	activateFork(t, SlotConsolidationsFork, Mainnet, 1000)
+	cold := "0x00000000000000000000000000000000000000a1"
+
+	oracle := testOracle(Mainnet, 0)
+	oracle.state.Validators[1] = trackedValidator(1, Active, 100, cold)
+	oracle.state.Validators[2] = trackedValidator(2, Active, 0, cold)
+	oracle.state.ProposedBlocks = []SummarizedBlock{{RewardType: VanilaBlock, Reward: big.NewInt(100)}}
+	oracle.state.Consolidations = []Consolidation{{SourceIndex: 1, TargetIndex: 2, Status: ConsolidationPending}}
+	oracle.state.NextSlotToProcess = 1023
+	oracle.state.LatestProcessedSlot = 1022
+	pending := []PendingConsolidation{}
+	mockPendingConsolidations(oracle, &pending)
+	mockValidators(oracle, map[phase0.ValidatorIndex]*v1.Validator{})
+
+	// Only tracked at the start of each epoch
+	_, err := oracle.AdvanceStateToNextSlot(missedFullBlock(1023))
+	require.NoError(t, err)
+	require.Equal(t, ConsolidationPending, oracle.state.Consolidations[0].Status)
+
+	_, err = oracle.AdvanceStateToNextSlot(missedFullBlock(1024))
+	require.NoError(t, err)
+	require.Equal(t, ConsolidationCompleted, oracle.state.Consolidations[0].Status)
+	require.Equal(t, big.NewInt(100), oracle.state.Validators[2].PendingRewardsWei)
+
+	reloaded, err := reloadOracle(t, oracle)
+	require.NoError(t, err)
+	require.Equal(t, ConsolidationsOf(oracle.state, nil), ConsolidationsOf(reloaded.state, nil))
+}
+
+func Test_ConsolidationsFork(t *testing.T) {
+	cold := "0x00000000000000000000000000000000000000a1"
+	electraSlot := SlotElectraFork[Mainnet]
+	cleanupOracle := func() *Oracle {
+		oracle := testOracle(Mainnet, 0)
+		oracle.state.Validators[1] = trackedValidator(1, Active, 100, cold)
+		oracle.state.Validators[2] = trackedValidator(2, Active, 0, cold)
+		pending := []PendingConsolidation{{SourceIndex: 1, TargetIndex: 2}}
+		mockPendingConsolidations(oracle, &pending)
+		mockValidators(oracle, map[phase0.ValidatorIndex]*v1.Validator{
+			1: {Index: 1, Status: v1.ValidatorStateExitedUnslashed, Validator: &phase0.Validator{}},
+			2: {Index: 2, Status: v1.ValidatorStateActiveOngoing, Validator: &phase0.Validator{}},
+		})
+		return oracle
+	}
+
+	// Before the fork the cleanup transfers the rewards without recording the consolidation
+	activateFork(t, SlotConsolidationsFork, Mainnet, electraSlot+2)
+	oracle := cleanupOracle()
+	require.NoError(t, oracle.ValidatorCleanup(electraSlot+1))
+	require.Equal(t, big.NewInt(100), oracle.state.Validators[2].PendingRewardsWei)
+	require.Empty(t, oracle.state.Consolidations)
+
+	// From it, its recorded with the transferred rewards
+	activateFork(t, SlotConsolidationsFork, Mainnet, electraSlot)
+	oracle = cleanupOracle()
+	require.NoError(t, oracle.ValidatorCleanup(electraSlot+1))
+	require.Equal(t, big.NewInt(100), oracle.state.Validators[2].PendingRewardsWei)
+	require.Len(t, oracle.state.Consolidations, 1)
+	require.Equal(t, ConsolidationPending, oracle.state.Consolidations[0].Status)
+	require.Equal(t, big.NewInt(100), oracle.state.Consolidations[0].TransferredPendingWei)
+	require.Equal(t, TargetSubscribed, oracle.state.Consolidations[0].Target)
+}
+
+func Test_ConsolidationsFork_Requests(t *testing.T) {
+	cold := "0x00000000000000000000000000000000000000a1"
+	requestOracle := func() *Oracle {
+		oracle := testOracle(Mainnet, 0)
+		oracle.state.Validators[1] = trackedValidator(1, Active, 0, cold)
+		return oracle
+	}
+	block := func() *FullBlock {
+		return proposedBlockWithBody(1000, &electra.BeaconBlockBody{
+			ExecutionRequests: &electra.ExecutionRequests{
+				Consolidations: []*electra.ConsolidationRequest{consolidationRequest(1, 2)},
+			},
+		})
+	}
+
+	// Requests before the fork are not tracked
+	activateFork(t, SlotConsolidationsFork, Mainnet, 1001)
+	oracle := requestOracle()
+	_, err := oracle.AdvanceStateToNextSlot(block())
+	require.NoError(t, err)
+	require.Empty(t, oracle.state.Consolidations)
+
+	// And from it they are
+	activateFork(t, SlotConsolidationsFork, Mainnet, 1000)
+	oracle = requestOracle()
+	_, err = oracle.AdvanceStateToNextSlot(block())
+	require.NoError(t, err)
+	require.Len(t, oracle.state.Consolidations, 1)
+	require.Equal(t, ConsolidationRequested, oracle.state.Consolidations[0].Status)
+}
+
+func Test_ConsolidationsFork_Scheduled(t *testing.T) {
+	cold := "0x00000000000000000000000000000000000000a1"
+	for _, network := range []string{Mainnet, Hoodi} {
+		forkSlot := SlotConsolidationsFork[network]
+		cfg := testConfig(network, 0)
+		cfg.DeployedSlot = forkSlot - 1
+		oracle := NewOracle(cfg)
+		oracle.state.Validators[1] = trackedValidator(1, Active, 0, cold)
+		oracle.state.Validators[3] = trackedValidator(3, Active, 0, cold)
+		mockPendingConsolidations(oracle, &[]PendingConsolidation{})
+		mockValidators(oracle, map[phase0.ValidatorIndex]*v1.Validator{
+			1: {Index: 1, Status: v1.ValidatorStateActiveOngoing, Validator: &phase0.Validator{}},
+			3: {Index: 3, Status: v1.ValidatorStateActiveOngoing, Validator: &phase0.Validator{}},
+		})
+		block := func(slot uint64, sourceKey byte) *FullBlock {
+			return proposedBlockWithBody(slot, &electra.BeaconBlockBody{
+				ExecutionRequests: &electra.ExecutionRequests{
+					Consolidations: []*electra.ConsolidationRequest{consolidationRequest(sourceKey, 2)},
+				},
+			})
+		}
+
+		// Requests before the scheduled slot are not tracked
+		_, err := oracle.AdvanceStateToNextSlot(block(forkSlot-1, 3))
+		require.NoError(t, err, network)
+		require.Empty(t, oracle.state.Consolidations, network)
+
+		_, err = oracle.AdvanceStateToNextSlot(block(forkSlot, 1))
+		require.NoError(t, err, network)
+		require.Len(t, oracle.state.Consolidations, 1, network)
+		require.Equal(t, uint64(1), oracle.state.Consolidations[0].SourceIndex, network)
+	}
+}
+
+func Test_ValidatorIndexByKey(t *testing.T) {
+	cold := "0x00000000000000000000000000000000000000a1"
+	oracle := testOracle(Mainnet, 0)
+	oracle.state.Validators[1] = trackedValidator(1, Active, 0, cold)
+
+	valIndex, found := oracle.validatorIndexByKey(strings.ToUpper(oracle.state.Validators[1].ValidatorKey))
+	require.True(t, found)
+	require.Equal(t, uint64(1), valIndex)
+	_, found = oracle.validatorIndexByKey(phase0.BLSPubKey{2}.String())
+	require.False(t, found)
+
+	// Validators added later are found
+	oracle.state.Validators[2] = trackedValidator(2, Active, 0, cold)
+	valIndex, found = oracle.validatorIndexByKey(phase0.BLSPubKey{2}.String())
+	require.True(t, found)
+	require.Equal(t, uint64(2), valIndex)
+
+	// And the ones of a replaced state
+	oracle.state = &OracleState{Validators: map[uint64]*ValidatorInfo{3: trackedValidator(3, Active, 0, cold)}}
+	_, found = oracle.validatorIndexByKey(phase0.BLSPubKey{2}.String())
+	require.False(t, found)
+	valIndex, found = oracle.validatorIndexByKey(phase0.BLSPubKey{3}.String())
+	require.True(t, found)
+	require.Equal(t, uint64(3), valIndex)
+}
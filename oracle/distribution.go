@@ -0,0 +1,323 @@
+package oracle
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Everything a reward method needs to split a reward among the eligible validators
+type DistributionInput struct {
+	Reward                   *big.Int
+	PoolFeesPercentOver10000 int
+	Slot                     uint64
+	EligibleValidators       []phase0.ValidatorIndex
+	GetSetOfValidators       GetSetOfValidatorsFunc
+}
+
+// Returns the cut the pool takes from the reward, before any rounding remainder
+func (in *DistributionInput) PoolCut() *big.Int {
+	aux := new(big.Int).Mul(in.Reward, big.NewInt(int64(in.PoolFeesPercentOver10000)))
+	return aux.Div(aux, big.NewInt(100*100))
+}
+
+// A reward method of the pool. Each method is registered per network with the slot
+// where it activates, so that adding a new one does not require modifying the state
+// transition, just implementing this interface and registering it.
+type RewardDistributor interface {
+	// Name of the method, stored in logs and used to force it in simulations
+	Method() string
+
+	// Returns the reward of each validator and the pool fees, which include the pool cut
+	// and whatever could not be evenly distributed
+	Distribute(in *DistributionInput) (map[uint64]*big.Int, *big.Int, error)
+
+	// Checks that the output of Distribute is consistent with the input. Its called
+	// after every distribution, and a violation halts the oracle
+	CheckInvariant(in *DistributionInput, perValidator map[uint64]*big.Int, poolFees *big.Int) error
+}
+
+// A reward distributor that is used from a given slot onwards, until the next one activates
+type rewardDistributorActivation struct {
+	slot        uint64
+	distributor RewardDistributor
+}
+
+// Reward methods of each network, sorted by activation slot
+var rewardDistributors = map[string][]rewardDistributorActivation{
+	Mainnet: {
+		{slot: 0, distributor: &PreFork1Distributor{}},
+		{slot: SlotFork1[Mainnet], distributor: &Fork1Distributor{}},
+		{slot: SlotElectraFork[Mainnet], distributor: &ElectraDistributor{}},
+	},
+	Holesky: {
+		{slot: 0, distributor: &PreFork1Distributor{}},
+		{slot: SlotFork1[Holesky], distributor: &Fork1Distributor{}},
+	},
+	Hoodi: {
+		{slot: 0, distributor: &PreFork1Distributor{}},
+		{slot: SlotFork1[Hoodi], distributor: &Fork1Distributor{}},
+		{slot: SlotElectraFork[Hoodi], distributor: &ElectraDistributor{}},
+	},
+}
+
+// Registers a reward distributor for a network, active from activationSlot onwards. If there
+// is already one activating at the same slot, its replaced. Not safe to call concurrently
+// with the oracle processing slots, meant to be used at startup.
+func RegisterRewardDistributor(network string, activationSlot uint64, distributor RewardDistributor) {
+	activations := rewardDistributors[network]
+	for i, activation := range activations {
+		if activation.slot == activationSlot {
+			activations[i].distributor = distributor
+			return
+		}
+	}
+	activations = append(activations, rewardDistributorActivation{slot: activationSlot, distributor: distributor})
+	sort.Slice(activations, func(i, j int) bool { return activations[i].slot < activations[j].slot })
+	rewardDistributors[network] = activations
+}
+
+// Returns the reward distributor of a network at a given slot
+func GetRewardDistributor(network string, slot uint64) (RewardDistributor, error) {
+	activations, found := rewardDistributors[network]
+	if !found || len(activations) == 0 {
+		return nil, errors.New(fmt.Sprintf("no reward distributors for network: %s", network))
+	}
+	for i := len(activations) - 1; i >= 0; i-- {
+		if slot >= activations[i].slot {
+			return activations[i].distributor, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("no reward distributor active at slot %d for network: %s", slot, network))
+}
+
+// Returns a registered reward distributor given its method name, in any network
+func GetRewardDistributorByMethod(method string) (RewardDistributor, error) {
+	for _, activations := range rewardDistributors {
+		for _, activation := range activations {
+			if activation.distributor.Method() == method {
+				return activation.distributor, nil
+			}
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("unknown reward method: %s", method))
+}
+
+// Checks the invariants that any reward method must hold: no reward is created nor
+// lost, the pool gets at least its cut and only eligible validators get rewards
+func checkCommonInvariants(in *DistributionInput, perValidator map[uint64]*big.Int, poolFees *big.Int) error {
+	eligible := make(map[uint64]bool, len(in.EligibleValidators))
+	for _, valIndex := range in.EligibleValidators {
+		eligible[uint64(valIndex)] = true
+	}
+
+	sum := new(big.Int).Set(poolFees)
+	for valIndex, reward := range perValidator {
+		if !eligible[valIndex] {
+			return errors.New(fmt.Sprintf("validator %d is not eligible but got rewards: %s", valIndex, reward))
+		}
+		sum.Add(sum, reward)
+	}
+
+	if sum.Cmp(in.Reward) != 0 {
+		return errors.New(fmt.Sprintf("rewards to validators + pool fees != total reward: %s vs %s", sum, in.Reward))
+	}
+	if poolFees.Cmp(in.PoolCut()) < 0 {
+		return errors.New(fmt.Sprintf("pool fees are lower than the pool cut: %s vs %s", poolFees, in.PoolCut()))
+	}
+	return nil
+}
+
+// Checks that no validator got negative rewards
+func checkNonNegative(perValidator map[uint64]*big.Int) error {
+	for valIndex, reward := range perValidator {
+		if reward.Sign() < 0 {
+			return errors.New(fmt.Sprintf("validator %d got negative rewards: %s", valIndex, reward))
+		}
+	}
+	return nil
+}
+
+// Checks that all eligible validators got the same reward and that the part that
+// could not be evenly distributed is lower than maxRemainder
+func checkEvenSplit(in *DistributionInput, perValidator map[uint64]*big.Int, poolFees *big.Int, maxRemainder *big.Int) error {
+	if len(perValidator) != len(in.EligibleValidators) {
+		return errors.New(fmt.Sprintf("not all eligible validators got rewards: %d vs %d",
+			len(perValidator), len(in.EligibleValidators)))
+	}
+	var share *big.Int
+	for valIndex, reward := range perValidator {
+		if share == nil {
+			share = reward
+		} else if share.Cmp(reward) != 0 {
+			return errors.New(fmt.Sprintf("uneven split, validator %d got %s instead of %s", valIndex, reward, share))
+		}
+	}
+	remainder := new(big.Int).Sub(poolFees, in.PoolCut())
+	if remainder.Cmp(maxRemainder) >= 0 {
+		return errors.New(fmt.Sprintf("remainder sent to the pool is too big: %s, max: %s", remainder, maxRemainder))
+	}
+	return nil
+}
+
+// First method used by the pool. The reward is evenly split among all eligible validators.
+// Has a minor rounding issue, where the remainder of the pool cut is not distributed. With
+// rewards of a few wei this can even result in negative shares, which is kept as is since
+// this method is only used to reproduce the history before fork 1.
+type PreFork1Distributor struct{}
+
+func (d *PreFork1Distributor) Method() string {
+	return RewardMethodPreFork1
+}
+
+func (d *PreFork1Distributor) Distribute(in *DistributionInput) (map[uint64]*big.Int, *big.Int, error) {
+	if len(in.EligibleValidators) == 0 {
+		return nil, nil, errors.New("no eligible validators")
+	}
+	numEligibleValidators := big.NewInt(int64(len(in.EligibleValidators)))
+	over := big.NewInt(100 * 100)
+	aux := new(big.Int).Mul(in.Reward, big.NewInt(int64(in.PoolFeesPercentOver10000)))
+	poolCut := new(big.Int).Div(aux, over)
+	remainder1 := new(big.Int).Mod(aux, over)
+
+	toShareAllValidators := new(big.Int).Sub(in.Reward, poolCut)
+	toShareAllValidators.Sub(toShareAllValidators, remainder1)
+
+	perValidatorReward := new(big.Int).Div(toShareAllValidators, numEligibleValidators)
+	remainder2 := new(big.Int).Mod(toShareAllValidators, numEligibleValidators)
+
+	totalFees := new(big.Int).Add(poolCut, remainder1)
+	totalFees.Add(totalFees, remainder2)
+
+	perValidator := make(map[uint64]*big.Int, len(in.EligibleValidators))
+	for _, valIndex := range in.EligibleValidators {
+		perValidator[uint64(valIndex)] = new(big.Int).Set(perValidatorReward)
+	}
+	return perValidator, totalFees, nil
+}
+
+func (d *PreFork1Distributor) CheckInvariant(in *DistributionInput, perValidator map[uint64]*big.Int, poolFees *big.Int) error {
+	if err := checkCommonInvariants(in, perValidator, poolFees); err != nil {
+		return err
+	}
+	// Both the remainder of the pool cut and of the split go to the pool
+	maxRemainder := big.NewInt(int64(100*100 + len(in.EligibleValidators)))
+	return checkEvenSplit(in, perValidator, poolFees, maxRemainder)
+}
+
+// Fork 1 method. The reward is evenly split among all eligible validators, fixing the
+// rounding of the pool cut.
+type Fork1Distributor struct{}
+
+func (d *Fork1Distributor) Method() string {
+	return RewardMethodFork1
+}
+
+func (d *Fork1Distributor) Distribute(in *DistributionInput) (map[uint64]*big.Int, *big.Int, error) {
+	if len(in.EligibleValidators) == 0 {
+		return nil, nil, errors.New("no eligible validators")
+	}
+	numEligibleValidators := big.NewInt(int64(len(in.EligibleValidators)))
+	poolCut := in.PoolCut()
+
+	toShareAllValidators := new(big.Int).Sub(in.Reward, poolCut)
+	perValidatorReward := new(big.Int).Div(toShareAllValidators, numEligibleValidators)
+	remainder := new(big.Int).Mod(toShareAllValidators, numEligibleValidators)
+	totalFees := new(big.Int).Add(poolCut, remainder)
+
+	perValidator := make(map[uint64]*big.Int, len(in.EligibleValidators))
+	for _, valIndex := range in.EligibleValidators {
+		perValidator[uint64(valIndex)] = new(big.Int).Set(perValidatorReward)
+	}
+	return perValidator, totalFees, nil
+}
+
+func (d *Fork1Distributor) CheckInvariant(in *DistributionInput, perValidator map[uint64]*big.Int, poolFees *big.Int) error {
+	if err := checkCommonInvariants(in, perValidator, poolFees); err != nil {
+		return err
+	}
+	if err := checkNonNegative(perValidator); err != nil {
+		return err
+	}
+	return checkEvenSplit(in, perValidator, poolFees, big.NewInt(int64(len(in.EligibleValidators))))
+}
+
+// Electra method. The reward is split proportionally to the effective balance of each
+// eligible validator, since after electra a validator can have more than 32 ETH.
+type ElectraDistributor struct{}
+
+func (d *ElectraDistributor) Method() string {
+	return RewardMethodElectra
+}
+
+func (d *ElectraDistributor) Distribute(in *DistributionInput) (map[uint64]*big.Int, *big.Int, error) {
+	if in.GetSetOfValidators == nil {
+		return nil, nil, errors.New("electra method requires the effective balances of the validators")
+	}
+	poolCut := in.PoolCut()
+	toDistribute := new(big.Int).Sub(in.Reward, poolCut)
+
+	// get onchain validators balance at current processing slot
+	validatorsMap, err := in.GetSetOfValidators(in.EligibleValidators, strconv.FormatUint(in.Slot, 10))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch validator balances: %w", err)
+	}
+
+	// total balance of all validators, needed to compute what each validator gets
+	totalBalance := new(big.Int)
+	for _, v := range validatorsMap {
+		totalBalance.Add(totalBalance, big.NewInt(int64(v.Validator.EffectiveBalance)))
+	}
+	if totalBalance.Sign() == 0 {
+		return nil, nil, fmt.Errorf("total balance is zero")
+	}
+
+	// we keep track of the total amount of rewards distributed to validators to later check everything is correct
+	perValidator := make(map[uint64]*big.Int, len(validatorsMap))
+	totalDistributed := new(big.Int)
+
+	// iterate over all validators and calculate their share of the rewards
+	// we use the balance of each validator to calculate their share
+	for _, v := range validatorsMap {
+		share := new(big.Int).Mul(toDistribute, big.NewInt(int64(v.Validator.EffectiveBalance)))
+		share.Div(share, totalBalance)
+
+		log.WithFields(log.Fields{
+			"ValidatorIndex":            v.Index,
+			"ValidatorEffectiveBalance": v.Validator.EffectiveBalance,
+			"TotalEffectiveBalance":     totalBalance,
+			"RewardToDistribute":        toDistribute,
+			"Share":                     share,
+		}).Trace("[ELECTRA] Validator share")
+
+		perValidator[uint64(v.Index)] = share
+		totalDistributed.Add(totalDistributed, share)
+	}
+
+	// there will be a small amount of wei that cannot be distributed evenly, it goes to the pool
+	remainder := new(big.Int).Sub(toDistribute, totalDistributed)
+	totalFees := new(big.Int).Add(poolCut, remainder)
+
+	return perValidator, totalFees, nil
+}
+
+func (d *ElectraDistributor) CheckInvariant(in *DistributionInput, perValidator map[uint64]*big.Int, poolFees *big.Int) error {
+	if err := checkCommonInvariants(in, perValidator, poolFees); err != nil {
+		return err
+	}
+	if err := checkNonNegative(perValidator); err != nil {
+		return err
+	}
+	// Each share is rounded down, so each validator can lose at most 1 wei
+	remainder := new(big.Int).Sub(poolFees, in.PoolCut())
+	if remainder.Cmp(big.NewInt(int64(len(perValidator)))) >= 0 {
+		return errors.New(fmt.Sprintf("remainder sent to the pool is too big: %s, validators: %d",
+			remainder, len(perValidator)))
+	}
+	return nil
+}
@@ -0,0 +1,310 @@
+package oracle
+
+import (
+	"math/big"
+	"testing"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/avast/retry-go/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// Test vector shared by all reward distributors
+type distributionTestVector struct {
+	Name         string
+	Reward       *big.Int
+	Fee          int
+	Eligible     []phase0.ValidatorIndex
+	Balances     map[phase0.ValidatorIndex]phase0.Gwei
+	PerValidator map[uint64]*big.Int
+	PoolFees     *big.Int
+}
+
+// Returns a GetSetOfValidatorsFunc serving the given effective balances
+func effectiveBalancesFunc(balances map[phase0.ValidatorIndex]phase0.Gwei) GetSetOfValidatorsFunc {
+	return func(valIndices []phase0.ValidatorIndex, slot string, opts ...retry.Option) (map[phase0.ValidatorIndex]*v1.Validator, error) {
+		validators := make(map[phase0.ValidatorIndex]*v1.Validator)
+		for _, valIndex := range valIndices {
+			if balance, found := balances[valIndex]; found {
+				validators[valIndex] = &v1.Validator{Index: valIndex, Validator: &phase0.Validator{EffectiveBalance: balance}}
+			}
+		}
+		return validators, nil
+	}
+}
+
+func runDistributionTestVectors(t *testing.T, distributor RewardDistributor, vectors []distributionTestVector) {
+	for _, vector := range vectors {
+		t.Run(vector.Name, func(t *testing.T) {
+			in := &DistributionInput{
+				Reward:                   vector.Reward,
+				PoolFeesPercentOver10000: vector.Fee,
+				EligibleValidators:       vector.Eligible,
+				GetSetOfValidators:       effectiveBalancesFunc(vector.Balances),
+			}
+			perValidator, poolFees, err := distributor.Distribute(in)
+			require.NoError(t, err)
+			require.Equal(t, vector.PerValidator, perValidator)
+			require.Equal(t, vector.PoolFees, poolFees)
+			require.NoError(t, distributor.CheckInvariant(in, perValidator, poolFees))
+		})
+	}
+}
+
+func Test_PreFork1Distributor(t *testing.T) {
+	runDistributionTestVectors(t, &PreFork1Distributor{}, []distributionTestVector{
+		{
+			Name:         "Clean division",
+			Reward:       big.NewInt(1000),
+			Fee:          1000,
+			Eligible:     []phase0.ValidatorIndex{3, 6, 9},
+			PerValidator: map[uint64]*big.Int{3: big.NewInt(300), 6: big.NewInt(300), 9: big.NewInt(300)},
+			PoolFees:     big.NewInt(100),
+		},
+		{
+			// aux = 1500004500, cut = 150000, remainder1 = 4500
+			// to share = 1000003 - 150000 - 4500 = 845503 → 422751 each + 1
+			Name:         "Rounding of the pool cut goes to the pool",
+			Reward:       big.NewInt(1000003),
+			Fee:          1500,
+			Eligible:     []phase0.ValidatorIndex{1, 2},
+			PerValidator: map[uint64]*big.Int{1: big.NewInt(422751), 2: big.NewInt(422751)},
+			PoolFees:     big.NewInt(154501),
+		},
+		{
+			// aux = 1001*1000 = 1001000, cut = 100, remainder1 = 1000
+			// to share = 1001 - 100 - 1000 = -99 → -33 each
+			Name:         "Tiny reward results in negative shares",
+			Reward:       big.NewInt(1001),
+			Fee:          1000,
+			Eligible:     []phase0.ValidatorIndex{1, 2, 3},
+			PerValidator: map[uint64]*big.Int{1: big.NewInt(-33), 2: big.NewInt(-33), 3: big.NewInt(-33)},
+			PoolFees:     big.NewInt(1100),
+		},
+		{
+			Name:         "Big reward with remainder",
+			Reward:       big.NewInt(32000000000007),
+			Fee:          1500,
+			Eligible:     []phase0.ValidatorIndex{1, 2},
+			PerValidator: map[uint64]*big.Int{1: big.NewInt(13599999999753), 2: big.NewInt(13599999999753)},
+			PoolFees:     big.NewInt(4800000000501),
+		},
+	})
+}
+
+func Test_Fork1Distributor(t *testing.T) {
+	runDistributionTestVectors(t, &Fork1Distributor{}, []distributionTestVector{
+		{
+			Name:         "Even split with zero pool fees",
+			Reward:       big.NewInt(900),
+			Fee:          0,
+			Eligible:     []phase0.ValidatorIndex{4, 6, 10},
+			PerValidator: map[uint64]*big.Int{4: big.NewInt(300), 6: big.NewInt(300), 10: big.NewInt(300)},
+			PoolFees:     big.NewInt(0),
+		},
+		{
+			Name:         "Remainder goes to pool",
+			Reward:       big.NewInt(1001),
+			Fee:          0,
+			Eligible:     []phase0.ValidatorIndex{3, 5, 9},
+			PerValidator: map[uint64]*big.Int{3: big.NewInt(333), 5: big.NewInt(333), 9: big.NewInt(333)},
+			PoolFees:     big.NewInt(2),
+		},
+		{
+			Name:         "Pool cut and remainder",
+			Reward:       big.NewInt(1001),
+			Fee:          1000,
+			Eligible:     []phase0.ValidatorIndex{1, 2, 3},
+			PerValidator: map[uint64]*big.Int{1: big.NewInt(300), 2: big.NewInt(300), 3: big.NewInt(300)},
+			PoolFees:     big.NewInt(101),
+		},
+	})
+}
+
+func Test_ElectraDistributor(t *testing.T) {
+	runDistributionTestVectors(t, &ElectraDistributor{}, []distributionTestVector{
+		{
+			Name:         "Equal balances",
+			Reward:       big.NewInt(900),
+			Fee:          1000,
+			Eligible:     []phase0.ValidatorIndex{3, 5, 7},
+			Balances:     map[phase0.ValidatorIndex]phase0.Gwei{3: 100, 5: 100, 7: 100},
+			PerValidator: map[uint64]*big.Int{3: big.NewInt(270), 5: big.NewInt(270), 7: big.NewInt(270)},
+			PoolFees:     big.NewInt(90),
+		},
+		{
+			Name:         "Proportional to effective balance",
+			Reward:       big.NewInt(600),
+			Fee:          2000,
+			Eligible:     []phase0.ValidatorIndex{3, 5, 7},
+			Balances:     map[phase0.ValidatorIndex]phase0.Gwei{3: 1, 5: 2, 7: 3},
+			PerValidator: map[uint64]*big.Int{3: big.NewInt(80), 5: big.NewInt(160), 7: big.NewInt(240)},
+			PoolFees:     big.NewInt(120),
+		},
+		{
+			Name:         "Validator with double balance",
+			Reward:       big.NewInt(100),
+			Fee:          0,
+			Eligible:     []phase0.ValidatorIndex{1, 2, 3},
+			Balances:     map[phase0.ValidatorIndex]phase0.Gwei{1: 32, 2: 32, 3: 64},
+			PerValidator: map[uint64]*big.Int{1: big.NewInt(25), 2: big.NewInt(25), 3: big.NewInt(50)},
+			PoolFees:     big.NewInt(0),
+		},
+		{
+			Name:         "Uneven with remainder",
+			Reward:       big.NewInt(100),
+			Fee:          0,
+			Eligible:     []phase0.ValidatorIndex{1, 2, 3},
+			Balances:     map[phase0.ValidatorIndex]phase0.Gwei{1: 1, 2: 1, 3: 1},
+			PerValidator: map[uint64]*big.Int{1: big.NewInt(33), 2: big.NewInt(33), 3: big.NewInt(33)},
+			PoolFees:     big.NewInt(1),
+		},
+	})
+
+	// Total effective balance can't be zero
+	_, _, err := (&ElectraDistributor{}).Distribute(&DistributionInput{
+		Reward:             big.NewInt(100),
+		EligibleValidators: []phase0.ValidatorIndex{1},
+		GetSetOfValidators: effectiveBalancesFunc(map[phase0.ValidatorIndex]phase0.Gwei{1: 0}),
+	})
+	require.Error(t, err)
+
+	// Balances are required
+	_, _, err = (&ElectraDistributor{}).Distribute(&DistributionInput{
+		Reward:             big.NewInt(100),
+		EligibleValidators: []phase0.ValidatorIndex{1},
+	})
+	require.Error(t, err)
+}
+
+func Test_RewardDistributors_CheckInvariant(t *testing.T) {
+	in := &DistributionInput{
+		Reward:                   big.NewInt(1000),
+		PoolFeesPercentOver10000: 1000,
+		EligibleValidators:       []phase0.ValidatorIndex{1, 2},
+	}
+
+	for _, distributor := range []RewardDistributor{&PreFork1Distributor{}, &Fork1Distributor{}, &ElectraDistributor{}} {
+		// Valid distribution
+		require.NoError(t, distributor.CheckInvariant(in,
+			map[uint64]*big.Int{1: big.NewInt(450), 2: big.NewInt(450)}, big.NewInt(100)))
+
+		// Rewards are created
+		require.Error(t, distributor.CheckInvariant(in,
+			map[uint64]*big.Int{1: big.NewInt(450), 2: big.NewInt(451)}, big.NewInt(100)))
+
+		// Non eligible validator gets rewards
+		require.Error(t, distributor.CheckInvariant(in,
+			map[uint64]*big.Int{1: big.NewInt(450), 3: big.NewInt(450)}, big.NewInt(100)))
+
+		// Pool gets less than its cut
+		require.Error(t, distributor.CheckInvariant(in,
+			map[uint64]*big.Int{1: big.NewInt(460), 2: big.NewInt(460)}, big.NewInt(80)))
+	}
+
+	// Even split methods
+	for _, distributor := range []RewardDistributor{&PreFork1Distributor{}, &Fork1Distributor{}} {
+		require.Error(t, distributor.CheckInvariant(in,
+			map[uint64]*big.Int{1: big.NewInt(400), 2: big.NewInt(500)}, big.NewInt(100)))
+		require.Error(t, distributor.CheckInvariant(in,
+			map[uint64]*big.Int{1: big.NewInt(900)}, big.NewInt(100)))
+	}
+
+	// Fork1 remainder must be lower than the amount of validators
+	require.Error(t, (&Fork1Distributor{}).CheckInvariant(in,
+		map[uint64]*big.Int{1: big.NewInt(449), 2: big.NewInt(449)}, big.NewInt(102)))
+
+	// Electra remainder must be lower than the amount of rewarded validators
+	require.Error(t, (&ElectraDistributor{}).CheckInvariant(in,
+		map[uint64]*big.Int{1: big.NewInt(300), 2: big.NewInt(598)}, big.NewInt(102)))
+	require.NoError(t, (&ElectraDistributor{}).CheckInvariant(in,
+		map[uint64]*big.Int{1: big.NewInt(300), 2: big.NewInt(599)}, big.NewInt(101)))
+
+	// Negative rewards
+	require.Error(t, (&ElectraDistributor{}).CheckInvariant(in,
+		map[uint64]*big.Int{1: big.NewInt(-100), 2: big.NewInt(1000)}, big.NewInt(100)))
+}
+
+func Test_GetRewardDistributor(t *testing.T) {
+	type test struct {
+		Network string
+		Slot    uint64
+		Method  string
+	}
+
+	tests := []test{
+		{Mainnet, 0, RewardMethodPreFork1},
+		{Mainnet, SlotFork1[Mainnet] - 1, RewardMethodPreFork1},
+		{Mainnet, SlotFork1[Mainnet], RewardMethodFork1},
+		{Mainnet, SlotElectraFork[Mainnet] - 1, RewardMethodFork1},
+		{Mainnet, SlotElectraFork[Mainnet], RewardMethodElectra},
+		{Holesky, SlotFork1[Holesky], RewardMethodFork1},
+		{Holesky, 99999999, RewardMethodFork1},
+		{Hoodi, 0, RewardMethodPreFork1},
+		{Hoodi, 1, RewardMethodFork1},
+		{Hoodi, SlotElectraFork[Hoodi], RewardMethodElectra},
+	}
+
+	for _, test := range tests {
+		distributor, err := GetRewardDistributor(test.Network, test.Slot)
+		require.NoError(t, err)
+		require.Equal(t, test.Method, distributor.Method())
+	}
+
+	_, err := GetRewardDistributor("unknown", 1)
+	require.Error(t, err)
+
+	distributor, err := GetRewardDistributorByMethod(RewardMethodFork1)
+	require.NoError(t, err)
+	require.Equal(t, RewardMethodFork1, distributor.Method())
+
+	_, err = GetRewardDistributorByMethod("unknown")
+	require.Error(t, err)
+}
+
+// Distributor that sends everything to the pool, to test new registrations
+type allToPoolDistributor struct{}
+
+func (d *allToPoolDistributor) Method() string {
+	return "all_to_pool"
+}
+
+func (d *allToPoolDistributor) Distribute(in *DistributionInput) (map[uint64]*big.Int, *big.Int, error) {
+	return map[uint64]*big.Int{}, new(big.Int).Set(in.Reward), nil
+}
+
+func (d *allToPoolDistributor) CheckInvariant(in *DistributionInput, perValidator map[uint64]*big.Int, poolFees *big.Int) error {
+	return checkCommonInvariants(in, perValidator, poolFees)
+}
+
+func Test_RegisterRewardDistributor(t *testing.T) {
+	network := "test_register"
+	defer delete(rewardDistributors, network)
+
+	RegisterRewardDistributor(network, 100, &Fork1Distributor{})
+	RegisterRewardDistributor(network, 0, &PreFork1Distributor{})
+	RegisterRewardDistributor(network, 200, &allToPoolDistributor{})
+
+	distributor, err := GetRewardDistributor(network, 50)
+	require.NoError(t, err)
+	require.Equal(t, RewardMethodPreFork1, distributor.Method())
+
+	distributor, err = GetRewardDistributor(network, 150)
+	require.NoError(t, err)
+	require.Equal(t, RewardMethodFork1, distributor.Method())
+
+	// New method is used by the oracle without further changes
+	oracle := NewOracle(&Config{Network: network, PoolFeesPercentOver10000: 1000})
+	oracle.state.NextSlotToProcess = 250
+	oracle.state.Validators[1] = &ValidatorInfo{PendingRewardsWei: big.NewInt(0), ValidatorStatus: Active}
+	oracle.increaseAllPendingRewards(big.NewInt(1000))
+	require.Equal(t, big.NewInt(1000), oracle.state.PoolAccumulatedFees)
+	require.Equal(t, big.NewInt(0), oracle.state.Validators[1].PendingRewardsWei)
+
+	// Registering at the same slot replaces it
+	RegisterRewardDistributor(network, 200, &Fork1Distributor{})
+	distributor, err = GetRewardDistributor(network, 250)
+	require.NoError(t, err)
+	require.Equal(t, RewardMethodFork1, distributor.Method())
+}
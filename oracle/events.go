@@ -0,0 +1,233 @@
+package oracle
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Types of the events streamed to the api clients
+type StreamEventType string
+
+const (
+	SlotProcessedEvent      StreamEventType = "slot_processed"
+	BlockClassifiedEvent    StreamEventType = "block_classified"
+	ValidatorStatusEvent    StreamEventType = "validator_status"
+	DonationReceivedEvent   StreamEventType = "donation_received"
+	CheckpointFrozenEvent   StreamEventType = "checkpoint_frozen"
+	ReportSubmittedEvent    StreamEventType = "report_submitted"
+	ReportConsolidatedEvent StreamEventType = "report_consolidated"
+	ReconciliationEvent     StreamEventType = "reconciliation"
+)
+
+var AllStreamEventTypes = []StreamEventType{
+	SlotProcessedEvent, BlockClassifiedEvent, ValidatorStatusEvent, DonationReceivedEvent,
+	CheckpointFrozenEvent, ReportSubmittedEvent, ReportConsolidatedEvent, ReconciliationEvent,
+}
+
+// Event of the oracle. Events of a validator have its index and withdrawal address, the
+// ones of the whole pool none. Id is the token to resume the stream after this event
+type StreamEvent struct {
+	Id                string          `json:"id"`
+	Type              StreamEventType `json:"type"`
+	Slot              uint64          `json:"slot"`
+	ValidatorIndex    *uint64         `json:"validator_index,omitempty"`
+	WithdrawalAddress string          `json:"withdrawal_address,omitempty"`
+	Data              json.RawMessage `json:"data"`
+
+	sequence uint64
+}
+
+// Data of the events that have no type of their own
+type SlotProcessedData struct {
+	Slot      uint64 `json:"slot"`
+	Block     uint64 `json:"block"`
+	BlockType string `json:"block_type"`
+}
+
+type DonationData struct {
+	Sender    string `json:"sender"`
+	AmountWei string `json:"amount_wei"`
+	Block     uint64 `json:"block_number"`
+	TxHash    string `json:"tx_hash"`
+}
+
+type CheckpointData struct {
+	Slot         uint64 `json:"slot"`
+	MerkleRoot   string `json:"merkleroot"`
+	OracleMember string `json:"oracle_member,omitempty"`
+}
+
+// Events kept to resume the streams of clients that reconnect
+var MaxBufferedEvents = 10000
+
+// Events that a subscriber can have pending before its dropped for being too slow
+var SubscriberBuffer = 1000
+
+var ErrResumeExpired = errors.New("resume token expired, events were lost")
+
+// Subscription to the events published after it, see EventStream.Subscribe
+type EventSubscription struct {
+	Events chan StreamEvent
+}
+
+// Publishes the events of the oracle to its subscribers, keeping the latest ones so that
+// subscribers can resume after reconnecting. Tokens of a previous run are expired
+type EventStream struct {
+	mutex       sync.Mutex
+	run         int64
+	nextSeq     uint64
+	buffer      []StreamEvent
+	subscribers map[*EventSubscription]struct{}
+}
+
+func NewEventStream() *EventStream {
+	return &EventStream{
+		run:         time.Now().UnixNano(),
+		nextSeq:     1,
+		buffer:      make([]StreamEvent, 0),
+		subscribers: make(map[*EventSubscription]struct{}),
+	}
+}
+
+func (s *EventStream) Publish(event StreamEvent) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	event.sequence = s.nextSeq
+	event.Id = fmt.Sprintf("%d-%d", s.run, event.sequence)
+	s.nextSeq++
+
+	// Compacted once it doubles, so that publishing stays cheap
+	s.buffer = append(s.buffer, event)
+	if len(s.buffer) >= 2*MaxBufferedEvents {
+		s.buffer = append(make([]StreamEvent, 0, 2*MaxBufferedEvents), s.buffer[len(s.buffer)-MaxBufferedEvents:]...)
+	}
+
+	// Never blocks the oracle, slow subscribers are dropped and can resume
+	for subscription := range s.subscribers {
+		select {
+		case subscription.Events <- event:
+		default:
+			delete(s.subscribers, subscription)
+			close(subscription.Events)
+		}
+	}
+}
+
+// Subscribes to the events after the resume token, if any, returning the buffered ones
+// already published. Returns ErrResumeExpired if some of them are no longer buffered
+func (s *EventStream) Subscribe(resumeToken string) ([]StreamEvent, *EventSubscription, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	backlog := make([]StreamEvent, 0)
+	if resumeToken != "" {
+		run, seq, err := parseResumeToken(resumeToken)
+		if err != nil {
+			return nil, nil, err
+		}
+		oldest := s.nextSeq
+		if len(s.buffer) != 0 {
+			oldest = s.buffer[0].sequence
+		}
+		if s.nextSeq-oldest > uint64(MaxBufferedEvents) {
+			oldest = s.nextSeq - uint64(MaxBufferedEvents)
+		}
+		if run != s.run || seq >= s.nextSeq || seq+1 < oldest {
+			return nil, nil, ErrResumeExpired
+		}
+		for _, event := range s.buffer {
+			if event.sequence > seq && event.sequence >= oldest {
+				backlog = append(backlog, event)
+			}
+		}
+	}
+
+	subscription := &EventSubscription{Events: make(chan StreamEvent, SubscriberBuffer)}
+	s.subscribers[subscription] = struct{}{}
+	return backlog, subscription, nil
+}
+
+func (s *EventStream) Unsubscribe(subscription *EventSubscription) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, found := s.subscribers[subscription]; found {
+		delete(s.subscribers, subscription)
+		close(subscription.Events)
+	}
+}
+
+func parseResumeToken(token string) (int64, uint64, error) {
+	runStr, seqStr, found := strings.Cut(token, "-")
+	run, errRun := strconv.ParseInt(runStr, 10, 64)
+	seq, errSeq := strconv.ParseUint(seqStr, 10, 64)
+	if !found || errRun != nil || errSeq != nil {
+		return 0, 0, errors.New("invalid resume token: " + token)
+	}
+	return run, seq, nil
+}
+
+// Events published by the oracle
+func (or *Oracle) Events() *EventStream {
+	return or.events
+}
+
+func (or *Oracle) publish(eventType StreamEventType, slot uint64, valIndex *uint64, withdrawalAddress string, data any) {
+	if or.events == nil {
+		return
+	}
+	// Encoded now, since the data may change once the lock is released
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		log.WithField("Type", eventType).WithError(err).Error("Could not encode event")
+		return
+	}
+	or.events.Publish(StreamEvent{
+		Type:              eventType,
+		Slot:              slot,
+		ValidatorIndex:    valIndex,
+		WithdrawalAddress: strings.ToLower(withdrawalAddress),
+		Data:              encoded,
+	})
+}
+
+func (or *Oracle) publishBlock(block SummarizedBlock) {
+	valIndex := block.ValidatorIndex
+	or.publish(BlockClassifiedEvent, block.Slot, &valIndex, block.WithdrawalAddress, &block)
+}
+
+func (or *Oracle) publishDonation(donation *contract.ContractEtherReceived) {
+	or.publish(DonationReceivedEvent, or.state.NextSlotToProcess, nil, "", DonationData{
+		Sender:    donation.Sender.String(),
+		AmountWei: donation.DonationAmount.String(),
+		Block:     donation.Raw.BlockNumber,
+		TxHash:    donation.Raw.TxHash.String(),
+	})
+}
+
+// Publishes the reports that oracle members submitted to the contract and the ones that were
+// consolidated in the processed block, so they are seen regardless of what this oracle submits
+func (or *Oracle) publishReports(events *Events) {
+	for _, report := range events.SubmitReport {
+		or.publish(ReportSubmittedEvent, report.SlotNumber.Uint64(), nil, "", CheckpointData{
+			Slot:         report.SlotNumber.Uint64(),
+			MerkleRoot:   hexutil.Encode(report.NewRewardsRoot[:]),
+			OracleMember: report.OracleMember.String(),
+		})
+	}
+	for _, report := range events.ReportConsolidated {
+		or.publish(ReportConsolidatedEvent, report.SlotNumber.Uint64(), nil, "", CheckpointData{
+			Slot:       report.SlotNumber.Uint64(),
+			MerkleRoot: hexutil.Encode(report.NewRewardsRoot[:]),
+		})
+	}
+}
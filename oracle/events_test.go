@@ -0,0 +1,169 @@
+package oracle
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/require"
+)
+
+func eventSlots(events []StreamEvent) []uint64 {
+	slots := make([]uint64, 0)
+	for _, event := range events {
+		slots = append(slots, event.Slot)
+	}
+	return slots
+}
+
+func Test_EventStream(t *testing.T) {
+	stream := NewEventStream()
+	for slot := uint64(1); slot <= 3; slot++ {
+		stream.Publish(StreamEvent{Type: SlotProcessedEvent, Slot: slot})
+	}
+
+	// Without token only the new events are received
+	backlog, subscription, err := stream.Subscribe("")
+	require.NoError(t, err)
+	require.Empty(t, backlog)
+	stream.Publish(StreamEvent{Type: SlotProcessedEvent, Slot: 4})
+	last := <-subscription.Events
+	require.Equal(t, uint64(4), last.Slot)
+	stream.Unsubscribe(subscription)
+	_, open := <-subscription.Events
+	require.False(t, open)
+
+	// Resuming gets the events after the token
+	first, _, err := stream.Subscribe("")
+	require.NoError(t, err)
+	require.Empty(t, first)
+	backlog, _, err = stream.Subscribe(stream.buffer[0].Id)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{2, 3, 4}, eventSlots(backlog))
+	backlog, _, err = stream.Subscribe(last.Id)
+	require.NoError(t, err)
+	require.Empty(t, backlog)
+
+	// Tokens of other runs, not published yet or invalid are rejected
+	_, _, err = stream.Subscribe("1-2")
+	require.Equal(t, ErrResumeExpired, err)
+	_, _, err = stream.Subscribe(last.Id[:len(last.Id)-1] + "9")
+	require.Equal(t, ErrResumeExpired, err)
+	_, _, err = stream.Subscribe("abc")
+	require.Error(t, err)
+	require.NotEqual(t, ErrResumeExpired, err)
+}
+
+func Test_EventStream_Expired(t *testing.T) {
+	defaultMax := MaxBufferedEvents
+	defer func() { MaxBufferedEvents = defaultMax }()
+	MaxBufferedEvents = 2
+
+	stream := NewEventStream()
+	for slot := uint64(1); slot <= 5; slot++ {
+		stream.Publish(StreamEvent{Type: SlotProcessedEvent, Slot: slot})
+	}
+	id := func(sequence uint64) string {
+		return fmt.Sprintf("%d-%d", stream.run, sequence)
+	}
+
+	// Only the last two events are kept, so resuming after the second loses the third
+	_, _, err := stream.Subscribe(id(2))
+	require.Equal(t, ErrResumeExpired, err)
+	backlog, _, err := stream.Subscribe(id(3))
+	require.NoError(t, err)
+	require.Equal(t, []uint64{4, 5}, eventSlots(backlog))
+}
+
+func Test_EventStream_SlowSubscriber(t *testing.T) {
+	defaultBuffer := SubscriberBuffer
+	defer func() { SubscriberBuffer = defaultBuffer }()
+	SubscriberBuffer = 2
+
+	stream := NewEventStream()
+	_, slow, err := stream.Subscribe("")
+	require.NoError(t, err)
+	for slot := uint64(1); slot <= 3; slot++ {
+		stream.Publish(StreamEvent{Type: SlotProcessedEvent, Slot: slot})
+	}
+
+	// Gets the events that fitted and then its dropped, without blocking the stream
+	received := make([]StreamEvent, 0)
+	for event := range slow.Events {
+		received = append(received, event)
+	}
+	require.Equal(t, []uint64{1, 2}, eventSlots(received))
+	require.Empty(t, stream.subscribers)
+
+	// And can resume from the last one it got
+	backlog, _, err := stream.Subscribe(received[1].Id)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{3}, eventSlots(backlog))
+}
+
+func Test_OracleEvents(t *testing.T) {
+	oracle := testOracle(Mainnet, 0)
+	address := "0x00000000000000000000000000000000000000A1"
+	oracle.state.Validators[1] = trackedValidator(1, Active, 0, address)
+	_, subscription, err := oracle.Events().Subscribe("")
+	require.NoError(t, err)
+
+	oracle.state.NextSlotToProcess = 1000
+	oracle.handleMissedBlock(SummarizedBlock{Slot: 1000, Block: 2000, ValidatorIndex: 1, WithdrawalAddress: address})
+	oracle.handleDonations([]*contract.ContractEtherReceived{historyDonation(2000, "0x00000000000000000000000000000000000000d1", 10)})
+
+	status := <-subscription.Events
+	require.Equal(t, ValidatorStatusEvent, status.Type)
+	require.Equal(t, uint64(1000), status.Slot)
+	require.Equal(t, uint64(1), *status.ValidatorIndex)
+	require.Equal(t, "0x00000000000000000000000000000000000000a1", status.WithdrawalAddress)
+	transition := StateTransition{}
+	require.NoError(t, json.Unmarshal(status.Data, &transition))
+	require.Equal(t, Active, transition.From)
+	require.Equal(t, YellowCard, transition.To)
+
+	donation := <-subscription.Events
+	require.Equal(t, DonationReceivedEvent, donation.Type)
+	require.Nil(t, donation.ValidatorIndex)
+	data := DonationData{}
+	require.NoError(t, json.Unmarshal(donation.Data, &data))
+	require.Equal(t, "10", data.AmountWei)
+	require.Equal(t, uint64(2000), data.Block)
+}
+
+func Test_OracleEvents_Reports(t *testing.T) {
+	oracle := testOracle(Mainnet, 0)
+	_, subscription, err := oracle.Events().Subscribe("")
+	require.NoError(t, err)
+
+	// Reports are published from the events of the processed block, even if this oracle submits none
+	block := missedFullBlock(1000)
+	root := [32]byte{0x12}
+	block.Events.SubmitReport = []*contract.ContractSubmitReport{
+		{SlotNumber: big.NewInt(900), NewRewardsRoot: root, OracleMember: common.HexToAddress("0xb1")}}
+	block.Events.ReportConsolidated = []*contract.ContractReportConsolidated{
+		{SlotNumber: big.NewInt(900), NewRewardsRoot: root}}
+	_, err = oracle.AdvanceStateToNextSlot(block)
+	require.NoError(t, err)
+
+	reports := make([]StreamEvent, 0)
+	for event := range subscription.Events {
+		if event.Type == SlotProcessedEvent {
+			break
+		}
+		reports = append(reports, event)
+	}
+	require.Equal(t, 2, len(reports))
+	require.Equal(t, ReportSubmittedEvent, reports[0].Type)
+	require.Equal(t, ReportConsolidatedEvent, reports[1].Type)
+	require.Equal(t, []uint64{900, 900}, eventSlots(reports))
+	submitted := CheckpointData{}
+	require.NoError(t, json.Unmarshal(reports[0].Data, &submitted))
+	require.Equal(t, hexutil.Encode(root[:]), submitted.MerkleRoot)
+	require.Equal(t, "0x00000000000000000000000000000000000000b1", strings.ToLower(submitted.OracleMember))
+}
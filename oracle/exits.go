@@ -0,0 +1,99 @@
+package oracle
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/dappnode/mev-sp-oracle/constants"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Reasons why a validator leaves the beacon chain, as seen in a block
+const (
+	ExitSlashing          = "slashing"
+	ExitVoluntary         = "voluntary_exit"
+	ExitWithdrawalRequest = "withdrawal_request"
+)
+
+// Unsubscribes the tracked validators that are slashed or start exiting in the block, sharing
+// their pending rewards among the pool in the same slot instead of waiting for the cleanup.
+// Slashings and voluntary exits are valid if included, but EIP-7002 withdrawal requests come
+// from the execution layer and can be rejected, so the exit is checked in the beacon chain.
+func (or *Oracle) handleSlashingsAndExits(fullBlock *FullBlock) error {
+	for _, valIndex := range fullBlock.GetSlashedIndices() {
+		err := or.handleExitingValidator(valIndex, ExitSlashing)
+		if err != nil {
+			return err
+		}
+	}
+	for _, valIndex := range fullBlock.GetVoluntaryExitIndices() {
+		err := or.handleExitingValidator(valIndex, ExitVoluntary)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, request := range fullBlock.GetFullExitRequests() {
+		valIndex, found := or.validatorIndexByKey(strings.ToLower(request.ValidatorPubkey.String()))
+		if !found || !or.hasRewardsAtStake(valIndex) {
+			continue
+		}
+		slot := or.state.NextSlotToProcess
+		validators, err := or.getSetOfValidators([]phase0.ValidatorIndex{phase0.ValidatorIndex(valIndex)},
+			strconv.FormatUint(slot, 10))
+		if err != nil {
+			return errors.Wrap(err, "could not get info of validator with withdrawal request")
+		}
+		validator, found := validators[phase0.ValidatorIndex(valIndex)]
+		if !found || validator.Validator == nil || uint64(validator.Validator.ExitEpoch) == constants.FarFutureEpoch {
+			log.WithFields(log.Fields{
+				"ValidatorIndex": valIndex,
+				"SourceAddress":  request.SourceAddress.String(),
+				"Slot":           slot,
+			}).Warn("[EXIT] Withdrawal request was not accepted by the beacon chain, ignoring")
+			continue
+		}
+		err = or.handleExitingValidator(valIndex, ExitWithdrawalRequest)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unsubscribes the validator and shares its pending rewards among the pool, if it has any
+func (or *Oracle) handleExitingValidator(valIndex uint64, reason string) error {
+	if !or.hasRewardsAtStake(valIndex) {
+		return nil
+	}
+	pending := new(big.Int).Set(or.state.Validators[valIndex].PendingRewardsWei)
+
+	log.WithFields(log.Fields{
+		"ValidatorIndex":    valIndex,
+		"ValidatorStatus":   or.state.Validators[valIndex].ValidatorStatus,
+		"Reason":            reason,
+		"PendingRewardsWei": pending,
+		"Slot":              or.state.NextSlotToProcess,
+	}).Info("[EXIT] Validator is leaving the beacon chain, unsubscribing it")
+
+	if or.isSubscribed(valIndex) {
+		or.advanceStateMachine(valIndex, Unsubscribe, TransitionCause{Reason: reason})
+	}
+	or.resetPendingRewards(valIndex)
+	if pending.Cmp(big.NewInt(0)) != 0 {
+		return or.increaseAllPendingRewards(pending)
+	}
+	return nil
+}
+
+// Returns true if the validator is subscribed or has pending rewards that may be lost
+func (or *Oracle) hasRewardsAtStake(valIndex uint64) bool {
+	validator, found := or.state.Validators[valIndex]
+	if !found {
+		return false
+	}
+	return or.isSubscribed(valIndex) || validator.PendingRewardsWei.Cmp(big.NewInt(0)) != 0
+}
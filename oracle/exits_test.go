@@ -0,0 +1,194 @@
+package oracle
+
+import (
+	"math/big"
+	"testing"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec"
+	"github.com/attestantio/go-eth2-client/spec/deneb"
+	"github.com/attestantio/go-eth2-client/spec/electra"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+// Helper to create a FullBlock with an electra beacon block body
+func fullBlockWithBody(slot uint64, body *electra.BeaconBlockBody) *FullBlock {
+	block := fullBlockNoEvents(slot)
+	block.ConsensusBlock = &spec.VersionedSignedBeaconBlock{
+		Version: spec.DataVersionElectra,
+		Electra: &electra.SignedBeaconBlock{Message: &electra.BeaconBlock{Body: body}},
+	}
+	return block
+}
+
+func Test_GetSlashedAndExitingValidators(t *testing.T) {
+	block := fullBlockWithBody(1000, &electra.BeaconBlockBody{
+		ProposerSlashings: []*phase0.ProposerSlashing{{
+			SignedHeader1: &phase0.SignedBeaconBlockHeader{Message: &phase0.BeaconBlockHeader{ProposerIndex: 1}},
+		}},
+		AttesterSlashings: []*electra.AttesterSlashing{{
+			Attestation1: &electra.IndexedAttestation{AttestingIndices: []uint64{2, 3, 4}},
+			Attestation2: &electra.IndexedAttestation{AttestingIndices: []uint64{3, 4, 5}},
+		}},
+		VoluntaryExits: []*phase0.SignedVoluntaryExit{{Message: &phase0.VoluntaryExit{ValidatorIndex: 6}}},
+		ExecutionRequests: &electra.ExecutionRequests{
+			Withdrawals: []*electra.WithdrawalRequest{
+				{ValidatorPubkey: phase0.BLSPubKey{7}, Amount: 0},
+				{ValidatorPubkey: phase0.BLSPubKey{8}, Amount: 1000},
+			},
+		},
+	})
+
+	require.Equal(t, []uint64{1, 3, 4}, block.GetSlashedIndices())
+	require.Equal(t, []uint64{6}, block.GetVoluntaryExitIndices())
+	require.Equal(t, 1, len(block.GetFullExitRequests()))
+	require.Equal(t, phase0.BLSPubKey{7}, block.GetFullExitRequests()[0].ValidatorPubkey)
+
+	// Missed blocks have nothing
+	require.Empty(t, fullBlockNoEvents(1000).GetSlashedIndices())
+	require.Empty(t, fullBlockNoEvents(1000).GetVoluntaryExitIndices())
+	require.Empty(t, fullBlockNoEvents(1000).GetFullExitRequests())
+}
+
+func Test_handleSlashingsAndExits(t *testing.T) {
+	cold := "0x00000000000000000000000000000000000000a1"
+
+	oracle := testOracle(Mainnet, 0)
+	for index := uint64(1); index <= 9; index++ {
+		oracle.state.Validators[index] = trackedValidator(index, Active, 100, cold)
+	}
+	// Not subscribed anymore but with pending rewards, eg the target of a consolidation
+	oracle.state.Validators[4].ValidatorStatus = NotSubscribed
+	oracle.state.NextSlotToProcess = 1000
+
+	// 6 withdrawal request is accepted by the beacon chain, 7 is not
+	mockValidators(oracle, map[phase0.ValidatorIndex]*v1.Validator{
+		6: {Index: 6, Validator: &phase0.Validator{ExitEpoch: 300}},
+		7: {Index: 7, Validator: &phase0.Validator{ExitEpoch: phase0.Epoch(0xffffffffffffffff)}},
+	})
+
+	block := fullBlockWithBody(1000, &electra.BeaconBlockBody{
+		ProposerSlashings: []*phase0.ProposerSlashing{{
+			SignedHeader1: &phase0.SignedBeaconBlockHeader{Message: &phase0.BeaconBlockHeader{ProposerIndex: 1}},
+		}},
+		AttesterSlashings: []*electra.AttesterSlashing{{
+			Attestation1: &electra.IndexedAttestation{AttestingIndices: []uint64{2, 4, 20}},
+			Attestation2: &electra.IndexedAttestation{AttestingIndices: []uint64{4, 20}},
+		}},
+		VoluntaryExits: []*phase0.SignedVoluntaryExit{{Message: &phase0.VoluntaryExit{ValidatorIndex: 5}}},
+		ExecutionRequests: &electra.ExecutionRequests{
+			Withdrawals: []*electra.WithdrawalRequest{
+				{ValidatorPubkey: phase0.BLSPubKey{6}},
+				{ValidatorPubkey: phase0.BLSPubKey{7}},
+				{ValidatorPubkey: phase0.BLSPubKey{8}, Amount: 1000},
+			},
+		},
+	})
+	require.NoError(t, oracle.handleSlashingsAndExits(block))
+
+	for _, index := range []uint64{1, 4, 5, 6} {
+		require.Equal(t, NotSubscribed, oracle.state.Validators[index].ValidatorStatus, "validator %d", index)
+		require.Equal(t, big.NewInt(0), oracle.state.Validators[index].PendingRewardsWei, "validator %d", index)
+	}
+
+	// The rest are still subscribed and get the pending rewards of the ones that left
+	totalPending := big.NewInt(0)
+	for _, index := range []uint64{2, 3, 7, 8, 9} {
+		require.Equal(t, Active, oracle.state.Validators[index].ValidatorStatus, "validator %d", index)
+		require.True(t, oracle.state.Validators[index].PendingRewardsWei.Cmp(big.NewInt(100)) > 0, "validator %d", index)
+		totalPending.Add(totalPending, oracle.state.Validators[index].PendingRewardsWei)
+	}
+	require.Equal(t, big.NewInt(900), new(big.Int).Add(totalPending, oracle.state.PoolAccumulatedFees))
+}
+
+// Helper to activate a fork in a network at a slot, restoring it when the test ends
+func activateFork(t *testing.T, fork map[string]uint64, network string, slot uint64) {
+	previous, found := fork[network]
+	fork[network] = slot
+	t.Cleanup(func() {
+		if found {
+			fork[network] = previous
+		} else {
+			delete(fork, network)
+		}
+	})
+}
+
+// Helper to create a block proposed by an untracked validator to another fee recipient
+func proposedBlockWithBody(slot uint64, body *electra.BeaconBlockBody) *FullBlock {
+	body.ExecutionPayload = &deneb.ExecutionPayload{BlockNumber: slot}
+	block := fullBlockWithBody(slot, body)
+	block.ConsensusDuty.ValidatorIndex = 99
+	block.ConsensusBlock.Electra.Message.ProposerIndex = 99
+	block.Validator = &v1.Validator{Index: 99, Validator: &phase0.Validator{WithdrawalCredentials: make([]byte, 32)}}
+	return block
+}
+
+// Block slashing validator 1
+func proposedBlockSlashing1(slot uint64) *FullBlock {
+	return proposedBlockWithBody(slot, &electra.BeaconBlockBody{
+		ProposerSlashings: []*phase0.ProposerSlashing{{
+			SignedHeader1: &phase0.SignedBeaconBlockHeader{Message: &phase0.BeaconBlockHeader{ProposerIndex: 1}},
+		}},
+	})
+}
+
+func Test_ExitsFork(t *testing.T) {
+	cold := "0x00000000000000000000000000000000000000a1"
+
+	exitsOracle := func() *Oracle {
+		oracle := testOracle(Mainnet, 0)
+		oracle.state.Validators[1] = trackedValidator(1, Active, 0, cold)
+		oracle.state.Validators[2] = trackedValidator(2, Active, 0, cold)
+		return oracle
+	}
+
+	// Before the fork the slashed validator is left to the cleanup
+	activateFork(t, SlotExitsFork, Mainnet, 1001)
+	oracle := exitsOracle()
+	_, err := oracle.AdvanceStateToNextSlot(proposedBlockSlashing1(1000))
+	require.NoError(t, err)
+	require.Equal(t, Active, oracle.state.Validators[1].ValidatorStatus)
+
+	// And from it, its unsubscribed in the same slot
+	activateFork(t, SlotExitsFork, Mainnet, 1000)
+	oracle = exitsOracle()
+	_, err = oracle.AdvanceStateToNextSlot(proposedBlockSlashing1(1000))
+	require.NoError(t, err)
+	require.Equal(t, NotSubscribed, oracle.state.Validators[1].ValidatorStatus)
+	require.Equal(t, Active, oracle.state.Validators[2].ValidatorStatus)
+
+	// Not scheduled in a network, its never active
+	require.False(t, isForkActive(SlotExitsFork, "unknown", 1000))
+}
+
+func Test_ExitsFork_Scheduled(t *testing.T) {
+	for _, network := range []string{Mainnet, Holesky, Hoodi} {
+		forkSlot := SlotExitsFork[network]
+		cfg := testConfig(network, 0)
+		cfg.DeployedSlot = forkSlot - 1
+		oracle := NewOracle(cfg)
+		oracle.state.Validators[1] = trackedValidator(1, Active, 0, "0x00000000000000000000000000000000000000a1")
+		oracle.state.Validators[2] = trackedValidator(2, Active, 0, "0x00000000000000000000000000000000000000a1")
+		// The scheduled slot also runs the cleanup, with both validators still in the beacon chain
+		mockPendingConsolidations(oracle, &[]PendingConsolidation{})
+		mockValidators(oracle, map[phase0.ValidatorIndex]*v1.Validator{
+			1: {Index: 1, Status: v1.ValidatorStateActiveOngoing, Validator: &phase0.Validator{}},
+			2: {Index: 2, Status: v1.ValidatorStateActiveOngoing, Validator: &phase0.Validator{}},
+		})
+
+		// Slashings before the scheduled slot are left to the cleanup
+		_, err := oracle.AdvanceStateToNextSlot(proposedBlockWithBody(forkSlot-1, &electra.BeaconBlockBody{
+			ProposerSlashings: []*phase0.ProposerSlashing{{
+				SignedHeader1: &phase0.SignedBeaconBlockHeader{Message: &phase0.BeaconBlockHeader{ProposerIndex: 2}},
+			}},
+		}))
+		require.NoError(t, err, network)
+		require.Equal(t, Active, oracle.state.Validators[2].ValidatorStatus, network)
+
+		_, err = oracle.AdvanceStateToNextSlot(proposedBlockSlashing1(forkSlot))
+		require.NoError(t, err, network)
+		require.Equal(t, NotSubscribed, oracle.state.Validators[1].ValidatorStatus, network)
+	}
+}
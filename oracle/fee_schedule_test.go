@@ -101,34 +101,45 @@ func Test_getFeeSchedule_UnknownNetwork(t *testing.T) {
 }
 
 // =============================================================================
-// applyFeeScheduleUpTo tests
+// backfillConfigHistory tests
 // =============================================================================
 
-func Test_applyFeeScheduleUpTo_BeforeAnyChange(t *testing.T) {
+// Returns the fee in effect at the given slot according to a backfilled history
+func backfilledFeeAt(cfg *Config, upToSlot uint64, slot uint64) int {
+	config, _ := configAtSlot(backfillConfigHistory(cfg, upToSlot), slot)
+	return config.PoolFeesPercentOver10000
+}
+
+func Test_backfillConfigHistory_BeforeAnyChange(t *testing.T) {
 	cfg := testConfig(Mainnet, 700)
-	applyFeeScheduleUpTo(cfg, 10000000) // before slot 14082460
-	require.Equal(t, 700, cfg.PoolFeesPercentOver10000, "fee should remain initial value")
+	history := backfillConfigHistory(cfg, 10000000) // before slot 14082460
+	require.Equal(t, 1, len(history))
+	require.Equal(t, 700, history[0].PoolFeesPercentOver10000, "fee should remain initial value")
+	require.Equal(t, cfg.DeployedSlot, history[0].Slot)
 }
 
-func Test_applyFeeScheduleUpTo_ExactlyAtChange(t *testing.T) {
+func Test_backfillConfigHistory_ExactlyAtChange(t *testing.T) {
 	cfg := testConfig(Mainnet, 700)
-	applyFeeScheduleUpTo(cfg, 14082460)
-	require.Equal(t, 500, cfg.PoolFeesPercentOver10000, "fee should be updated to 500")
+	history := backfillConfigHistory(cfg, 14082460)
+	require.Equal(t, 2, len(history))
+	require.Equal(t, uint64(14082460), history[1].Slot)
+	require.Equal(t, 500, history[1].PoolFeesPercentOver10000, "fee should be updated to 500")
 }
 
-func Test_applyFeeScheduleUpTo_AfterChange(t *testing.T) {
+func Test_backfillConfigHistory_AfterChange(t *testing.T) {
 	cfg := testConfig(Mainnet, 700)
-	applyFeeScheduleUpTo(cfg, 20000000)
-	require.Equal(t, 500, cfg.PoolFeesPercentOver10000, "fee should be updated to 500")
+	require.Equal(t, 700, backfilledFeeAt(cfg, 20000000, 14082459))
+	require.Equal(t, 500, backfilledFeeAt(cfg, 20000000, 20000000), "fee should be updated to 500")
 }
 
-func Test_applyFeeScheduleUpTo_UnknownNetwork(t *testing.T) {
+func Test_backfillConfigHistory_UnknownNetwork(t *testing.T) {
 	cfg := testConfig("goerli", 1000)
-	applyFeeScheduleUpTo(cfg, 99999999)
-	require.Equal(t, 1000, cfg.PoolFeesPercentOver10000, "fee should remain unchanged for unknown network")
+	history := backfillConfigHistory(cfg, 99999999)
+	require.Equal(t, 1, len(history))
+	require.Equal(t, 1000, history[0].PoolFeesPercentOver10000, "fee should remain unchanged for unknown network")
 }
 
-func Test_applyFeeScheduleUpTo_MultipleEntries_PicksLatest(t *testing.T) {
+func Test_backfillConfigHistory_MultipleEntries_PicksLatest(t *testing.T) {
 	// Temporarily add a second entry to test map ordering safety
 	origSchedule := feeSchedule[Mainnet]
 	feeSchedule[Mainnet] = map[uint64]int{
@@ -137,34 +148,26 @@ func Test_applyFeeScheduleUpTo_MultipleEntries_PicksLatest(t *testing.T) {
 	}
 	defer func() { feeSchedule[Mainnet] = origSchedule }()
 
-	// Slot before all changes
 	cfg := testConfig(Mainnet, 700)
-	applyFeeScheduleUpTo(cfg, 10000000)
-	require.Equal(t, 700, cfg.PoolFeesPercentOver10000)
+
+	// Slot before all changes
+	require.Equal(t, 700, backfilledFeeAt(cfg, 10000000, 10000000))
 
 	// Slot between changes
-	cfg = testConfig(Mainnet, 700)
-	applyFeeScheduleUpTo(cfg, 17000000)
-	require.Equal(t, 500, cfg.PoolFeesPercentOver10000)
+	require.Equal(t, 500, backfilledFeeAt(cfg, 17000000, 17000000))
 
 	// Slot after all changes — must pick highest qualifying (20000000 -> 300)
-	cfg = testConfig(Mainnet, 700)
-	applyFeeScheduleUpTo(cfg, 25000000)
-	require.Equal(t, 300, cfg.PoolFeesPercentOver10000)
+	require.Equal(t, 300, backfilledFeeAt(cfg, 25000000, 25000000))
 
 	// Exactly at second change
-	cfg = testConfig(Mainnet, 700)
-	applyFeeScheduleUpTo(cfg, 20000000)
-	require.Equal(t, 300, cfg.PoolFeesPercentOver10000)
+	require.Equal(t, 300, backfilledFeeAt(cfg, 20000000, 20000000))
 
 	// Exactly at first change (second doesn't apply yet)
-	cfg = testConfig(Mainnet, 700)
-	applyFeeScheduleUpTo(cfg, 14082460)
-	require.Equal(t, 500, cfg.PoolFeesPercentOver10000)
+	require.Equal(t, 500, backfilledFeeAt(cfg, 14082460, 14082460))
 }
 
 // Run the multi-entry test many times to catch Go map iteration randomness
-func Test_applyFeeScheduleUpTo_MultipleEntries_Deterministic(t *testing.T) {
+func Test_backfillConfigHistory_MultipleEntries_Deterministic(t *testing.T) {
 	origSchedule := feeSchedule[Mainnet]
 	feeSchedule[Mainnet] = map[uint64]int{
 		14082460: 500,
@@ -174,71 +177,91 @@ func Test_applyFeeScheduleUpTo_MultipleEntries_Deterministic(t *testing.T) {
 	defer func() { feeSchedule[Mainnet] = origSchedule }()
 
 	for i := 0; i < 100; i++ {
-		cfg := testConfig(Mainnet, 700)
-		applyFeeScheduleUpTo(cfg, 30000000)
-		require.Equal(t, 200, cfg.PoolFeesPercentOver10000,
+		history := backfillConfigHistory(testConfig(Mainnet, 700), 30000000)
+		require.Equal(t, 4, len(history))
+		require.Equal(t, []uint64{1000, 14082460, 20000000, 25000000},
+			[]uint64{history[0].Slot, history[1].Slot, history[2].Slot, history[3].Slot},
+			fmt.Sprintf("iteration %d: entries should be sorted by slot", i))
+		require.Equal(t, 200, history[3].PoolFeesPercentOver10000,
 			fmt.Sprintf("iteration %d: should pick highest slot (25000000 -> 200)", i))
 	}
 }
 
 // =============================================================================
-// validateFullBlockConfig tests — UpdatePoolFee event handling
+// validateFullBlockConfig tests — UpdatePoolFee event handling. Any fee change
+// is applied at its slot and recorded in the config history
 // =============================================================================
 
 func Test_validateFullBlockConfig_ExpectedFeeChange(t *testing.T) {
 	oracle := testOracle(Mainnet, 700)
 
 	block := fullBlockWithFeeEvent(14082460, 500)
-	err := oracle.validateFullBlockConfig(block, oracle.cfg)
+	err := oracle.validateFullBlockConfig(block)
 
 	require.NoError(t, err)
-	require.Equal(t, 500, oracle.cfg.PoolFeesPercentOver10000, "config should be updated")
+	require.Equal(t, 700, oracle.cfg.PoolFeesPercentOver10000, "config should be unchanged")
 	require.Equal(t, 500, oracle.state.PoolFeesPercentOver10000, "state should be updated")
 }
 
-func Test_validateFullBlockConfig_UnexpectedSlot(t *testing.T) {
+func Test_validateFullBlockConfig_UnscheduledSlot(t *testing.T) {
 	oracle := testOracle(Mainnet, 700)
 
 	// Fee event at slot not in the schedule
 	block := fullBlockWithFeeEvent(99999999, 500)
-	err := oracle.validateFullBlockConfig(block, oracle.cfg)
+	err := oracle.validateFullBlockConfig(block)
 
-	require.Error(t, err)
-	require.Contains(t, err.Error(), "unexpected pool fee change")
+	require.NoError(t, err)
 	require.Equal(t, 700, oracle.cfg.PoolFeesPercentOver10000, "config should be unchanged")
-	require.Equal(t, 700, oracle.state.PoolFeesPercentOver10000, "state should be unchanged")
+	require.Equal(t, 500, oracle.state.PoolFeesPercentOver10000, "state should be updated")
+	require.Equal(t, 2, len(oracle.state.ConfigHistory))
+	require.Equal(t, uint64(99999999), oracle.state.ConfigHistory[1].Slot)
+	require.Equal(t, 700, oracle.state.ConfigHistory[0].PoolFeesPercentOver10000)
 }
 
-func Test_validateFullBlockConfig_WrongFeeAtExpectedSlot(t *testing.T) {
+func Test_validateFullBlockConfig_OtherFeeAtScheduledSlot(t *testing.T) {
 	oracle := testOracle(Mainnet, 700)
 
-	// Right slot but wrong fee value
+	// Scheduled slot but a different fee value, the event prevails
 	block := fullBlockWithFeeEvent(14082460, 300)
-	err := oracle.validateFullBlockConfig(block, oracle.cfg)
+	err := oracle.validateFullBlockConfig(block)
+
+	require.NoError(t, err)
+	require.Equal(t, 700, oracle.cfg.PoolFeesPercentOver10000, "config should be unchanged")
+	require.Equal(t, 300, oracle.state.PoolFeesPercentOver10000)
+}
+
+func Test_validateFullBlockConfig_InvalidFee(t *testing.T) {
+	oracle := testOracle(Mainnet, 700)
+
+	block := fullBlockWithFeeEvent(14082460, 10001)
+	err := oracle.validateFullBlockConfig(block)
 
 	require.Error(t, err)
-	require.Contains(t, err.Error(), "unexpected pool fee change")
+	require.Contains(t, err.Error(), "invalid pool fee")
 	require.Equal(t, 700, oracle.cfg.PoolFeesPercentOver10000, "config should be unchanged")
+	require.Equal(t, 700, oracle.state.PoolFeesPercentOver10000, "state should be unchanged")
+	require.Equal(t, 1, len(oracle.state.ConfigHistory))
 }
 
 func Test_validateFullBlockConfig_NoFeeEvent(t *testing.T) {
 	oracle := testOracle(Mainnet, 700)
 
 	block := fullBlockNoEvents(14082460)
-	err := oracle.validateFullBlockConfig(block, oracle.cfg)
+	err := oracle.validateFullBlockConfig(block)
 
 	require.NoError(t, err)
-	require.Equal(t, 700, oracle.cfg.PoolFeesPercentOver10000, "config should be unchanged when no event")
+	require.Equal(t, 700, oracle.state.PoolFeesPercentOver10000, "state should be unchanged when no event")
+	require.Equal(t, 1, len(oracle.state.ConfigHistory), "no change should be recorded")
 }
 
 func Test_validateFullBlockConfig_HoodiExpectedFeeChange(t *testing.T) {
 	oracle := testOracle(Hoodi, 1000)
 
 	block := fullBlockWithFeeEvent(2801050, 500)
-	err := oracle.validateFullBlockConfig(block, oracle.cfg)
+	err := oracle.validateFullBlockConfig(block)
 
 	require.NoError(t, err)
-	require.Equal(t, 500, oracle.cfg.PoolFeesPercentOver10000)
+	require.Equal(t, 1000, oracle.cfg.PoolFeesPercentOver10000, "config should be unchanged")
 	require.Equal(t, 500, oracle.state.PoolFeesPercentOver10000)
 }
 
@@ -256,14 +279,17 @@ func Test_validateFullBlockConfig_MultipleFeeEventsInSameBlock(t *testing.T) {
 			},
 		},
 	}
-	err := oracle.validateFullBlockConfig(block, oracle.cfg)
+	err := oracle.validateFullBlockConfig(block)
 
-	require.Error(t, err)
-	require.Contains(t, err.Error(), "more than one event of the same type")
+	// The last one prevails, recorded as a single change
+	require.NoError(t, err)
+	require.Equal(t, 700, oracle.cfg.PoolFeesPercentOver10000, "config should be unchanged")
+	require.Equal(t, 400, oracle.state.PoolFeesPercentOver10000)
+	require.Equal(t, 2, len(oracle.state.ConfigHistory))
 }
 
 // =============================================================================
-// LoadFromBytes tests — state recovery with fee schedule reconciliation
+// LoadFromBytes tests — states without config history, backfilled from the fee schedule
 // =============================================================================
 
 func Test_LoadFromBytes_StateBefore_FeeChange(t *testing.T) {
@@ -301,7 +327,7 @@ func Test_LoadFromBytes_StateBefore_FeeChange(t *testing.T) {
 	found, err := oracle.LoadFromBytes(rawBytes)
 	require.NoError(t, err)
 	require.True(t, found)
-	require.Equal(t, 700, oracle.cfg.PoolFeesPercentOver10000)
+	require.Equal(t, 700, oracle.cfg.PoolFeesPercentOver10000, "config should be unchanged")
 	require.Equal(t, 700, oracle.state.PoolFeesPercentOver10000)
 }
 
@@ -340,8 +366,8 @@ func Test_LoadFromBytes_StateAfter_FeeChange(t *testing.T) {
 	found, err := oracle.LoadFromBytes(rawBytes)
 	require.NoError(t, err)
 	require.True(t, found)
-	// Config should have been reconciled to 500 via applyFeeScheduleUpTo
-	require.Equal(t, 500, oracle.cfg.PoolFeesPercentOver10000)
+	// The state fee was reconciled to 500 via the backfilled history, the config is kept
+	require.Equal(t, 700, oracle.cfg.PoolFeesPercentOver10000, "config should be unchanged")
 	require.Equal(t, 500, oracle.state.PoolFeesPercentOver10000)
 }
 
@@ -378,7 +404,7 @@ func Test_LoadFromBytes_StateExactlyAt_FeeChange(t *testing.T) {
 	found, err := oracle.LoadFromBytes(rawBytes)
 	require.NoError(t, err)
 	require.True(t, found)
-	require.Equal(t, 500, oracle.cfg.PoolFeesPercentOver10000)
+	require.Equal(t, 500, oracle.state.PoolFeesPercentOver10000)
 }
 
 func Test_LoadFromBytes_StateWithWrongFee_Rejected(t *testing.T) {
@@ -457,7 +483,7 @@ func Test_LoadFromBytes_MultipleScheduleEntries(t *testing.T) {
 	found, err := oracle.LoadFromBytes(rawBytes)
 	require.NoError(t, err)
 	require.True(t, found)
-	require.Equal(t, 500, oracle.cfg.PoolFeesPercentOver10000)
+	require.Equal(t, 500, oracle.state.PoolFeesPercentOver10000)
 
 	// State saved after both changes: fee=300
 	oracle2 := testOracle(Mainnet, 700)
@@ -490,7 +516,7 @@ func Test_LoadFromBytes_MultipleScheduleEntries(t *testing.T) {
 	found2, err := oracle2.LoadFromBytes(rawBytes2)
 	require.NoError(t, err)
 	require.True(t, found2)
-	require.Equal(t, 300, oracle2.cfg.PoolFeesPercentOver10000)
+	require.Equal(t, 300, oracle2.state.PoolFeesPercentOver10000)
 }
 
 // Run the multi-entry LoadFromBytes test many times to catch map ordering issues
@@ -534,8 +560,8 @@ func Test_LoadFromBytes_MultipleEntries_Deterministic(t *testing.T) {
 		found, err := oracle.LoadFromBytes(rawBytes)
 		require.NoError(t, err, "iteration %d", i)
 		require.True(t, found, "iteration %d", i)
-		require.Equal(t, 200, oracle.cfg.PoolFeesPercentOver10000,
-			"iteration %d: config fee should be 200 (latest schedule entry)", i)
+		require.Equal(t, 200, oracle.state.PoolFeesPercentOver10000,
+			"iteration %d: state fee should be 200 (latest schedule entry)", i)
 	}
 }
 
@@ -544,79 +570,62 @@ func Test_LoadFromBytes_MultipleEntries_Deterministic(t *testing.T) {
 // =============================================================================
 
 func Test_SequentialFeeChanges(t *testing.T) {
-	origSchedule := feeSchedule[Mainnet]
-	feeSchedule[Mainnet] = map[uint64]int{
-		100: 500,
-		200: 300,
-	}
-	defer func() { feeSchedule[Mainnet] = origSchedule }()
-
 	oracle := testOracle(Mainnet, 700)
 
 	// Slot before first change — no event
-	block := fullBlockNoEvents(50)
-	err := oracle.validateFullBlockConfig(block, oracle.cfg)
+	block := fullBlockNoEvents(1050)
+	err := oracle.validateFullBlockConfig(block)
 	require.NoError(t, err)
-	require.Equal(t, 700, oracle.cfg.PoolFeesPercentOver10000)
+	require.Equal(t, 700, oracle.state.PoolFeesPercentOver10000)
 
 	// Slot at first change — event 700->500
-	block = fullBlockWithFeeEvent(100, 500)
-	err = oracle.validateFullBlockConfig(block, oracle.cfg)
+	block = fullBlockWithFeeEvent(1100, 500)
+	err = oracle.validateFullBlockConfig(block)
 	require.NoError(t, err)
-	require.Equal(t, 500, oracle.cfg.PoolFeesPercentOver10000)
+	require.Equal(t, 700, oracle.cfg.PoolFeesPercentOver10000, "config should be unchanged")
 	require.Equal(t, 500, oracle.state.PoolFeesPercentOver10000)
 
 	// Slot between changes — no event
-	block = fullBlockNoEvents(150)
-	err = oracle.validateFullBlockConfig(block, oracle.cfg)
+	block = fullBlockNoEvents(1150)
+	err = oracle.validateFullBlockConfig(block)
 	require.NoError(t, err)
-	require.Equal(t, 500, oracle.cfg.PoolFeesPercentOver10000)
+	require.Equal(t, 500, oracle.state.PoolFeesPercentOver10000)
 
 	// Slot at second change — event 500->300
-	block = fullBlockWithFeeEvent(200, 300)
-	err = oracle.validateFullBlockConfig(block, oracle.cfg)
+	block = fullBlockWithFeeEvent(1200, 300)
+	err = oracle.validateFullBlockConfig(block)
 	require.NoError(t, err)
-	require.Equal(t, 300, oracle.cfg.PoolFeesPercentOver10000)
+	require.Equal(t, 700, oracle.cfg.PoolFeesPercentOver10000, "config should be unchanged")
 	require.Equal(t, 300, oracle.state.PoolFeesPercentOver10000)
 
 	// Slot after all changes — no event
-	block = fullBlockNoEvents(300)
-	err = oracle.validateFullBlockConfig(block, oracle.cfg)
+	block = fullBlockNoEvents(1300)
+	err = oracle.validateFullBlockConfig(block)
 	require.NoError(t, err)
-	require.Equal(t, 300, oracle.cfg.PoolFeesPercentOver10000)
-}
+	require.Equal(t, 300, oracle.state.PoolFeesPercentOver10000)
 
-func Test_UnexpectedFeeChange_AtScheduledSlot_WrongFee(t *testing.T) {
-	origSchedule := feeSchedule[Mainnet]
-	feeSchedule[Mainnet] = map[uint64]int{
-		100: 500,
+	// The fee in effect at each slot is kept
+	history := oracle.state.ConfigHistory
+	require.Equal(t, 3, len(history))
+	for slot, fee := range map[uint64]int{1000: 700, 1099: 700, 1100: 500, 1199: 500, 1200: 300, 5000: 300} {
+		config, found := configAtSlot(history, slot)
+		require.True(t, found)
+		require.Equal(t, fee, config.PoolFeesPercentOver10000, "slot %d", slot)
 	}
-	defer func() { feeSchedule[Mainnet] = origSchedule }()
-
-	oracle := testOracle(Mainnet, 700)
-
-	// Event at the right slot but with wrong fee
-	block := fullBlockWithFeeEvent(100, 999)
-	err := oracle.validateFullBlockConfig(block, oracle.cfg)
-	require.Error(t, err)
-	require.Contains(t, err.Error(), "unexpected pool fee change")
-	require.Equal(t, 700, oracle.cfg.PoolFeesPercentOver10000, "config unchanged on error")
 }
 
-func Test_UnexpectedFeeChange_AtUnscheduledSlot(t *testing.T) {
-	origSchedule := feeSchedule[Mainnet]
-	feeSchedule[Mainnet] = map[uint64]int{
-		100: 500,
-	}
-	defer func() { feeSchedule[Mainnet] = origSchedule }()
-
+func Test_FeeChange_NotAfterLatestChange_Rejected(t *testing.T) {
 	oracle := testOracle(Mainnet, 700)
 
-	// Event at an unscheduled slot
-	block := fullBlockWithFeeEvent(999, 500)
-	err := oracle.validateFullBlockConfig(block, oracle.cfg)
+	block := fullBlockWithFeeEvent(1200, 500)
+	require.NoError(t, oracle.validateFullBlockConfig(block))
+
+	// Same slot or before the latest change
+	block = fullBlockWithFeeEvent(1200, 300)
+	err := oracle.validateFullBlockConfig(block)
 	require.Error(t, err)
-	require.Contains(t, err.Error(), "unexpected pool fee change")
+	require.Contains(t, err.Error(), "is not after the latest one")
+	require.Equal(t, 500, oracle.state.PoolFeesPercentOver10000, "state unchanged on error")
 }
 
 // =============================================================================
@@ -627,16 +636,16 @@ func Test_NoSchedule_NoEvent_OK(t *testing.T) {
 	oracle := testOracle(Goerli, 1000)
 
 	block := fullBlockNoEvents(5000)
-	err := oracle.validateFullBlockConfig(block, oracle.cfg)
+	err := oracle.validateFullBlockConfig(block)
 	require.NoError(t, err)
-	require.Equal(t, 1000, oracle.cfg.PoolFeesPercentOver10000)
+	require.Equal(t, 1000, oracle.state.PoolFeesPercentOver10000)
 }
 
-func Test_NoSchedule_WithEvent_Rejected(t *testing.T) {
+func Test_NoSchedule_WithEvent_Applied(t *testing.T) {
 	oracle := testOracle(Goerli, 1000)
 
 	block := fullBlockWithFeeEvent(5000, 500)
-	err := oracle.validateFullBlockConfig(block, oracle.cfg)
-	require.Error(t, err)
-	require.Contains(t, err.Error(), "unexpected pool fee change")
+	err := oracle.validateFullBlockConfig(block)
+	require.NoError(t, err)
+	require.Equal(t, 500, oracle.state.PoolFeesPercentOver10000)
 }
@@ -0,0 +1,360 @@
+package oracle
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/pkg/errors"
+)
+
+// Lists of blocks stored in the state, each one sorted by slot
+type BlockList uint8
+
+const (
+	ProposedBlockList BlockList = 0
+	MissedBlockList   BlockList = 1
+	WrongFeeBlockList BlockList = 2
+)
+
+var AllBlockLists = []BlockList{ProposedBlockList, MissedBlockList, WrongFeeBlockList}
+
+// Filters of QueryBlocks. Nil or empty fields dont filter, slots are inclusive
+type BlockFilter struct {
+	FromSlot          uint64
+	ToSlot            *uint64
+	ValidatorIndex    *uint64
+	WithdrawalAddress string
+	BlockType         *BlockType
+	RewardType        *RewardType
+	MinRewardWei      *big.Int
+}
+
+// Filters of QueryDonations. Nil or empty fields dont filter, blocks are inclusive
+type DonationFilter struct {
+	FromBlock    uint64
+	ToBlock      *uint64
+	Sender       string
+	MinAmountWei *big.Int
+}
+
+// Positions of the entries of a list by validator index and by address, for the first
+// indexed entries. Lists only grow, so the index is extended as they do, and snapshots
+// taken before only use the positions they have
+type positionIndex struct {
+	indexed     int
+	byValidator map[uint64][]int
+	byAddress   map[string][]int
+}
+
+// Indexes of the blocks and donations of the state, to serve the api queries without
+// going through the whole history. Not stored in the state, built the first time its queried
+type historyIndex struct {
+	blocks    map[BlockList]*positionIndex
+	donations *positionIndex
+}
+
+func newPositionIndex() *positionIndex {
+	return &positionIndex{
+		byValidator: make(map[uint64][]int),
+		byAddress:   make(map[string][]int),
+	}
+}
+
+// Position of a block in the order of the queries: by slot, then list and position in the list
+type blockKey struct {
+	slot     uint64
+	list     BlockList
+	position int
+}
+
+func (k blockKey) less(other blockKey) bool {
+	if k.slot != other.slot {
+		return k.slot < other.slot
+	}
+	if k.list != other.list {
+		return k.list < other.list
+	}
+	return k.position < other.position
+}
+
+func (k blockKey) cursor() string {
+	return fmt.Sprintf("%d-%d-%d", k.slot, k.list, k.position)
+}
+
+func parseBlockCursor(cursor string) (*blockKey, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	parts := strings.Split(cursor, "-")
+	if len(parts) != 3 {
+		return nil, errors.New("invalid cursor: " + cursor)
+	}
+	slot, errSlot := strconv.ParseUint(parts[0], 10, 64)
+	list, errList := strconv.ParseUint(parts[1], 10, 8)
+	position, errPosition := strconv.Atoi(parts[2])
+	if errSlot != nil || errList != nil || errPosition != nil || position < 0 {
+		return nil, errors.New("invalid cursor: " + cursor)
+	}
+	return &blockKey{slot: slot, list: BlockList(list), position: position}, nil
+}
+
+// Returns up to limit blocks of the given lists of the state matching the filter, sorted by
+// slot and starting after the cursor, if any. The returned cursor is empty if there are no more.
+// The state is the oracle's or one of its snapshots.
+func (or *Oracle) QueryBlocks(state *OracleState, lists []BlockList, filter BlockFilter, cursor string, limit int) ([]SummarizedBlock, string, error) {
+	if limit <= 0 {
+		return nil, "", errors.New(fmt.Sprintf("invalid limit: %d", limit))
+	}
+	after, err := parseBlockCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	or.historyMutex.Lock()
+	defer or.historyMutex.Unlock()
+	index := or.historyIndexLockFree(state)
+
+	withdrawalAddress := strings.ToLower(filter.WithdrawalAddress)
+	keys := make([]blockKey, 0)
+	for _, list := range lists {
+		blocks := blockList(state, list)
+		positions := newCandidates(index.blocks[list], len(blocks), filter.ValidatorIndex, withdrawalAddress)
+
+		// Blocks are sorted by slot, so the first candidate can be searched
+		start := sort.Search(positions.len(), func(i int) bool {
+			key := blockKey{slot: blocks[positions.at(i)].Slot, list: list, position: positions.at(i)}
+			return key.slot >= filter.FromSlot && (after == nil || after.less(key))
+		})
+
+		// One more than the limit, to know if there are more
+		found := 0
+		for i := start; i < positions.len(); i++ {
+			position := positions.at(i)
+			block := blocks[position]
+			if filter.ToSlot != nil && block.Slot > *filter.ToSlot {
+				break
+			}
+			if !blockMatches(block, filter, withdrawalAddress) {
+				continue
+			}
+			keys = append(keys, blockKey{slot: block.Slot, list: list, position: position})
+			found++
+			if found > limit {
+				break
+			}
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+	nextCursor := ""
+	if len(keys) > limit {
+		keys = keys[:limit]
+		nextCursor = keys[limit-1].cursor()
+	}
+	blocks := make([]SummarizedBlock, 0, len(keys))
+	for _, key := range keys {
+		blocks = append(blocks, blockList(state, key.list)[key.position])
+	}
+	return blocks, nextCursor, nil
+}
+
+// Returns up to limit donations of the state matching the filter, sorted by block and starting
+// after the cursor, if any. The returned cursor is empty if there are no more.
+func (or *Oracle) QueryDonations(state *OracleState, filter DonationFilter, cursor string, limit int) ([]*contract.ContractEtherReceived, string, error) {
+	if limit <= 0 {
+		return nil, "", errors.New(fmt.Sprintf("invalid limit: %d", limit))
+	}
+	after := -1
+	if cursor != "" {
+		position, err := strconv.Atoi(cursor)
+		if err != nil || position < 0 {
+			return nil, "", errors.New("invalid cursor: " + cursor)
+		}
+		after = position
+	}
+
+	or.historyMutex.Lock()
+	defer or.historyMutex.Unlock()
+	index := or.historyIndexLockFree(state)
+
+	donations := state.Donations
+	positions := newCandidates(index.donations, len(donations), nil, strings.ToLower(filter.Sender))
+
+	// Donations are sorted by block, so the first candidate can be searched
+	start := sort.Search(positions.len(), func(i int) bool {
+		return donations[positions.at(i)].Raw.BlockNumber >= filter.FromBlock && positions.at(i) > after
+	})
+
+	result := make([]*contract.ContractEtherReceived, 0)
+	lastPosition := -1
+	for i := start; i < positions.len(); i++ {
+		position := positions.at(i)
+		donation := donations[position]
+		if filter.ToBlock != nil && donation.Raw.BlockNumber > *filter.ToBlock {
+			break
+		}
+		if filter.MinAmountWei != nil && donation.DonationAmount.Cmp(filter.MinAmountWei) < 0 {
+			continue
+		}
+		// There are more
+		if len(result) == limit {
+			return result, strconv.Itoa(lastPosition), nil
+		}
+		result = append(result, donation)
+		lastPosition = position
+	}
+	return result, "", nil
+}
+
+// Positions of a list to go through: the ones of the validator or address if filtering
+// by them, otherwise all of them
+type candidates struct {
+	positions []int
+	all       bool
+	length    int
+}
+
+// The index can have more positions than the list, if it was extended by a later snapshot
+func newCandidates(index *positionIndex, length int, validatorIndex *uint64, address string) candidates {
+	if validatorIndex != nil {
+		return candidates{positions: upTo(index.byValidator[*validatorIndex], length)}
+	}
+	if address != "" {
+		return candidates{positions: upTo(index.byAddress[address], length)}
+	}
+	return candidates{all: true, length: length}
+}
+
+// Positions are sorted, so the ones in the list are a prefix
+func upTo(positions []int, length int) []int {
+	return positions[:sort.SearchInts(positions, length)]
+}
+
+func (c candidates) len() int {
+	if c.all {
+		return c.length
+	}
+	return len(c.positions)
+}
+
+func (c candidates) at(i int) int {
+	if c.all {
+		return i
+	}
+	return c.positions[i]
+}
+
+func blockMatches(block SummarizedBlock, filter BlockFilter, withdrawalAddress string) bool {
+	if filter.ValidatorIndex != nil && block.ValidatorIndex != *filter.ValidatorIndex {
+		return false
+	}
+	if withdrawalAddress != "" && strings.ToLower(block.WithdrawalAddress) != withdrawalAddress {
+		return false
+	}
+	if filter.BlockType != nil && block.BlockType != *filter.BlockType {
+		return false
+	}
+	if filter.RewardType != nil && block.RewardType != *filter.RewardType {
+		return false
+	}
+	if filter.MinRewardWei != nil && (block.Reward == nil || block.Reward.Cmp(filter.MinRewardWei) < 0) {
+		return false
+	}
+	return true
+}
+
+func blockList(state *OracleState, list BlockList) []SummarizedBlock {
+	switch list {
+	case MissedBlockList:
+		return state.MissedBlocks
+	case WrongFeeBlockList:
+		return state.WrongFeeBlocks
+	default:
+		return state.ProposedBlocks
+	}
+}
+
+// Returns the index of the history, indexing the entries of the state added since the last
+// query. Its reset when the state is loaded. Must be called with the history lock held
+func (or *Oracle) historyIndexLockFree(state *OracleState) *historyIndex {
+	if or.history == nil {
+		or.history = &historyIndex{
+			blocks:    make(map[BlockList]*positionIndex),
+			donations: newPositionIndex(),
+		}
+	}
+	for _, list := range AllBlockLists {
+		blocks := blockList(state, list)
+		index, found := or.history.blocks[list]
+		if !found {
+			index = newPositionIndex()
+			or.history.blocks[list] = index
+		}
+		for ; index.indexed < len(blocks); index.indexed++ {
+			block := blocks[index.indexed]
+			address := strings.ToLower(block.WithdrawalAddress)
+			index.byValidator[block.ValidatorIndex] = append(index.byValidator[block.ValidatorIndex], index.indexed)
+			index.byAddress[address] = append(index.byAddress[address], index.indexed)
+		}
+	}
+
+	donations := state.Donations
+	index := or.history.donations
+	for ; index.indexed < len(donations); index.indexed++ {
+		sender := strings.ToLower(donations[index.indexed].Sender.String())
+		index.byAddress[sender] = append(index.byAddress[sender], index.indexed)
+	}
+	return or.history
+}
+
+// Rewards of a validator frozen at a checkpoint
+type CheckpointRewards struct {
+	Slot                  uint64
+	AccumulatedRewardsWei *big.Int
+	PendingRewardsWei     *big.Int
+}
+
+// Status changes of a validator, its blocks and its rewards at each checkpoint, oldest first
+type ValidatorHistory struct {
+	Transitions []StateTransition
+	Blocks      []SummarizedBlock
+	Rewards     []CheckpointRewards
+}
+
+// Returns the history of the validator, false if the oracle knows nothing about it
+func (or *Oracle) ValidatorHistory(valIndex uint64) (*ValidatorHistory, bool) {
+	state := or.Snapshot()
+	blocks, _, err := or.QueryBlocks(state, AllBlockLists, BlockFilter{ValidatorIndex: &valIndex}, "", math.MaxInt)
+	if err != nil {
+		return nil, false
+	}
+
+	history := &ValidatorHistory{
+		Transitions: append([]StateTransition{}, state.ValidatorTransitions[valIndex]...),
+		Blocks:      blocks,
+		Rewards:     make([]CheckpointRewards, 0),
+	}
+	for slot, commited := range state.CommitedStates {
+		validator, found := commited.Validators[valIndex]
+		if !found || validator == nil {
+			continue
+		}
+		history.Rewards = append(history.Rewards, CheckpointRewards{
+			Slot:                  slot,
+			AccumulatedRewardsWei: copyBigInt(validator.AccumulatedRewardsWei),
+			PendingRewardsWei:     copyBigInt(validator.PendingRewardsWei),
+		})
+	}
+	sort.Slice(history.Rewards, func(i, j int) bool { return history.Rewards[i].Slot < history.Rewards[j].Slot })
+
+	_, tracked := state.Validators[valIndex]
+	if !tracked && len(history.Transitions) == 0 && len(history.Blocks) == 0 {
+		return nil, false
+	}
+	return history, true
+}
@@ -0,0 +1,226 @@
+package oracle
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+func historyBlock(slot uint64, valIndex uint64, blockType BlockType, rewardType RewardType, reward int64, address string) SummarizedBlock {
+	return SummarizedBlock{
+		Slot:              slot,
+		ValidatorIndex:    valIndex,
+		BlockType:         blockType,
+		RewardType:        rewardType,
+		Reward:            big.NewInt(reward),
+		WithdrawalAddress: address,
+	}
+}
+
+func historyDonation(block uint64, sender string, amount int64) *contract.ContractEtherReceived {
+	return &contract.ContractEtherReceived{
+		Sender:         common.HexToAddress(sender),
+		DonationAmount: big.NewInt(amount),
+		Raw:            types.Log{BlockNumber: block},
+	}
+}
+
+func historyTestOracle() *Oracle {
+	addressA := "0x00000000000000000000000000000000000000a1"
+	addressB := "0x00000000000000000000000000000000000000B2"
+	oracle := testOracle(Mainnet, 0)
+	oracle.state.ProposedBlocks = []SummarizedBlock{
+		historyBlock(10, 1, OkPoolProposal, VanilaBlock, 100, addressA),
+		historyBlock(30, 2, OkPoolProposal, MevBlock, 300, addressB),
+		historyBlock(50, 1, OkPoolProposal, MevBlock, 500, addressA),
+	}
+	oracle.state.MissedBlocks = []SummarizedBlock{
+		historyBlock(20, 2, MissedProposal, UnknownRewardType, 0, addressB),
+		historyBlock(40, 1, MissedProposal, UnknownRewardType, 0, addressA),
+	}
+	oracle.state.WrongFeeBlocks = []SummarizedBlock{
+		historyBlock(60, 3, WrongFeeRecipient, VanilaBlock, 600, addressB),
+	}
+	oracle.state.Donations = []*contract.ContractEtherReceived{
+		historyDonation(100, "0x00000000000000000000000000000000000000d1", 10),
+		historyDonation(100, "0x00000000000000000000000000000000000000d2", 20),
+		historyDonation(200, "0x00000000000000000000000000000000000000d1", 30),
+	}
+	return oracle
+}
+
+func blockSlots(blocks []SummarizedBlock) []uint64 {
+	slots := make([]uint64, 0)
+	for _, block := range blocks {
+		slots = append(slots, block.Slot)
+	}
+	return slots
+}
+
+func Test_QueryBlocks(t *testing.T) {
+	oracle := historyTestOracle()
+	validator1 := uint64(1)
+	toSlot := uint64(50)
+	mev := MevBlock
+	missed := MissedProposal
+
+	tests := []struct {
+		name   string
+		lists  []BlockList
+		filter BlockFilter
+		slots  []uint64
+	}{
+		{"All blocks sorted by slot", AllBlockLists, BlockFilter{}, []uint64{10, 20, 30, 40, 50, 60}},
+		{"One list", []BlockList{MissedBlockList}, BlockFilter{}, []uint64{20, 40}},
+		{"Slot range", AllBlockLists, BlockFilter{FromSlot: 20, ToSlot: &toSlot}, []uint64{20, 30, 40, 50}},
+		{"Validator", AllBlockLists, BlockFilter{ValidatorIndex: &validator1}, []uint64{10, 40, 50}},
+		{"Withdrawal address in any case", AllBlockLists, BlockFilter{WithdrawalAddress: "0x00000000000000000000000000000000000000b2"}, []uint64{20, 30, 60}},
+		{"Unknown address", AllBlockLists, BlockFilter{WithdrawalAddress: "0x00000000000000000000000000000000000000c3"}, []uint64{}},
+		{"Block type", AllBlockLists, BlockFilter{BlockType: &missed}, []uint64{20, 40}},
+		{"Reward type", AllBlockLists, BlockFilter{RewardType: &mev}, []uint64{30, 50}},
+		{"Min reward", AllBlockLists, BlockFilter{MinRewardWei: big.NewInt(300)}, []uint64{30, 50, 60}},
+		{"Combined", AllBlockLists, BlockFilter{ValidatorIndex: &validator1, MinRewardWei: big.NewInt(1), ToSlot: &toSlot}, []uint64{10, 50}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			blocks, cursor, err := oracle.QueryBlocks(oracle.state, test.lists, test.filter, "", 100)
+			require.NoError(t, err)
+			require.Equal(t, test.slots, blockSlots(blocks))
+			require.Equal(t, "", cursor)
+		})
+	}
+
+	// Paging through all the blocks
+	slots := make([]uint64, 0)
+	cursor := ""
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 3)
+		var blocks []SummarizedBlock
+		var err error
+		blocks, cursor, err = oracle.QueryBlocks(oracle.state, AllBlockLists, BlockFilter{}, cursor, 2)
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(blocks), 2)
+		slots = append(slots, blockSlots(blocks)...)
+		if cursor == "" {
+			break
+		}
+	}
+	require.Equal(t, []uint64{10, 20, 30, 40, 50, 60}, slots)
+
+	// New blocks are indexed as they are added
+	snapshot := snapshotState(oracle.state)
+	oracle.state.MissedBlocks = append(oracle.state.MissedBlocks,
+		historyBlock(70, 1, MissedProposal, UnknownRewardType, 0, "0x00000000000000000000000000000000000000a1"))
+	blocks, _, err := oracle.QueryBlocks(oracle.state, AllBlockLists, BlockFilter{ValidatorIndex: &validator1, FromSlot: 45}, "", 100)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{50, 70}, blockSlots(blocks))
+
+	// Snapshots taken before only see their blocks, even if the index has more
+	blocks, _, err = oracle.QueryBlocks(snapshot, AllBlockLists, BlockFilter{ValidatorIndex: &validator1, FromSlot: 45}, "", 100)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{50}, blockSlots(blocks))
+
+	_, _, err = oracle.QueryBlocks(oracle.state, AllBlockLists, BlockFilter{}, "wrong", 10)
+	require.Error(t, err)
+	_, _, err = oracle.QueryBlocks(oracle.state, AllBlockLists, BlockFilter{}, "", 0)
+	require.Error(t, err)
+}
+
+func Test_QueryDonations(t *testing.T) {
+	oracle := historyTestOracle()
+
+	donations, cursor, err := oracle.QueryDonations(oracle.state, DonationFilter{}, "", 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(donations))
+	require.Equal(t, "1", cursor)
+	donations, cursor, err = oracle.QueryDonations(oracle.state, DonationFilter{}, cursor, 2)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(donations))
+	require.Equal(t, big.NewInt(30), donations[0].DonationAmount)
+	require.Equal(t, "", cursor)
+
+	donations, _, err = oracle.QueryDonations(oracle.state, DonationFilter{Sender: "0x00000000000000000000000000000000000000D1"}, "", 10)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(donations))
+
+	toBlock := uint64(100)
+	donations, _, err = oracle.QueryDonations(oracle.state, DonationFilter{ToBlock: &toBlock, MinAmountWei: big.NewInt(20)}, "", 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(donations))
+	require.Equal(t, big.NewInt(20), donations[0].DonationAmount)
+
+	donations, _, err = oracle.QueryDonations(oracle.state, DonationFilter{FromBlock: 150}, "", 10)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(donations))
+
+	_, _, err = oracle.QueryDonations(oracle.state, DonationFilter{}, "-1", 10)
+	require.Error(t, err)
+}
+
+func Test_ValidatorHistory(t *testing.T) {
+	oracle := testOracle(Mainnet, 0)
+	address := "0x00000000000000000000000000000000000000a1"
+	oracle.state.Validators[1] = trackedValidator(1, Active, 0, address)
+	oracle.state.CommitedStates[900] = &OnchainState{
+		Slot:       900,
+		Validators: map[uint64]*ValidatorInfo{1: trackedValidator(1, Active, 40, address)},
+	}
+
+	// Two missed blocks red card the validator
+	oracle.state.NextSlotToProcess = 1000
+	oracle.handleMissedBlock(SummarizedBlock{Slot: 1000, Block: 2000, ValidatorIndex: 1, WithdrawalAddress: address})
+	oracle.state.NextSlotToProcess = 1100
+	oracle.handleMissedBlock(SummarizedBlock{Slot: 1100, Block: 2100, ValidatorIndex: 1, WithdrawalAddress: address})
+	oracle.state.NextSlotToProcess = 1101
+
+	// Transitions are stored in the state
+	reloaded, err := reloadOracle(t, oracle)
+	require.NoError(t, err)
+
+	history, found := reloaded.ValidatorHistory(1)
+	require.True(t, found)
+	require.Equal(t, []StateTransition{
+		{Slot: 1000, Event: ProposalMissed, From: Active, To: YellowCard, TransitionCause: TransitionCause{Block: 2000}},
+		{Slot: 1100, Event: ProposalMissed, From: YellowCard, To: RedCard, TransitionCause: TransitionCause{Block: 2100}},
+	}, history.Transitions)
+	require.Equal(t, []uint64{1000, 1100}, blockSlots(history.Blocks))
+	require.Equal(t, 1, len(history.Rewards))
+	require.Equal(t, uint64(900), history.Rewards[0].Slot)
+	require.Equal(t, big.NewInt(40), history.Rewards[0].PendingRewardsWei)
+
+	_, found = reloaded.ValidatorHistory(2)
+	require.False(t, found)
+}
+
+func Test_ValidatorHistory_SameStatus(t *testing.T) {
+	oracle := testOracle(Mainnet, 0)
+	oracle.state.Validators[1] = trackedValidator(1, Active, 0, "0x00000000000000000000000000000000000000a1")
+
+	// Proposals of active validators dont change the status, so are not stored
+	oracle.state.NextSlotToProcess = 1000
+	oracle.advanceStateMachine(1, ProposalOk, TransitionCause{Block: 2000})
+	require.Empty(t, oracle.state.ValidatorTransitions[1])
+
+	oracle.state.NextSlotToProcess = 1100
+	oracle.advanceStateMachine(1, ProposalMissed, TransitionCause{Block: 2100})
+	oracle.state.NextSlotToProcess = 1200
+	oracle.advanceStateMachine(1, ProposalOk, TransitionCause{Block: 2200})
+	oracle.state.NextSlotToProcess = 1300
+	oracle.advanceStateMachine(1, ProposalOk, TransitionCause{Block: 2300})
+	require.Equal(t, []StateTransition{
+		{Slot: 1100, Event: ProposalMissed, From: Active, To: YellowCard, TransitionCause: TransitionCause{Block: 2100}},
+		{Slot: 1200, Event: ProposalOk, From: YellowCard, To: Active, TransitionCause: TransitionCause{Block: 2200}},
+	}, oracle.state.ValidatorTransitions[1])
+
+	// Bans are always stored
+	oracle.state.Validators[1].ValidatorStatus = Banned
+	oracle.state.NextSlotToProcess = 1400
+	oracle.setValidatorStatus(1, ManualBan, Banned, TransitionCause{})
+	require.Equal(t, 3, len(oracle.state.ValidatorTransitions[1]))
+	require.Equal(t, StateTransition{Slot: 1400, Event: ManualBan, From: Banned, To: Banned},
+		oracle.state.ValidatorTransitions[1][2])
+}
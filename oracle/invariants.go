@@ -0,0 +1,222 @@
+package oracle
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Running totals of what the pool received (assets) and what it owes (liabilities)
+// up to Slot. After every processed slot both sides must match.
+// - assets: ether received by the contract (donations + mev rewards), subscription collateral
+// and vanilla rewards of pool proposals
+// - liabilities: pending and accumulated rewards of all validators + pool fees
+type AccountingTotals struct {
+	Slot                  uint64   `json:"slot"`
+	EtherReceivedWei      *big.Int `json:"ether_received_wei"`
+	CollateralWei         *big.Int `json:"collateral_wei"`
+	VanillaRewardsWei     *big.Int `json:"vanilla_rewards_wei"`
+	PendingRewardsWei     *big.Int `json:"pending_rewards_wei"`
+	AccumulatedRewardsWei *big.Int `json:"accumulated_rewards_wei"`
+	PoolFeesWei           *big.Int `json:"pool_fees_wei"`
+}
+
+// Inputs and outputs of the slot that broke the accounting invariant. Stored to
+// disk before halting so that the offending slot can be debugged.
+type InvariantViolation struct {
+	Slot            uint64                            `json:"slot"`
+	Error           string                            `json:"error"`
+	SummarizedBlock SummarizedBlock                   `json:"summarized_block"`
+	Events          *Events                           `json:"events"`
+	Donations       []*contract.ContractEtherReceived `json:"donations"`
+	Before          *AccountingTotals                 `json:"before"`
+	After           *AccountingTotals                 `json:"after"`
+	AssetsWei       *big.Int                          `json:"assets_wei"`
+	LiabilitiesWei  *big.Int                          `json:"liabilities_wei"`
+	DeltaWei        *big.Int                          `json:"delta_wei"`
+}
+
+func (t *AccountingTotals) Assets() *big.Int {
+	assets := new(big.Int).Add(t.EtherReceivedWei, t.CollateralWei)
+	return assets.Add(assets, t.VanillaRewardsWei)
+}
+
+func (t *AccountingTotals) Liabilities() *big.Int {
+	liabilities := new(big.Int).Add(t.PendingRewardsWei, t.AccumulatedRewardsWei)
+	return liabilities.Add(liabilities, t.PoolFeesWei)
+}
+
+// Returns an error if assets and liabilities dont match
+func (t *AccountingTotals) Check() error {
+	assets := t.Assets()
+	liabilities := t.Liabilities()
+	if liabilities.Cmp(assets) != 0 {
+		return errors.New(fmt.Sprintf("liabilities and assets dont match at slot %d: %d vs %d, delta: %d",
+			t.Slot, liabilities, assets, new(big.Int).Sub(assets, liabilities)))
+	}
+	return nil
+}
+
+func (t *AccountingTotals) copy() *AccountingTotals {
+	return &AccountingTotals{
+		Slot:                  t.Slot,
+		EtherReceivedWei:      new(big.Int).Set(t.EtherReceivedWei),
+		CollateralWei:         new(big.Int).Set(t.CollateralWei),
+		VanillaRewardsWei:     new(big.Int).Set(t.VanillaRewardsWei),
+		PendingRewardsWei:     new(big.Int).Set(t.PendingRewardsWei),
+		AccumulatedRewardsWei: new(big.Int).Set(t.AccumulatedRewardsWei),
+		PoolFeesWei:           new(big.Int).Set(t.PoolFeesWei),
+	}
+}
+
+// Calculates the totals of the whole state, iterating all events and blocks
+func computeAccountingTotals(state *OracleState) *AccountingTotals {
+	totals := &AccountingTotals{
+		Slot:              state.LatestProcessedSlot,
+		EtherReceivedWei:  big.NewInt(0),
+		CollateralWei:     big.NewInt(0),
+		VanillaRewardsWei: big.NewInt(0),
+	}
+	addAssets(totals, state, 0, 0, 0)
+	setLiabilities(totals, state)
+	return totals
+}
+
+// Adds the assets of the state stored from the given positions onwards
+func addAssets(totals *AccountingTotals, state *OracleState, fromEtherRx int, fromSubs int, fromBlocks int) {
+	for _, etherRx := range state.EtherReceivedEvents[fromEtherRx:] {
+		totals.EtherReceivedWei.Add(totals.EtherReceivedWei, etherRx.DonationAmount)
+	}
+	for _, subs := range state.SubscriptionEvents[fromSubs:] {
+		totals.CollateralWei.Add(totals.CollateralWei, subs.SubscriptionCollateral)
+	}
+	for _, block := range state.ProposedBlocks[fromBlocks:] {
+		if block.RewardType == VanilaBlock {
+			totals.VanillaRewardsWei.Add(totals.VanillaRewardsWei, block.Reward)
+		}
+	}
+}
+
+// Calculates the liabilities from all the validators of the state. Only done when the totals
+// are calculated from the state, afterwards they are kept as running totals. See trackLiabilities
+func setLiabilities(totals *AccountingTotals, state *OracleState) {
+	totals.PendingRewardsWei = big.NewInt(0)
+	totals.AccumulatedRewardsWei = big.NewInt(0)
+	for _, val := range state.Validators {
+		totals.PendingRewardsWei.Add(totals.PendingRewardsWei, val.PendingRewardsWei)
+		totals.AccumulatedRewardsWei.Add(totals.AccumulatedRewardsWei, val.AccumulatedRewardsWei)
+	}
+	totals.PoolFeesWei = new(big.Int).Set(state.PoolAccumulatedFees)
+}
+
+// Returns the running totals, calculating them from the state if they were not yet
+func (or *Oracle) accountingTotalsLockFree() *AccountingTotals {
+	if or.accounting == nil {
+		or.accounting = computeAccountingTotals(or.state)
+	}
+	return or.accounting
+}
+
+// Moves the running liabilities by the rewards given to or taken from validators (pending and
+// accumulated) and the pool. Nil amounts are skipped. Nothing is tracked until the totals are
+// calculated, since they are then calculated from the state.
+func (or *Oracle) trackLiabilities(pendingWei *big.Int, accumulatedWei *big.Int, poolFeesWei *big.Int) {
+	if or.accounting == nil {
+		return
+	}
+	if pendingWei != nil {
+		or.accounting.PendingRewardsWei.Add(or.accounting.PendingRewardsWei, pendingWei)
+	}
+	if accumulatedWei != nil {
+		or.accounting.AccumulatedRewardsWei.Add(or.accounting.AccumulatedRewardsWei, accumulatedWei)
+	}
+	if poolFeesWei != nil {
+		or.accounting.PoolFeesWei.Add(or.accounting.PoolFeesWei, poolFeesWei)
+	}
+}
+
+// Updates the running totals with the events and blocks the slot appended to the state,
+// its liabilities being already tracked, and checks that assets and liabilities still match. If not, the inputs and outputs of
+// the slot are dumped to disk and an error is returned. Forks dont dump them, since their
+// blocks can be reorged and its not a violation of the finalized state.
+func (or *Oracle) checkSlotInvariant(
+	before *AccountingTotals,
+	fromEtherRx int,
+	fromSubs int,
+	fromBlocks int,
+	fullBlock *FullBlock,
+	summarizedBlock SummarizedBlock,
+	donations []*contract.ContractEtherReceived) error {
+
+	after := or.accountingTotalsLockFree()
+	after.Slot = or.state.LatestProcessedSlot
+	addAssets(after, or.state, fromEtherRx, fromSubs, fromBlocks)
+
+	err := after.Check()
+	if err == nil {
+		return nil
+	}
+
+	violation := &InvariantViolation{
+		Slot:            after.Slot,
+		Error:           err.Error(),
+		SummarizedBlock: summarizedBlock,
+		Events:          fullBlock.Events,
+		Donations:       donations,
+		Before:          before,
+		After:           after,
+		AssetsWei:       after.Assets(),
+		LiabilitiesWei:  after.Liabilities(),
+		DeltaWei:        new(big.Int).Sub(after.Assets(), after.Liabilities()),
+	}
+	if or.isFork {
+		log.WithFields(log.Fields{
+			"Slot":           violation.Slot,
+			"AssetsWei":      violation.AssetsWei,
+			"LiabilitiesWei": violation.LiabilitiesWei,
+			"DeltaWei":       violation.DeltaWei,
+		}).Warn("Accounting invariant violated in a slot not finalized yet")
+		return errors.Wrap(err, "accounting invariant violated in a slot not finalized yet")
+	}
+	path, dumpErr := dumpInvariantViolation(violation)
+	if dumpErr != nil {
+		log.Error("Could not dump accounting invariant violation: ", dumpErr)
+	}
+
+	log.WithFields(log.Fields{
+		"Slot":           violation.Slot,
+		"AssetsWei":      violation.AssetsWei,
+		"LiabilitiesWei": violation.LiabilitiesWei,
+		"DeltaWei":       violation.DeltaWei,
+		"BlockType":      summarizedBlock.BlockType,
+		"ValIndex":       summarizedBlock.ValidatorIndex,
+		"Path":           path,
+	}).Error("Accounting invariant violated")
+
+	return errors.Wrap(err, fmt.Sprintf("accounting invariant violated, diagnostic dump: %s", path))
+}
+
+// Stores the violation as json in the state folder, returning its path
+func dumpInvariantViolation(violation *InvariantViolation) (string, error) {
+	jsonData, err := json.MarshalIndent(violation, "", " ")
+	if err != nil {
+		return "", errors.Wrap(err, "could not marshal invariant violation")
+	}
+	err = os.MkdirAll(StateFolder, os.ModePerm)
+	if err != nil {
+		return "", errors.Wrap(err, "could not create folder")
+	}
+	path := filepath.Join(StateFolder, fmt.Sprintf("invariant_violation_%d.json", violation.Slot))
+	err = ioutil.WriteFile(path, jsonData, 0644)
+	if err != nil {
+		return "", errors.Wrap(err, "could not write file")
+	}
+	return path, nil
+}
@@ -0,0 +1,144 @@
+package oracle
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+// Helper to create a FullBlock of a missed proposal at a given slot
+func missedFullBlock(slot uint64) *FullBlock {
+	block := fullBlockNoEvents(slot)
+	block.Validator = &v1.Validator{
+		Validator: &phase0.Validator{WithdrawalCredentials: make([]byte, 32)},
+	}
+	return block
+}
+
+func Test_AccountingTotals_Check(t *testing.T) {
+	totals := &AccountingTotals{
+		Slot:                  1000,
+		EtherReceivedWei:      big.NewInt(100),
+		CollateralWei:         big.NewInt(20),
+		VanillaRewardsWei:     big.NewInt(3),
+		PendingRewardsWei:     big.NewInt(60),
+		AccumulatedRewardsWei: big.NewInt(40),
+		PoolFeesWei:           big.NewInt(23),
+	}
+	require.Equal(t, big.NewInt(123), totals.Assets())
+	require.Equal(t, big.NewInt(123), totals.Liabilities())
+	require.NoError(t, totals.Check())
+
+	totals.PoolFeesWei = big.NewInt(24)
+	err := totals.Check()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "delta: -1")
+}
+
+func Test_AdvanceStateToNextSlot_InvariantViolation(t *testing.T) {
+	origFolder := StateFolder
+	StateFolder = t.TempDir()
+	defer func() { StateFolder = origFolder }()
+
+	oracle := testOracle(Mainnet, 700)
+	oracle.state.ProposedBlocks = []SummarizedBlock{{RewardType: VanilaBlock, Reward: big.NewInt(1000)}}
+	oracle.state.PoolAccumulatedFees = big.NewInt(1000)
+
+	_, err := oracle.AdvanceStateToNextSlot(missedFullBlock(1000))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1000), oracle.accounting.Slot)
+	require.Equal(t, big.NewInt(1000), oracle.accounting.Assets())
+
+	// Some bug credits the pool with rewards it never received
+	oracle.sendRewardToPool(big.NewInt(5))
+
+	_, err = oracle.AdvanceStateToNextSlot(missedFullBlock(1001))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "accounting invariant violated")
+
+	// Inputs and outputs of the offending slot are dumped
+	rawDump, err := ioutil.ReadFile(filepath.Join(StateFolder, "invariant_violation_1001.json"))
+	require.NoError(t, err)
+	var violation InvariantViolation
+	require.NoError(t, json.Unmarshal(rawDump, &violation))
+	require.Equal(t, uint64(1001), violation.Slot)
+	require.Equal(t, uint64(1001), violation.SummarizedBlock.Slot)
+	require.Equal(t, big.NewInt(-5), violation.DeltaWei)
+	require.Equal(t, big.NewInt(1000), violation.Before.Assets())
+	require.Equal(t, big.NewInt(1005), violation.Before.PoolFeesWei)
+	require.Equal(t, big.NewInt(1005), violation.After.PoolFeesWei)
+}
+
+func Test_LoadFromBytes_InvariantViolation(t *testing.T) {
+	oracle := testOracle(Mainnet, 700)
+	oracle.state.ProposedBlocks = []SummarizedBlock{{RewardType: VanilaBlock, Reward: big.NewInt(1000)}}
+	oracle.state.PoolAccumulatedFees = big.NewInt(1000)
+	oracle.state.NextSlotToProcess = 1100
+
+	reloaded, err := reloadOracle(t, oracle)
+	require.NoError(t, err)
+	require.Equal(t, big.NewInt(1000), reloaded.accounting.Assets())
+	require.NoError(t, reloaded.RunOffchainReconciliation())
+
+	oracle.state.PoolAccumulatedFees = big.NewInt(999)
+	_, err = reloadOracle(t, oracle)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "accounting invariant violated in loaded state")
+}
+
+func Test_RunOffchainReconciliation_RunningLiabilities(t *testing.T) {
+	oracle := testOracle(Mainnet, 700)
+	oracle.state.ProposedBlocks = []SummarizedBlock{{RewardType: VanilaBlock, Reward: big.NewInt(1000)}}
+	oracle.state.Validators[1] = trackedValidator(1, Active, 0, "0x00000000000000000000000000000000000000a1")
+	oracle.state.PoolAccumulatedFees = big.NewInt(1000)
+
+	// Rewards moved between validators and the pool are tracked
+	_, err := oracle.AdvanceStateToNextSlot(missedFullBlock(1000))
+	require.NoError(t, err)
+	oracle.mutex.Lock()
+	oracle.state.PoolAccumulatedFees.Sub(oracle.state.PoolAccumulatedFees, big.NewInt(400))
+	oracle.trackLiabilities(nil, nil, big.NewInt(-400))
+	require.NoError(t, oracle.increaseAllPendingRewards(big.NewInt(400)))
+	oracle.consolidateBalance(1)
+	oracle.mutex.Unlock()
+	_, err = oracle.AdvanceStateToNextSlot(missedFullBlock(1001))
+	require.NoError(t, err)
+	require.Zero(t, oracle.accounting.PendingRewardsWei.Sign())
+	require.Equal(t, big.NewInt(372), oracle.accounting.AccumulatedRewardsWei)
+	require.Equal(t, big.NewInt(628), oracle.accounting.PoolFeesWei)
+	require.NoError(t, oracle.RunOffchainReconciliation())
+
+	// Modifying the rewards without tracking them is caught when recalculating them
+	oracle.state.Validators[1].AccumulatedRewardsWei.Add(oracle.state.Validators[1].AccumulatedRewardsWei, big.NewInt(5))
+	oracle.state.PoolAccumulatedFees.Sub(oracle.state.PoolAccumulatedFees, big.NewInt(5))
+	require.NoError(t, oracle.RunOffchainReconciliation())
+	oracle.state.PoolAccumulatedFees.Sub(oracle.state.PoolAccumulatedFees, big.NewInt(5))
+	err = oracle.RunOffchainReconciliation()
+	require.Error(t, err)
+}
+
+func Test_AdvanceStateToNextSlot_InvariantViolationInFork(t *testing.T) {
+	origFolder := StateFolder
+	StateFolder = t.TempDir()
+	defer func() { StateFolder = origFolder }()
+
+	oracle := testOracle(Mainnet, 700)
+	_, err := oracle.AdvanceStateToNextSlot(missedFullBlock(1000))
+	require.NoError(t, err)
+
+	// Forks process blocks that can be reorged, so their violations are not dumped
+	fork := oracle.Fork()
+	fork.sendRewardToPool(big.NewInt(5))
+	_, err = fork.AdvanceStateToNextSlot(missedFullBlock(1001))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "accounting invariant violated")
+	dumps, err := ioutil.ReadDir(StateFolder)
+	require.NoError(t, err)
+	require.Empty(t, dumps)
+}
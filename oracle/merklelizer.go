@@ -6,7 +6,6 @@ import (
 	"sort"
 	"strings"
 
-	"github.com/dappnode/mev-sp-oracle/utils"
 	"github.com/ethereum/go-ethereum/common"
 	log "github.com/sirupsen/logrus"
 
@@ -47,30 +46,28 @@ func (merklelizer *Merklelizer) AggregateValidatorsIndexes(state *OracleState) [
 	// for all the validators belonging to the same withdrawal address
 	allLeafs := make([]RawLeaf, 0)
 
+	// Index of the leaf of each withdrawal address (lowercase) in allLeafs
+	leafIndexes := make(map[string]int)
+
 	// Iterate all validators
 	for _, validator := range state.Validators {
-
-		// If the withdrawal addresd was found before
-		found := false
+		// In lowercase to avoid confusion when claiming
+		withdrawalAddress := strings.ToLower(validator.WithdrawalAddress)
 
 		// If the leaf already exists, add the balance to the existing leaf (by withdrawal address)
-		for _, leaf := range allLeafs {
-			if utils.Equals(leaf.WithdrawalAddress, validator.WithdrawalAddress) {
-				leaf.AccumulatedBalanceWei.Add(leaf.AccumulatedBalanceWei, validator.AccumulatedRewardsWei)
-				found = true
-				continue
-			}
+		if leafIndex, found := leafIndexes[withdrawalAddress]; found {
+			leaf := allLeafs[leafIndex]
+			leaf.AccumulatedBalanceWei.Add(leaf.AccumulatedBalanceWei, validator.AccumulatedRewardsWei)
+			continue
 		}
 
 		// If the leaf does not exist, create a new one, initing the balance to the current validator balance
-		if !found {
-			allLeafs = append(allLeafs, RawLeaf{
-				// In lowercase to avoid confusion when claiming
-				WithdrawalAddress: strings.ToLower(validator.WithdrawalAddress),
-				// Copy the value
-				AccumulatedBalanceWei: new(big.Int).Set(validator.AccumulatedRewardsWei),
-			})
-		}
+		leafIndexes[withdrawalAddress] = len(allLeafs)
+		allLeafs = append(allLeafs, RawLeaf{
+			WithdrawalAddress: withdrawalAddress,
+			// Copy the value
+			AccumulatedBalanceWei: new(big.Int).Set(validator.AccumulatedRewardsWei),
+		})
 	}
 
 	// Run a sanity check to make sure the after the transformations we are distributing
@@ -128,6 +125,14 @@ func (merklelizer *Merklelizer) AggregateValidatorsIndexes(state *OracleState) [
 	return orderedByWithdrawalAddress
 }
 
+// Hash of a leaf, as the contract computes it: keccak256(abi.encodePacked(address, uint256))
+func LeafHash(withdrawalAddress string, accumulatedBalanceWei *big.Int) []byte {
+	return solsha3.SoliditySHA3(
+		solsha3.Address(withdrawalAddress),
+		solsha3.Uint256(accumulatedBalanceWei),
+	)
+}
+
 // Sort by withdrawal address
 func (merklelizer *Merklelizer) OrderByWithdrawalAddress(leafs []RawLeaf) []RawLeaf {
 	sortedLeafs := make([]RawLeaf, len(leafs))
@@ -141,10 +146,13 @@ func (merklelizer *Merklelizer) OrderByWithdrawalAddress(leafs []RawLeaf) []RawL
 // Returns a map of withdrawal address to the hashed leaf and raw leaf. It also
 // returns the merkle tree and false if there was not enough information to create a tree
 func (merklelizer *Merklelizer) GenerateTreeFromState(state *OracleState) (map[string]mt.DataBlock, map[string]RawLeaf, *mt.MerkleTree, bool) {
+	return merklelizer.GenerateTreeFromLeafs(merklelizer.AggregateValidatorsIndexes(state))
+}
 
-	blocks := make([]mt.DataBlock, 0)
+// Same as GenerateTreeFromState but from the already aggregated and ordered leafs
+func (merklelizer *Merklelizer) GenerateTreeFromLeafs(orderedRawLeafs []RawLeaf) (map[string]mt.DataBlock, map[string]RawLeaf, *mt.MerkleTree, bool) {
 
-	orderedRawLeafs := merklelizer.AggregateValidatorsIndexes(state)
+	blocks := make([]mt.DataBlock, 0)
 
 	log.WithFields(log.Fields{
 		"Leafs": len(orderedRawLeafs),
@@ -157,10 +165,7 @@ func (merklelizer *Merklelizer) GenerateTreeFromState(state *OracleState) (map[s
 	withdrawalToRawLeaf := make(map[string]RawLeaf, 0)
 
 	for _, leaf := range orderedRawLeafs {
-		leafHash := solsha3.SoliditySHA3(
-			solsha3.Address(leaf.WithdrawalAddress),
-			solsha3.Uint256(leaf.AccumulatedBalanceWei),
-		)
+		leafHash := LeafHash(leaf.WithdrawalAddress, leaf.AccumulatedBalanceWei)
 		blocks = append(blocks, &testData{data: leafHash})
 		withdrawalToLeaf[leaf.WithdrawalAddress] = &testData{data: leafHash}
 		withdrawalToRawLeaf[leaf.WithdrawalAddress] = leaf
@@ -169,7 +174,7 @@ func (merklelizer *Merklelizer) GenerateTreeFromState(state *OracleState) (map[s
 			"WithdrawalAddress":     leaf.WithdrawalAddress,
 			"AccumulatedBalanceWei": leaf.AccumulatedBalanceWei,
 			"LeafHash":              hex.EncodeToString(leafHash),
-		}).Info("Leaf information")
+		}).Debug("Leaf information")
 	}
 
 	if len(blocks) < 2 {
@@ -435,3 +435,15 @@ func Test_OrderByWithdrawalAddress(t *testing.T) {
 	ordered := merklelizer.OrderByWithdrawalAddress(leafs)
 	require.Equal(t, expected, ordered)
 }
+
+func BenchmarkAggregateValidatorsIndexes(b *testing.B) {
+	merklelizer := NewMerklelizer()
+	for _, size := range benchmarkSizes {
+		oracle := benchmarkOracle(size.Validators, size.Addresses)
+		b.Run(fmt.Sprintf("validators=%d/addresses=%d", size.Validators, size.Addresses), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				require.Equal(b, size.Addresses+1, len(merklelizer.AggregateValidatorsIndexes(oracle.state)))
+			}
+		})
+	}
+}
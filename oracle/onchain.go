@@ -269,22 +269,31 @@ func (o *Onchain) GetFinalizedValidators(opts ...retry.Option) (map[phase0.Valid
 }
 
 func (o *Onchain) FinalizedBeaconBlockHeader(opts ...retry.Option) (*v1.BeaconBlockHeader, error) {
+	return o.beaconBlockHeader("finalized", opts...)
+}
+
+// Header of the latest block of the chain, which may not be finalized yet
+func (o *Onchain) HeadBeaconBlockHeader(opts ...retry.Option) (*v1.BeaconBlockHeader, error) {
+	return o.beaconBlockHeader("head", opts...)
+}
+
+func (o *Onchain) beaconBlockHeader(blockId string, opts ...retry.Option) (*v1.BeaconBlockHeader, error) {
 	var beaconBlockHeader *api.Response[*v1.BeaconBlockHeader]
 	var err error
 
 	err = retry.Do(func() error {
 		beaconBlockHeader, err = o.ConsensusClient.BeaconBlockHeader(context.Background(), &eth2.BeaconBlockHeaderOpts{
-			Block: "finalized",
+			Block: blockId,
 		})
 		if err != nil {
-			log.Warn("Failed attempt to fetch finalized beacon block header: ", err.Error(), " Retrying...")
-			return errors.New("Error fetching finalized  beacon block header: " + err.Error())
+			log.Warn("Failed attempt to fetch ", blockId, " beacon block header: ", err.Error(), " Retrying...")
+			return errors.New("Error fetching " + blockId + " beacon block header: " + err.Error())
 		}
 		return nil
 	}, o.GetRetryOpts(opts)...)
 
 	if err != nil {
-		return nil, errors.New("Could not fetch finalized beacon block header: " + err.Error())
+		return nil, errors.New("Could not fetch " + blockId + " beacon block header: " + err.Error())
 	}
 	return beaconBlockHeader.Data, err
 }
@@ -397,23 +406,32 @@ func (o *Onchain) BlockByNumber(blockNumber *big.Int, opts ...retry.Option) (*ty
 	return block, err
 }
 
-// decodeInitialPoolFee extracts the _poolFee parameter from the initialize() transaction
-// in the deployment block. This works on full nodes since transaction data is stored in blocks.
+// Parameters the pool contract was initialized with, before any governance change
+type initialParams struct {
+	PoolFeesPercentOver10000 int
+	PoolFeesAddress          string
+	CheckPointSizeInSlots    uint64
+	CollateralInWei          *big.Int
+}
+
+// decodeInitialParams extracts the initialize() parameters (_subscriptionCollateral, _poolFee,
+// _poolFeeRecipient and _checkpointSlotSize) from the transaction in the deployment block.
+// This works on full nodes since transaction data is stored in blocks.
 //
 // It handles two deployment patterns:
 //  1. Direct call: initialize() sent as a separate tx TO the pool address.
 //  2. Proxy constructor: a TransparentUpgradeableProxy is deployed in a single
 //     contract-creation tx; the initialize() calldata is embedded in the
 //     constructor arguments and executed via delegatecall during deployment.
-func decodeInitialPoolFee(block *types.Block, poolAddress string) (int, error) {
+func decodeInitialParams(block *types.Block, poolAddress string) (*initialParams, error) {
 	contractABI, err := contract.ContractMetaData.GetAbi()
 	if err != nil {
-		return 0, errors.Wrap(err, "could not parse contract ABI")
+		return nil, errors.Wrap(err, "could not parse contract ABI")
 	}
 
 	initMethod, ok := contractABI.Methods["initialize"]
 	if !ok {
-		return 0, errors.New("initialize method not found in contract ABI")
+		return nil, errors.New("initialize method not found in contract ABI")
 	}
 	selector := initMethod.ID // 4-byte selector
 
@@ -431,52 +449,70 @@ func decodeInitialPoolFee(block *types.Block, poolAddress string) (int, error) {
 			if err != nil || method.Name != "initialize" {
 				continue
 			}
-			return decodePoolFeeFromInitArgs(initMethod, data[4:])
+			return decodeInitArgs(initMethod, data[4:])
 		}
 
 		// Case 2: contract-creation tx (proxy deployment). The initialize()
 		// calldata is passed as a constructor argument and appears embedded
 		// in the tx data. Scan for the 4-byte selector.
 		if tx.To() == nil {
-			if fee, err := findInitializeInCreationData(initMethod, selector, data); err == nil {
-				return fee, nil
+			if params, err := findInitializeInCreationData(initMethod, selector, data); err == nil {
+				return params, nil
 			}
 		}
 	}
 
-	return 0, errors.New("initialize() transaction not found in deployment block")
+	return nil, errors.New("initialize() transaction not found in deployment block")
 }
 
-// decodePoolFeeFromInitArgs ABI-decodes the initialize() arguments and returns _poolFee.
-func decodePoolFeeFromInitArgs(method abi.Method, argData []byte) (int, error) {
+// decodeInitArgs ABI-decodes the initialize() arguments
+func decodeInitArgs(method abi.Method, argData []byte) (*initialParams, error) {
 	args, err := method.Inputs.Unpack(argData)
 	if err != nil {
-		return 0, errors.Wrap(err, "could not decode initialize() arguments")
+		return nil, errors.Wrap(err, "could not decode initialize() arguments")
 	}
-	// initialize(address _governance, uint256 _subscriptionCollateral, uint256 _poolFee, ...)
-	if len(args) < 3 {
-		return 0, errors.New("initialize() has fewer arguments than expected")
+	// initialize(address _governance, uint256 _subscriptionCollateral, uint256 _poolFee,
+	// address _poolFeeRecipient, uint64 _checkpointSlotSize, uint64 _quorum)
+	if len(args) < 5 {
+		return nil, errors.New("initialize() has fewer arguments than expected")
+	}
+	collateral, ok := args[1].(*big.Int)
+	if !ok {
+		return nil, errors.New("could not cast _subscriptionCollateral argument to *big.Int")
 	}
 	poolFee, ok := args[2].(*big.Int)
 	if !ok {
-		return 0, errors.New("could not cast _poolFee argument to *big.Int")
+		return nil, errors.New("could not cast _poolFee argument to *big.Int")
 	}
-	return int(poolFee.Int64()), nil
+	poolFeeRecipient, ok := args[3].(common.Address)
+	if !ok {
+		return nil, errors.New("could not cast _poolFeeRecipient argument to address")
+	}
+	checkpointSlotSize, ok := args[4].(uint64)
+	if !ok {
+		return nil, errors.New("could not cast _checkpointSlotSize argument to uint64")
+	}
+	return &initialParams{
+		PoolFeesPercentOver10000: int(poolFee.Int64()),
+		PoolFeesAddress:          poolFeeRecipient.Hex(),
+		CheckPointSizeInSlots:    checkpointSlotSize,
+		CollateralInWei:          collateral,
+	}, nil
 }
 
 // findInitializeInCreationData scans a contract-creation tx's data for an
 // embedded initialize() call (as seen in proxy constructor arguments).
-func findInitializeInCreationData(method abi.Method, selector []byte, data []byte) (int, error) {
+func findInitializeInCreationData(method abi.Method, selector []byte, data []byte) (*initialParams, error) {
 	for i := 0; i+4 <= len(data); i++ {
 		if data[i] == selector[0] && data[i+1] == selector[1] &&
 			data[i+2] == selector[2] && data[i+3] == selector[3] {
 			remaining := data[i+4:]
-			if fee, err := decodePoolFeeFromInitArgs(method, remaining); err == nil {
-				return fee, nil
+			if params, err := decodeInitArgs(method, remaining); err == nil {
+				return params, nil
 			}
 		}
 	}
-	return 0, errors.New("initialize() selector not found in contract creation data")
+	return nil, errors.New("initialize() selector not found in contract creation data")
 }
 
 func (o *Onchain) GetProposalDuty(slot uint64, opts ...retry.Option) (*v1.ProposerDuty, error) {
@@ -937,22 +973,33 @@ func (o *Onchain) FetchFullBlock(slot uint64, oracle *Oracle, opt ...bool) *Full
 		fetchAll = false
 	}
 
+	fullBlock, err := o.GetFullBlock(slot, oracle, fetchAll)
+	if err != nil {
+		log.Fatal("could not fetch full block: ", err)
+	}
+	return fullBlock
+}
+
+// Same as FetchFullBlock but returning an error instead of halting, for slots that
+// can fail to be fetched without stopping the oracle, such as the non finalized ones
+func (o *Onchain) GetFullBlock(slot uint64, oracle *Oracle, fetchAll bool) (*FullBlock, error) {
+
 	// Get who should propose the block
 	slotDuty, err := o.GetProposalDuty(slot)
 	if err != nil {
-		log.Fatal("could not get proposal duty: ", err)
+		return nil, errors.Wrap(err, "could not get proposal duty")
 	}
 
 	// Sanity check to ensure the slot duty is the one we requested
 	if uint64(slotDuty.Slot) != slot {
-		log.Fatal("slot duty slot does not match requested slot: ", slotDuty.Slot, " vs ", slot)
+		return nil, errors.New(fmt.Sprintf("slot duty slot does not match requested slot: %d vs %d", slotDuty.Slot, slot))
 	}
 
 	// Get the validator info that proposed (or should have proposed) the block
 	currentSlotStr := strconv.FormatUint(slot, 10)
 	validator, err := o.GetSingleValidator(slotDuty.ValidatorIndex, currentSlotStr)
 	if err != nil {
-		log.Fatal("could not get single validator: ", err)
+		return nil, errors.Wrap(err, "could not get single validator")
 	}
 
 	// Create the full block with the duty, which is the minimum info it can have
@@ -961,79 +1008,102 @@ func (o *Onchain) FetchFullBlock(slot uint64, oracle *Oracle, opt ...bool) *Full
 	// Fetch the whole consensus block
 	proposedBlock, err := o.GetConsensusBlockAtSlot(slot)
 	if err != nil {
-		log.Fatal("could not get block at slot:", err)
+		return nil, errors.Wrap(err, "could not get block at slot")
 	}
 
 	if proposedBlock == nil {
 		// Mised block, nothing to do
 	} else {
 		// Succesfull proposal, fetch the info we need
-		fullBlock.SetConsensusBlock(proposedBlock)
+		err = fullBlock.SetConsensusBlock(proposedBlock)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not set consensus block")
+		}
 
 		// Sanity check to ensure the block is the one we requested
 		if fullBlock.GetSlotUint64() != slot {
-			log.Fatal("slot does not match requested slot: ", fullBlock.GetSlotUint64(), " vs ", slot)
+			return nil, errors.New(fmt.Sprintf("slot does not match requested slot: %d vs %d", fullBlock.GetSlotUint64(), slot))
 		}
 
 		etherReceived, err := o.GetEtherReceivedEvents(fullBlock.GetBlockNumber())
 		if err != nil {
-			log.Fatal("failed getting ether received events: ", err)
+			return nil, errors.Wrap(err, "failed getting ether received events")
 		}
 
 		subscribeValidator, err := o.GetSubscribeValidatorEvents(fullBlock.GetBlockNumber())
 		if err != nil {
-			log.Fatal("failed getting subscribe validator events: ", err)
+			return nil, errors.Wrap(err, "failed getting subscribe validator events")
 		}
 
 		unsubscribeValidator, err := o.GetUnsubscribeValidatorEvents(fullBlock.GetBlockNumber())
 		if err != nil {
-			log.Fatal("failed getting unsubscribe validator events: ", err)
+			return nil, errors.Wrap(err, "failed getting unsubscribe validator events")
 		}
 
 		updatePoolFee, err := o.GetUpdatePoolFeeEvents(fullBlock.GetBlockNumber())
 		if err != nil {
-			log.Fatal("failed getting update pool fee events: ", err)
+			return nil, errors.Wrap(err, "failed getting update pool fee events")
 		}
 
 		poolFeeRecipient, err := o.GetPoolFeeRecipientEvents(fullBlock.GetBlockNumber())
 		if err != nil {
-			log.Fatal("failed getting pool fee recipient events: ", err)
+			return nil, errors.Wrap(err, "failed getting pool fee recipient events")
 		}
 
 		checkpointSlotSize, err := o.GetCheckpointSlotSizeEvents(fullBlock.GetBlockNumber())
 		if err != nil {
-			log.Fatal("failed getting checkpoint slot size events: ", err)
+			return nil, errors.Wrap(err, "failed getting checkpoint slot size events")
 		}
 
 		updateSubscriptionCollateral, err := o.GetUpdateSubscriptionCollateralEvents(fullBlock.GetBlockNumber())
 		if err != nil {
-			log.Fatal("failed getting update subscription collateral events: ", err)
+			return nil, errors.Wrap(err, "failed getting update subscription collateral events")
 		}
 
 		banValidator, err := o.GetBanValidatorEvents(fullBlock.GetBlockNumber())
 		if err != nil {
-			log.Fatal("failed getting ban validator events: ", err)
+			return nil, errors.Wrap(err, "failed getting ban validator events")
 		}
 
 		unbanValidator, err := o.GetUnbanValidatorEvents(fullBlock.GetBlockNumber())
 		if err != nil {
-			log.Fatal("failed getting unban validator events: ", err)
+			return nil, errors.Wrap(err, "failed getting unban validator events")
+		}
+
+		setRewardRecipient, err := o.GetSetRewardRecipientEvents(fullBlock.GetBlockNumber())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed getting set reward recipient events")
+		}
+
+		claimRewards, err := o.GetClaimRewardsEvents(fullBlock.GetBlockNumber())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed getting claim rewards events")
+		}
+
+		submitReport, err := o.GetSubmitReportEvents(fullBlock.GetBlockNumber())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed getting submit report events")
+		}
+
+		reportConsolidated, err := o.GetReportConsolidatedEvents(fullBlock.GetBlockNumber())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed getting report consolidated events")
 		}
 
 		// Not all events are fetched as they are not needed
 		events := &Events{
-			EtherReceived:      etherReceived,
-			SubscribeValidator: subscribeValidator,
-			//ClaimRewards: claimRewards,
-			//SetRewardRecipient: setRewardRecipient,
+			EtherReceived:        etherReceived,
+			SubscribeValidator:   subscribeValidator,
+			ClaimRewards:         claimRewards,
+			SetRewardRecipient:   setRewardRecipient,
 			UnsubscribeValidator: unsubscribeValidator,
 			//InitSmoothingPool: initSmoothingPool,
 			UpdatePoolFee:                updatePoolFee,
 			PoolFeeRecipient:             poolFeeRecipient,
 			CheckpointSlotSize:           checkpointSlotSize,
 			UpdateSubscriptionCollateral: updateSubscriptionCollateral,
-			//SubmitReport: submitReport,
-			//ReportConsolidated: reportConsolidated,
+			SubmitReport:                 submitReport,
+			ReportConsolidated:           reportConsolidated,
 			//UpdateQuorum: updateQuorum,
 			//AddOracleMember: addOracleMember,
 			//RemoveOracleMember: removeOracleMember,
@@ -1043,8 +1113,18 @@ func (o *Onchain) FetchFullBlock(slot uint64, oracle *Oracle, opt ...bool) *Full
 			UnbanValidator: unbanValidator,
 		}
 
+		// Events are fetched by block number, so they must belong to the proposed block and not
+		// to another one at the same height, which can happen with blocks not finalized yet
+		err = fullBlock.CheckEventsBlockHash(events)
+		if err != nil {
+			return nil, err
+		}
+
 		// Add the events to the block
-		fullBlock.SetEvents(events)
+		err = fullBlock.SetEvents(events)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not set events")
+		}
 
 		// If we have subscriptions or unsubscriptions, we need the state of that validator(s) at the current slot
 		validatorsSubs := make([]*v1.Validator, 0)
@@ -1053,7 +1133,7 @@ func (o *Onchain) FetchFullBlock(slot uint64, oracle *Oracle, opt ...bool) *Full
 		for _, sub := range fullBlock.Events.SubscribeValidator {
 			validatorSub, err := o.GetSingleValidator(phase0.ValidatorIndex(sub.ValidatorID), currentSlotStr)
 			if err != nil {
-				log.Fatal("could not get validator subscriptions: ", err)
+				return nil, errors.Wrap(err, "could not get validator subscriptions")
 			}
 			validatorsSubs = append(validatorsSubs, validatorSub)
 		}
@@ -1061,7 +1141,7 @@ func (o *Onchain) FetchFullBlock(slot uint64, oracle *Oracle, opt ...bool) *Full
 		for _, unsub := range fullBlock.Events.UnsubscribeValidator {
 			validatorsUnsub, err := o.GetSingleValidator(phase0.ValidatorIndex(unsub.ValidatorID), currentSlotStr)
 			if err != nil {
-				log.Fatal("could not get validator unsubscriptions: ", err)
+				return nil, errors.Wrap(err, "could not get validator unsubscriptions")
 			}
 			validatorsUnsubs = append(validatorsUnsubs, validatorsUnsub)
 		}
@@ -1080,13 +1160,51 @@ func (o *Onchain) FetchFullBlock(slot uint64, oracle *Oracle, opt ...bool) *Full
 		if fetchAll || (isFromSubscriber || isPoolRewarded) {
 			header, receipts, err := o.GetExecHeaderAndReceipts(fullBlock.GetBlockNumberBigInt(), fullBlock.GetBlockTransactions())
 			if err != nil {
-				log.Fatal("failed getting header and receipts: ", err)
+				return nil, errors.Wrap(err, "failed getting header and receipts")
+			}
+			err = fullBlock.CheckHeaderBlockHash(header, receipts)
+			if err != nil {
+				return nil, err
+			}
+			err = fullBlock.SetHeaderAndReceipts(header, receipts)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not set header and receipts")
 			}
-			fullBlock.SetHeaderAndReceipts(header, receipts)
 		}
 	}
 
-	return fullBlock
+	return fullBlock, nil
+}
+
+// Returns an error if the execution block at the height of the given block is not its execution
+// payload anymore. Events are fetched by block number, so blocks not finalized yet have to be
+// checked once fetched, as they may have been reorged meanwhile
+func (o *Onchain) CheckCanonicalBlock(fullBlock *FullBlock, opts ...retry.Option) error {
+	// Missed blocks have no execution payload
+	if fullBlock.ConsensusBlock == nil {
+		return nil
+	}
+
+	var header *types.Header
+	var err error
+	blockNumber := fullBlock.GetBlockNumberBigInt()
+	err = retry.Do(func() error {
+		header, err = o.ExecutionClient.HeaderByNumber(context.Background(), blockNumber)
+		if err != nil {
+			log.Warn("Failed attempt to fetch header for block ", blockNumber.String(), ": ", err.Error(), " Retrying...")
+			return errors.New("Error fetching header for block " + blockNumber.String() + ": " + err.Error())
+		}
+		return nil
+	}, o.GetRetryOpts(opts)...)
+	if err != nil {
+		return errors.Wrap(err, "could not fetch header for block "+blockNumber.String())
+	}
+
+	if header.Hash() != fullBlock.GetBlockHash() {
+		return errors.New(fmt.Sprintf("block %d was reorged: %s vs %s",
+			blockNumber.Uint64(), fullBlock.GetBlockHash().String(), header.Hash().String()))
+	}
+	return nil
 }
 
 // TODO: This function is not wrapped with retries
@@ -1215,30 +1333,11 @@ func (onchain *Onchain) GetConfigFromContract(
 
 	log.Info("[Loaded from contract] Contract deployed in slot: ", deployedSlot)
 
-	// Decode the initial pool fee from the initialize() transaction in the deployment block.
-	// This avoids hardcoding the initial fee and works on full nodes (no archive needed).
-	// If decoding fails, fall back to a network-specific default.
-	initialPoolFee, err := decodeInitialPoolFee(block, cliCfg.PoolAddress)
-	if err != nil {
-		defaultFees := map[string]int{
-			Mainnet: 700,  // 7%
-			Hoodi:   1000, // 10%
-		}
-		defaultFee, ok := defaultFees[network]
-		if !ok {
-			log.Fatal("Could not decode initial pool fee and no default configured for network ", network, ": ", err.Error())
-		}
-		initialPoolFee = defaultFee
-		log.Warn("Could not decode initial pool fee from deployment block (", err.Error(), "), using default for ", network, ": ", float64(initialPoolFee)/100, "%")
-	} else {
-		log.Info("[Decoded from deployment tx] Initial pool fees percent: ", float64(initialPoolFee)/100, "% (raw value: ", initialPoolFee, ")")
-	}
-
 	checkPointSizeInSlots, err := onchain.GetSlotCheckpointSize()
 	if err != nil {
 		log.Fatal("Could not get slot checkpoint size: " + err.Error())
 	}
-	log.Info("[Loaded from contract] Checkpoints will be created every ", checkPointSizeInSlots, " slots (", utils.SlotsToTime(checkPointSizeInSlots, constants.SecondsInSlot), ")")
+	log.Info("[Loaded from contract] Checkpoints are created every ", checkPointSizeInSlots, " slots (", utils.SlotsToTime(checkPointSizeInSlots, constants.SecondsInSlot), ")")
 
 	poolFeesAddress, err := onchain.GetPoolFeeAddress()
 	if err != nil {
@@ -1253,6 +1352,38 @@ func (onchain *Onchain) GetConfigFromContract(
 	log.Info("[Loaded from contract] Required collateral to join the pool: ",
 		ethCollateralInWei, " wei (", utils.WeiToEther(ethCollateralInWei), " Eth)")
 
+	// The config starts with the parameters the contract was initialized with, decoded from the
+	// initialize() transaction in the deployment block. Governance changes done afterwards are
+	// applied at the slot they happened while syncing. This avoids hardcoding the initial values
+	// and works on full nodes (no archive needed). If decoding fails, fall back to a network-specific
+	// default fee and to the current contract values for the rest.
+	initial, err := decodeInitialParams(block, cliCfg.PoolAddress)
+	if err != nil {
+		defaultFees := map[string]int{
+			Mainnet: 700,  // 7%
+			Hoodi:   1000, // 10%
+		}
+		defaultFee, ok := defaultFees[network]
+		if !ok {
+			log.Fatal("Could not decode initial pool params and no default configured for network ", network, ": ", err.Error())
+		}
+		initial = &initialParams{
+			PoolFeesPercentOver10000: defaultFee,
+			PoolFeesAddress:          poolFeesAddress,
+			CheckPointSizeInSlots:    checkPointSizeInSlots,
+			CollateralInWei:          ethCollateralInWei,
+		}
+		log.Warn("Could not decode initial pool params from deployment block (", err.Error(), "), using default fee for ", network, ": ",
+			float64(defaultFee)/100, "% and the current contract values for the rest")
+	} else {
+		log.WithFields(log.Fields{
+			"PoolFeesPercent":       fmt.Sprintf("%.2f%%", float64(initial.PoolFeesPercentOver10000)/100),
+			"PoolFeesAddress":       initial.PoolFeesAddress,
+			"CheckPointSizeInSlots": initial.CheckPointSizeInSlots,
+			"CollateralInWei":       initial.CollateralInWei,
+		}).Info("[Decoded from deployment tx] Initial pool params")
+	}
+
 	if cliCfg.DryRun {
 		log.Warn("The pool contract WILL NOT be updated, running in dry-run mode")
 	} else {
@@ -1279,11 +1410,13 @@ func (onchain *Onchain) GetConfigFromContract(
 		PoolAddress:              cliCfg.PoolAddress,
 		DeployedSlot:             deployedSlot,
 		DeployedBlock:            deployedBlock.Uint64(),
-		CheckPointSizeInSlots:    checkPointSizeInSlots,
-		PoolFeesPercentOver10000: initialPoolFee,
-		PoolFeesAddress:          poolFeesAddress,
-		CollateralInWei:          ethCollateralInWei,
+		CheckPointSizeInSlots:    initial.CheckPointSizeInSlots,
+		PoolFeesPercentOver10000: initial.PoolFeesPercentOver10000,
+		PoolFeesAddress:          initial.PoolFeesAddress,
+		CollateralInWei:          initial.CollateralInWei,
 		DryRun:                   cliCfg.DryRun,
+		RequireReconciliation:    cliCfg.RequireReconciliation,
+		BlsCreditSlot:            cliCfg.BlsCreditSlot,
 		NumRetries:               cliCfg.NumRetries,
 		UpdaterKeyPass:           cliCfg.UpdaterKeyPass,
 		UpdaterKeyFile:           cliCfg.UpdaterKeyFile,
@@ -1332,9 +1465,14 @@ func (o *Onchain) GetEtherReceivedEvents(
 func (o *Onchain) GetSubscribeValidatorEvents(
 	blockNumber uint64,
 	opts ...retry.Option) ([]*contract.ContractSubscribeValidator, error) {
+	return o.GetSubscribeValidatorEventsInRange(blockNumber, blockNumber, opts...)
+}
 
-	startBlock := uint64(blockNumber)
-	endBlock := uint64(blockNumber)
+// Returns the SubscribeValidator events from startBlock to endBlock, both included
+func (o *Onchain) GetSubscribeValidatorEventsInRange(
+	startBlock uint64,
+	endBlock uint64,
+	opts ...retry.Option) ([]*contract.ContractSubscribeValidator, error) {
 
 	filterOpts := &bind.FilterOpts{Context: context.Background(), Start: startBlock, End: &endBlock}
 
@@ -1344,7 +1482,8 @@ func (o *Onchain) GetSubscribeValidatorEvents(
 	err = retry.Do(func() error {
 		itr, err = o.Contract.FilterSubscribeValidator(filterOpts)
 		if err != nil {
-			log.Warn("Failed attempt GetSubscribeValidatorEvents for block ", strconv.FormatUint(blockNumber, 10), ": ", err.Error(), " Retrying...")
+			log.Warn("Failed attempt GetSubscribeValidatorEvents for blocks ", strconv.FormatUint(startBlock, 10),
+				"-", strconv.FormatUint(endBlock, 10), ": ", err.Error(), " Retrying...")
 			return err
 		}
 		return nil
@@ -1365,31 +1504,126 @@ func (o *Onchain) GetSubscribeValidatorEvents(
 	return events, nil
 }
 
+// Iterator over the logs of a contract event, as generated by abigen
+type eventIterator interface {
+	Next() bool
+	Error() error
+	Close() error
+}
+
+// Gets all the events of the given name in [startBlock, endBlock] with the given filter, querying
+// chunks of blocksPerQuery blocks to stay within the log range limits of the execution clients.
+// Each chunk is retried on its own
+func getEventsInRange[I eventIterator, E any](
+	o *Onchain,
+	name string,
+	filter func(opts *bind.FilterOpts) (I, error),
+	event func(itr I) E,
+	startBlock uint64,
+	endBlock uint64,
+	blocksPerQuery uint64,
+	opts ...retry.Option) ([]E, error) {
+
+	if blocksPerQuery == 0 {
+		return nil, errors.New(fmt.Sprintf("could not get %s events, blocks per query cant be 0", name))
+	}
+
+	events := make([]E, 0)
+	for from := startBlock; from <= endBlock; {
+		to := endBlock
+		if endBlock-from >= blocksPerQuery {
+			to = from + blocksPerQuery - 1
+		}
+		filterOpts := &bind.FilterOpts{Context: context.Background(), Start: from, End: &to}
+
+		var err error
+		var itr I
+		err = retry.Do(func() error {
+			itr, err = filter(filterOpts)
+			if err != nil {
+				log.Warn("Failed attempt Get", name, "Events for blocks ", strconv.FormatUint(from, 10),
+					"-", strconv.FormatUint(to, 10), ": ", err.Error(), " Retrying...")
+				return err
+			}
+			return nil
+		}, o.GetRetryOpts(opts)...)
+
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("could not get %s events", name))
+		}
+
+		for itr.Next() {
+			events = append(events, event(itr))
+		}
+		if itr.Error() != nil {
+			itr.Close()
+			return nil, errors.Wrap(itr.Error(), fmt.Sprintf("could not iterate %s events", name))
+		}
+		err = itr.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("could not close %s iterator", name))
+		}
+		if to == endBlock {
+			break
+		}
+		from = to + 1
+	}
+	return events, nil
+}
+
 func (o *Onchain) GetClaimRewardsEvents(
 	blockNumber uint64,
 	opts ...retry.Option) ([]*contract.ContractClaimRewards, error) {
 
-	var events []*contract.ContractClaimRewards
-	log.Fatal("Not implemented: GetClaimRewardsEvents is not implemented")
+	return o.GetClaimRewardsEventsInRange(blockNumber, blockNumber, 1, opts...)
+}
 
-	return events, nil
+// Gets all ClaimRewards events in [startBlock, endBlock], querying chunks of
+// blocksPerQuery blocks to stay within the log range limits of the execution clients
+func (o *Onchain) GetClaimRewardsEventsInRange(
+	startBlock uint64,
+	endBlock uint64,
+	blocksPerQuery uint64,
+	opts ...retry.Option) ([]*contract.ContractClaimRewards, error) {
+
+	return getEventsInRange(o, "ClaimRewards", o.Contract.FilterClaimRewards,
+		func(itr *contract.ContractClaimRewardsIterator) *contract.ContractClaimRewards { return itr.Event },
+		startBlock, endBlock, blocksPerQuery, opts...)
 }
 
 func (o *Onchain) GetSetRewardRecipientEvents(
 	blockNumber uint64,
 	opts ...retry.Option) ([]*contract.ContractSetRewardRecipient, error) {
 
-	var events []*contract.ContractSetRewardRecipient
-	log.Fatal("Not implemented: GetSetRewardsRecipientEvents it not implemented")
-	return events, nil
+	return o.GetSetRewardRecipientEventsInRange(blockNumber, blockNumber, 1, opts...)
+}
+
+// Gets all SetRewardRecipient events in [startBlock, endBlock], querying chunks of
+// blocksPerQuery blocks to stay within the log range limits of the execution clients
+func (o *Onchain) GetSetRewardRecipientEventsInRange(
+	startBlock uint64,
+	endBlock uint64,
+	blocksPerQuery uint64,
+	opts ...retry.Option) ([]*contract.ContractSetRewardRecipient, error) {
+
+	return getEventsInRange(o, "SetRewardRecipient", o.Contract.FilterSetRewardRecipient,
+		func(itr *contract.ContractSetRewardRecipientIterator) *contract.ContractSetRewardRecipient {
+			return itr.Event
+		},
+		startBlock, endBlock, blocksPerQuery, opts...)
 }
 
 func (o *Onchain) GetUnsubscribeValidatorEvents(
 	blockNumber uint64,
 	opts ...retry.Option) ([]*contract.ContractUnsubscribeValidator, error) {
+	return o.GetUnsubscribeValidatorEventsInRange(blockNumber, blockNumber, opts...)
+}
 
-	startBlock := uint64(blockNumber)
-	endBlock := uint64(blockNumber)
+// Returns the UnsubscribeValidator events from startBlock to endBlock, both included
+func (o *Onchain) GetUnsubscribeValidatorEventsInRange(
+	startBlock uint64,
+	endBlock uint64,
+	opts ...retry.Option) ([]*contract.ContractUnsubscribeValidator, error) {
 
 	filterOpts := &bind.FilterOpts{Context: context.Background(), Start: startBlock, End: &endBlock}
 
@@ -1399,7 +1633,8 @@ func (o *Onchain) GetUnsubscribeValidatorEvents(
 	err = retry.Do(func() error {
 		itr, err = o.Contract.FilterUnsubscribeValidator(filterOpts)
 		if err != nil {
-			log.Warn("Failed attempt GetUnsubscribeValidatorEvents for block ", strconv.FormatUint(blockNumber, 10), ": ", err.Error(), " Retrying...")
+			log.Warn("Failed attempt GetUnsubscribeValidatorEvents for blocks ", strconv.FormatUint(startBlock, 10),
+				"-", strconv.FormatUint(endBlock, 10), ": ", err.Error(), " Retrying...")
 			return err
 		}
 		return nil
@@ -1645,18 +1880,59 @@ func (o *Onchain) GetSubmitReportEvents(
 	blockNumber uint64,
 	opts ...retry.Option) ([]*contract.ContractSubmitReport, error) {
 
+	startBlock := uint64(blockNumber)
+	endBlock := uint64(blockNumber)
+
+	filterOpts := &bind.FilterOpts{Context: context.Background(), Start: startBlock, End: &endBlock}
+
+	var err error
+	var itr *contract.ContractSubmitReportIterator
+
+	err = retry.Do(func() error {
+		itr, err = o.Contract.FilterSubmitReport(filterOpts)
+		if err != nil {
+			log.Warn("Failed attempt GetSubmitReportEvents for block ", strconv.FormatUint(blockNumber, 10), ": ", err.Error(), " Retrying...")
+			return err
+		}
+		return nil
+	}, o.GetRetryOpts(opts)...)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get SubmitReport events")
+	}
+
 	var events []*contract.ContractSubmitReport
-	log.Fatal("Not implemented: GetSubmitReportEvents it not implemented")
+	for itr.Next() {
+		events = append(events, itr.Event)
+	}
+	err = itr.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not close SubmitReport iterator")
+	}
 	return events, nil
 }
 func (o *Onchain) GetReportConsolidatedEvents(
 	blockNumber uint64,
 	opts ...retry.Option) ([]*contract.ContractReportConsolidated, error) {
 
-	var events []*contract.ContractReportConsolidated
-	log.Fatal("Not implemented: GetReportConsolidatedEvents it not implemented")
-	return events, nil
+	return o.GetReportConsolidatedEventsInRange(blockNumber, blockNumber, 1, opts...)
+}
+
+// Gets all ReportConsolidated events in [startBlock, endBlock], querying chunks of
+// blocksPerQuery blocks to stay within the log range limits of the execution clients
+func (o *Onchain) GetReportConsolidatedEventsInRange(
+	startBlock uint64,
+	endBlock uint64,
+	blocksPerQuery uint64,
+	opts ...retry.Option) ([]*contract.ContractReportConsolidated, error) {
+
+	return getEventsInRange(o, "ReportConsolidated", o.Contract.FilterReportConsolidated,
+		func(itr *contract.ContractReportConsolidatedIterator) *contract.ContractReportConsolidated {
+			return itr.Event
+		},
+		startBlock, endBlock, blocksPerQuery, opts...)
 }
+
 func (o *Onchain) GetUpdateQuorumEvents(
 	blockNumber uint64,
 	opts ...retry.Option) ([]*contract.ContractUpdateQuorum, error) {
@@ -1698,24 +1974,6 @@ func (o *Onchain) GetAcceptGovernanceEvents(
 	return events, nil
 }
 
-func (o *Onchain) GetClaimedPerWithdrawalAddress(addresses []string, finalizedBlock *big.Int, opts ...retry.Option) (map[string]*big.Int, error) {
-	claimedMap := make(map[string]*big.Int)
-
-	for _, address := range addresses {
-		// Important to use finalized blocks. Otherwise our local oracle view and onchain view may differ
-		claimed, err := o.GetContractClaimedBalance(address, finalizedBlock, opts...)
-		if err != nil {
-			return nil, errors.Wrap(err, "could not get claimed balance")
-		}
-		_, found := claimedMap[address]
-		if found {
-			log.Fatal("Duplicate deposit address found: ", address)
-		}
-		claimedMap[address] = claimed
-	}
-	return claimedMap, nil
-}
-
 func (o *Onchain) UpdateContractMerkleRoot(slot uint64, newMerkleRoot string) error {
 
 	// Support both 0x prefixed and non prefixed merkle roots
@@ -6,13 +6,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"path/filepath"
 	"testing"
 
 	eth2 "github.com/attestantio/go-eth2-client/api"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/avast/retry-go/v4"
 	"github.com/dappnode/mev-sp-oracle/config"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
@@ -130,7 +133,8 @@ func Test_BlocksWithInternalTx(t *testing.T) {
 	oracle := NewOracle(&Config{})
 
 	fullBlock := onchain.FetchFullBlock(8097330, oracle)
-	donations := fullBlock.GetDonations(pool)
+	donations, err := fullBlock.GetDonations(pool)
+	require.NoError(t, err)
 	mevReward, isMev, recipient := fullBlock.MevRewardInWei()
 	require.Equal(t, big.NewInt(0).SetUint64(31995314350342039), mevReward)
 	require.Equal(t, true, isMev)
@@ -154,7 +158,8 @@ func Test_BlocksWithInternalTx2(t *testing.T) {
 	// Self destruct that does not trigger EtherReceived event
 	// https://etherscan.io/tx/0x60571ab93a187c7e8f8ae7952430a7de64b47843e716cbd53a0fa741316569c6
 	fullBlock := onchain.FetchFullBlock(ExceptionSlotMainnet1, oracle)
-	donations := fullBlock.GetDonations(pool)
+	donations, err := fullBlock.GetDonations(pool)
+	require.NoError(t, err)
 	mevReward, isMev, recipient := fullBlock.MevRewardInWei()
 	require.Equal(t, big.NewInt(0).SetUint64(177043568463114308), mevReward)
 	require.Equal(t, true, isMev)
@@ -508,7 +513,7 @@ func Test_Mainnet_BeaverIssue(t *testing.T) {
 	oracleInstance := NewOracle(cfg)
 
 	// Subscribe the proposer of slot 9444748
-	oracleInstance.addSubscription(12137, "", "")
+	oracleInstance.addSubscription(12137, "", "", TransitionCause{})
 
 	// Slots where something happened. This saves having to sync everything, which takes too long
 	slotsToProcess := []uint64{
@@ -544,3 +549,65 @@ func isInSlice(element uint64, slice []uint64) bool {
 	}
 	return false
 }
+
+// Iterator over a fixed set of events, failing after them if err is set
+type fakeIterator struct {
+	events []uint64
+	next   int
+	err    error
+	closed bool
+}
+
+func (it *fakeIterator) Next() bool {
+	if it.next >= len(it.events) {
+		return false
+	}
+	it.next++
+	return true
+}
+func (it *fakeIterator) Error() error { return it.err }
+func (it *fakeIterator) Close() error { it.closed = true; return nil }
+
+func Test_GetEventsInRange(t *testing.T) {
+	onchain := &Onchain{}
+	retryOnce := []retry.Option{retry.Attempts(2), retry.Delay(0)}
+	queried := make([][2]uint64, 0)
+	failures := 1
+	filter := func(opts *bind.FilterOpts) (*fakeIterator, error) {
+		// The first query fails once, and is retried
+		if failures > 0 {
+			failures--
+			return nil, fmt.Errorf("timeout")
+		}
+		queried = append(queried, [2]uint64{opts.Start, *opts.End})
+		return &fakeIterator{events: []uint64{opts.Start, *opts.End}}, nil
+	}
+	event := func(itr *fakeIterator) uint64 { return itr.events[itr.next-1] }
+
+	// Queried in chunks, the last one up to the end block
+	events, err := getEventsInRange(onchain, "Test", filter, event, 100, 124, 10, retryOnce...)
+	require.NoError(t, err)
+	require.Equal(t, [][2]uint64{{100, 109}, {110, 119}, {120, 124}}, queried)
+	require.Equal(t, []uint64{100, 109, 110, 119, 120, 124}, events)
+
+	// Errors of the filter and of the iterator are returned
+	failures = 2
+	_, err = getEventsInRange(onchain, "Test", filter, event, 100, 124, 10, retryOnce...)
+	require.ErrorContains(t, err, "could not get Test events")
+
+	iterator := &fakeIterator{err: fmt.Errorf("connection reset")}
+	_, err = getEventsInRange(onchain, "Test",
+		func(opts *bind.FilterOpts) (*fakeIterator, error) { return iterator, nil }, event, 100, 100, 1, retryOnce...)
+	require.ErrorContains(t, err, "connection reset")
+	require.True(t, iterator.closed)
+
+	// Chunks of 0 blocks would never end
+	_, err = getEventsInRange(onchain, "Test", filter, event, 100, 124, 0, retryOnce...)
+	require.ErrorContains(t, err, "blocks per query cant be 0")
+
+	// And chunks past the last block dont overflow
+	queried = queried[:0]
+	_, err = getEventsInRange(onchain, "Test", filter, event, math.MaxUint64-5, math.MaxUint64, 4, retryOnce...)
+	require.NoError(t, err)
+	require.Equal(t, [][2]uint64{{math.MaxUint64 - 5, math.MaxUint64 - 2}, {math.MaxUint64 - 1, math.MaxUint64}}, queried)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"strconv"
+	"strings"
 
 	"fmt"
 	"io/ioutil"
@@ -12,12 +13,15 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/avast/retry-go/v4"
 	"github.com/pkg/errors"
 
 	v1 "github.com/attestantio/go-eth2-client/api/v1"
 	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/dappnode/mev-sp-oracle/constants"
 	"github.com/dappnode/mev-sp-oracle/contract"
 	"github.com/dappnode/mev-sp-oracle/utils"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -27,6 +31,10 @@ import (
 // Default path of persisted state
 var StateFolder = "oracle-data"
 var StateJsonName = "state.json"
+var ReconciliationJsonName = "reconciliation.json"
+
+// Max amount of reconciliation reports stored, oldest are discarded
+var MaxReconciliationReports = 500
 
 type GetSetOfValidatorsFunc func(valIndices []phase0.ValidatorIndex, slot string, opts ...retry.Option) (map[phase0.ValidatorIndex]*v1.Validator, error)
 type GetPendingConsolidationsFunc func(stateID string, opts ...retry.Option) (*PendingConsolidationsResponse, error)
@@ -37,10 +45,55 @@ type Oracle struct {
 	mutex                    sync.RWMutex
 	getSetOfValidators       GetSetOfValidatorsFunc
 	getPendingConsolidations GetPendingConsolidationsFunc
+
+	// Trees of the latest requested commited states, to generate proofs. See Proof
+	proofTrees      map[uint64]*checkpointTree
+	proofTreesMutex sync.Mutex
+	proofTreesUses  uint64
+
+	// Tracked validators by lowercase key, for the state and amount of validators it was built
+	// from. See validatorIndexByKey
+	validatorsByKey      map[string]uint64
+	validatorsByKeyState *OracleState
+	validatorsByKeyCount int
+
+	// Indexes of the blocks and donations to serve queries. See QueryBlocks
+	history      *historyIndex
+	historyMutex sync.Mutex
+
+	// Events published as the state changes, to stream them. See EventStream
+	events *EventStream
+
+	// Immutable copy of the state and the version of the state, increased after every
+	// change. See Snapshot
+	snapshot     atomic.Pointer[stateSnapshot]
+	stateVersion atomic.Uint64
+
+	// Forces a reward distributor regardless of the slot. Only set in simulations
+	forcedRewardDistributor RewardDistributor
+
+	// Set in forks processing slots not finalized yet, whose blocks can be reorged. See Fork
+	isFork bool
+
+	// Running totals of assets and liabilities up to the latest processed slot. Not
+	// stored in the state, calculated when its loaded. See AccountingTotals
+	accounting *AccountingTotals
+
+	// Latest onchain reconciliation reports, oldest first. Not stored in the state since
+	// they depend on when each oracle runs them, but next to it. See ReconciliationReports
+	reconciliationReports []ReconciliationReport
+	reconciliationMutex   sync.Mutex
+
+	// Results of the simulations up to the latest processed slot by params, and the lock
+	// held while one runs. See Simulate
+	simulations      map[string]*SimulationResult
+	simulationsSlot  uint64
+	simulationsMutex sync.Mutex
+	simulationMutex  sync.Mutex
 }
 
 // Rewards calculation methods. Different methods on how
-// to calculate the rewards of the pool.
+// to calculate the rewards of the pool. See RewardDistributor.
 const (
 	RewardMethodPreFork1 = "pre_fork1"
 	RewardMethodFork1    = "fork1"
@@ -64,6 +117,42 @@ var SlotElectraFork = map[string]uint64{
 	Hoodi: uint64(65536),
 }
 
+// Exits fork unsubscribes the validators that are slashed or start exiting in the slot they
+// are seen, sharing their pending rewards among the pool instead of waiting for the cleanup.
+// Like the forks above it changes the balances, so all oracles must apply it from the same
+// slot. Scheduled at the first epoch after December 1, 2026 12:00 UTC
+var SlotExitsFork = map[string]uint64{
+	Mainnet: uint64(15775200),
+	Holesky: uint64(8352032),
+	Hoodi:   uint64(4492768),
+}
+
+// Bls rotation fork remembers the correct proposals of validators with BLS credentials, and
+// auto subscribes them once they rotate to an execution withdrawal address. Only proposals
+// from the fork on are remembered. Scheduled with the exits fork. Whether the rewards of the
+// remembered proposals are credited when rotating is configured, see Config.BlsCreditSlot
+var SlotBlsRotationFork = map[string]uint64{
+	Mainnet: uint64(15775200),
+	Holesky: uint64(8352032),
+	Hoodi:   uint64(4492768),
+}
+
+// Consolidations fork tracks the EIP-7251 consolidation requests of tracked validators and
+// follows them in the beacon chain once per epoch, transferring the pending rewards of the
+// source when they complete. Before it, the cleanup transfers the pending rewards of exited
+// sources still in the pending consolidations. Scheduled with the exits fork, in the networks
+// with the electra fork
+var SlotConsolidationsFork = map[string]uint64{
+	Mainnet: uint64(15775200),
+	Hoodi:   uint64(4492768),
+}
+
+// Returns true if the fork is scheduled in the network and active at the slot
+func isForkActive(fork map[string]uint64, network string, slot uint64) bool {
+	activationSlot, found := fork[network]
+	return found && slot >= activationSlot
+}
+
 func NewOracle(cfg *Config) *Oracle {
 	state := &OracleState{
 		StateHash:            "",
@@ -90,6 +179,10 @@ func NewOracle(cfg *Config) *Oracle {
 		DeployedBlock:            cfg.DeployedBlock,
 		DeployedSlot:             cfg.DeployedSlot,
 		CollateralInWei:          cfg.CollateralInWei,
+		ConfigHistory:            []ConfigChange{initialConfigChange(cfg)},
+
+		RewardRecipientsIndexed: true,
+		ClaimsIndexed:           true,
 	}
 
 	oracle := &Oracle{
@@ -97,7 +190,9 @@ func NewOracle(cfg *Config) *Oracle {
 		state:                    state,
 		getSetOfValidators:       nil,
 		getPendingConsolidations: nil,
+		events:                   NewEventStream(),
 	}
+	oracle.copySnapshotLockFree()
 
 	return oracle
 }
@@ -111,7 +206,8 @@ func (or *Oracle) GetPendingConsolidationsFunc(oc GetPendingConsolidationsFunc)
 }
 
 // Returns the state of the oracle, containing all the information about the
-// validatores, with their state, balances, etc
+// validatores, with their state, balances, etc. Its modified as slots are processed,
+// so only the goroutine processing them can read it. Others must use Snapshot
 func (or *Oracle) State() *OracleState {
 	or.mutex.RLock()
 	defer or.mutex.RUnlock()
@@ -119,7 +215,8 @@ func (or *Oracle) State() *OracleState {
 }
 
 // Returns wether a checkpoint has been reached or not. A checkpoint is reached
-// when CheckPointSizeInSlots have passed from the last checkpoint
+// when CheckPointSizeInSlots have passed from the last checkpoint, taking into
+// account the checkpoint size changes done by governance
 func (or *Oracle) IsCheckpoint() (bool, error) {
 	or.mutex.RLock()
 	defer or.mutex.RUnlock()
@@ -136,24 +233,18 @@ func (or *Oracle) IsCheckpoint() (bool, error) {
 			or.cfg.DeployedSlot, latestProcSlot))
 	}
 
-	if (latestProcSlot-or.cfg.DeployedSlot)%or.cfg.CheckPointSizeInSlots == 0 {
-		return true, nil
-	}
-	return false, nil
+	return isCheckpointSlot(or.state.ConfigHistory, latestProcSlot), nil
 }
 
-// Returns the state of the oracle, recalculating the hash of the state for
-// verification purposes
+// Returns a snapshot of the state of the oracle, recalculating the hash of the state for
+// verification purposes. The copy is hashed, so the oracle is not blocked meanwhile
 func (or *Oracle) StateWithHash() (*OracleState, error) {
-	or.mutex.Lock()
-
-	// Update hash
-	err := or.hashStateLockFree()
+	state := *or.Snapshot()
+	err := hashState(&state)
 	if err != nil {
 		return nil, errors.Wrap(err, "error hashing the oracle state")
 	}
-	or.mutex.Unlock()
-	return or.State(), nil
+	return &state, nil
 }
 
 // Given a previous or.state, this function applies the new block to it, updating the or.state
@@ -178,13 +269,16 @@ func (or *Oracle) AdvanceStateToNextSlot(fullBlock *FullBlock) (uint64, error) {
 	}
 
 	// Some misc validations
-	err := or.validateFullBlockConfig(fullBlock, or.cfg)
+	err := or.validateFullBlockConfig(fullBlock)
 	if err != nil {
 		return 0, errors.Wrap(err, "Error validating full block config")
 	}
 
 	// Full block is too heavy to be stored in the state, so we summarize it
-	summarizedBlock := fullBlock.SummarizedBlock(or, or.cfg.PoolAddress)
+	summarizedBlock, err := fullBlock.SummarizedBlock(or, or.cfg.PoolAddress)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not summarize block")
+	}
 
 	// Ensure the block we process is the expected one
 	if or.state.NextSlotToProcess != summarizedBlock.Slot {
@@ -193,7 +287,18 @@ func (or *Oracle) AdvanceStateToNextSlot(fullBlock *FullBlock) (uint64, error) {
 	}
 
 	// Get donations to the pool in this block
-	blockDonations := fullBlock.GetDonations(or.cfg.PoolAddress)
+	blockDonations, err := fullBlock.GetDonations(or.cfg.PoolAddress)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not get donations")
+	}
+
+	// Keep track of what this slot appends, to update the running totals
+	accountingBefore := or.accountingTotalsLockFree().copy()
+	numEtherRx := len(or.state.EtherReceivedEvents)
+	numSubs := len(or.state.SubscriptionEvents)
+	numBlocks := len(or.state.ProposedBlocks)
+	numMissed := len(or.state.MissedBlocks)
+	numWrongFee := len(or.state.WrongFeeBlocks)
 
 	// Store all events raw for trazability
 	or.state.SubscriptionEvents = append(or.state.SubscriptionEvents, fullBlock.Events.SubscribeValidator...)
@@ -201,7 +306,10 @@ func (or *Oracle) AdvanceStateToNextSlot(fullBlock *FullBlock) (uint64, error) {
 	or.state.EtherReceivedEvents = append(or.state.EtherReceivedEvents, fullBlock.Events.EtherReceived...)
 
 	// Handle subscriptions first thing
-	or.handleManualSubscriptions(fullBlock.Events.SubscribeValidator, fullBlock.ValidatorsSubs)
+	err = or.handleManualSubscriptions(fullBlock.Events.SubscribeValidator, fullBlock.ValidatorsSubs)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not handle subscriptions")
+	}
 
 	// If the validator was subscribed and missed proposed the block in this slot
 	if summarizedBlock.BlockType == MissedProposal && or.isSubscribed(summarizedBlock.ValidatorIndex) {
@@ -210,33 +318,91 @@ func (or *Oracle) AdvanceStateToNextSlot(fullBlock *FullBlock) (uint64, error) {
 
 	// If we have a successful block proposal BUT the validator has BLS keys, we cant auto subscribe it
 	if summarizedBlock.BlockType == OkPoolProposalBlsKeys {
-		or.handleBlsCorrectBlockProposal(summarizedBlock)
+		err = or.handleBlsCorrectBlockProposal(summarizedBlock)
+		if err != nil {
+			return 0, errors.Wrap(err, "could not handle bls block proposal")
+		}
 	}
 
 	// If fee recipient matches the pool, we distribute the rewards and upate
 	// the validator state. Automatic subscriptions are considered here
 	if summarizedBlock.BlockType == OkPoolProposal {
-		or.handleCorrectBlockProposal(summarizedBlock)
+		err = or.handleCorrectBlockProposal(summarizedBlock)
+		if err != nil {
+			return 0, errors.Wrap(err, "could not handle block proposal")
+		}
 	}
 
 	// If the validator was subscribed but the fee recipient was wrong we ban the validator
 	if summarizedBlock.BlockType == WrongFeeRecipient && or.isSubscribed(summarizedBlock.ValidatorIndex) {
-		or.handleBanValidator(summarizedBlock)
+		err = or.handleBanValidator(summarizedBlock)
+		if err != nil {
+			return 0, errors.Wrap(err, "could not ban validator")
+		}
 	}
 
 	// Handle unsubscriptions the last thing after distributing rewards
-	or.handleManualUnsubscriptions(fullBlock.Events.UnsubscribeValidator, fullBlock.ValidatorsUnsubs)
+	err = or.handleManualUnsubscriptions(fullBlock.Events.UnsubscribeValidator, fullBlock.ValidatorsUnsubs)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not handle unsubscriptions")
+	}
 
 	// Handle the donations from this block
-	or.handleDonations(blockDonations)
+	err = or.handleDonations(blockDonations)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not handle donations")
+	}
+
+	// Since the exits fork, validators that are slashed or start exiting in this block stop earning
+	// rewards right away, the cleanup below is just a safety net
+	if isForkActive(SlotExitsFork, or.cfg.Network, or.state.NextSlotToProcess) {
+		err = or.handleSlashingsAndExits(fullBlock)
+		if err != nil {
+			return 0, errors.Wrap(err, "could not handle slashings and exits")
+		}
+	}
+
+	// Since the bls rotation fork, BLS proposers that rotate to execution credentials are auto subscribed
+	if isForkActive(SlotBlsRotationFork, or.cfg.Network, or.state.NextSlotToProcess) {
+		or.handleBlsToExecutionChanges(fullBlock)
+	}
+
+	// Reward recipients dont change any balance, just who can receive the claimed rewards
+	or.handleSetRewardRecipients(fullBlock.Events.SetRewardRecipient)
+
+	// Claims dont change the liabilities of the state, since the accumulated rewards are
+	// not reset. They are tracked to know what is still owed by the contract
+	or.handleClaimRewards(fullBlock.Events.ClaimRewards)
+
+	// Since the consolidations fork, consolidation requests are tracked. They dont change any
+	// balance until the beacon chain processes them
+	trackingConsolidations := isForkActive(SlotConsolidationsFork, or.cfg.Network, or.state.NextSlotToProcess)
+	if trackingConsolidations {
+		or.handleConsolidationRequests(fullBlock.GetConsolidationRequests(), summarizedBlock.Block)
+	}
 
 	// Manual bans/unbans should always be the last thing to be processed in each block, since
 	// we want to ensure they persist to the next block
 	// Handle manual bans
-	or.handleManualBans(fullBlock.Events.BanValidator)
+	err = or.handleManualBans(fullBlock.Events.BanValidator)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not handle manual bans")
+	}
 
 	// Handle manual unbans
-	or.handleManualUnbans(fullBlock.Events.UnbanValidator)
+	err = or.handleManualUnbans(fullBlock.Events.UnbanValidator)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not handle manual unbans")
+	}
+
+	// Follow the consolidations of tracked validators in the beacon chain once per epoch, so that
+	// pending rewards are transferred when they complete. Only while there are unfinished ones.
+	if trackingConsolidations && or.state.NextSlotToProcess%constants.SlotsInEpoch == 0 && or.hasOpenConsolidations() {
+		err = or.trackConsolidations(or.state.NextSlotToProcess)
+		if err != nil {
+			return 0, errors.Wrap(err, "could not track consolidations")
+		}
+	}
 
 	// Handle validator cleanup: redisitribute the pending rewards of validators subscribed to the pool
 	// that are not in the beacon chain anymore (exited/slashed). We dont run this on every slot because
@@ -254,6 +420,31 @@ func (or *Oracle) AdvanceStateToNextSlot(fullBlock *FullBlock) (uint64, error) {
 	if summarizedBlock.BlockType != MissedProposal {
 		or.state.LatestProcessedBlock = summarizedBlock.Block
 	}
+
+	// Assets and liabilities must match after every slot
+	err = or.checkSlotInvariant(accountingBefore, numEtherRx, numSubs, numBlocks,
+		fullBlock, summarizedBlock, blockDonations)
+	if err != nil {
+		return 0, err
+	}
+	or.updateSnapshotLockFree()
+
+	// Blocks stored by the pool in this slot
+	for _, block := range or.state.ProposedBlocks[numBlocks:] {
+		or.publishBlock(block)
+	}
+	for _, block := range or.state.MissedBlocks[numMissed:] {
+		or.publishBlock(block)
+	}
+	for _, block := range or.state.WrongFeeBlocks[numWrongFee:] {
+		or.publishBlock(block)
+	}
+	or.publishReports(fullBlock.Events)
+	or.publish(SlotProcessedEvent, processedSlot, nil, "", SlotProcessedData{
+		Slot:      processedSlot,
+		Block:     summarizedBlock.Block,
+		BlockType: summarizedBlock.BlockType.String(),
+	})
 	return processedSlot, nil
 }
 
@@ -263,6 +454,14 @@ func (or *Oracle) ValidatorCleanup(slot uint64) error {
 	// Only cleanup if we're past the cleanup slot fork
 	if slot >= SlotFork1[or.cfg.Network] {
 
+		// Rotations of bls proposers that were not seen in a processed block
+		if isForkActive(SlotBlsRotationFork, or.cfg.Network, slot) {
+			err := or.checkBlsProposersRotation(slot)
+			if err != nil {
+				return errors.Wrap(err, "could not check bls proposers rotation")
+			}
+		}
+
 		// Extract all validator indices from the oracle state
 		indices := make([]phase0.ValidatorIndex, 0)
 		for idx := range or.state.Validators {
@@ -275,19 +474,25 @@ func (or *Oracle) ValidatorCleanup(slot uint64) error {
 			return nil
 		}
 
-		var sourceToTarget map[uint64]uint64
-		sourceToTarget = make(map[uint64]uint64)
-
-		// 🔒 Only fetch consolidations if past Electra fork
+		// 🔒 Only fetch consolidations if past Electra fork. Since the consolidations fork, the
+		// consolidations of tracked validators are updated first, so the ones still pending are
+		// known below. Before it, only the sources in the pending consolidations are
+		trackingConsolidations := isForkActive(SlotConsolidationsFork, or.cfg.Network, slot)
+		sourceToTarget := make(map[uint64]uint64)
 		if slot >= SlotElectraFork[or.cfg.Network] {
-			pendingConsolidations, err := or.getPendingConsolidations(strconv.FormatUint(slot, 10))
-			if err != nil {
-				return errors.Wrap(err, "could not fetch pending consolidations")
-			}
-
-			for _, c := range pendingConsolidations.Data {
-				sourceToTarget[uint64(c.SourceIndex)] = uint64(c.TargetIndex)
-
+			if trackingConsolidations {
+				err := or.trackConsolidations(slot)
+				if err != nil {
+					return errors.Wrap(err, "could not track consolidations")
+				}
+			} else {
+				pendingConsolidations, err := or.getPendingConsolidations(strconv.FormatUint(slot, 10))
+				if err != nil {
+					return errors.Wrap(err, "could not fetch pending consolidations")
+				}
+				for _, c := range pendingConsolidations.Data {
+					sourceToTarget[uint64(c.SourceIndex)] = uint64(c.TargetIndex)
+				}
 			}
 		}
 
@@ -315,76 +520,24 @@ func (or *Oracle) ValidatorCleanup(slot uint64) error {
 					"Network":                 or.cfg.Network,
 				}).Info("Cleaning up validator")
 
-				or.advanceStateMachine(idx, Unsubscribe)
-				// sourceToTarget map will only be populated if the oracle is past the Electra fork and there are pending consolidations
-				if targetIdx, ok := sourceToTarget[idx]; ok {
-					log.WithFields(log.Fields{
-						"SourceIndex":        idx,
-						"TargetIndex":        targetIdx,
-						"SourceState":        or.state.Validators[idx].ValidatorStatus,
-						"IsTargetTracked":    or.isTracked(targetIdx),
-						"IsTargetBanned":     or.isBanned(targetIdx),
-						"IsTargetSubscribed": or.isSubscribed(targetIdx),
-					}).Info("[CONSOLIDATION] of exited source found")
-					if or.isTracked(targetIdx) && !or.isBanned(targetIdx) {
-						log.WithFields(log.Fields{
-							"SourceIndex":        idx,
-							"TargetIndex":        targetIdx,
-							"SourceState":        or.state.Validators[idx].ValidatorStatus,
-							"TargetState":        or.state.Validators[targetIdx].ValidatorStatus,
-							"PendingTransferred": or.state.Validators[idx].PendingRewardsWei,
-						}).Info("[CONSOLIDATION] Transferring pending rewards of consolidated source to target")
-						or.increaseValidatorPendingRewards(targetIdx, or.state.Validators[idx].PendingRewardsWei)
-					} else if !or.isTracked(targetIdx) {
-						log.WithFields(log.Fields{
-							"SourceIndex":        idx,
-							"TargetIndex":        targetIdx,
-							"PendingToAddTarget": or.state.Validators[idx].PendingRewardsWei,
-						}).Info("[CONSOLIDATION] Target is not subscribed, transfer rewards and not subscribe")
-						targetValidator, err := or.getSetOfValidators([]phase0.ValidatorIndex{
-							phase0.ValidatorIndex(targetIdx)},
-							strconv.FormatUint(slot, 10))
-						if err != nil {
-							return errors.Wrap(err, "could not get target validator info")
-						}
-
-						targetValidatorSingle, found := targetValidator[phase0.ValidatorIndex(targetIdx)]
-
-						if !found {
-							return errors.New(fmt.Sprintf("expected validator not found. got: %v", targetValidator))
-						}
-						validatorWithdrawal, err := utils.GetCompatibleAddressByte(targetValidatorSingle.Validator.WithdrawalCredentials)
-						if err != nil {
-							// In theory impossible. Fail
-							// A validator with BLS cant be consolidated
-							log.WithFields(log.Fields{
-								"WithdrawalAddr": hexutil.Encode(targetValidatorSingle.Validator.WithdrawalCredentials[:]),
-								"TargetIndex":    targetIdx,
-							}).Fatal("[CONSOLIDATION]: The target has BLS keys, this cant happen")
-						}
-
-						or.state.Validators[targetIdx] = &ValidatorInfo{
-							ValidatorStatus:       NotSubscribed,
-							AccumulatedRewardsWei: big.NewInt(0),
-							PendingRewardsWei:     or.state.Validators[idx].PendingRewardsWei,
-							CollateralWei:         big.NewInt(0),
-							WithdrawalAddress:     validatorWithdrawal,
-							ValidatorIndex:        targetIdx,
-							ValidatorKey:          hexutil.Encode(targetValidatorSingle.Validator.PublicKey[:]),
-							// Note that 0 = Manual. But it is NotSubscribed. May be confusing.
-							SubscriptionType: Manual,
-						}
-
-					} else if or.isBanned(targetIdx) {
-						log.WithFields(log.Fields{
-							"SourceIndex":     idx,
-							"TargetIndex":     targetIdx,
-							"PendingGoToPool": or.state.Validators[idx].PendingRewardsWei,
-						}).Info("[CONSOLIDATION] Target is banned, rewards go to pool")
-						rewardsToDistribute.Add(rewardsToDistribute, or.state.Validators[idx].PendingRewardsWei)
-					} else {
-						log.Fatal("[CONSOLIDATION] Fail. Unknown case")
+				or.advanceStateMachine(idx, Unsubscribe, TransitionCause{Reason: "beacon chain status " + validator.Status.String()})
+				// Pending rewards of an exited source follow its balance to the consolidation target.
+				// Slashed sources are never consolidated, so their rewards go to the pool.
+				consolidation := or.openConsolidation(idx)
+				isSlashed := validator.Validator != nil && validator.Validator.Slashed
+				targetIdx, inQueue := sourceToTarget[idx]
+				if trackingConsolidations && consolidation >= 0 && or.state.Consolidations[consolidation].Status == ConsolidationPending && !isSlashed {
+					toPool, err := or.transferConsolidatedRewards(consolidation, slot, nil)
+					if err != nil {
+						return errors.Wrap(err, "could not transfer consolidated rewards")
 					}
+					rewardsToDistribute.Add(rewardsToDistribute, toPool)
+				} else if inQueue {
+					toPool, err := or.transferToConsolidationTarget(idx, targetIdx, slot)
+					if err != nil {
+						return errors.Wrap(err, "could not transfer rewards to consolidation target")
+					}
+					rewardsToDistribute.Add(rewardsToDistribute, toPool)
 				} else {
 					rewardsToDistribute.Add(rewardsToDistribute, or.state.Validators[idx].PendingRewardsWei)
 				}
@@ -394,7 +547,10 @@ func (or *Oracle) ValidatorCleanup(slot uint64) error {
 		}
 
 		if rewardsToDistribute.Cmp(big.NewInt(0)) != 0 {
-			or.increaseAllPendingRewards(rewardsToDistribute)
+			err = or.increaseAllPendingRewards(rewardsToDistribute)
+			if err != nil {
+				return errors.Wrap(err, "could not share the rewards of cleaned up validators")
+			}
 		}
 		log.Info("Validator cleanup done! Redistributed a total of ", rewardsToDistribute, " wei in pending among the pool in slot ", slot)
 	}
@@ -402,10 +558,9 @@ func (or *Oracle) ValidatorCleanup(slot uint64) error {
 	return nil
 }
 
-// Known fee schedule per network: maps the slot where an expected fee change takes effect to the new fee value.
-// When the oracle processes a slot listed here, it updates the config and state.
-// If an UpdatePoolFee event is seen at a slot NOT in this schedule, the oracle crashes.
-// Add new entries here when planning a fee change on-chain.
+// Known fee changes per network, mapping the slot where the change took effect to the new fee value.
+// Older versions of the oracle only accepted UpdatePoolFee events listed here and did not store the
+// config history, so this is only used to backfill the history of states created by them.
 var feeSchedule = map[string]map[uint64]int{
 	Mainnet: {
 		14082460: 500, // block 24848448: 7% -> 5%
@@ -423,68 +578,94 @@ func getFeeSchedule(network string) map[uint64]int {
 	return map[uint64]int{}
 }
 
-// applyFeeScheduleUpTo applies the latest fee schedule entry with slot <= upToSlot to the config.
-// When multiple entries qualify, the one with the highest slot wins (most recent change).
-func applyFeeScheduleUpTo(config *Config, upToSlot uint64) {
-	bestSlot := uint64(0)
-	bestFee := 0
-	found := false
-	for slot, fee := range getFeeSchedule(config.Network) {
-		if slot <= upToSlot && (!found || slot > bestSlot) {
-			bestSlot = slot
-			bestFee = fee
-			found = true
-		}
-	}
-	if found {
-		config.PoolFeesPercentOver10000 = bestFee
-	}
-}
-
-// We use the following events to validate that the config has not changed. Dynamic
-// parameters are not supported, except for known fee changes listed in feeSchedule.
+// Applies the config changes done by governance in the block, which take effect from its slot:
 // UpdatePoolFee: Indicates the cut in %*100 the pool gets
 // PoolFeeRecipient: Indicates the address that receives the pool fees
 // CheckpointSlotSize: Indicates the size of the checkpoint in slots
 // UpdateSubscriptionCollateral: Indicates the amount of ETH required to subscribe
-func (or *Oracle) validateFullBlockConfig(fullBlock *FullBlock, config *Config) error {
-	if len(fullBlock.Events.UpdatePoolFee) > 1 ||
-		len(fullBlock.Events.PoolFeeRecipient) > 1 ||
-		len(fullBlock.Events.CheckpointSlotSize) > 1 ||
-		len(fullBlock.Events.UpdateSubscriptionCollateral) > 1 {
-		return errors.New("more than one event of the same type in the same block, weird")
-	}
-
-	// If an UpdatePoolFee event is found, check if it's expected in the fee schedule.
-	// If expected, apply the new fee from the event. If not, crash.
-	if len(fullBlock.Events.UpdatePoolFee) != 0 {
-		slot := uint64(fullBlock.ConsensusDuty.Slot)
-		newFee := int(fullBlock.Events.UpdatePoolFee[0].NewPoolFee.Int64())
-		expectedFee, expected := getFeeSchedule(config.Network)[slot]
-		if !expected || expectedFee != newFee {
-			return errors.New(fmt.Sprintf("unexpected pool fee change at slot %d. event fee: %d, expected: %d (scheduled: %v)",
-				slot, newFee, expectedFee, expected))
+// If many events of the same type are found in the block, the last one prevails. Every
+// change is recorded in the config history of the state.
+func (or *Oracle) validateFullBlockConfig(fullBlock *FullBlock) error {
+	events := fullBlock.Events
+	slot := uint64(fullBlock.ConsensusDuty.Slot)
+	blsCreditStarts := slot == or.cfg.BlsCreditSlot && slot > or.cfg.DeployedSlot
+	if len(events.UpdatePoolFee) == 0 &&
+		len(events.PoolFeeRecipient) == 0 &&
+		len(events.CheckpointSlotSize) == 0 &&
+		len(events.UpdateSubscriptionCollateral) == 0 &&
+		!blsCreditStarts {
+		return nil
+	}
+
+	if len(or.state.ConfigHistory) == 0 {
+		return errors.New("config history is empty, cant apply config changes")
+	}
+
+	prev := or.state.ConfigHistory[len(or.state.ConfigHistory)-1]
+	if slot <= prev.Slot {
+		return errors.New(fmt.Sprintf("config change at slot %d is not after the latest one at slot %d",
+			slot, prev.Slot))
+	}
+
+	change := prev
+	change.Slot = slot
+
+	for _, event := range events.UpdatePoolFee {
+		change.Block = event.Raw.BlockNumber
+		if event.NewPoolFee.Sign() < 0 || event.NewPoolFee.Cmp(big.NewInt(100*100)) > 0 {
+			return errors.New(fmt.Sprintf("invalid pool fee at slot %d: %d", slot, event.NewPoolFee))
 		}
-		log.Info("Applying expected fee change from UpdatePoolFee event at slot ", slot, ": ", config.PoolFeesPercentOver10000, " -> ", newFee)
-		config.PoolFeesPercentOver10000 = newFee
-		or.state.PoolFeesPercentOver10000 = newFee
+		change.PoolFeesPercentOver10000 = int(event.NewPoolFee.Int64())
+	}
+
+	for _, event := range events.PoolFeeRecipient {
+		change.Block = event.Raw.BlockNumber
+		change.PoolFeesAddress = event.NewPoolFeeRecipient.Hex()
 	}
 
-	if len(fullBlock.Events.PoolFeeRecipient) != 0 && !utils.Equals(config.PoolFeesAddress, fullBlock.Events.PoolFeeRecipient[0].NewPoolFeeRecipient.String()) {
-		return errors.New(fmt.Sprintf("pool fee recipient has changed. config: %s, block: %s",
-			config.PoolFeesAddress, fullBlock.Events.PoolFeeRecipient[0].NewPoolFeeRecipient.String()))
+	for _, event := range events.CheckpointSlotSize {
+		change.Block = event.Raw.BlockNumber
+		if event.NewCheckpointSlotSize == 0 {
+			return errors.New(fmt.Sprintf("invalid checkpoint size at slot %d: 0", slot))
+		}
+		change.CheckPointSizeInSlots = event.NewCheckpointSlotSize
+	}
+	if change.CheckPointSizeInSlots != prev.CheckPointSizeInSlots {
+		change.CheckpointAnchorSlot = lastCheckpointBefore(prev, slot)
 	}
 
-	if len(fullBlock.Events.CheckpointSlotSize) != 0 && config.CheckPointSizeInSlots != fullBlock.Events.CheckpointSlotSize[0].NewCheckpointSlotSize {
-		return errors.New(fmt.Sprintf("checkpoint size has changed. config: %d, block: %d",
-			config.CheckPointSizeInSlots, fullBlock.Events.CheckpointSlotSize[0].NewCheckpointSlotSize))
+	for _, event := range events.UpdateSubscriptionCollateral {
+		change.Block = event.Raw.BlockNumber
+		if event.NewSubscriptionCollateral == nil || event.NewSubscriptionCollateral.Sign() < 0 {
+			return errors.New(fmt.Sprintf("invalid subscription collateral at slot %d: %v",
+				slot, event.NewSubscriptionCollateral))
+		}
+		// Copied, so that the event can't modify the state
+		change.CollateralInWei = new(big.Int).Set(event.NewSubscriptionCollateral)
 	}
 
-	if len(fullBlock.Events.UpdateSubscriptionCollateral) != 0 && config.CollateralInWei.Cmp(fullBlock.Events.UpdateSubscriptionCollateral[0].NewSubscriptionCollateral) != 0 {
-		return errors.New(fmt.Sprintf("subscription collateral has changed. config: %d, block: %d",
-			config.CollateralInWei, fullBlock.Events.UpdateSubscriptionCollateral[0].NewSubscriptionCollateral))
+	// Not a governance change, but recorded the same way so that it is part of the state
+	if blsCreditStarts {
+		change.BlsCredit = true
 	}
 
+	log.WithFields(log.Fields{
+		"Slot":                  slot,
+		"Block":                 change.Block,
+		"PoolFeesPercent":       fmt.Sprintf("%d -> %d", prev.PoolFeesPercentOver10000, change.PoolFeesPercentOver10000),
+		"PoolFeesAddress":       fmt.Sprintf("%s -> %s", prev.PoolFeesAddress, change.PoolFeesAddress),
+		"CheckPointSizeInSlots": fmt.Sprintf("%d -> %d", prev.CheckPointSizeInSlots, change.CheckPointSizeInSlots),
+		"CheckpointAnchorSlot":  change.CheckpointAnchorSlot,
+		"CollateralInWei":       fmt.Sprintf("%d -> %d", prev.CollateralInWei, change.CollateralInWei),
+		"BlsCredit":             fmt.Sprintf("%t -> %t", prev.BlsCredit, change.BlsCredit),
+	}).Info("Applying config change")
+
+	or.state.PoolFeesPercentOver10000 = change.PoolFeesPercentOver10000
+	or.state.PoolFeesAddress = change.PoolFeesAddress
+	or.state.CheckPointSizeInSlots = change.CheckPointSizeInSlots
+	or.state.CollateralInWei = change.CollateralInWei
+	or.state.ConfigHistory = append(or.state.ConfigHistory, change)
+
 	return nil
 }
 
@@ -630,42 +811,65 @@ func (or *Oracle) LoadFromBytes(rawBytes []byte) (bool, error) {
 			state.PoolAddress, or.cfg.PoolAddress))
 	}
 
-	if state.PoolFeesAddress != or.cfg.PoolFeesAddress {
-		return false, errors.New(fmt.Sprintf("pool fees address mismatch, recovered: %s, expected: %s",
-			state.PoolFeesAddress, or.cfg.PoolFeesAddress))
+	if state.DeployedBlock != or.cfg.DeployedBlock {
+		return false, errors.New(fmt.Sprintf("deployed block mismatch, recovered: %d, expected: %d",
+			state.DeployedBlock, or.cfg.DeployedBlock))
 	}
 
-	if state.PoolFeesPercentOver10000 != or.cfg.PoolFeesPercentOver10000 {
-		// The config starts at initialPoolFee. If the state was saved after a
-		// scheduled fee change, apply the schedule up to the state's slot.
-		applyFeeScheduleUpTo(or.cfg, state.LatestProcessedSlot)
-		if state.PoolFeesPercentOver10000 != or.cfg.PoolFeesPercentOver10000 {
-			return false, errors.New(fmt.Sprintf("pool fees percent mismatch, recovered: %d, expected: %d",
-				state.PoolFeesPercentOver10000, or.cfg.PoolFeesPercentOver10000))
-		}
+	if state.DeployedSlot != or.cfg.DeployedSlot {
+		return false, errors.New(fmt.Sprintf("deployed slot mismatch, recovered: %d, expected: %d",
+			state.DeployedSlot, or.cfg.DeployedSlot))
 	}
 
-	if state.CollateralInWei.Cmp(or.cfg.CollateralInWei) != 0 {
-		return false, errors.New(fmt.Sprintf("collateral mismatch, recovered: %d, expected: %d",
-			state.CollateralInWei, or.cfg.CollateralInWei))
+	// States created by older versions dont store the config history
+	if len(state.ConfigHistory) == 0 {
+		log.Info("State has no config history, backfilling it from the known fee changes")
+		state.ConfigHistory = backfillConfigHistory(or.cfg, state.LatestProcessedSlot)
 	}
 
-	if state.CheckPointSizeInSlots != or.cfg.CheckPointSizeInSlots {
-		return false, errors.New(fmt.Sprintf("check point size mismatch, recovered: %d, expected: %d",
-			state.CheckPointSizeInSlots, or.cfg.CheckPointSizeInSlots))
+	// States created by older versions dont remember the bls proposers
+	backfillBlsProposers(&state)
+
+	// States created by older versions store the proofs of every commited state
+	upgradeCommitedStates(&state)
+
+	// The config parameters of the state must match the initial config plus the changes
+	// done by governance up to the state slot
+	err = validateConfigHistory(or.cfg, &state)
+	if err != nil {
+		return false, errors.Wrap(err, "config history mismatch")
 	}
 
-	if state.DeployedBlock != or.cfg.DeployedBlock {
-		return false, errors.New(fmt.Sprintf("deployed block mismatch, recovered: %d, expected: %d",
-			state.DeployedBlock, or.cfg.DeployedBlock))
+	// The reward recipients must be the result of applying its history
+	err = validateRewardRecipients(&state)
+	if err != nil {
+		return false, errors.Wrap(err, "reward recipients mismatch")
 	}
 
-	if state.DeployedSlot != or.cfg.DeployedSlot {
-		return false, errors.New(fmt.Sprintf("deployed slot mismatch, recovered: %d, expected: %d",
-			state.DeployedSlot, or.cfg.DeployedSlot))
+	// The claimed rewards must be the result of adding all claims
+	err = validateClaims(&state)
+	if err != nil {
+		return false, errors.Wrap(err, "claims mismatch")
+	}
+
+	// Assets and liabilities of the loaded state must match
+	accounting := computeAccountingTotals(&state)
+	err = accounting.Check()
+	if err != nil {
+		return false, errors.Wrap(err, "accounting invariant violated in loaded state")
 	}
 
 	or.state = &state
+	or.accounting = accounting
+	or.updateSnapshotLockFree()
+
+	// Trees of the previous state may not match the loaded one
+	or.proofTreesMutex.Lock()
+	or.proofTrees = nil
+	or.proofTreesMutex.Unlock()
+	or.historyMutex.Lock()
+	or.history = nil
+	or.historyMutex.Unlock()
 
 	mRoot, enoughData := or.getMerkleRootIfAny()
 	log.WithFields(log.Fields{
@@ -690,9 +894,13 @@ func (or *Oracle) LoadGivenState(slotCheckpoint uint64) (bool, error) {
 
 	// If not found, attemp to load previous states up to "attempts" checkpoints before
 	attempts := 3
+	checkpointSize := or.state.CheckPointSizeInSlots
+	if config, found := configAtSlot(or.state.ConfigHistory, slotCheckpoint); found {
+		checkpointSize = config.CheckPointSizeInSlots
+	}
 	if !has {
 		for i := 1; i < attempts; i++ {
-			trySlot := slotCheckpoint - or.cfg.CheckPointSizeInSlots*uint64(i)
+			trySlot := slotCheckpoint - checkpointSize*uint64(i)
 			log.Info("Could not find slot for checkpoint, ", slotCheckpoint, ", trying slot: ", trySlot)
 			path = filepath.Join(StateFolder, fmt.Sprintf("state_%d.json", trySlot))
 			has, err = or.LoadFromPath(path)
@@ -714,11 +922,11 @@ func (or *Oracle) FreezeCheckpoint() bool {
 	or.mutex.Lock()
 	defer or.mutex.Unlock()
 
-	validatorsCopy := make(map[uint64]*ValidatorInfo)
-	utils.DeepCopy(or.state.Validators, &validatorsCopy)
+	validatorsCopy := CopyValidators(or.state.Validators)
 
 	mk := NewMerklelizer()
-	withdrawalToLeaf, withdrawalToRawLeaf, tree, enoughData := mk.GenerateTreeFromState(or.state)
+	orderedLeafs := mk.AggregateValidatorsIndexes(or.state)
+	_, _, tree, enoughData := mk.GenerateTreeFromLeafs(orderedLeafs)
 	if !enoughData {
 		return false
 	}
@@ -729,40 +937,43 @@ func (or *Oracle) FreezeCheckpoint() bool {
 		"MerkleRoot": merkleRootStr,
 	}).Info("Freezing state")
 
-	// Merkle proofs for each withdrawal address
-	proofs := make(map[string][]string)
-	leafs := make(map[string]RawLeaf)
-	for WithdrawalAddress, rawLeaf := range withdrawalToRawLeaf {
-
-		// Extra sanity check to make sure the withdrawal address is the same as the key
-		if !utils.Equals(WithdrawalAddress, rawLeaf.WithdrawalAddress) {
-			log.Fatal("withdrawal address in raw leaf doesnt match the key")
-		}
+	// Only the leafs are stored, proofs are generated on demand. See Proof
+	state := &OnchainState{
+		Validators:   validatorsCopy,
+		MerkleRoot:   merkleRootStr,
+		Slot:         or.state.LatestProcessedSlot,
+		OrderedLeafs: orderedLeafs,
+	}
 
-		block := withdrawalToLeaf[WithdrawalAddress]
-		proof, err := tree.Proof(block)
+	or.state.CommitedStates[state.Slot] = state
+	or.updateSnapshotLockFree()
+	or.publish(CheckpointFrozenEvent, state.Slot, nil, "", CheckpointData{Slot: state.Slot, MerkleRoot: merkleRootStr})
+	return true
+}
 
-		if err != nil {
-			log.Fatal("could not generate proof for block: ", err)
+// Returns a deep copy of the validators, so that they can be modified without
+// affecting the original ones
+func CopyValidators(validators map[uint64]*ValidatorInfo) map[uint64]*ValidatorInfo {
+	validatorsCopy := make(map[uint64]*ValidatorInfo, len(validators))
+	for valIndex, validator := range validators {
+		if validator == nil {
+			validatorsCopy[valIndex] = nil
+			continue
 		}
-
-		// Store the proofs of the withdrawal address (to be used onchain)
-		proofs[WithdrawalAddress] = utils.ByteArrayToArray(proof.Siblings)
-
-		// Store the leafs (to be used onchain)
-		leafs[WithdrawalAddress] = rawLeaf
+		validatorCopy := *validator
+		validatorCopy.AccumulatedRewardsWei = copyBigInt(validator.AccumulatedRewardsWei)
+		validatorCopy.PendingRewardsWei = copyBigInt(validator.PendingRewardsWei)
+		validatorCopy.CollateralWei = copyBigInt(validator.CollateralWei)
+		validatorsCopy[valIndex] = &validatorCopy
 	}
+	return validatorsCopy
+}
 
-	state := &OnchainState{
-		Validators: validatorsCopy,
-		MerkleRoot: merkleRootStr,
-		Slot:       or.state.LatestProcessedSlot,
-		Proofs:     proofs,
-		Leafs:      leafs,
+func copyBigInt(value *big.Int) *big.Int {
+	if value == nil {
+		return nil
 	}
-
-	or.state.CommitedStates[state.Slot] = state
-	return true
+	return new(big.Int).Set(value)
 }
 
 // Returns true and the latest commited slot if there is any commited state
@@ -860,9 +1071,10 @@ func (or *Oracle) IsOracleInSyncWithChain(onchainRoot string, onchainSlot uint64
 // result in the pool being unable to pay.
 // - assets > liabilities: means less rewards are distributed, and since everything is encoded
 // in the root, this means some funds will be locked forever.
-func (or *Oracle) RunOnchainReconciliation(
-	contractBalanceWei *big.Int,
-	claimedAmountsWei map[string]*big.Int) error {
+// The contract balance must be captured at the latest processed block, and the claimed amounts are
+// the claims processed up to it. The result is stored as a ReconciliationReport, and an error
+// is returned if they dont match.
+func (or *Oracle) RunOnchainReconciliation(contractBalanceWei *big.Int) (*ReconciliationReport, error) {
 
 	// We calculate:
 	// 1. what we owe: total pending + accumulated rewards for all vlaidators + pool fees.
@@ -872,6 +1084,9 @@ func (or *Oracle) RunOnchainReconciliation(
 	// both amount have to match at any time, asssuming we run this on finalized
 	// on the same slots (finalized epochs)
 
+	or.mutex.RLock()
+	report := or.newReconciliationReport()
+
 	// What we owe (1/2)
 	totalCumulativeRewards := big.NewInt(0)
 	for _, val := range or.state.Validators {
@@ -880,13 +1095,14 @@ func (or *Oracle) RunOnchainReconciliation(
 	}
 	totalCumulativeRewards.Add(totalCumulativeRewards, or.state.PoolAccumulatedFees)
 
-	log.Info("[Reconciliation] Total amount of accumulated + pending rewards: ", totalCumulativeRewards)
-
 	// What we owe (2/2)
 	totalAlreadyClaimed := big.NewInt(0)
-	for _, claimed := range claimedAmountsWei {
+	for _, claimed := range or.state.ClaimedRewards {
 		totalAlreadyClaimed.Add(totalAlreadyClaimed, claimed)
 	}
+	or.mutex.RUnlock()
+
+	log.Info("[Reconciliation] Total amount of accumulated + pending rewards: ", totalCumulativeRewards)
 
 	log.Info("[Reconciliation] Total amount already claimed by all addresses: ", totalAlreadyClaimed)
 
@@ -897,45 +1113,142 @@ func (or *Oracle) RunOnchainReconciliation(
 
 	log.Info("[Reconciliation] Total pool balance (what we have): ", contractBalanceWei)
 
-	if totalLiabilities.Cmp(contractBalanceWei) != 0 {
-		return errors.New(fmt.Sprintf("[Reconciliation] Liabilities and balance dont match: %d vs %d",
-			totalLiabilities, contractBalanceWei))
+	report.RewardsWei = totalCumulativeRewards
+	report.ClaimedWei = totalAlreadyClaimed
+	report.AssetsWei = new(big.Int).Set(contractBalanceWei)
+	report.LiabilitiesWei = totalLiabilities
+	report.DeltaWei = new(big.Int).Sub(contractBalanceWei, totalLiabilities)
+	report.Success = report.DeltaWei.Sign() == 0
+
+	if !report.Success {
+		report.Error = fmt.Sprintf("liabilities and balance dont match: %d vs %d",
+			totalLiabilities, contractBalanceWei)
+		or.storeReconciliationReport(report)
+		return report, errors.New("[Reconciliation] " + report.Error)
 	}
 
+	or.storeReconciliationReport(report)
 	log.Info("[Reconciliation] Success! Liabilities and balance match: ", totalLiabilities, " vs ", contractBalanceWei)
 
-	return nil
+	return report, nil
 }
 
-func (or *Oracle) RunOffchainReconciliation() error {
-	liabilities := big.NewInt(0)
+// Stores a failed report for a reconciliation that could not be run at the latest processed
+// block, eg because its inputs could not be fetched
+func (or *Oracle) RecordFailedReconciliation(reason error) *ReconciliationReport {
+	or.mutex.RLock()
+	report := or.newReconciliationReport()
+	or.mutex.RUnlock()
 
-	for _, val := range or.state.Validators {
-		liabilities.Add(liabilities, val.AccumulatedRewardsWei)
-		liabilities.Add(liabilities, val.PendingRewardsWei)
+	report.Error = reason.Error()
+	or.storeReconciliationReport(report)
+	return report
+}
+
+// Returns a copy of the stored reconciliation reports, oldest first
+func (or *Oracle) ReconciliationReports() []ReconciliationReport {
+	or.reconciliationMutex.Lock()
+	defer or.reconciliationMutex.Unlock()
+	reports := make([]ReconciliationReport, len(or.reconciliationReports))
+	copy(reports, or.reconciliationReports)
+	return reports
+}
+
+// Returns true if the latest reconciliation report succeeded and was captured at most
+// maxAgeSlots before the given slot
+func (or *Oracle) HasFreshReconciliation(slot uint64, maxAgeSlots uint64) bool {
+	or.reconciliationMutex.Lock()
+	defer or.reconciliationMutex.Unlock()
+	reports := or.reconciliationReports
+	if len(reports) == 0 {
+		return false
 	}
-	liabilities.Add(liabilities, or.state.PoolAccumulatedFees)
+	latest := reports[len(reports)-1]
+	return latest.Success && latest.Slot <= slot && slot-latest.Slot <= maxAgeSlots
+}
 
-	assets := big.NewInt(0)
+func (or *Oracle) newReconciliationReport() *ReconciliationReport {
+	return &ReconciliationReport{
+		Slot:      or.state.LatestProcessedSlot,
+		Block:     or.state.LatestProcessedBlock,
+		Timestamp: time.Now().Unix(),
+	}
+}
 
-	for _, etherRx := range or.state.EtherReceivedEvents {
-		assets.Add(assets, etherRx.DonationAmount)
+// Stores the report and persists all of them next to the state, so they survive restarts.
+// Failing to persist them does not fail the reconciliation
+func (or *Oracle) storeReconciliationReport(report *ReconciliationReport) {
+	or.reconciliationMutex.Lock()
+	defer or.reconciliationMutex.Unlock()
+	or.reconciliationReports = append(or.reconciliationReports, *report)
+	if len(or.reconciliationReports) > MaxReconciliationReports {
+		or.reconciliationReports = or.reconciliationReports[len(or.reconciliationReports)-MaxReconciliationReports:]
 	}
-	for _, subs := range or.state.SubscriptionEvents {
-		assets.Add(assets, subs.SubscriptionCollateral)
+	or.publish(ReconciliationEvent, report.Slot, nil, "", report)
+
+	jsonData, err := json.MarshalIndent(or.reconciliationReports, "", " ")
+	if err != nil {
+		log.Error("Could not marshal reconciliation reports: ", err)
+		return
 	}
-	for _, vanilaBlock := range or.state.ProposedBlocks {
-		if vanilaBlock.RewardType == VanilaBlock {
-			assets.Add(assets, vanilaBlock.Reward)
-		}
+	err = os.MkdirAll(StateFolder, os.ModePerm)
+	if err != nil {
+		log.Error("Could not create folder for reconciliation reports: ", err)
+		return
+	}
+	err = ioutil.WriteFile(filepath.Join(StateFolder, ReconciliationJsonName), jsonData, 0644)
+	if err != nil {
+		log.Error("Could not write reconciliation reports: ", err)
+	}
+}
+
+// Loads the reconciliation reports stored next to the state, if any
+func (or *Oracle) LoadReconciliationReports() error {
+	rawBytes, err := ioutil.ReadFile(filepath.Join(StateFolder, ReconciliationJsonName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "could not read reconciliation reports")
+	}
+	var reports []ReconciliationReport
+	err = json.Unmarshal(rawBytes, &reports)
+	if err != nil {
+		return errors.Wrap(err, "could not unmarshal reconciliation reports")
 	}
 
+	or.reconciliationMutex.Lock()
+	defer or.reconciliationMutex.Unlock()
+	or.reconciliationReports = reports
+	return nil
+}
+
+// Recalculates assets and liabilities from all the events and blocks of the state, and
+// checks that they match and agree with the running totals
+func (or *Oracle) RunOffchainReconciliation() error {
+	or.mutex.RLock()
+	defer or.mutex.RUnlock()
+
+	totals := computeAccountingTotals(or.state)
+	liabilities := totals.Liabilities()
+	assets := totals.Assets()
+
 	log.Info("[Offchain reconciliation] Liabilities: ", liabilities, "wei ", utils.WeiToEther(liabilities), " Ether")
 	log.Info("[Offchain reconciliation] Assets: ", assets, "wei ", utils.WeiToEther(assets), " Ether")
 
-	if liabilities.Cmp(assets) != 0 {
-		return errors.New(fmt.Sprintf("Liabilities and assets dont match: %d vs %d",
-			liabilities, assets))
+	err := totals.Check()
+	if err != nil {
+		return err
+	}
+
+	if or.accounting != nil && or.accounting.Assets().Cmp(assets) != 0 {
+		return errors.New(fmt.Sprintf("Running assets dont match the state: %d vs %d",
+			or.accounting.Assets(), assets))
+	}
+
+	if or.accounting != nil && or.accounting.Liabilities().Cmp(liabilities) != 0 {
+		return errors.New(fmt.Sprintf("Running liabilities dont match the state: %d vs %d",
+			or.accounting.Liabilities(), liabilities))
 	}
 
 	return nil
@@ -944,21 +1257,19 @@ func (or *Oracle) RunOffchainReconciliation() error {
 func (or *Oracle) GetUniqueWithdrawalAddresses() []string {
 	var uniqueWithAdd []string
 
+	// Withdrawal addresses (lowercase) processed before
+	seen := make(map[string]bool)
+
 	// Iterate all validators
 	for _, validator := range or.State().Validators {
-		skip := false
-		// Iterate all unique deposit addresses processed before
-		for _, u := range uniqueWithAdd {
-			// If the deposit address is already in the list, skip it
-			if utils.Equals(validator.WithdrawalAddress, u) {
-				skip = true
-				break
-			}
+		// If the withdrawal address is already in the list, skip it
+		withdrawalAddress := strings.ToLower(validator.WithdrawalAddress)
+		if seen[withdrawalAddress] {
+			continue
 		}
 		// Not found, add it
-		if !skip {
-			uniqueWithAdd = append(uniqueWithAdd, validator.WithdrawalAddress)
-		}
+		seen[withdrawalAddress] = true
+		uniqueWithAdd = append(uniqueWithAdd, validator.WithdrawalAddress)
 	}
 
 	// Include also the pool address
@@ -986,11 +1297,15 @@ func CanValidatorSubscribeToPool(validator *v1.Validator) bool {
 }
 
 func (or *Oracle) hashStateLockFree() error {
+	return hashState(or.state)
+}
+
+func hashState(state *OracleState) error {
 	// We remove the hash before hashing, always hashing an empty hash
-	or.state.StateHash = ""
+	state.StateHash = ""
 
 	// Serialize the state
-	jsonData, err := json.MarshalIndent(or.state, "", " ")
+	jsonData, err := json.MarshalIndent(state, "", " ")
 	if err != nil {
 		return errors.Wrap(err, "could not marshal state to json")
 	}
@@ -1000,7 +1315,7 @@ func (or *Oracle) hashStateLockFree() error {
 	stateHashStr := hexutil.Encode(stateHash[:])
 
 	// Set the hash of the state
-	or.state.StateHash = stateHashStr
+	state.StateHash = stateHashStr
 
 	return nil
 }
@@ -1008,15 +1323,13 @@ func (or *Oracle) hashStateLockFree() error {
 // Returns if a validator is subscribed to the pool. A validator is subscribed if
 // its state is: active, yellowcard, redcard
 func (or *Oracle) isSubscribed(validatorIndex uint64) bool {
-	for valIndex, validator := range or.state.Validators {
-		if valIndex == validatorIndex &&
-			validator.ValidatorStatus != Banned &&
-			validator.ValidatorStatus != NotSubscribed &&
-			validator.ValidatorStatus != UnknownState {
-			return true
-		}
+	validator, found := or.state.Validators[validatorIndex]
+	if !found {
+		return false
 	}
-	return false
+	return validator.ValidatorStatus != Banned &&
+		validator.ValidatorStatus != NotSubscribed &&
+		validator.ValidatorStatus != UnknownState
 }
 
 // Returns true if a validator is banned
@@ -1047,20 +1360,24 @@ func (or *Oracle) isCollateralEnough(collateral *big.Int) bool {
 }
 
 // Handles the donations of a given block
-func (or *Oracle) handleDonations(donations []*contract.ContractEtherReceived) {
+func (or *Oracle) handleDonations(donations []*contract.ContractEtherReceived) error {
 	// Ensure the donations are from the same block
 	if len(donations) > 0 {
 		blockReference := donations[0].Raw.BlockNumber
 		for _, donation := range donations {
 			if donation.Raw.BlockNumber != blockReference {
-				log.Fatal("Handling donations from different blocks is not possible: ",
-					donation.Raw.BlockNumber, " vs ", blockReference)
+				return errors.New(fmt.Sprintf("handling donations from different blocks is not possible: %d vs %d",
+					donation.Raw.BlockNumber, blockReference))
 			}
 		}
 	}
 	for _, donation := range donations {
-		or.increaseAllPendingRewards(donation.DonationAmount)
+		err := or.increaseAllPendingRewards(donation.DonationAmount)
+		if err != nil {
+			return err
+		}
 		or.state.Donations = append(or.state.Donations, donation)
+		or.publishDonation(donation)
 		log.WithFields(log.Fields{
 			"RewardWei":   donation.DonationAmount,
 			"BlockNumber": donation.Raw.BlockNumber,
@@ -1068,13 +1385,17 @@ func (or *Oracle) handleDonations(donations []*contract.ContractEtherReceived) {
 			"TxHash":      donation.Raw.TxHash.String(),
 		}).Info("[Reward]")
 	}
+	return nil
 }
 
 // Handles a correct block proposal into the pool
-func (or *Oracle) handleCorrectBlockProposal(block SummarizedBlock) {
-	or.addSubscription(block.ValidatorIndex, block.WithdrawalAddress, block.ValidatorKey)
-	or.advanceStateMachine(block.ValidatorIndex, ProposalOk)
-	or.increaseAllPendingRewards(block.Reward)
+func (or *Oracle) handleCorrectBlockProposal(block SummarizedBlock) error {
+	or.addSubscription(block.ValidatorIndex, block.WithdrawalAddress, block.ValidatorKey, TransitionCause{Block: block.Block})
+	or.advanceStateMachine(block.ValidatorIndex, ProposalOk, TransitionCause{Block: block.Block})
+	err := or.increaseAllPendingRewards(block.Reward)
+	if err != nil {
+		return err
+	}
 	or.consolidateBalance(block.ValidatorIndex)
 	or.state.ProposedBlocks = append(or.state.ProposedBlocks, block)
 
@@ -1085,12 +1406,13 @@ func (or *Oracle) handleCorrectBlockProposal(block SummarizedBlock) {
 		"RewardWei":  block.Reward,
 		"RewardType": block.RewardType.String(),
 	}).Info("[Reward]")
+	return nil
 }
 
 // Handles the proposal of a block but that has BLS withdrawal keys
-func (or *Oracle) handleBlsCorrectBlockProposal(block SummarizedBlock) {
+func (or *Oracle) handleBlsCorrectBlockProposal(block SummarizedBlock) error {
 	if block.BlockType != OkPoolProposalBlsKeys {
-		log.Fatal("Block type is not OkPoolProposalBlsKeys, BlockType: ", block.BlockType)
+		return errors.New(fmt.Sprintf("block type is not OkPoolProposalBlsKeys, BlockType: %s", block.BlockType.String()))
 	}
 
 	log.WithFields(log.Fields{
@@ -1103,6 +1425,10 @@ func (or *Oracle) handleBlsCorrectBlockProposal(block SummarizedBlock) {
 
 	or.sendRewardToPool(block.Reward)
 	or.state.ProposedBlocks = append(or.state.ProposedBlocks, block)
+
+	// Remembered, so that it is subscribed once it rotates to execution credentials
+	or.rememberBlsProposal(block)
+	return nil
 }
 
 // Handles a manual subscription to the pool, meaning that an event from the smart contract
@@ -1110,22 +1436,22 @@ func (or *Oracle) handleBlsCorrectBlockProposal(block SummarizedBlock) {
 // of the validator accordingly
 func (or *Oracle) handleManualSubscriptions(
 	subsEvents []*contract.ContractSubscribeValidator,
-	vals []*v1.Validator) {
+	vals []*v1.Validator) error {
 
 	// Ensure the subscriptions events are from the same block
 	if len(subsEvents) > 0 {
 		blockReference := subsEvents[0].Raw.BlockNumber
 		for _, donation := range subsEvents {
 			if donation.Raw.BlockNumber != blockReference {
-				log.Fatal("Handling manual subscriptions from different blocks is not possible: ",
-					donation.Raw.BlockNumber, " vs ", blockReference)
+				return errors.New(fmt.Sprintf("handling manual subscriptions from different blocks is not possible: %d vs %d",
+					donation.Raw.BlockNumber, blockReference))
 			}
 		}
 	}
 
 	if len(subsEvents) != len(vals) {
-		log.Fatal("Number of subscriptions events and validators dont match: subs=",
-			len(subsEvents), " vs vals=", len(vals))
+		return errors.New(fmt.Sprintf("number of subscriptions events and validators dont match: subs=%d vs vals=%d",
+			len(subsEvents), len(vals)))
 	}
 
 	for i, sub := range subsEvents {
@@ -1149,8 +1475,8 @@ func (or *Oracle) handleManualSubscriptions(
 		}
 
 		if valIdx != uint64(validator.Index) {
-			log.Fatal("Subscription event validator index doesnt match the validator index. ",
-				valIdx, " vs ", validator.Index)
+			return errors.New(fmt.Sprintf("subscription event validator index doesnt match the validator index: %d vs %d",
+				valIdx, validator.Index))
 		}
 
 		// Subscription received for a validator that cannot subscribe (see states)
@@ -1263,7 +1589,7 @@ func (or *Oracle) handleManualSubscriptions(
 			}).Info("[Subscription]: Validator subscribed ok")
 			or.state.Validators[valIdx].SubscriptionType = Manual
 			or.increaseValidatorPendingRewards(valIdx, collateral)
-			or.advanceStateMachine(valIdx, ManualSubscription)
+			or.advanceStateMachine(valIdx, ManualSubscription, TransitionCause{TxHash: sub.Raw.TxHash.String()})
 			continue
 		}
 
@@ -1278,6 +1604,7 @@ func (or *Oracle) handleManualSubscriptions(
 		// Send the collateral to the pool
 		or.sendRewardToPool(collateral)
 	}
+	return nil
 }
 
 // Handle the unsubscriptions detected as events triggered from the contract for a given block
@@ -1285,22 +1612,22 @@ func (or *Oracle) handleManualSubscriptions(
 // is that the sender matches the withdrawal address of the validator
 func (or *Oracle) handleManualUnsubscriptions(
 	unsubEvents []*contract.ContractUnsubscribeValidator,
-	vals []*v1.Validator) {
+	vals []*v1.Validator) error {
 
 	// Ensure the subscriptions events are from the same block
 	if len(unsubEvents) > 0 {
 		blockReference := unsubEvents[0].Raw.BlockNumber
 		for _, unsub := range unsubEvents {
 			if unsub.Raw.BlockNumber != blockReference {
-				log.Fatal("Handling manual unsubscriptions from different blocks is not possible: ",
-					unsub.Raw.BlockNumber, " vs ", blockReference)
+				return errors.New(fmt.Sprintf("handling manual unsubscriptions from different blocks is not possible: %d vs %d",
+					unsub.Raw.BlockNumber, blockReference))
 			}
 		}
 	}
 
 	if len(unsubEvents) != len(vals) {
-		log.Fatal("Number of unsubscriptions events and validators dont match: ",
-			len(unsubEvents), " vs ", len(vals))
+		return errors.New(fmt.Sprintf("number of unsubscriptions events and validators dont match: %d vs %d",
+			len(unsubEvents), len(vals)))
 	}
 
 	for i, unsub := range unsubEvents {
@@ -1321,8 +1648,8 @@ func (or *Oracle) handleManualUnsubscriptions(
 		}
 
 		if validator.Index != phase0.ValidatorIndex(valIdx) {
-			log.Fatal("Unsubscription event validator index doesnt match the validator index. ",
-				valIdx, " vs ", validator.Index)
+			return errors.New(fmt.Sprintf("unsubscription event validator index doesnt match the validator index: %d vs %d",
+				valIdx, validator.Index))
 		}
 
 		// Unsubscription received for a validator that doesnt have a compatible withdrawal address (0x01 or 0x02)
@@ -1353,8 +1680,11 @@ func (or *Oracle) handleManualUnsubscriptions(
 
 		// After all the checks, we can proceed with the unsubscription
 		if or.isSubscribed(valIdx) {
-			or.advanceStateMachine(valIdx, Unsubscribe)
-			or.increaseAllPendingRewards(or.state.Validators[valIdx].PendingRewardsWei)
+			or.advanceStateMachine(valIdx, Unsubscribe, TransitionCause{TxHash: unsub.Raw.TxHash.String()})
+			err = or.increaseAllPendingRewards(or.state.Validators[valIdx].PendingRewardsWei)
+			if err != nil {
+				return err
+			}
 			or.resetPendingRewards(valIdx)
 			log.WithFields(log.Fields{
 				"BlockNumber":      unsub.Raw.BlockNumber,
@@ -1386,14 +1716,15 @@ func (or *Oracle) handleManualUnsubscriptions(
 			"Sender":           sender,
 		}).Warn("[Unsubscription] Not considered case meaning wrong unsubscription, skipping")
 	}
+	return nil
 }
 
 func (or *Oracle) handleManualBans(
-	banEvents []*contract.ContractBanValidator) {
+	banEvents []*contract.ContractBanValidator) error {
 
 	// Return immediately if there are no ban events. Nothing to process!
 	if len(banEvents) == 0 {
-		return
+		return nil
 	}
 
 	// FIRST: healthy checks, ensure the bans events are okay.
@@ -1402,8 +1733,8 @@ func (or *Oracle) handleManualBans(
 		blockReference := banEvents[0].Raw.BlockNumber
 		for _, ban := range banEvents {
 			if ban.Raw.BlockNumber != blockReference {
-				log.Fatal("Handling manual bans from different blocks is not possible: ",
-					ban.Raw.BlockNumber, " vs ", blockReference)
+				return errors.New(fmt.Sprintf("handling manual bans from different blocks is not possible: %d vs %d",
+					ban.Raw.BlockNumber, blockReference))
 			}
 		}
 	}
@@ -1426,7 +1757,7 @@ func (or *Oracle) handleManualBans(
 			continue
 		}
 
-		or.advanceStateMachine(ban.ValidatorID, ManualBan)
+		or.advanceStateMachine(ban.ValidatorID, ManualBan, TransitionCause{TxHash: ban.Raw.TxHash.String()})
 		totalPending.Add(totalPending, or.state.Validators[ban.ValidatorID].PendingRewardsWei)
 		or.resetPendingRewards(ban.ValidatorID)
 
@@ -1436,27 +1767,28 @@ func (or *Oracle) handleManualBans(
 	// once all the bans have been processed. This should also be only done if banEvents is not empty, thats
 	// why we have the check at the beginning of the function.
 
-	// If totalPending is negative, log a fatal error. We should never have negative rewards to share.
+	// If totalPending is negative, return an error. We should never have negative rewards to share.
 	if totalPending.Cmp(big.NewInt(0)) < 0 {
-		log.Fatal("Total pending rewards is negative. Aborting reward sharing.")
+		return errors.New("total pending rewards is negative, aborting reward sharing")
 	}
 
 	// Only share rewards if totalPending is greater than zero.
 	if totalPending.Cmp(big.NewInt(0)) > 0 {
-		or.increaseAllPendingRewards(totalPending)
+		return or.increaseAllPendingRewards(totalPending)
 	}
+	return nil
 }
 
 func (or *Oracle) handleManualUnbans(
-	unbanEvents []*contract.ContractUnbanValidator) {
+	unbanEvents []*contract.ContractUnbanValidator) error {
 
 	// FIRST: healthy checks, ensure the unbans events are okay.
 	if len(unbanEvents) > 0 {
 		blockReference := unbanEvents[0].Raw.BlockNumber
 		for _, ban := range unbanEvents {
 			if ban.Raw.BlockNumber != blockReference {
-				log.Fatal("Handling manual unbans from different blocks is not possible: ",
-					ban.Raw.BlockNumber, " vs ", blockReference)
+				return errors.New(fmt.Sprintf("handling manual unbans from different blocks is not possible: %d vs %d",
+					ban.Raw.BlockNumber, blockReference))
 			}
 		}
 	}
@@ -1476,32 +1808,37 @@ func (or *Oracle) handleManualUnbans(
 			continue
 		}
 
-		or.advanceStateMachine(unban.ValidatorID, ManualUnban)
+		or.advanceStateMachine(unban.ValidatorID, ManualUnban, TransitionCause{TxHash: unban.Raw.TxHash.String()})
 	}
+	return nil
 }
 
 // Banning a validator implies sharing its pending rewards among the rest
 // of the validators and setting its pending to zero.
-func (or *Oracle) handleBanValidator(block SummarizedBlock) {
+func (or *Oracle) handleBanValidator(block SummarizedBlock) error {
 	// First of all advance the state machine, so the banned validator is not
 	// considered for the pending reward share
-	or.advanceStateMachine(block.ValidatorIndex, ProposalWrongFee)
-	or.increaseAllPendingRewards(or.state.Validators[block.ValidatorIndex].PendingRewardsWei)
+	or.advanceStateMachine(block.ValidatorIndex, ProposalWrongFee, TransitionCause{Block: block.Block})
+	err := or.increaseAllPendingRewards(or.state.Validators[block.ValidatorIndex].PendingRewardsWei)
+	if err != nil {
+		return err
+	}
 	or.resetPendingRewards(block.ValidatorIndex)
 
 	// Store the proof of the wrong fee block. Reason why it was banned
 	or.state.WrongFeeBlocks = append(or.state.WrongFeeBlocks, block)
+	return nil
 }
 
 // Handles the case of a validator that has missed a block, only to be used
 // with subscribed validators into the pool
 func (or *Oracle) handleMissedBlock(block SummarizedBlock) {
-	or.advanceStateMachine(block.ValidatorIndex, ProposalMissed)
+	or.advanceStateMachine(block.ValidatorIndex, ProposalMissed, TransitionCause{Block: block.Block})
 	or.state.MissedBlocks = append(or.state.MissedBlocks, block)
 }
 
 // Subscribes a validator index with a given withdrawal address and validator key
-func (or *Oracle) addSubscription(valIndex uint64, withdrawalAddress string, validatorKey string) {
+func (or *Oracle) addSubscription(valIndex uint64, withdrawalAddress string, validatorKey string, cause TransitionCause) {
 	validator, found := or.state.Validators[valIndex]
 	if !found {
 		// If not found and not manually subscribed, we trigger the AutoSubscription event
@@ -1518,7 +1855,7 @@ func (or *Oracle) addSubscription(valIndex uint64, withdrawalAddress string, val
 		or.state.Validators[valIndex] = validator
 
 		// And update it state according to the event
-		or.advanceStateMachine(valIndex, AutoSubscription)
+		or.advanceStateMachine(valIndex, AutoSubscription, cause)
 
 		// If subscription is new its auto
 		or.state.Validators[valIndex].SubscriptionType = Auto
@@ -1526,7 +1863,7 @@ func (or *Oracle) addSubscription(valIndex uint64, withdrawalAddress string, val
 		// If we found the validator and is not subscribed, advance the state machine
 		// Most likely it was subscribed before, then unsubscribed and now auto subscribes
 		if !or.isSubscribed(valIndex) {
-			or.advanceStateMachine(valIndex, AutoSubscription)
+			or.advanceStateMachine(valIndex, AutoSubscription, cause)
 
 			if !or.isBanned(valIndex) {
 				// If it wasnt subscribed before, with this proposal its now auto
@@ -1545,6 +1882,7 @@ func (or *Oracle) consolidateBalance(valIndex uint64) {
 
 	or.state.Validators[valIndex].AccumulatedRewardsWei.Add(or.state.Validators[valIndex].AccumulatedRewardsWei, or.state.Validators[valIndex].PendingRewardsWei)
 	or.state.Validators[valIndex].PendingRewardsWei = big.NewInt(0)
+	or.trackLiabilities(new(big.Int).Neg(beforePending), beforePending, nil)
 
 	log.WithFields(log.Fields{
 		"AccumulatedAfter":  or.state.Validators[valIndex].AccumulatedRewardsWei,
@@ -1567,227 +1905,94 @@ func (or *Oracle) getEligibleValidators() []phase0.ValidatorIndex {
 	return eligibleValidators
 }
 
-func (or *Oracle) determineRewardMethod() string {
-	if forkSlot, found := SlotElectraFork[or.cfg.Network]; found && or.state.NextSlotToProcess >= forkSlot {
-		return RewardMethodElectra
+// Returns the reward distributor to use at the slot being processed
+func (or *Oracle) getRewardDistributor() (RewardDistributor, error) {
+	if or.forcedRewardDistributor != nil {
+		return or.forcedRewardDistributor, nil
 	}
-	if forkSlot, found := SlotFork1[or.cfg.Network]; found && or.state.NextSlotToProcess >= forkSlot {
-		return RewardMethodFork1
-	}
-	if _, found := SlotFork1[or.cfg.Network]; found {
-		return RewardMethodPreFork1
-	}
-	log.Fatal("Network not found in forks list: ", or.cfg.Network)
-	return ""
-}
-
-// Handles the reward distribution for the electra method. The rewards are distributed
-// according to the Effective Balance of each validator. The pool cut is added to the total fees.
-func (or *Oracle) handleElectraRewardDistribution(
-	reward, poolCut *big.Int,
-	eligibleValidators []phase0.ValidatorIndex,
-) (map[uint64]*big.Int, *big.Int, error) {
-	toDistribute := new(big.Int).Sub(reward, poolCut)
-
-	//get onchain validators balance at current processing slot
-	validatorsMap, err := or.getSetOfValidators(eligibleValidators, strconv.FormatUint(or.state.NextSlotToProcess, 10))
+	distributor, err := GetRewardDistributor(or.cfg.Network, or.state.NextSlotToProcess)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to fetch validator balances: %w", err)
-	}
-
-	// total balance of all validators, needed to compute what each validator gets
-	totalBalance := new(big.Int)
-	for _, v := range validatorsMap {
-		totalBalance.Add(totalBalance, big.NewInt(int64(v.Validator.EffectiveBalance)))
-	}
-	if totalBalance.Sign() == 0 {
-		return nil, nil, fmt.Errorf("total balance is zero")
-	}
-
-	// perValidatorRewards is a map of validator index to the amount of rewards they will receive
-	perValidatorRewards := make(map[uint64]*big.Int)
-
-	// we keep track of the total amount of rewards distributed to validators to later check everything is correct
-	totalDistributed := new(big.Int)
-
-	// iterate over all validators and calculate their share of the rewards
-	// we use the balance of each validator to calculate their share
-	for _, v := range validatorsMap {
-		share := new(big.Int).Mul(toDistribute, big.NewInt(int64(v.Validator.EffectiveBalance)))
-		share.Div(share, totalBalance)
-
-		log.WithFields(log.Fields{
-			"ValidatorIndex":            v.Index,
-			"ValidatorEffectiveBalance": v.Validator.EffectiveBalance,
-			"TotalEffectiveBalance":     totalBalance,
-			"RewardToDistribute":        toDistribute,
-			"Share":                     share,
-		}).Trace("[ELECTRA] Validator share")
-
-		perValidatorRewards[uint64(v.Index)] = share
-		totalDistributed.Add(totalDistributed, share)
-	}
-
-	// there will be a small amount of wei that cannot be distributed evenly, it goes to the pool
-	remainder := new(big.Int).Sub(toDistribute, totalDistributed)
-	totalFees := new(big.Int).Add(poolCut, remainder)
-
-	// Check that the "total amount of rewards distributed to validators" + "pool fees" = total reward
-	sum := new(big.Int).Add(totalDistributed, totalFees)
-	if sum.Cmp(reward) != 0 {
-		log.WithFields(log.Fields{
-			"SumValidatorRewards": totalDistributed,
-			"TotalFees":           totalFees,
-			"TotalExpectedReward": reward,
-		}).Fatal("Electra fork: Reward mismatch — rewards to validators + pool fees ≠ total reward")
-	}
-
-	return perValidatorRewards, totalFees, nil
-}
-
-// Distributes the rewards for the fork1 method. The rewards are distributed evenly among all
-// eligible validators. The pool cut is added to the total fees.
-func (or *Oracle) handleFork1RewardDistribution(
-	reward, poolCut, numEligibleValidators *big.Int,
-) (totalFees, perValidatorReward *big.Int, err error) {
-	toShareAllValidators := new(big.Int).Sub(reward, poolCut)
-	perValidatorReward = new(big.Int).Div(toShareAllValidators, numEligibleValidators)
-	remainder := new(big.Int).Mod(toShareAllValidators, numEligibleValidators)
-	totalFees = new(big.Int).Add(poolCut, remainder)
-
-	// Consistency check
-	sum := new(big.Int).Add(new(big.Int).Mul(perValidatorReward, numEligibleValidators), totalFees)
-	if sum.Cmp(reward) != 0 {
-		log.WithFields(log.Fields{
-			"perValidatorReward":    perValidatorReward,
-			"totalFees":             totalFees,
-			"numEligibleValidators": numEligibleValidators,
-		}).Fatal("Fork1: Reward mismatch — rewards to validators + pool fees ≠ total reward")
+		return nil, errors.Wrap(err, "could not get reward distributor")
 	}
-
-	return totalFees, perValidatorReward, nil
-}
-
-func (or *Oracle) handlePreFork1RewardDistribution(
-	reward, poolCut, aux, over, numEligibleValidators *big.Int,
-) (totalFees, perValidatorReward *big.Int, err error) {
-	log.WithFields(log.Fields{
-		"SlotFork":           SlotFork1[or.cfg.Network],
-		"Slot":               or.state.NextSlotToProcess,
-		"Network":            or.cfg.Network,
-		"FeePercentOver1000": or.cfg.PoolFeesPercentOver10000,
-		"Method":             "PreFork1",
-	}).Debug("Calculating rewards")
-
-	remainder1 := new(big.Int).Mod(aux, over)
-
-	toShareAllValidators := new(big.Int).Sub(reward, poolCut)
-	toShareAllValidators.Sub(toShareAllValidators, remainder1)
-
-	perValidatorReward = new(big.Int).Div(toShareAllValidators, numEligibleValidators)
-	remainder2 := new(big.Int).Mod(toShareAllValidators, numEligibleValidators)
-
-	totalFees = new(big.Int).Add(poolCut, remainder1)
-	totalFees.Add(totalFees, remainder2)
-
-	sum := new(big.Int).Add(new(big.Int).Mul(perValidatorReward, numEligibleValidators), totalFees)
-	if sum.Cmp(reward) != 0 {
-		log.WithFields(log.Fields{
-			"perValidatorReward":    perValidatorReward,
-			"totalFees":             totalFees,
-			"numEligibleValidators": numEligibleValidators,
-		}).Fatal("PreFork1: Reward mismatch — rewards to validators + pool fees ≠ total reward")
-	}
-
-	return totalFees, perValidatorReward, nil
+	return distributor, nil
 }
 
 // Increases the pending rewards of all validators, and gives the pool owner a cut
 // of said rewards. Note that pending rewards cant be claimed until a block is proposed
 // by the validator. But the pool owner can claim the pool cut at any time, so they are
 // added as accumulated rewards.
-// The reward will be shared differently depending on the network and the slot. Electra fork
-// makes the rewards proportional to the balance of the validators.
-func (or *Oracle) increaseAllPendingRewards(reward *big.Int) {
+// The reward will be shared differently depending on the network and the slot, according
+// to the registered reward distributors. See rewardDistributors.
+func (or *Oracle) increaseAllPendingRewards(reward *big.Int) error {
 	eligibleValidators := or.getEligibleValidators()
-	numEligibleValidators := big.NewInt(int64(len(eligibleValidators)))
 
 	if len(eligibleValidators) == 0 {
 		log.Warn("No validators are eligible to receive rewards, pool fees address will receive all")
 		or.state.PoolAccumulatedFees.Add(or.state.PoolAccumulatedFees, reward)
-		return
+		or.trackLiabilities(nil, nil, reward)
+		return nil
 	}
 
 	if or.state.PoolFeesPercentOver10000 > 100*100 {
-		log.Fatal("Pool fees percent cannot be greater than 100% (10000) value: ", or.state.PoolFeesPercentOver10000)
+		return errors.New(fmt.Sprintf("pool fees percent cannot be greater than 100%% (10000) value: %d",
+			or.state.PoolFeesPercentOver10000))
 	}
 
-	over := big.NewInt(100 * 100)
-	aux := big.NewInt(0).Mul(reward, big.NewInt(int64(or.state.PoolFeesPercentOver10000)))
-	poolCut := big.NewInt(0).Div(aux, over)
-
-	method := or.determineRewardMethod()
-
-	var totalFees *big.Int
-	var perValidatorReward *big.Int
-	var electraRewards map[uint64]*big.Int
-	var err error
-
-	switch method {
-	case RewardMethodElectra:
-		electraRewards, totalFees, err = or.handleElectraRewardDistribution(reward, poolCut, eligibleValidators)
-	case RewardMethodFork1:
-		totalFees, perValidatorReward, err = or.handleFork1RewardDistribution(reward, poolCut, numEligibleValidators)
-	case RewardMethodPreFork1:
-		totalFees, perValidatorReward, err = or.handlePreFork1RewardDistribution(reward, poolCut, aux, over, numEligibleValidators)
-	default:
-		log.Fatal("Unknown reward distribution method")
+	distributor, err := or.getRewardDistributor()
+	if err != nil {
+		return err
+	}
+	input := &DistributionInput{
+		Reward:                   reward,
+		PoolFeesPercentOver10000: or.state.PoolFeesPercentOver10000,
+		Slot:                     or.state.NextSlotToProcess,
+		EligibleValidators:       eligibleValidators,
+		GetSetOfValidators:       or.getSetOfValidators,
 	}
 
+	perValidatorRewards, totalFees, err := distributor.Distribute(input)
 	if err != nil {
-		log.Fatal("Reward distribution failed: ", err)
+		return errors.Wrap(err, "reward distribution failed")
 	}
 
-	or.state.PoolAccumulatedFees.Add(or.state.PoolAccumulatedFees, totalFees)
-
-	// Apply rewards. Electra rewards are returned as a map where the key is the validator index
-	// because the rewards are proportional to the balance of the validator. The rest of the forks
-	// are just a fixed amount per validator.
-	switch method {
-	case RewardMethodElectra:
-		log.WithFields(log.Fields{
-			"AmountEligibleValidators": numEligibleValidators,
-			"PoolFeePercent":           fmt.Sprintf("%.2f%%", float64(or.state.PoolFeesPercentOver10000)/100),
-			"PoolFeesWei":              totalFees,
-			"TotalRewardWei":           reward,
-		}).Info("[PECTRA] Increasing pending rewards of eligible validators")
-		for idx, reward := range electraRewards {
-			or.state.Validators[idx].PendingRewardsWei.Add(
-				or.state.Validators[idx].PendingRewardsWei, reward,
-			)
-		}
-	case RewardMethodFork1, RewardMethodPreFork1:
+	err = distributor.CheckInvariant(input, perValidatorRewards, totalFees)
+	if err != nil {
 		log.WithFields(log.Fields{
-			"AmountEligibleValidators": numEligibleValidators,
-			"PoolFeePercent":           fmt.Sprintf("%.2f%%", float64(or.state.PoolFeesPercentOver10000)/100),
-			"RewardPerValidatorWei":    perValidatorReward,
+			"Method":                   distributor.Method(),
+			"Slot":                     or.state.NextSlotToProcess,
+			"AmountEligibleValidators": len(eligibleValidators),
 			"PoolFeesWei":              totalFees,
 			"TotalRewardWei":           reward,
-		}).Info("Increasing pending rewards of eligible validators")
-		for _, eligibleIndex := range eligibleValidators {
-			or.state.Validators[uint64(eligibleIndex)].PendingRewardsWei.Add(
-				or.state.Validators[uint64(eligibleIndex)].PendingRewardsWei, perValidatorReward,
-			)
-		}
-	default:
-		log.Fatal("Unknown reward distribution method: ", method)
+		}).Error("Reward distribution invariant violated")
+		return errors.Wrap(err, "reward distribution invariant violated")
 	}
+
+	or.state.PoolAccumulatedFees.Add(or.state.PoolAccumulatedFees, totalFees)
+
+	log.WithFields(log.Fields{
+		"Method":                   distributor.Method(),
+		"AmountEligibleValidators": len(eligibleValidators),
+		"PoolFeePercent":           fmt.Sprintf("%.2f%%", float64(or.state.PoolFeesPercentOver10000)/100),
+		"PoolFeesWei":              totalFees,
+		"TotalRewardWei":           reward,
+	}).Info("Increasing pending rewards of eligible validators")
+
+	totalPending := big.NewInt(0)
+	for idx, reward := range perValidatorRewards {
+		or.state.Validators[idx].PendingRewardsWei.Add(
+			or.state.Validators[idx].PendingRewardsWei, reward,
+		)
+		totalPending.Add(totalPending, reward)
+	}
+	or.trackLiabilities(totalPending, nil, totalFees)
+	return nil
 }
 
 // Increases the pending rewards of a given validator index.
 func (or *Oracle) increaseValidatorPendingRewards(valIndex uint64, reward *big.Int) {
 	beforePending := new(big.Int).Set(or.state.Validators[valIndex].PendingRewardsWei)
 	or.state.Validators[valIndex].PendingRewardsWei.Add(or.state.Validators[valIndex].PendingRewardsWei, reward)
+	or.trackLiabilities(reward, nil, nil)
 
 	log.WithFields(log.Fields{
 		"PendingAfter":  or.state.Validators[valIndex].PendingRewardsWei,
@@ -1802,6 +2007,7 @@ func (or *Oracle) increaseValidatorAccumulatedRewards(valIndex uint64, reward *b
 	accumulatedBefore := new(big.Int).Set(or.state.Validators[valIndex].AccumulatedRewardsWei)
 
 	or.state.Validators[valIndex].AccumulatedRewardsWei.Add(or.state.Validators[valIndex].AccumulatedRewardsWei, reward)
+	or.trackLiabilities(nil, reward, nil)
 
 	log.WithFields(log.Fields{
 		"AccumulatedAfter":  or.state.Validators[valIndex].AccumulatedRewardsWei,
@@ -1816,6 +2022,7 @@ func (or *Oracle) sendRewardToPool(reward *big.Int) {
 
 	poolAccumulatedBefore := new(big.Int).Set(or.state.PoolAccumulatedFees)
 	or.state.PoolAccumulatedFees.Add(or.state.PoolAccumulatedFees, reward)
+	or.trackLiabilities(nil, nil, reward)
 
 	log.WithFields(log.Fields{
 		"PoolAccumulatedBefore": poolAccumulatedBefore,
@@ -1830,6 +2037,7 @@ func (or *Oracle) resetPendingRewards(valIndex uint64) {
 		"PendingRewardsBefore": or.state.Validators[valIndex].PendingRewardsWei,
 		"ValIndex":             valIndex,
 	}).Debug("Resetting pending rewards")
+	or.trackLiabilities(new(big.Int).Neg(or.state.Validators[valIndex].PendingRewardsWei), nil, nil)
 	or.state.Validators[valIndex].PendingRewardsWei = big.NewInt(0)
 }
 
@@ -1872,7 +2080,7 @@ func GetWithdrawalAndType(validator *v1.Validator) (string, WithdrawalType) {
 
 // See the spec for state diagram with states and transitions. This tracks all the different
 // states and state transitions that a given validator can have from the oracle point of view
-func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
+func (or *Oracle) advanceStateMachine(valIndex uint64, event Event, cause TransitionCause) {
 
 	// Safety check, if the validator does not exist, we log it and return
 	validator, exists := or.state.Validators[valIndex]
@@ -1895,7 +2103,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = Active
+			or.setValidatorStatus(valIndex, event, Active, cause)
 		case ProposalWrongFee:
 			log.WithFields(log.Fields{
 				"Event":          "ProposalWrongFee",
@@ -1903,7 +2111,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = Banned
+			or.setValidatorStatus(valIndex, event, Banned, cause)
 		case ProposalMissed:
 			log.WithFields(log.Fields{
 				"Event":          "ProposalMissed",
@@ -1911,7 +2119,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = YellowCard
+			or.setValidatorStatus(valIndex, event, YellowCard, cause)
 		case Unsubscribe:
 			log.WithFields(log.Fields{
 				"Event":          "Unsubscribe",
@@ -1919,7 +2127,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = NotSubscribed
+			or.setValidatorStatus(valIndex, event, NotSubscribed, cause)
 		case ManualBan:
 			log.WithFields(log.Fields{
 				"Event":          "ManualBan",
@@ -1927,7 +2135,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = Banned
+			or.setValidatorStatus(valIndex, event, Banned, cause)
 
 		}
 	case YellowCard:
@@ -1939,7 +2147,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = Active
+			or.setValidatorStatus(valIndex, event, Active, cause)
 		case ProposalWrongFee:
 			log.WithFields(log.Fields{
 				"Event":          "ProposalWrongFee",
@@ -1947,7 +2155,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = Banned
+			or.setValidatorStatus(valIndex, event, Banned, cause)
 		case ProposalMissed:
 			log.WithFields(log.Fields{
 				"Event":          "ProposalMissed",
@@ -1955,7 +2163,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = RedCard
+			or.setValidatorStatus(valIndex, event, RedCard, cause)
 		case Unsubscribe:
 			log.WithFields(log.Fields{
 				"Event":          "Unsubscribe",
@@ -1963,7 +2171,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = NotSubscribed
+			or.setValidatorStatus(valIndex, event, NotSubscribed, cause)
 		case ManualBan:
 			log.WithFields(log.Fields{
 				"Event":          "ManualBan",
@@ -1971,7 +2179,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = Banned
+			or.setValidatorStatus(valIndex, event, Banned, cause)
 		}
 	case RedCard:
 		switch event {
@@ -1982,7 +2190,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = YellowCard
+			or.setValidatorStatus(valIndex, event, YellowCard, cause)
 		case ProposalWrongFee:
 			log.WithFields(log.Fields{
 				"Event":          "ProposalWrongFee",
@@ -1990,7 +2198,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = Banned
+			or.setValidatorStatus(valIndex, event, Banned, cause)
 		case ProposalMissed:
 			log.WithFields(log.Fields{
 				"Event":          "ProposalMissed",
@@ -1998,7 +2206,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = RedCard
+			or.setValidatorStatus(valIndex, event, RedCard, cause)
 		case Unsubscribe:
 			log.WithFields(log.Fields{
 				"Event":          "Unsubscribe",
@@ -2006,7 +2214,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = NotSubscribed
+			or.setValidatorStatus(valIndex, event, NotSubscribed, cause)
 		case ManualBan:
 			log.WithFields(log.Fields{
 				"Event":          "ManualBan",
@@ -2014,7 +2222,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = Banned
+			or.setValidatorStatus(valIndex, event, Banned, cause)
 		}
 	case NotSubscribed:
 		switch event {
@@ -2025,7 +2233,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = Active
+			or.setValidatorStatus(valIndex, event, Active, cause)
 		case AutoSubscription:
 			log.WithFields(log.Fields{
 				"Event":          "AutoSubscription",
@@ -2033,7 +2241,7 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = Active
+			or.setValidatorStatus(valIndex, event, Active, cause)
 		}
 	// A validator could return to the state it was after being banned, but we
 	// return it always to the Active state for the sake of simplicity.
@@ -2046,7 +2254,30 @@ func (or *Oracle) advanceStateMachine(valIndex uint64, event Event) {
 				"ValidatorIndex": valIndex,
 				"Slot":           or.state.NextSlotToProcess,
 			}).Info("Validator state change")
-			or.state.Validators[valIndex].ValidatorStatus = Active
+			or.setValidatorStatus(valIndex, event, Active, cause)
 		}
 	}
 }
+
+// Sets the status of the validator, storing the transition. Events that keep the status
+// are not stored, unless they are bans or unsubscriptions
+func (or *Oracle) setValidatorStatus(valIndex uint64, event Event, status ValidatorStatus, cause TransitionCause) {
+	validator := or.state.Validators[valIndex]
+	if validator.ValidatorStatus == status &&
+		event != ManualBan && event != ProposalWrongFee && event != Unsubscribe {
+		return
+	}
+	if or.state.ValidatorTransitions == nil {
+		or.state.ValidatorTransitions = make(map[uint64][]StateTransition)
+	}
+	transition := StateTransition{
+		Slot:            or.state.NextSlotToProcess,
+		Event:           event,
+		From:            validator.ValidatorStatus,
+		To:              status,
+		TransitionCause: cause,
+	}
+	or.state.ValidatorTransitions[valIndex] = append(or.state.ValidatorTransitions[valIndex], transition)
+	validator.ValidatorStatus = status
+	or.publish(ValidatorStatusEvent, transition.Slot, &valIndex, validator.WithdrawalAddress, &transition)
+}
@@ -202,14 +202,14 @@ func Test_SaveReadToFromJson(t *testing.T) {
 	}
 	oracle := NewOracle(config)
 
-	oracle.addSubscription(uint64(3), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000")
-	oracle.addSubscription(uint64(6434), "0x2000000000000000000000000000000000000000", "0x2000000000000000000000000000000000000000")
+	oracle.addSubscription(uint64(3), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000", TransitionCause{})
+	oracle.addSubscription(uint64(6434), "0x2000000000000000000000000000000000000000", "0x2000000000000000000000000000000000000000", TransitionCause{})
 
 	oracle.FreezeCheckpoint()
 
-	oracle.addSubscription(uint64(3), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000")
-	oracle.addSubscription(uint64(6434), "0x2000000000000000000000000000000000000000", "0x2000000000000000000000000000000000000000")
-	oracle.addSubscription(uint64(643344), "0x2000000000000000000000000000000000000000", "0x2000000000000000000000000000000000000000")
+	oracle.addSubscription(uint64(3), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000", TransitionCause{})
+	oracle.addSubscription(uint64(6434), "0x2000000000000000000000000000000000000000", "0x2000000000000000000000000000000000000000", TransitionCause{})
+	oracle.addSubscription(uint64(643344), "0x2000000000000000000000000000000000000000", "0x2000000000000000000000000000000000000000", TransitionCause{})
 
 	oracle.FreezeCheckpoint()
 
@@ -231,6 +231,9 @@ func Test_SaveReadToFromJson(t *testing.T) {
 	}
 	oracle.state.SubscriptionEvents = subs
 
+	// Keep assets and liabilities balanced, otherwise the state cant be loaded
+	oracle.sendRewardToPool(big.NewInt(1000))
+
 	defer os.Remove(filepath.Join(StateFolder, StateJsonName))
 	defer os.RemoveAll(StateFolder)
 	oracle.SaveToJson(false)
@@ -380,13 +383,24 @@ func Test_FreezeCheckpoint(t *testing.T) {
 	require.Equal(t, "0xd9a1eee574026532cddccbcce6320c0600f370a7c64ce30c5eafc63357449940", oracle.state.CommitedStates[commitedSlot].MerkleRoot)
 
 	// Ensure proofs and leafs are correct
-	require.Equal(t, oracle.state.CommitedStates[commitedSlot].Proofs["0xfee0000000000000000000000000000000000000"], []string{"0x8bfb8acff6772a60d6641cb854587bb2b6f2100391fbadff2c34be0b8c20a0cc", "0x27205dd4c642acd1b1352617df2c4f410e20ff3fd6f3e3efddee9cea044921f8"})
-	require.Equal(t, oracle.state.CommitedStates[commitedSlot].Proofs["0x1000000000000000000000000000000000000000"], []string{"0xaaf838df9c8d5cec6ed77fcbc2cace945e8f2078eede4a0bb7164818d425f24d", "0x27205dd4c642acd1b1352617df2c4f410e20ff3fd6f3e3efddee9cea044921f8"})
-	require.Equal(t, oracle.state.CommitedStates[commitedSlot].Proofs["0x2000000000000000000000000000000000000000"], []string{"0xd643163144dcba353b4d27c50939b3d11133bd3c6916092de059d07353b4cb5f", "0xda53f5dd3e17f66f4a35c9c9d5fd27c094fa4249e2933fb819ac724476dc9ae1"})
+	expectedProofs := map[string][]string{
+		"0xfee0000000000000000000000000000000000000": {"0x8bfb8acff6772a60d6641cb854587bb2b6f2100391fbadff2c34be0b8c20a0cc", "0x27205dd4c642acd1b1352617df2c4f410e20ff3fd6f3e3efddee9cea044921f8"},
+		"0x1000000000000000000000000000000000000000": {"0xaaf838df9c8d5cec6ed77fcbc2cace945e8f2078eede4a0bb7164818d425f24d", "0x27205dd4c642acd1b1352617df2c4f410e20ff3fd6f3e3efddee9cea044921f8"},
+		"0x2000000000000000000000000000000000000000": {"0xd643163144dcba353b4d27c50939b3d11133bd3c6916092de059d07353b4cb5f", "0xda53f5dd3e17f66f4a35c9c9d5fd27c094fa4249e2933fb819ac724476dc9ae1"},
+	}
+	for address, expectedProof := range expectedProofs {
+		_, proof, found, err := oracle.Proof(commitedSlot, address)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, expectedProof, proof)
+	}
 
-	require.Equal(t, oracle.state.CommitedStates[commitedSlot].Leafs["0xfee0000000000000000000000000000000000000"], RawLeaf{"0xfee0000000000000000000000000000000000000", big.NewInt(0)})
-	require.Equal(t, oracle.state.CommitedStates[commitedSlot].Leafs["0x1000000000000000000000000000000000000000"], RawLeaf{"0x1000000000000000000000000000000000000000", big.NewInt(1000000000000000000)})
-	require.Equal(t, oracle.state.CommitedStates[commitedSlot].Leafs["0x2000000000000000000000000000000000000000"], RawLeaf{"0x2000000000000000000000000000000000000000", big.NewInt(4000000000000000000)})
+	require.Equal(t, []RawLeaf{
+		{"0xfee0000000000000000000000000000000000000", big.NewInt(0)},
+		{"0x1000000000000000000000000000000000000000", big.NewInt(1000000000000000000)},
+		{"0x2000000000000000000000000000000000000000", big.NewInt(4000000000000000000)},
+	}, oracle.state.CommitedStates[commitedSlot].OrderedLeafs)
+	require.Nil(t, oracle.state.CommitedStates[commitedSlot].Proofs)
 
 	// Ensure LatestCommitedState contains a deep copy of the validators and not just a reference
 	// This is very important since otherwise they will be modified when the state is modified
@@ -417,9 +431,9 @@ func Test_LatestCommitedSlot_LatestCommitedState(t *testing.T) {
 
 	// Add state slot = 100
 	oracle.state.LatestProcessedSlot = 100
-	oracle.addSubscription(uint64(10), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000")
-	oracle.addSubscription(uint64(11), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000")
-	oracle.addSubscription(uint64(12), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000")
+	oracle.addSubscription(uint64(10), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000", TransitionCause{})
+	oracle.addSubscription(uint64(11), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000", TransitionCause{})
+	oracle.addSubscription(uint64(12), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000", TransitionCause{})
 	oracle.FreezeCheckpoint()
 	slot, stateExistst = oracle.LatestCommitedSlot()
 	state = oracle.LatestCommitedState()
@@ -430,9 +444,9 @@ func Test_LatestCommitedSlot_LatestCommitedState(t *testing.T) {
 
 	// Add state slot = 200
 	oracle.state.LatestProcessedSlot = 200
-	oracle.addSubscription(uint64(13), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000")
-	oracle.addSubscription(uint64(14), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000")
-	oracle.addSubscription(uint64(15), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000")
+	oracle.addSubscription(uint64(13), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000", TransitionCause{})
+	oracle.addSubscription(uint64(14), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000", TransitionCause{})
+	oracle.addSubscription(uint64(15), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000", TransitionCause{})
 	oracle.FreezeCheckpoint()
 	slot, stateExistst = oracle.LatestCommitedSlot()
 	state = oracle.LatestCommitedState()
@@ -458,9 +472,9 @@ func Test_IsOracleInSyncWithChain(t *testing.T) {
 
 	// Add a state
 	oracle.state.LatestProcessedSlot = 100
-	oracle.addSubscription(uint64(10), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000")
-	oracle.addSubscription(uint64(11), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000")
-	oracle.addSubscription(uint64(12), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000")
+	oracle.addSubscription(uint64(10), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000", TransitionCause{})
+	oracle.addSubscription(uint64(11), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000", TransitionCause{})
+	oracle.addSubscription(uint64(12), "0x1000000000000000000000000000000000000000", "0x1000000000000000000000000000000000000000", TransitionCause{})
 	oracle.FreezeCheckpoint()
 
 	// In sync
@@ -491,11 +505,11 @@ func Test_GetUniqueDepositFromState(t *testing.T) {
 	})
 
 	// Subscribe 3 validators with no balance
-	oracle1.addSubscription(1, "0xa000000000000000000000000000000000000000", "0x")
-	oracle1.addSubscription(2, "0xa000000000000000000000000000000000000000", "0x")
-	oracle1.addSubscription(3, "0xa000000000000000000000000000000000000000", "0x")
-	oracle1.addSubscription(4, "0xb000000000000000000000000000000000000000", "0x")
-	oracle1.addSubscription(5, "0xc000000000000000000000000000000000000000", "0x")
+	oracle1.addSubscription(1, "0xa000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle1.addSubscription(2, "0xa000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle1.addSubscription(3, "0xa000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle1.addSubscription(4, "0xb000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle1.addSubscription(5, "0xc000000000000000000000000000000000000000", "0x", TransitionCause{})
 
 	unique1 := oracle1.GetUniqueWithdrawalAddresses()
 	require.Equal(t, 4, len(unique1))
@@ -511,16 +525,16 @@ func Test_GetUniqueDepositFromState(t *testing.T) {
 	})
 
 	// Subscribe 3 validators with no balance
-	oracle2.addSubscription(1, "0xa000000000000000000000000000000000000000", "0x")
-	oracle2.addSubscription(2, "0xa000000000000000000000000000000000000000", "0x")
-	oracle2.addSubscription(3, "0xa000000000000000000000000000000000000000", "0x")
-	oracle2.addSubscription(4, "0xb000000000000000000000000000000000000000", "0x")
-	oracle2.addSubscription(5, "0xb000000000000000000000000000000000000000", "0x")
-	oracle2.addSubscription(6, "0xc000000000000000000000000000000000000000", "0x")
-	oracle2.addSubscription(7, "0xc000000000000000000000000000000000000000", "0x")
-	oracle2.addSubscription(8, "0xd000000000000000000000000000000000000000", "0x")
-	oracle2.addSubscription(9, "0xd000000000000000000000000000000000000000", "0x")
-	oracle2.addSubscription(9, "0xc000000000000000000000000000000000000000", "0x")
+	oracle2.addSubscription(1, "0xa000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle2.addSubscription(2, "0xa000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle2.addSubscription(3, "0xa000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle2.addSubscription(4, "0xb000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle2.addSubscription(5, "0xb000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle2.addSubscription(6, "0xc000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle2.addSubscription(7, "0xc000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle2.addSubscription(8, "0xd000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle2.addSubscription(9, "0xd000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle2.addSubscription(9, "0xc000000000000000000000000000000000000000", "0x", TransitionCause{})
 
 	unique2 := oracle2.GetUniqueWithdrawalAddresses()
 	require.Equal(t, 5, len(unique2))
@@ -536,11 +550,11 @@ func Test_GetUniqueDepositFromState(t *testing.T) {
 	})
 
 	// Subscribe 3 validators with no balance
-	oracle3.addSubscription(1, "0x1000000000000000000000000000000000000000", "0x")
-	oracle3.addSubscription(2, "0x1000000000000000000000000000000000000000", "0x")
-	oracle3.addSubscription(3, "0x1000000000000000000000000000000000000000", "0x")
-	oracle3.addSubscription(4, "0x1000000000000000000000000000000000000000", "0x")
-	oracle3.addSubscription(5, "0x1000000000000000000000000000000000000000", "0x")
+	oracle3.addSubscription(1, "0x1000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle3.addSubscription(2, "0x1000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle3.addSubscription(3, "0x1000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle3.addSubscription(4, "0x1000000000000000000000000000000000000000", "0x", TransitionCause{})
+	oracle3.addSubscription(5, "0x1000000000000000000000000000000000000000", "0x", TransitionCause{})
 
 	unique3 := oracle3.GetUniqueWithdrawalAddresses()
 	require.Equal(t, 2, len(unique3))
@@ -567,7 +581,7 @@ func Test_Oracle_CanValidatorSubscribeToPool(t *testing.T) {
 
 func Test_addSubscription_1(t *testing.T) {
 	oracle := NewOracle(&Config{Network: "mainnet"})
-	oracle.addSubscription(10, "0x", "0x")
+	oracle.addSubscription(10, "0x", "0x", TransitionCause{})
 	oracle.increaseAllPendingRewards(big.NewInt(100))
 	oracle.consolidateBalance(10)
 	oracle.increaseAllPendingRewards(big.NewInt(200))
@@ -576,14 +590,14 @@ func Test_addSubscription_1(t *testing.T) {
 	require.Equal(t, Auto, oracle.state.Validators[10].SubscriptionType)
 
 	// check that adding again doesnt reset the subscription
-	oracle.addSubscription(10, "0x", "0x")
+	oracle.addSubscription(10, "0x", "0x", TransitionCause{})
 	require.Equal(t, big.NewInt(200), oracle.state.Validators[10].PendingRewardsWei)
 	require.Equal(t, big.NewInt(100), oracle.state.Validators[10].AccumulatedRewardsWei)
 }
 
 func Test_addSubscription_2(t *testing.T) {
 	oracle := NewOracle(&Config{})
-	oracle.addSubscription(uint64(100), "0x3000000000000000000000000000000000000000", "0xkey")
+	oracle.addSubscription(uint64(100), "0x3000000000000000000000000000000000000000", "0xkey", TransitionCause{})
 	require.Equal(t, 1, len(oracle.state.Validators))
 	require.Equal(t, &ValidatorInfo{
 		ValidatorStatus:       Active,
@@ -601,7 +615,7 @@ func Test_addSubscription_2(t *testing.T) {
 	oracle.state.Validators[100].PendingRewardsWei = big.NewInt(87653)
 
 	// If we call it again, it shouldnt be overwritten as its already there
-	oracle.addSubscription(uint64(100), "0x3000000000000000000000000000000000000000", "0xkey")
+	oracle.addSubscription(uint64(100), "0x3000000000000000000000000000000000000000", "0xkey", TransitionCause{})
 
 	require.Equal(t, big.NewInt(334545546), oracle.state.Validators[100].AccumulatedRewardsWei)
 	require.Equal(t, big.NewInt(87653), oracle.state.Validators[100].PendingRewardsWei)
@@ -660,8 +674,8 @@ func Test_handleDonations_SharedEqual(t *testing.T) {
 			},
 		},
 	}
-	oracle.addSubscription(10, "0x", "0x")
-	oracle.addSubscription(20, "0x", "0x")
+	oracle.addSubscription(10, "0x", "0x", TransitionCause{})
+	oracle.addSubscription(20, "0x", "0x", TransitionCause{})
 	oracle.handleDonations(donations)
 
 	// Pool gets a share
@@ -712,7 +726,7 @@ func Test_handleCorrectBlockProposal_AlreadySub(t *testing.T) {
 	}
 
 	oracle := NewOracle(cfg)
-	oracle.addSubscription(10, "0x", "0x")
+	oracle.addSubscription(10, "0x", "0x", TransitionCause{})
 	oracle.increaseValidatorPendingRewards(10, big.NewInt(1))
 	oracle.increaseValidatorAccumulatedRewards(10, big.NewInt(1))
 
@@ -2232,9 +2246,9 @@ func Test_handleManualUnsubscriptions_AndRejoin(t *testing.T) {
 
 func Test_handleBanValidator(t *testing.T) {
 	oracle := NewOracle(&Config{Network: "mainnet"})
-	oracle.addSubscription(1, "0xa", "0xb")
-	oracle.addSubscription(2, "0xa", "0xb")
-	oracle.addSubscription(3, "0xa", "0xb")
+	oracle.addSubscription(1, "0xa", "0xb", TransitionCause{})
+	oracle.addSubscription(2, "0xa", "0xb", TransitionCause{})
+	oracle.addSubscription(3, "0xa", "0xb", TransitionCause{})
 
 	// New reward arrives
 	oracle.increaseAllPendingRewards(big.NewInt(99))
@@ -2258,8 +2272,8 @@ func Test_handleBanValidator(t *testing.T) {
 
 func Test_handleMissedBlock(t *testing.T) {
 	oracle := NewOracle(&Config{Network: "mainnet"})
-	oracle.addSubscription(1, "0xa", "0xb")
-	oracle.addSubscription(2, "0xa", "0xb")
+	oracle.addSubscription(1, "0xa", "0xb", TransitionCause{})
+	oracle.addSubscription(2, "0xa", "0xb", TransitionCause{})
 
 	oracle.increaseValidatorPendingRewards(1, big.NewInt(100))
 	oracle.increaseValidatorAccumulatedRewards(1, big.NewInt(200))
@@ -2295,8 +2309,8 @@ func Test_handleBlsCorrectBlockProposal_NotSubscribed(t *testing.T) {
 		PoolFeesPercentOver10000: 100, // 1%
 	})
 
-	oracle.addSubscription(888, "0xa", "0xb")
-	oracle.addSubscription(999, "0xa", "0xb")
+	oracle.addSubscription(888, "0xa", "0xb", TransitionCause{})
+	oracle.addSubscription(999, "0xa", "0xb", TransitionCause{})
 
 	blsBlock := SummarizedBlock{
 		Block:             1,
@@ -2336,7 +2350,7 @@ func Test_handleBlsCorrectBlockProposal_NotSubscribed(t *testing.T) {
 func Test_handleBlsCorrectBlockProposal_Subscribed(t *testing.T) {
 	// This should never happen
 	oracle := NewOracle(&Config{})
-	oracle.addSubscription(1, "0xa", "0xb")
+	oracle.addSubscription(1, "0xa", "0xb", TransitionCause{})
 
 	missed := SummarizedBlock{
 		Slot:              uint64(100),
@@ -2369,9 +2383,9 @@ func Test_increaseAllPendingRewards_1(t *testing.T) {
 	})
 
 	// Subscribe 3 validators with no balance
-	oracle.addSubscription(1, "0x", "0x")
-	oracle.addSubscription(2, "0x", "0x")
-	oracle.addSubscription(3, "0x", "0x")
+	oracle.addSubscription(1, "0x", "0x", TransitionCause{})
+	oracle.addSubscription(2, "0x", "0x", TransitionCause{})
+	oracle.addSubscription(3, "0x", "0x", TransitionCause{})
 
 	oracle.increaseAllPendingRewards(big.NewInt(10000))
 
@@ -2391,9 +2405,9 @@ func Test_increaseAllPendingRewards_2(t *testing.T) {
 	})
 
 	// Subscribe 3 validators with no balance
-	oracle.addSubscription(1, "0x", "0x")
-	oracle.addSubscription(2, "0x", "0x")
-	oracle.addSubscription(3, "0x", "0x")
+	oracle.addSubscription(1, "0x", "0x", TransitionCause{})
+	oracle.addSubscription(2, "0x", "0x", TransitionCause{})
+	oracle.addSubscription(3, "0x", "0x", TransitionCause{})
 
 	oracle.increaseAllPendingRewards(big.NewInt(10000))
 
@@ -2436,7 +2450,7 @@ func Test_increaseAllPendingRewards_3(t *testing.T) {
 		})
 
 		for i := 0; i < test.AmountValidators; i++ {
-			oracle.addSubscription(uint64(i), "0x", "0x")
+			oracle.addSubscription(uint64(i), "0x", "0x", TransitionCause{})
 		}
 
 		totalRewards := big.NewInt(0)
@@ -2504,7 +2518,7 @@ func Test_increaseAllPendingRewards_4(t *testing.T) {
 			Network:                  "mainnet",
 		})
 		for i := 0; i < test.AmountValidators; i++ {
-			oracle.addSubscription(uint64(i), "0x", "0x")
+			oracle.addSubscription(uint64(i), "0x", "0x", TransitionCause{})
 		}
 		oracle.increaseAllPendingRewards(test.Reward)
 		for i := 0; i < test.AmountValidators; i++ {
@@ -2605,7 +2619,7 @@ func Test_increaseAllPendingRewards_5(t *testing.T) {
 			Network:                  "mainnet",
 		})
 		for i := 0; i < test.AmountValidators; i++ {
-			oracle.addSubscription(uint64(i), "0x", "0x")
+			oracle.addSubscription(uint64(i), "0x", "0x", TransitionCause{})
 		}
 		oracle.state.NextSlotToProcess = test.Slot
 		oracle.increaseAllPendingRewards(test.Reward)
@@ -3983,8 +3997,8 @@ func Test_StateMachine(t *testing.T) {
 			ValidatorStatus: testState.From,
 		}
 
-		oracle.advanceStateMachine(valIndex1, testState.Event)
-		oracle.advanceStateMachine(valIndex2, testState.Event)
+		oracle.advanceStateMachine(valIndex1, testState.Event, TransitionCause{})
+		oracle.advanceStateMachine(valIndex2, testState.Event, TransitionCause{})
 
 		require.Equal(t, testState.End, oracle.state.Validators[valIndex1].ValidatorStatus)
 		require.Equal(t, testState.End, oracle.state.Validators[valIndex2].ValidatorStatus)
@@ -4070,9 +4084,9 @@ func Test_getMerkleRootIfAny(t *testing.T) {
 		PoolFeesAddress: "0x1123456789abcdef0123456789abcdef01234568",
 	})
 	oracle.state.LatestProcessedSlot = 100
-	oracle.addSubscription(uint64(10), "0x1123456789abcdef0123456789abcdef01234568", "0x1123456789abcdef0123456789abcdef01234568")
-	oracle.addSubscription(uint64(11), "0x1123456789abcdef0123456789abcdef01234568", "0x1123456789abcdef0123456789abcdef01234568")
-	oracle.addSubscription(uint64(12), "0x1123456789abcdef0123456789abcdef01234568", "0x1123456789abcdef0123456789abcdef01234568")
+	oracle.addSubscription(uint64(10), "0x1123456789abcdef0123456789abcdef01234568", "0x1123456789abcdef0123456789abcdef01234568", TransitionCause{})
+	oracle.addSubscription(uint64(11), "0x1123456789abcdef0123456789abcdef01234568", "0x1123456789abcdef0123456789abcdef01234568", TransitionCause{})
+	oracle.addSubscription(uint64(12), "0x1123456789abcdef0123456789abcdef01234568", "0x1123456789abcdef0123456789abcdef01234568", TransitionCause{})
 
 	root, enough := oracle.getMerkleRootIfAny()
 	require.Equal(t, "0x3ba6b7c80fed7f5f5f5796c610c7dc5bbabf408b8525cbcef67086766ab51863", root)
@@ -4157,7 +4171,7 @@ func Test_StateSize(t *testing.T) {
 
 		// Add validators
 		for i := 0; i < numValidators; i++ {
-			oracle.addSubscription(uint64(i), fmt.Sprintf("0x%d123456789abcdef0123456789abcdef01234567", i%9), "0x0123456789abcdef0123456789abcdef01234567")
+			oracle.addSubscription(uint64(i), fmt.Sprintf("0x%d123456789abcdef0123456789abcdef01234567", i%9), "0x0123456789abcdef0123456789abcdef01234567", TransitionCause{})
 		}
 
 		// Add blocks
@@ -4219,3 +4233,184 @@ func checkFileExists(t *testing.T, path string) {
 		t.Errorf("Expected file does not exist: %s", path)
 	}
 }
+
+func Test_RunOnchainReconciliation(t *testing.T) {
+	StateFolder = t.TempDir()
+	oracle := testOracle(Mainnet, 1000)
+	oracle.state.LatestProcessedSlot = 1100
+	oracle.state.LatestProcessedBlock = 900
+	oracle.state.PoolAccumulatedFees = big.NewInt(100)
+	oracle.state.Validators[1] = &ValidatorInfo{
+		AccumulatedRewardsWei: big.NewInt(500),
+		PendingRewardsWei:     big.NewInt(400),
+		WithdrawalAddress:     "0x1000000000000000000000000000000000000000",
+	}
+	oracle.state.ProposedBlocks = []SummarizedBlock{{RewardType: VanilaBlock, Reward: big.NewInt(1000)}}
+
+	// Owes 1000 - 300 claimed, and has 700
+	oracle.applyClaim(Claim{
+		Block:             800,
+		WithdrawalAddress: "0x1000000000000000000000000000000000000000",
+		AmountWei:         big.NewInt(300),
+	})
+	report, err := oracle.RunOnchainReconciliation(big.NewInt(700))
+	require.NoError(t, err)
+	require.True(t, report.Success)
+	require.Equal(t, uint64(1100), report.Slot)
+	require.Equal(t, uint64(900), report.Block)
+	require.Equal(t, big.NewInt(1000), report.RewardsWei)
+	require.Equal(t, big.NewInt(300), report.ClaimedWei)
+	require.Equal(t, big.NewInt(700), report.LiabilitiesWei)
+	require.Equal(t, 0, report.DeltaWei.Sign())
+
+	// Has more than it owes, still stored
+	oracle.state.LatestProcessedSlot = 1150
+	report, err = oracle.RunOnchainReconciliation(big.NewInt(750))
+	require.Error(t, err)
+	require.False(t, report.Success)
+	require.Equal(t, big.NewInt(50), report.DeltaWei)
+	require.Contains(t, report.Error, "dont match")
+
+	// Inputs could not be fetched
+	oracle.state.LatestProcessedSlot = 1160
+	report = oracle.RecordFailedReconciliation(fmt.Errorf("missing trie node"))
+	require.False(t, report.Success)
+	require.Nil(t, report.DeltaWei)
+
+	reports := oracle.ReconciliationReports()
+	require.Equal(t, 3, len(reports))
+	require.Equal(t, []uint64{1100, 1150, 1160}, []uint64{reports[0].Slot, reports[1].Slot, reports[2].Slot})
+	require.Equal(t, "missing trie node", reports[2].Error)
+
+	// Reports are persisted next to the state, not in it, so they dont change its hash
+	rawBytes, err := serializeStateWithHash(oracle.state)
+	require.NoError(t, err)
+	require.NotContains(t, string(rawBytes), "missing trie node")
+	reloaded := testOracle(Mainnet, 1000)
+	require.NoError(t, reloaded.LoadReconciliationReports())
+	expected, err := json.Marshal(reports)
+	require.NoError(t, err)
+	recovered, err := json.Marshal(reloaded.ReconciliationReports())
+	require.NoError(t, err)
+	require.JSONEq(t, string(expected), string(recovered))
+}
+
+func Test_HasFreshReconciliation(t *testing.T) {
+	StateFolder = t.TempDir()
+	oracle := testOracle(Mainnet, 1000)
+	oracle.state.PoolAccumulatedFees = big.NewInt(100)
+
+	// No reports
+	require.False(t, oracle.HasFreshReconciliation(1100, 100))
+
+	oracle.state.LatestProcessedSlot = 1050
+	_, err := oracle.RunOnchainReconciliation(big.NewInt(100))
+	require.NoError(t, err)
+
+	require.True(t, oracle.HasFreshReconciliation(1100, 100))
+	require.True(t, oracle.HasFreshReconciliation(1150, 100))
+	require.False(t, oracle.HasFreshReconciliation(1151, 100), "too old")
+	require.False(t, oracle.HasFreshReconciliation(1000, 100), "after the slot")
+
+	// Only the latest report counts
+	oracle.state.LatestProcessedSlot = 1100
+	oracle.RecordFailedReconciliation(fmt.Errorf("timeout"))
+	require.False(t, oracle.HasFreshReconciliation(1100, 100))
+}
+
+func Test_ReconciliationReports_Bounded(t *testing.T) {
+	StateFolder = t.TempDir()
+	origMax := MaxReconciliationReports
+	MaxReconciliationReports = 3
+	defer func() { MaxReconciliationReports = origMax }()
+
+	oracle := testOracle(Mainnet, 1000)
+	for slot := uint64(1000); slot < 1005; slot++ {
+		oracle.state.LatestProcessedSlot = slot
+		oracle.RecordFailedReconciliation(fmt.Errorf("error"))
+	}
+
+	reports := oracle.ReconciliationReports()
+	require.Equal(t, 3, len(reports))
+	require.Equal(t, uint64(1002), reports[0].Slot)
+	require.Equal(t, uint64(1004), reports[2].Slot)
+}
+
+// Oracle with numValidators subscribed validators spread among numAddresses withdrawal addresses
+func benchmarkOracle(numValidators int, numAddresses int) *Oracle {
+	oracle := NewOracle(&Config{
+		PoolAddress:     "0x0000000000000000000000000000000000000001",
+		PoolFeesAddress: "0x0000000000000000000000000000000000000002",
+	})
+	oracle.state.PoolAccumulatedFees = big.NewInt(1000)
+	for i := 0; i < numValidators; i++ {
+		oracle.state.Validators[uint64(i)] = &ValidatorInfo{
+			ValidatorStatus:       Active,
+			AccumulatedRewardsWei: big.NewInt(int64(i)),
+			PendingRewardsWei:     big.NewInt(int64(i)),
+			CollateralWei:         big.NewInt(1000),
+			WithdrawalAddress:     common.BigToAddress(big.NewInt(int64(i%numAddresses + 10))).Hex(),
+			ValidatorIndex:        uint64(i),
+		}
+	}
+	return oracle
+}
+
+// Sizes used to check that the hot paths scale linearly with the amount of validators
+var benchmarkSizes = []struct {
+	Validators int
+	Addresses  int
+}{
+	{1000, 500},
+	{10000, 5000},
+	{100000, 50000},
+}
+
+func Test_CopyValidators(t *testing.T) {
+	validators := map[uint64]*ValidatorInfo{
+		1: {ValidatorStatus: Active, AccumulatedRewardsWei: big.NewInt(1), PendingRewardsWei: big.NewInt(2), WithdrawalAddress: "0xa"},
+	}
+	validatorsCopy := CopyValidators(validators)
+	require.Equal(t, validators, validatorsCopy)
+
+	validatorsCopy[1].AccumulatedRewardsWei.SetInt64(10)
+	validatorsCopy[1].ValidatorStatus = Banned
+	require.Equal(t, big.NewInt(1), validators[1].AccumulatedRewardsWei)
+	require.Equal(t, Active, validators[1].ValidatorStatus)
+	require.Nil(t, validatorsCopy[1].CollateralWei)
+}
+
+func BenchmarkFreezeCheckpoint(b *testing.B) {
+	log.SetLevel(log.WarnLevel)
+	defer log.SetLevel(log.InfoLevel)
+	for _, size := range benchmarkSizes {
+		oracle := benchmarkOracle(size.Validators, size.Addresses)
+		b.Run(fmt.Sprintf("validators=%d/addresses=%d", size.Validators, size.Addresses), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				require.True(b, oracle.FreezeCheckpoint())
+			}
+		})
+	}
+}
+
+func BenchmarkGetUniqueWithdrawalAddresses(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		oracle := benchmarkOracle(size.Validators, size.Addresses)
+		b.Run(fmt.Sprintf("validators=%d/addresses=%d", size.Validators, size.Addresses), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				require.Equal(b, size.Addresses+1, len(oracle.GetUniqueWithdrawalAddresses()))
+			}
+		})
+	}
+}
+
+func BenchmarkIsSubscribed(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		oracle := benchmarkOracle(size.Validators, size.Addresses)
+		b.Run(fmt.Sprintf("validators=%d", size.Validators), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				oracle.isSubscribed(uint64(i % size.Validators))
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// How often the head preview follows the chain, and how many slots above the finalized
+// state it processes at most. When finality is lost the preview stops there
+var HeadPreviewInterval = 12 * time.Second
+var HeadPreviewMaxSlots = uint64(256)
+
+// Returns a slot of the chain, such as the finalized one or the head
+type SlotFunc func() (uint64, error)
+
+// Returns the block of the slot with what the given oracle needs to process it
+type GetFullBlockFunc func(slot uint64, oracle *Oracle) (*FullBlock, error)
+
+// Returns a new oracle starting from a copy of the current state, that processes slots
+// without modifying this one. Its changes are not streamed as events
+func (or *Oracle) Fork() *Oracle {
+	or.mutex.RLock()
+	defer or.mutex.RUnlock()
+
+	fork := &Oracle{
+		cfg:                      or.cfg,
+		state:                    snapshotState(or.state),
+		getSetOfValidators:       or.getSetOfValidators,
+		getPendingConsolidations: or.getPendingConsolidations,
+		forcedRewardDistributor:  or.forcedRewardDistributor,
+		isFork:                   true,
+	}
+	if or.accounting != nil {
+		fork.accounting = or.accounting.copy()
+	}
+	return fork
+}
+
+// Previews the state at the head of the chain, processing the slots that are not finalized
+// yet with the same state transitions as the oracle, on a fork of its finalized state. The
+// fork is discarded and rebuilt once the oracle processes a new finalized slot, so a block
+// reorged at head is only previewed until then
+type HeadPreview struct {
+	oracle        *Oracle
+	headSlot      SlotFunc
+	finalizedSlot SlotFunc
+	getFullBlock  GetFullBlockFunc
+
+	// Fork being advanced to head and the slot of the oracle it was forked at. Only
+	// used by the goroutine updating the preview
+	fork       *Oracle
+	forkedSlot uint64
+
+	// Latest fork that reached the head, nil until the first one does
+	preview atomic.Pointer[Oracle]
+}
+
+func NewHeadPreview(oracle *Oracle, headSlot SlotFunc, finalizedSlot SlotFunc, getFullBlock GetFullBlockFunc) *HeadPreview {
+	return &HeadPreview{
+		oracle:        oracle,
+		headSlot:      headSlot,
+		finalizedSlot: finalizedSlot,
+		getFullBlock:  getFullBlock,
+	}
+}
+
+// Previews the state of the oracle with the blocks of the given nodes
+func NewOnchainHeadPreview(oracle *Oracle, onchain *Onchain) *HeadPreview {
+	return NewHeadPreview(oracle,
+		func() (uint64, error) {
+			header, err := onchain.HeadBeaconBlockHeader()
+			if err != nil {
+				return 0, err
+			}
+			return uint64(header.Header.Message.Slot), nil
+		},
+		func() (uint64, error) {
+			header, err := onchain.FinalizedBeaconBlockHeader()
+			if err != nil {
+				return 0, err
+			}
+			return uint64(header.Header.Message.Slot), nil
+		},
+		func(slot uint64, oracle *Oracle) (*FullBlock, error) {
+			fullBlock, err := onchain.GetFullBlock(slot, oracle, false)
+			if err != nil {
+				return nil, err
+			}
+			// The block may have been reorged while its events were fetched
+			err = onchain.CheckCanonicalBlock(fullBlock)
+			if err != nil {
+				return nil, err
+			}
+			return fullBlock, nil
+		})
+}
+
+// Returns the oracle previewing the state at head, false if there is none yet
+func (p *HeadPreview) Oracle() (*Oracle, bool) {
+	preview := p.preview.Load()
+	return preview, preview != nil
+}
+
+// Updates the preview every HeadPreviewInterval until the context is done
+func (p *HeadPreview) Run(ctx context.Context) {
+	ticker := time.NewTicker(HeadPreviewInterval)
+	defer ticker.Stop()
+	for {
+		if err := p.Update(); err != nil {
+			log.WithError(err).Warn("Could not update the head preview")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Processes the slots up to the head on the fork, forking the oracle again if it processed
+// a new finalized slot. A new fork is only previewed once it reaches the head. While the
+// oracle is still processing finalized slots, the previous preview is kept
+func (p *HeadPreview) Update() error {
+	finalizedSlot, err := p.finalizedSlot()
+	if err != nil {
+		return errors.Wrap(err, "could not get finalized slot")
+	}
+	processedSlot := p.oracle.Snapshot().LatestProcessedSlot
+	if processedSlot < finalizedSlot {
+		return nil
+	}
+	headSlot, err := p.headSlot()
+	if err != nil {
+		return errors.Wrap(err, "could not get head slot")
+	}
+	if headSlot > processedSlot+HeadPreviewMaxSlots {
+		headSlot = processedSlot + HeadPreviewMaxSlots
+	}
+
+	if p.fork == nil || p.forkedSlot != processedSlot {
+		p.fork = p.oracle.Fork()
+		p.forkedSlot = processedSlot
+	}
+
+	for p.fork.state.NextSlotToProcess <= headSlot {
+		slot := p.fork.state.NextSlotToProcess
+		fullBlock, err := p.getFullBlock(slot, p.fork)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("could not get block of slot %d", slot))
+		}
+		_, err = p.fork.AdvanceStateToNextSlot(fullBlock)
+		if err != nil {
+			// The fork may be left half processed, so its no longer previewed and its forked again
+			p.preview.CompareAndSwap(p.fork, nil)
+			p.fork = nil
+			return errors.Wrap(err, fmt.Sprintf("could not preview slot %d", slot))
+		}
+	}
+	p.preview.Store(p.fork)
+	return nil
+}
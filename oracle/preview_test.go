@@ -0,0 +1,138 @@
+package oracle
+
+import (
+	"testing"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// Block missed by the validator 1, with withdrawal address 0x..a1
+func missedBlockOfValidator1(slot uint64) *FullBlock {
+	block := fullBlockNoEvents(slot)
+	block.ConsensusDuty.ValidatorIndex = 1
+	block.Validator = &v1.Validator{Index: 1, Validator: &phase0.Validator{
+		WithdrawalCredentials: hexutil.MustDecode("0x01000000000000000000000000000000000000000000000000000000000000a1"),
+	}}
+	return block
+}
+
+func previewOracle(t *testing.T) *Oracle {
+	oracle := testOracle(Mainnet, 0)
+	oracle.state.Validators[1] = trackedValidator(1, Active, 0, "0x00000000000000000000000000000000000000a1")
+	_, err := oracle.AdvanceStateToNextSlot(missedFullBlock(1000))
+	require.NoError(t, err)
+	return oracle
+}
+
+func Test_Fork(t *testing.T) {
+	oracle := previewOracle(t)
+	_, subscription, err := oracle.Events().Subscribe("")
+	require.NoError(t, err)
+
+	// The fork runs the same state transitions
+	fork := oracle.Fork()
+	_, err = fork.AdvanceStateToNextSlot(missedBlockOfValidator1(1001))
+	require.NoError(t, err)
+	require.Equal(t, YellowCard, fork.Snapshot().Validators[1].ValidatorStatus)
+	require.Equal(t, uint64(1001), fork.Snapshot().LatestProcessedSlot)
+	require.Len(t, fork.Snapshot().MissedBlocks, 1)
+
+	// Without modifying the oracle, nor streaming its events
+	require.Equal(t, Active, oracle.state.Validators[1].ValidatorStatus)
+	require.Equal(t, uint64(1000), oracle.state.LatestProcessedSlot)
+	require.Empty(t, oracle.state.MissedBlocks)
+	require.Empty(t, subscription.Events)
+}
+
+func Test_HeadPreview(t *testing.T) {
+	oracle := previewOracle(t)
+	finalizedSlot := uint64(1000)
+	headSlot := uint64(1002)
+	var fetchErr error
+	fetched := make([]uint64, 0)
+	preview := NewHeadPreview(oracle,
+		func() (uint64, error) { return headSlot, nil },
+		func() (uint64, error) { return finalizedSlot, nil },
+		func(slot uint64, fork *Oracle) (*FullBlock, error) {
+			require.NotSame(t, oracle, fork)
+			fetched = append(fetched, slot)
+			return missedBlockOfValidator1(slot), fetchErr
+		})
+	_, found := preview.Oracle()
+	require.False(t, found)
+
+	// The slots up to head are processed on a fork, leaving the oracle as it was
+	require.NoError(t, preview.Update())
+	head, found := preview.Oracle()
+	require.True(t, found)
+	require.Equal(t, uint64(1002), head.Snapshot().LatestProcessedSlot)
+	require.Equal(t, RedCard, head.Snapshot().Validators[1].ValidatorStatus)
+	require.Equal(t, Active, oracle.Snapshot().Validators[1].ValidatorStatus)
+
+	// New slots at head are processed on the same fork
+	headSlot = 1003
+	require.NoError(t, preview.Update())
+	require.Equal(t, []uint64{1001, 1002, 1003}, fetched)
+	same, _ := preview.Oracle()
+	require.Same(t, head, same)
+	require.Equal(t, uint64(1003), head.Snapshot().LatestProcessedSlot)
+
+	// While the oracle processes the new finalized slots the preview is kept
+	finalizedSlot = 1001
+	require.NoError(t, preview.Update())
+	same, _ = preview.Oracle()
+	require.Same(t, head, same)
+
+	// And once it has, its forked again from the new finalized state
+	_, err := oracle.AdvanceStateToNextSlot(missedBlockOfValidator1(1001))
+	require.NoError(t, err)
+	fetched = fetched[:0]
+	require.NoError(t, preview.Update())
+	require.Equal(t, []uint64{1002, 1003}, fetched)
+	rebuilt, _ := preview.Oracle()
+	require.NotSame(t, head, rebuilt)
+	require.Equal(t, uint64(1003), rebuilt.Snapshot().LatestProcessedSlot)
+	require.Equal(t, YellowCard, oracle.Snapshot().Validators[1].ValidatorStatus)
+
+	// A new fork is not previewed until it reaches the head
+	finalizedSlot = 1002
+	_, err = oracle.AdvanceStateToNextSlot(missedFullBlock(1002))
+	require.NoError(t, err)
+	fetchErr = errors.New("node down")
+	require.Error(t, preview.Update())
+	same, _ = preview.Oracle()
+	require.Same(t, rebuilt, same)
+
+	fetchErr = nil
+	require.NoError(t, preview.Update())
+	updated, _ := preview.Oracle()
+	require.Equal(t, uint64(1003), updated.Snapshot().LatestProcessedSlot)
+	require.NotSame(t, rebuilt, updated)
+}
+
+func Test_HeadPreview_InconsistentBlock(t *testing.T) {
+	oracle := previewOracle(t)
+
+	// Data of a block reorged while it was fetched, with a subscription whose validator was not
+	// fetched. Its rejected with an error, instead of halting
+	preview := NewHeadPreview(oracle,
+		func() (uint64, error) { return 1001, nil },
+		func() (uint64, error) { return 1000, nil },
+		func(slot uint64, fork *Oracle) (*FullBlock, error) {
+			block := missedBlockOfValidator1(slot)
+			block.Events.SubscribeValidator = []*contract.ContractSubscribeValidator{{ValidatorID: 2}}
+			return block, nil
+		})
+	err := preview.Update()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "number of subscriptions events and validators dont match")
+	_, found := preview.Oracle()
+	require.False(t, found)
+	require.Equal(t, uint64(1000), oracle.Snapshot().LatestProcessedSlot)
+	require.Equal(t, Active, oracle.Snapshot().Validators[1].ValidatorStatus)
+}
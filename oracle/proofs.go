@@ -0,0 +1,168 @@
+package oracle
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dappnode/mev-sp-oracle/utils"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	mt "github.com/txaty/go-merkletree"
+)
+
+// Amount of commited states whose tree is kept in memory to serve proofs. The api
+// mostly serves the proofs of the latest state consolidated onchain
+var ProofTreesCacheSize = 2
+
+// Tree of a commited state rebuilt from its ordered leafs, with the proofs already served
+type checkpointTree struct {
+	tree   *mt.MerkleTree
+	blocks map[string]mt.DataBlock
+	leafs  map[string]RawLeaf
+	proofs map[string][]string
+
+	// Order of the latest request of the tree, to evict the least recently used one
+	lastUsed uint64
+}
+
+// Returns the leaf and the merkle proof of the withdrawal address in the state commited
+// at the given slot, and false if the address has no leaf. The tree is rebuilt from the
+// stored leafs the first time its needed, ensuring it matches the stored root.
+func (or *Oracle) Proof(slot uint64, withdrawalAddress string) (RawLeaf, []string, bool, error) {
+	or.mutex.RLock()
+	commited, found := or.state.CommitedStates[slot]
+	or.mutex.RUnlock()
+	if !found {
+		return RawLeaf{}, nil, false, errors.New(fmt.Sprintf("no commited state at slot %d", slot))
+	}
+	withdrawalAddress = strings.ToLower(withdrawalAddress)
+
+	// Old states that could not be upgraded keep serving the stored proofs
+	if len(commited.OrderedLeafs) == 0 {
+		leaf, leafFound := commited.Leafs[withdrawalAddress]
+		proof, proofFound := commited.Proofs[withdrawalAddress]
+		return leaf, proof, leafFound && proofFound, nil
+	}
+
+	or.proofTreesMutex.Lock()
+	defer or.proofTreesMutex.Unlock()
+
+	checkpoint, err := or.checkpointTreeLockFree(commited)
+	if err != nil {
+		return RawLeaf{}, nil, false, err
+	}
+
+	leaf, found := checkpoint.leafs[withdrawalAddress]
+	if !found {
+		return RawLeaf{}, nil, false, nil
+	}
+	proof, found := checkpoint.proofs[withdrawalAddress]
+	if !found {
+		merkleProof, err := checkpoint.tree.Proof(checkpoint.blocks[withdrawalAddress])
+		if err != nil {
+			return RawLeaf{}, nil, false, errors.Wrap(err, "could not generate proof")
+		}
+		proof = utils.ByteArrayToArray(merkleProof.Siblings)
+		checkpoint.proofs[withdrawalAddress] = proof
+	}
+	return leaf, proof, true, nil
+}
+
+// Returns the tree of the commited state from the cache, rebuilding it if needed
+func (or *Oracle) checkpointTreeLockFree(commited *OnchainState) (*checkpointTree, error) {
+	or.proofTreesUses++
+	if checkpoint, found := or.proofTrees[commited.Slot]; found {
+		checkpoint.lastUsed = or.proofTreesUses
+		return checkpoint, nil
+	}
+
+	blocks, leafs, tree, enoughData := NewMerklelizer().GenerateTreeFromLeafs(commited.OrderedLeafs)
+	if !enoughData {
+		return nil, errors.New(fmt.Sprintf("not enough leafs to rebuild the tree at slot %d", commited.Slot))
+	}
+	rebuiltRoot := hexutil.Encode(tree.Root)
+	if !strings.EqualFold(rebuiltRoot, commited.MerkleRoot) {
+		return nil, errors.New(fmt.Sprintf("rebuilt root does not match the commited one at slot %d: %s vs %s",
+			commited.Slot, rebuiltRoot, commited.MerkleRoot))
+	}
+
+	if or.proofTrees == nil {
+		or.proofTrees = make(map[uint64]*checkpointTree)
+	}
+	// Evict the least recently used trees
+	for len(or.proofTrees) >= ProofTreesCacheSize && len(or.proofTrees) > 0 {
+		var victimSlot uint64
+		var victim *checkpointTree
+		for slot, cached := range or.proofTrees {
+			if victim == nil || cached.lastUsed < victim.lastUsed {
+				victimSlot, victim = slot, cached
+			}
+		}
+		delete(or.proofTrees, victimSlot)
+	}
+
+	checkpoint := &checkpointTree{
+		tree:     tree,
+		blocks:   blocks,
+		leafs:    leafs,
+		proofs:   make(map[string][]string),
+		lastUsed: or.proofTreesUses,
+	}
+	or.proofTrees[commited.Slot] = checkpoint
+	return checkpoint, nil
+}
+
+// Returns the leafs of the commited state by withdrawal address
+func (s *OnchainState) LeafsByAddress() map[string]RawLeaf {
+	if len(s.OrderedLeafs) == 0 {
+		return s.Leafs
+	}
+	leafs := make(map[string]RawLeaf, len(s.OrderedLeafs))
+	for _, leaf := range s.OrderedLeafs {
+		leafs[leaf.WithdrawalAddress] = leaf
+	}
+	return leafs
+}
+
+// Replaces the leafs and proofs stored by older versions in every commited state with the
+// ordered leafs, if they rebuild the same root. The pool fees leaf goes first and the rest
+// are sorted by withdrawal address, as done by AggregateValidatorsIndexes.
+func upgradeCommitedStates(state *OracleState) {
+	for slot, commited := range state.CommitedStates {
+		if len(commited.OrderedLeafs) != 0 || len(commited.Leafs) == 0 {
+			continue
+		}
+		config, _ := configAtSlot(state.ConfigHistory, slot)
+		poolFeesAddress := strings.ToLower(config.PoolFeesAddress)
+
+		poolFeesLeaf, found := commited.Leafs[poolFeesAddress]
+		if !found {
+			log.WithFields(log.Fields{
+				"Slot":            slot,
+				"PoolFeesAddress": poolFeesAddress,
+			}).Warn("Commited state has no pool fees leaf, keeping its stored proofs")
+			continue
+		}
+		leafs := make([]RawLeaf, 0, len(commited.Leafs))
+		for address, leaf := range commited.Leafs {
+			if address != poolFeesAddress {
+				leafs = append(leafs, leaf)
+			}
+		}
+		mk := NewMerklelizer()
+		orderedLeafs := append([]RawLeaf{poolFeesLeaf}, mk.OrderByWithdrawalAddress(leafs)...)
+
+		_, _, tree, enoughData := mk.GenerateTreeFromLeafs(orderedLeafs)
+		if !enoughData || !strings.EqualFold(hexutil.Encode(tree.Root), commited.MerkleRoot) {
+			log.WithFields(log.Fields{
+				"Slot":       slot,
+				"MerkleRoot": commited.MerkleRoot,
+			}).Warn("Leafs of commited state dont rebuild its root, keeping its stored proofs")
+			continue
+		}
+		commited.OrderedLeafs = orderedLeafs
+		commited.Leafs = nil
+		commited.Proofs = nil
+	}
+}
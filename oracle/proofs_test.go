@@ -0,0 +1,151 @@
+package oracle
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/dappnode/mev-sp-oracle/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// Oracle with two withdrawal addresses with rewards, frozen at the given slots
+func proofsTestOracle(t *testing.T, slots ...uint64) *Oracle {
+	oracle := testOracle(Mainnet, 0)
+	oracle.state.Validators[1] = trackedValidator(1, Active, 0, "0x00000000000000000000000000000000000000a1")
+	oracle.state.Validators[1].AccumulatedRewardsWei = big.NewInt(100)
+	oracle.state.Validators[2] = trackedValidator(2, Active, 0, "0x00000000000000000000000000000000000000a2")
+	oracle.state.Validators[2].AccumulatedRewardsWei = big.NewInt(50)
+	oracle.state.ProposedBlocks = []SummarizedBlock{{RewardType: VanilaBlock, Reward: big.NewInt(150)}}
+	for _, slot := range slots {
+		oracle.state.LatestProcessedSlot = slot
+		oracle.state.NextSlotToProcess = slot + 1
+		require.True(t, oracle.FreezeCheckpoint())
+	}
+	return oracle
+}
+
+// Stores the leafs and proofs of every address, as older versions did
+func toLegacyCommitedState(commited *OnchainState) {
+	blocks, rawLeafs, tree, _ := NewMerklelizer().GenerateTreeFromLeafs(commited.OrderedLeafs)
+	commited.Leafs = rawLeafs
+	commited.Proofs = make(map[string][]string)
+	for address := range rawLeafs {
+		proof, _ := tree.Proof(blocks[address])
+		commited.Proofs[address] = utils.ByteArrayToArray(proof.Siblings)
+	}
+	commited.OrderedLeafs = nil
+}
+
+func Test_Proof(t *testing.T) {
+	oracle := proofsTestOracle(t, 1100, 1200, 1300)
+
+	leaf, proof, found, err := oracle.Proof(1100, "0x00000000000000000000000000000000000000A1")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, RawLeaf{"0x00000000000000000000000000000000000000a1", big.NewInt(100)}, leaf)
+	require.Equal(t, 2, len(proof))
+
+	_, _, found, err = oracle.Proof(1100, "0x00000000000000000000000000000000000000a3")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	_, _, _, err = oracle.Proof(1150, "0x00000000000000000000000000000000000000a1")
+	require.Error(t, err)
+
+	// Only the latest requested trees are kept
+	_, _, _, err = oracle.Proof(1200, "0x00000000000000000000000000000000000000a1")
+	require.NoError(t, err)
+	_, _, _, err = oracle.Proof(1300, "0x00000000000000000000000000000000000000a1")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(oracle.proofTrees))
+	require.NotContains(t, oracle.proofTrees, uint64(1100))
+
+	// Rebuilt trees must match the commited root
+	oracle.state.CommitedStates[1100].OrderedLeafs[1].AccumulatedBalanceWei = big.NewInt(1)
+	_, _, _, err = oracle.Proof(1100, "0x00000000000000000000000000000000000000a1")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "rebuilt root does not match")
+}
+
+func Test_Proof_CacheDescendingSlots(t *testing.T) {
+	oracle := proofsTestOracle(t, 1100, 1200, 1300, 1400)
+
+	// Requested from the newest to the oldest, the cache never grows past its size
+	for _, slot := range []uint64{1400, 1300, 1200, 1100} {
+		_, _, found, err := oracle.Proof(slot, "0x00000000000000000000000000000000000000a1")
+		require.NoError(t, err)
+		require.True(t, found)
+		require.LessOrEqual(t, len(oracle.proofTrees), ProofTreesCacheSize)
+		require.Contains(t, oracle.proofTrees, slot)
+	}
+	require.Equal(t, 2, len(oracle.proofTrees))
+	require.Contains(t, oracle.proofTrees, uint64(1200))
+	require.Contains(t, oracle.proofTrees, uint64(1100))
+
+	// The least recently used tree is evicted, not the oldest slot
+	_, _, _, err := oracle.Proof(1200, "0x00000000000000000000000000000000000000a1")
+	require.NoError(t, err)
+	_, _, _, err = oracle.Proof(1400, "0x00000000000000000000000000000000000000a1")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(oracle.proofTrees))
+	require.Contains(t, oracle.proofTrees, uint64(1200))
+	require.Contains(t, oracle.proofTrees, uint64(1400))
+}
+
+func Test_LoadFromBytes_UpgradesLegacyCommitedStates(t *testing.T) {
+	oracle := proofsTestOracle(t, 1100, 1200)
+	expectedLeafs := oracle.state.CommitedStates[1100].OrderedLeafs
+	_, expectedProof, _, err := oracle.Proof(1100, "0x00000000000000000000000000000000000000a2")
+	require.NoError(t, err)
+
+	toLegacyCommitedState(oracle.state.CommitedStates[1100])
+	toLegacyCommitedState(oracle.state.CommitedStates[1200])
+	require.Equal(t, expectedProof, oracle.state.CommitedStates[1100].Proofs["0x00000000000000000000000000000000000000a2"])
+
+	// Corrupted leafs cant be upgraded, the stored proofs are served instead
+	oracle.state.CommitedStates[1200].Leafs["0x00000000000000000000000000000000000000a1"] =
+		RawLeaf{"0x00000000000000000000000000000000000000a1", big.NewInt(1)}
+
+	reloaded, err := reloadOracle(t, oracle)
+	require.NoError(t, err)
+
+	upgraded := reloaded.state.CommitedStates[1100]
+	require.Equal(t, expectedLeafs, upgraded.OrderedLeafs)
+	require.Nil(t, upgraded.Leafs)
+	require.Nil(t, upgraded.Proofs)
+	_, proof, found, err := reloaded.Proof(1100, "0x00000000000000000000000000000000000000a2")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, expectedProof, proof)
+
+	legacy := reloaded.state.CommitedStates[1200]
+	require.Nil(t, legacy.OrderedLeafs)
+	_, proof, found, err = reloaded.Proof(1200, "0x00000000000000000000000000000000000000a2")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, legacy.Proofs["0x00000000000000000000000000000000000000a2"], proof)
+}
+
+// Proofs of all addresses of a commited state, including rebuilding its tree
+func BenchmarkProof(b *testing.B) {
+	log.SetLevel(log.WarnLevel)
+	defer log.SetLevel(log.InfoLevel)
+	for _, size := range benchmarkSizes {
+		oracle := benchmarkOracle(size.Validators, size.Addresses)
+		require.True(b, oracle.FreezeCheckpoint())
+		slot := oracle.state.LatestProcessedSlot
+		addresses := oracle.GetUniqueWithdrawalAddresses()
+		b.Run(fmt.Sprintf("validators=%d/addresses=%d", size.Validators, size.Addresses), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				oracle.proofTrees = nil
+				for _, address := range addresses {
+					_, _, found, err := oracle.Proof(slot, address)
+					require.NoError(b, err)
+					require.True(b, found)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package oracle
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/dappnode/mev-sp-oracle/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Handles the SetRewardRecipient events of a block, updating who receives the rewards
+// of each withdrawal address. The merkle tree is not affected, leafs are still per
+// withdrawal address and the contract sends the claimed rewards to the recipient.
+func (or *Oracle) handleSetRewardRecipients(events []*contract.ContractSetRewardRecipient) {
+	for _, event := range events {
+		change := rewardRecipientChangeFromEvent(event)
+		change.Slot = or.state.NextSlotToProcess
+		or.applyRewardRecipientChange(change)
+
+		log.WithFields(log.Fields{
+			"Slot":              change.Slot,
+			"Block":             change.Block,
+			"WithdrawalAddress": change.WithdrawalAddress,
+			"RewardRecipient":   change.RewardRecipient,
+			"TxHash":            change.TxHash,
+		}).Info("[RewardRecipient] Reward recipient set")
+	}
+}
+
+// Applies the SetRewardRecipient events processed by a state that did not track them.
+// Events must be the ones in the blocks the state processed, in order.
+func (or *Oracle) BackfillRewardRecipients(events []*contract.ContractSetRewardRecipient) error {
+	or.mutex.Lock()
+	defer or.mutex.Unlock()
+
+	if or.state.RewardRecipientsIndexed {
+		return errors.New("reward recipients are already indexed")
+	}
+	for _, event := range events {
+		if event.Raw.BlockNumber > or.state.LatestProcessedBlock {
+			return errors.New(fmt.Sprintf("event at block %d is after the latest processed block %d",
+				event.Raw.BlockNumber, or.state.LatestProcessedBlock))
+		}
+		or.applyRewardRecipientChange(rewardRecipientChangeFromEvent(event))
+	}
+	or.state.RewardRecipientsIndexed = true
+	or.updateSnapshotLockFree()
+
+	log.WithFields(log.Fields{
+		"Events":               len(events),
+		"RewardRecipients":     len(or.state.RewardRecipients),
+		"LatestProcessedBlock": or.state.LatestProcessedBlock,
+	}).Info("Backfilled reward recipients")
+	return nil
+}
+
+// Returns false if the state was created before reward recipients were tracked
+func (or *Oracle) RewardRecipientsIndexed() bool {
+	or.mutex.RLock()
+	defer or.mutex.RUnlock()
+	return or.state.RewardRecipientsIndexed
+}
+
+// Returns a copy of the withdrawal address to reward recipient mappings of the state. The
+// state is the oracle's or one of its snapshots.
+func RewardRecipientsOf(state *OracleState) map[string]string {
+	recipients := make(map[string]string, len(state.RewardRecipients))
+	for withdrawalAddress, recipient := range state.RewardRecipients {
+		recipients[withdrawalAddress] = recipient
+	}
+	return recipients
+}
+
+// Returns the address that receives the rewards of the withdrawal address in the state, if any
+func RewardRecipientOf(state *OracleState, withdrawalAddress string) (string, bool) {
+	recipient, found := state.RewardRecipients[strings.ToLower(withdrawalAddress)]
+	return recipient, found
+}
+
+// Returns the withdrawal addresses of the state whose rewards are received by the given
+// address, sorted
+func WithdrawalAddressesOfRecipient(state *OracleState, recipient string) []string {
+	withdrawalAddresses := make([]string, 0)
+	for withdrawalAddress, rewardRecipient := range state.RewardRecipients {
+		if utils.Equals(rewardRecipient, recipient) {
+			withdrawalAddresses = append(withdrawalAddresses, withdrawalAddress)
+		}
+	}
+	sort.Strings(withdrawalAddresses)
+	return withdrawalAddresses
+}
+
+// Returns the reward recipient changes of the state where the address is either the
+// withdrawal address or the recipient, oldest first. All of them if the address is empty.
+func RewardRecipientHistoryOf(state *OracleState, address string) []RewardRecipientChange {
+	history := make([]RewardRecipientChange, 0)
+	for _, change := range state.RewardRecipientHistory {
+		if address == "" ||
+			utils.Equals(change.WithdrawalAddress, address) ||
+			utils.Equals(change.RewardRecipient, address) {
+			history = append(history, change)
+		}
+	}
+	return history
+}
+
+func rewardRecipientChangeFromEvent(event *contract.ContractSetRewardRecipient) RewardRecipientChange {
+	return RewardRecipientChange{
+		Block:             event.Raw.BlockNumber,
+		TxHash:            event.Raw.TxHash.String(),
+		WithdrawalAddress: strings.ToLower(event.WithdrawalAddress.String()),
+		RewardRecipient:   strings.ToLower(event.PoolRecipient.String()),
+	}
+}
+
+func (or *Oracle) applyRewardRecipientChange(change RewardRecipientChange) {
+	or.state.RewardRecipientHistory = append(or.state.RewardRecipientHistory, change)
+	or.state.RewardRecipients = applyRewardRecipient(or.state.RewardRecipients, change)
+}
+
+func applyRewardRecipient(recipients map[string]string, change RewardRecipientChange) map[string]string {
+	if utils.Equals(change.RewardRecipient, DefaultAddress) {
+		delete(recipients, change.WithdrawalAddress)
+		return recipients
+	}
+	if recipients == nil {
+		recipients = make(map[string]string)
+	}
+	recipients[change.WithdrawalAddress] = change.RewardRecipient
+	return recipients
+}
+
+// Validates that the reward recipients of the state are the result of applying its history
+func validateRewardRecipients(state *OracleState) error {
+	expected := make(map[string]string)
+	for _, change := range state.RewardRecipientHistory {
+		if change.Block > state.LatestProcessedBlock {
+			return errors.New(fmt.Sprintf("reward recipient change at block %d is after the latest processed block %d",
+				change.Block, state.LatestProcessedBlock))
+		}
+		expected = applyRewardRecipient(expected, change)
+	}
+	if len(expected) != len(state.RewardRecipients) {
+		return errors.New(fmt.Sprintf("number of reward recipients mismatch, recovered: %d, expected: %d",
+			len(state.RewardRecipients), len(expected)))
+	}
+	for withdrawalAddress, recipient := range expected {
+		if state.RewardRecipients[withdrawalAddress] != recipient {
+			return errors.New(fmt.Sprintf("reward recipient of %s mismatch, recovered: %s, expected: %s",
+				withdrawalAddress, state.RewardRecipients[withdrawalAddress], recipient))
+		}
+	}
+	return nil
+}
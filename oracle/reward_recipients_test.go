@@ -0,0 +1,128 @@
+package oracle
+
+import (
+	"testing"
+
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/require"
+)
+
+// Helper to create a SetRewardRecipient event
+func setRewardRecipientEvent(withdrawalAddress string, recipient string, block uint64) *contract.ContractSetRewardRecipient {
+	return &contract.ContractSetRewardRecipient{
+		WithdrawalAddress: common.HexToAddress(withdrawalAddress),
+		PoolRecipient:     common.HexToAddress(recipient),
+		Raw:               types.Log{BlockNumber: block, TxHash: common.Hash{0x1}},
+	}
+}
+
+func Test_handleSetRewardRecipients(t *testing.T) {
+	oracle := testOracle(Mainnet, 700)
+	cold1 := "0x00000000000000000000000000000000000000a1"
+	cold2 := "0x00000000000000000000000000000000000000a2"
+	hot := "0x00000000000000000000000000000000000000bb"
+	other := "0x00000000000000000000000000000000000000cc"
+
+	oracle.state.NextSlotToProcess = 1100
+	oracle.handleSetRewardRecipients([]*contract.ContractSetRewardRecipient{
+		setRewardRecipientEvent(cold1, hot, 900),
+		setRewardRecipientEvent(cold2, "0x00000000000000000000000000000000000000BB", 900),
+	})
+
+	recipient, found := RewardRecipientOf(oracle.state, cold1)
+	require.True(t, found)
+	require.Equal(t, hot, recipient)
+	require.Equal(t, []string{cold1, cold2}, WithdrawalAddressesOfRecipient(oracle.state, hot))
+	require.Equal(t, uint64(1100), oracle.state.RewardRecipientHistory[0].Slot)
+
+	// Changing the recipient replaces it
+	oracle.state.NextSlotToProcess = 1200
+	oracle.handleSetRewardRecipients([]*contract.ContractSetRewardRecipient{
+		setRewardRecipientEvent(cold2, other, 1000),
+	})
+	require.Equal(t, []string{cold1}, WithdrawalAddressesOfRecipient(oracle.state, hot))
+	require.Equal(t, []string{cold2}, WithdrawalAddressesOfRecipient(oracle.state, other))
+
+	// Setting it to zero removes the mapping
+	oracle.state.NextSlotToProcess = 1300
+	oracle.handleSetRewardRecipients([]*contract.ContractSetRewardRecipient{
+		setRewardRecipientEvent(cold1, DefaultAddress, 1100),
+	})
+	_, found = RewardRecipientOf(oracle.state, cold1)
+	require.False(t, found)
+	require.Equal(t, map[string]string{cold2: other}, RewardRecipientsOf(oracle.state))
+
+	// History is kept for both sides of the mapping
+	require.Equal(t, 4, len(RewardRecipientHistoryOf(oracle.state, "")))
+	require.Equal(t, 2, len(RewardRecipientHistoryOf(oracle.state, cold1)))
+	require.Equal(t, 2, len(RewardRecipientHistoryOf(oracle.state, hot)))
+	require.Equal(t, []uint64{1100, 1200}, []uint64{
+		RewardRecipientHistoryOf(oracle.state, cold2)[0].Slot,
+		RewardRecipientHistoryOf(oracle.state, cold2)[1].Slot})
+}
+
+func Test_LoadFromBytes_WithRewardRecipients(t *testing.T) {
+	cold := "0x00000000000000000000000000000000000000a1"
+	hot := "0x00000000000000000000000000000000000000bb"
+
+	newOracleWithRecipient := func() *Oracle {
+		oracle := testOracle(Mainnet, 700)
+		oracle.state.NextSlotToProcess = 1100
+		oracle.state.LatestProcessedBlock = 1000
+		oracle.handleSetRewardRecipients([]*contract.ContractSetRewardRecipient{
+			setRewardRecipientEvent(cold, hot, 1000),
+		})
+		return oracle
+	}
+
+	reloaded, err := reloadOracle(t, newOracleWithRecipient())
+	require.NoError(t, err)
+	recipient, found := RewardRecipientOf(reloaded.state, cold)
+	require.True(t, found)
+	require.Equal(t, hot, recipient)
+	require.True(t, reloaded.RewardRecipientsIndexed())
+
+	// Mapping that does not match the history
+	oracle := newOracleWithRecipient()
+	oracle.state.RewardRecipients[cold] = "0x00000000000000000000000000000000000000cc"
+	_, err = reloadOracle(t, oracle)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "reward recipient of "+cold+" mismatch")
+
+	// Change after the latest processed block
+	oracle = newOracleWithRecipient()
+	oracle.state.LatestProcessedBlock = 999
+	_, err = reloadOracle(t, oracle)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "after the latest processed block")
+}
+
+func Test_BackfillRewardRecipients(t *testing.T) {
+	cold := "0x00000000000000000000000000000000000000a1"
+	hot := "0x00000000000000000000000000000000000000bb"
+
+	// State created before reward recipients were tracked
+	oracle := testOracle(Mainnet, 700)
+	oracle.state.RewardRecipientsIndexed = false
+	oracle.state.LatestProcessedBlock = 1000
+
+	err := oracle.BackfillRewardRecipients([]*contract.ContractSetRewardRecipient{
+		setRewardRecipientEvent(cold, hot, 1001),
+	})
+	require.Error(t, err)
+	require.False(t, oracle.RewardRecipientsIndexed())
+
+	err = oracle.BackfillRewardRecipients([]*contract.ContractSetRewardRecipient{
+		setRewardRecipientEvent(cold, hot, 1000),
+	})
+	require.NoError(t, err)
+	require.True(t, oracle.RewardRecipientsIndexed())
+	require.Equal(t, []string{cold}, WithdrawalAddressesOfRecipient(oracle.state, hot))
+	require.Equal(t, uint64(0), RewardRecipientHistoryOf(oracle.state, cold)[0].Slot)
+
+	// Only done once
+	err = oracle.BackfillRewardRecipients([]*contract.ContractSetRewardRecipient{})
+	require.Error(t, err)
+}
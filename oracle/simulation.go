@@ -0,0 +1,538 @@
+package oracle
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/avast/retry-go/v4"
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/dappnode/mev-sp-oracle/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Effective balance assumed for validators that are not found when taking the
+// balances snapshot used by simulations (32 ETH in gwei)
+var simulationDefaultEffectiveBalance = phase0.Gwei(32_000_000_000)
+
+// Parameters to modify when simulating the history of the pool. Nil or empty
+// fields keep the values that the oracle actually used.
+type SimulationParams struct {
+	PoolFeesPercentOver10000 *int     `json:"pool_fees_percent"`
+	CollateralInWei          *big.Int `json:"collateral_in_wei"`
+	RewardMethod             string   `json:"reward_method"`
+}
+
+// Returned by Simulate when another simulation is running
+var ErrSimulationRunning = errors.New("another simulation is running, try again later")
+
+// Cause of the errors returned by Simulate when the params are not valid
+var ErrInvalidSimulationParams = errors.New("invalid simulation params")
+
+// Identifies the params in the cache of simulations
+func (params SimulationParams) key() string {
+	poolFeesPercent := ""
+	if params.PoolFeesPercentOver10000 != nil {
+		poolFeesPercent = strconv.Itoa(*params.PoolFeesPercentOver10000)
+	}
+	collateral := ""
+	if params.CollateralInWei != nil {
+		collateral = params.CollateralInWei.String()
+	}
+	return poolFeesPercent + "/" + collateral + "/" + params.RewardMethod
+}
+
+// Returns the result of a simulation with the given params up to the given slot, if any
+func (or *Oracle) cachedSimulation(slot uint64, key string) *SimulationResult {
+	or.simulationsMutex.Lock()
+	defer or.simulationsMutex.Unlock()
+	if or.simulationsSlot != slot {
+		return nil
+	}
+	return or.simulations[key]
+}
+
+// Stores the result of a simulation up to the given slot, dropping the ones of older slots
+func (or *Oracle) cacheSimulation(slot uint64, key string, result *SimulationResult) {
+	or.simulationsMutex.Lock()
+	defer or.simulationsMutex.Unlock()
+	if or.simulations == nil || or.simulationsSlot != slot {
+		or.simulations = make(map[string]*SimulationResult)
+		or.simulationsSlot = slot
+	}
+	or.simulations[key] = result
+}
+
+// Balances of a withdrawal address when replaying the history with the real
+// config (baseline) and with the modified one (simulated)
+type SimulatedAddress struct {
+	WithdrawalAddress       string   `json:"withdrawal_address"`
+	BaselineAccumulatedWei  *big.Int `json:"baseline_accumulated_wei"`
+	SimulatedAccumulatedWei *big.Int `json:"simulated_accumulated_wei"`
+	DeltaAccumulatedWei     *big.Int `json:"delta_accumulated_wei"`
+	BaselinePendingWei      *big.Int `json:"baseline_pending_wei"`
+	SimulatedPendingWei     *big.Int `json:"simulated_pending_wei"`
+	DeltaPendingWei         *big.Int `json:"delta_pending_wei"`
+}
+
+// Outcome of a simulation. Deltas are simulated minus baseline.
+type SimulationResult struct {
+	Params               SimulationParams   `json:"params"`
+	FromSlot             uint64             `json:"from_slot"`
+	ToSlot               uint64             `json:"to_slot"`
+	ReplayedSlots        uint64             `json:"replayed_slots"`
+	RealPoolFeesWei      *big.Int           `json:"real_pool_fees_wei"`
+	BaselinePoolFeesWei  *big.Int           `json:"baseline_pool_fees_wei"`
+	SimulatedPoolFeesWei *big.Int           `json:"simulated_pool_fees_wei"`
+	DeltaPoolFeesWei     *big.Int           `json:"delta_pool_fees_wei"`
+	Addresses            []SimulatedAddress `json:"addresses"`
+}
+
+// Recorded history of a given slot (or block, for events of blocks that were
+// not proposed by the pool). Replayed in the same order as AdvanceStateToNextSlot
+type replayStep struct {
+	slot      uint64
+	block     uint64
+	subs      []*contract.ContractSubscribeValidator
+	unsubs    []*contract.ContractUnsubscribeValidator
+	donations []*contract.ContractEtherReceived
+	missed    []SummarizedBlock
+	proposed  []SummarizedBlock
+	wrongFee  []SummarizedBlock
+}
+
+// Simulates what the pool rewards would have been with a different fee, collateral or
+// reward method. The recorded history (proposed, missed and wrong fee blocks, donations,
+// subscriptions and unsubscriptions) is replayed twice on throwaway oracles: once with the
+// real config (baseline) and once with the modified one. The live oracle is never modified.
+// Since beacon chain data is not part of the history, this is an approximation: manual bans,
+// validator cleanups and consolidations are not replayed, and effective balances are taken
+// from a single snapshot at the latest processed slot. Comparing against the baseline replay
+// instead of the live state keeps these approximations out of the deltas. Results are reused
+// until a new slot is processed, so they must not be modified. Only one simulation runs at a
+// time, ErrSimulationRunning is returned while another one is running.
+func (or *Oracle) Simulate(params SimulationParams) (*SimulationResult, error) {
+	if params.PoolFeesPercentOver10000 != nil &&
+		(*params.PoolFeesPercentOver10000 < 0 || *params.PoolFeesPercentOver10000 > 100*100) {
+		return nil, errors.Wrap(ErrInvalidSimulationParams, fmt.Sprintf("pool fees percent must be between 0 and 10000, got: %d",
+			*params.PoolFeesPercentOver10000))
+	}
+	if params.CollateralInWei != nil && params.CollateralInWei.Sign() < 0 {
+		return nil, errors.Wrap(ErrInvalidSimulationParams, fmt.Sprintf("collateral can't be negative, got: %s", params.CollateralInWei))
+	}
+	var forcedDistributor RewardDistributor
+	if params.RewardMethod != "" {
+		var err error
+		forcedDistributor, err = GetRewardDistributorByMethod(params.RewardMethod)
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidSimulationParams, err.Error())
+		}
+	}
+
+	// Results only change when a new slot is processed, so they are reused until then
+	key := params.key()
+	or.mutex.RLock()
+	latestProcessedSlot := or.state.LatestProcessedSlot
+	or.mutex.RUnlock()
+	if result := or.cachedSimulation(latestProcessedSlot, key); result != nil {
+		return result, nil
+	}
+
+	// Only one simulation runs at a time, the rest are rejected
+	if !or.simulationMutex.TryLock() {
+		return nil, ErrSimulationRunning
+	}
+	defer or.simulationMutex.Unlock()
+	if result := or.cachedSimulation(latestProcessedSlot, key); result != nil {
+		return result, nil
+	}
+
+	result, err := or.simulate(params, forcedDistributor)
+	if err != nil {
+		return nil, err
+	}
+	or.cacheSimulation(result.ToSlot, key, result)
+	return result, nil
+}
+
+// Replays the history twice, see Simulate
+func (or *Oracle) simulate(params SimulationParams, forcedDistributor RewardDistributor) (*SimulationResult, error) {
+	// Copy what is needed from the live state, so that the replay runs without holding the lock
+	or.mutex.RLock()
+	history := &OracleState{}
+	utils.DeepCopy(&OracleState{
+		LatestProcessedSlot:  or.state.LatestProcessedSlot,
+		PoolAccumulatedFees:  or.state.PoolAccumulatedFees,
+		Validators:           or.state.Validators,
+		SubscriptionEvents:   or.state.SubscriptionEvents,
+		UnsubscriptionEvents: or.state.UnsubscriptionEvents,
+		Donations:            or.state.Donations,
+		ProposedBlocks:       or.state.ProposedBlocks,
+		MissedBlocks:         or.state.MissedBlocks,
+		WrongFeeBlocks:       or.state.WrongFeeBlocks,
+		DeployedSlot:         or.state.DeployedSlot,
+		DeployedBlock:        or.state.DeployedBlock,
+		CollateralInWei:      or.state.CollateralInWei,
+		ConfigHistory:        or.state.ConfigHistory,
+	}, history)
+	baselineCfg := *or.cfg
+	getSetOfValidators := or.getSetOfValidators
+	or.mutex.RUnlock()
+
+	steps := buildReplaySteps(history)
+	if len(steps) == 0 {
+		return nil, errors.New("no history to simulate, the oracle has not processed any relevant slot")
+	}
+
+	simulatedCfg := baselineCfg
+	if params.PoolFeesPercentOver10000 != nil {
+		simulatedCfg.PoolFeesPercentOver10000 = *params.PoolFeesPercentOver10000
+	}
+	if params.CollateralInWei != nil {
+		simulatedCfg.CollateralInWei = new(big.Int).Set(params.CollateralInWei)
+	}
+
+	// Effective balances are only needed if any of the replays uses the electra method
+	var balances GetSetOfValidatorsFunc
+	forkSlot, found := SlotElectraFork[baselineCfg.Network]
+	if params.RewardMethod == RewardMethodElectra || (found && history.LatestProcessedSlot >= forkSlot) {
+		if getSetOfValidators == nil {
+			return nil, errors.New("electra reward method requires access to the beacon chain")
+		}
+		var err error
+		balances, err = snapshotEffectiveBalances(getSetOfValidators, history)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get effective balances")
+		}
+	}
+
+	baseline, err := replayHistory(&baselineCfg, history, steps, nil, SimulationParams{}, balances)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not replay the history")
+	}
+	simulated, err := replayHistory(&simulatedCfg, history, steps, forcedDistributor, params, balances)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not replay the history with the simulated params")
+	}
+
+	result := &SimulationResult{
+		Params:               params,
+		FromSlot:             steps[0].slot,
+		ToSlot:               history.LatestProcessedSlot,
+		ReplayedSlots:        uint64(len(steps)),
+		RealPoolFeesWei:      history.PoolAccumulatedFees,
+		BaselinePoolFeesWei:  baseline.PoolAccumulatedFees,
+		SimulatedPoolFeesWei: simulated.PoolAccumulatedFees,
+		DeltaPoolFeesWei:     new(big.Int).Sub(simulated.PoolAccumulatedFees, baseline.PoolAccumulatedFees),
+		Addresses:            compareWithdrawalBalances(baseline, simulated),
+	}
+
+	log.WithFields(log.Fields{
+		"Params":               fmt.Sprintf("%+v", params),
+		"ReplayedSlots":        result.ReplayedSlots,
+		"BaselinePoolFeesWei":  result.BaselinePoolFeesWei,
+		"SimulatedPoolFeesWei": result.SimulatedPoolFeesWei,
+	}).Info("Simulation done")
+
+	return result, nil
+}
+
+// Replays the given steps on a new oracle with the given config and returns its state. The
+// config changes of the history are applied as the replay reaches them, except for the
+// parameters overridden in params.
+func replayHistory(
+	cfg *Config,
+	history *OracleState,
+	steps []*replayStep,
+	forcedDistributor RewardDistributor,
+	params SimulationParams,
+	balances GetSetOfValidatorsFunc) (*OracleState, error) {
+
+	sim := NewOracle(cfg)
+	sim.forcedRewardDistributor = forcedDistributor
+	// Replays must not be streamed as events of the oracle
+	sim.events = nil
+	sim.SetGetSetOfValidatorsFunc(balances)
+
+	for _, step := range steps {
+		sim.state.NextSlotToProcess = step.slot
+		if change, found := configAtSlot(history.ConfigHistory, step.slot); found {
+			if params.PoolFeesPercentOver10000 == nil {
+				sim.state.PoolFeesPercentOver10000 = change.PoolFeesPercentOver10000
+			}
+			if params.CollateralInWei == nil {
+				sim.state.CollateralInWei = new(big.Int).Set(change.CollateralInWei)
+			}
+		}
+
+		err := sim.handleManualSubscriptions(step.subs, replaySubsValidators(history, step.subs))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, block := range step.missed {
+			if sim.isSubscribed(block.ValidatorIndex) {
+				sim.handleMissedBlock(block)
+			}
+		}
+
+		for _, block := range step.proposed {
+			if block.BlockType == OkPoolProposalBlsKeys {
+				err = sim.handleBlsCorrectBlockProposal(block)
+			} else if block.BlockType == OkPoolProposal {
+				err = sim.handleCorrectBlockProposal(block)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		for _, block := range step.wrongFee {
+			if sim.isSubscribed(block.ValidatorIndex) {
+				err = sim.handleBanValidator(block)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		err = sim.handleManualUnsubscriptions(step.unsubs, replayUnsubsValidators(history, step.unsubs))
+		if err != nil {
+			return nil, err
+		}
+		err = sim.handleDonations(step.donations)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sim.state.LatestProcessedSlot = history.LatestProcessedSlot
+	sim.state.NextSlotToProcess = history.LatestProcessedSlot + 1
+	return sim.state, nil
+}
+
+// Groups the recorded history into steps, sorted by slot. Events only store the block
+// number, so their slot is taken from the proposals in the same block or, if there is
+// none, estimated from the closest previous known block. The estimation is a lower
+// bound of the real slot that keeps the relative order of the events.
+func buildReplaySteps(history *OracleState) []*replayStep {
+	type stepKey struct {
+		slot  uint64
+		block uint64
+	}
+
+	slotOfBlock := make(map[uint64]uint64)
+	slotOfBlock[history.DeployedBlock] = history.DeployedSlot
+	for _, block := range history.ProposedBlocks {
+		slotOfBlock[block.Block] = block.Slot
+	}
+	for _, block := range history.WrongFeeBlocks {
+		slotOfBlock[block.Block] = block.Slot
+	}
+	knownBlocks := make([]uint64, 0, len(slotOfBlock))
+	for block := range slotOfBlock {
+		knownBlocks = append(knownBlocks, block)
+	}
+	sort.Slice(knownBlocks, func(i, j int) bool { return knownBlocks[i] < knownBlocks[j] })
+
+	estimateSlot := func(blockNumber uint64) uint64 {
+		if slot, found := slotOfBlock[blockNumber]; found {
+			return slot
+		}
+		i := sort.Search(len(knownBlocks), func(i int) bool { return knownBlocks[i] > blockNumber })
+		if i == 0 {
+			return slotOfBlock[knownBlocks[0]]
+		}
+		anchor := knownBlocks[i-1]
+		return slotOfBlock[anchor] + (blockNumber - anchor)
+	}
+
+	steps := make(map[stepKey]*replayStep)
+	getStep := func(slot uint64, block uint64) *replayStep {
+		key := stepKey{slot: slot, block: block}
+		if _, found := steps[key]; !found {
+			steps[key] = &replayStep{slot: slot, block: block}
+		}
+		return steps[key]
+	}
+
+	for _, block := range history.ProposedBlocks {
+		step := getStep(block.Slot, block.Block)
+		step.proposed = append(step.proposed, block)
+	}
+	for _, block := range history.WrongFeeBlocks {
+		step := getStep(block.Slot, block.Block)
+		step.wrongFee = append(step.wrongFee, block)
+	}
+	for _, block := range history.MissedBlocks {
+		step := getStep(block.Slot, 0)
+		step.missed = append(step.missed, block)
+	}
+	for _, sub := range history.SubscriptionEvents {
+		step := getStep(estimateSlot(sub.Raw.BlockNumber), sub.Raw.BlockNumber)
+		step.subs = append(step.subs, sub)
+	}
+	for _, unsub := range history.UnsubscriptionEvents {
+		step := getStep(estimateSlot(unsub.Raw.BlockNumber), unsub.Raw.BlockNumber)
+		step.unsubs = append(step.unsubs, unsub)
+	}
+	for _, donation := range history.Donations {
+		step := getStep(estimateSlot(donation.Raw.BlockNumber), donation.Raw.BlockNumber)
+		step.donations = append(step.donations, donation)
+	}
+
+	sorted := make([]*replayStep, 0, len(steps))
+	for _, step := range steps {
+		sorted = append(sorted, step)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].slot != sorted[j].slot {
+			return sorted[i].slot < sorted[j].slot
+		}
+		return sorted[i].block < sorted[j].block
+	})
+	return sorted
+}
+
+// Beacon chain information is not part of the history, so the validators of the subscriptions
+// are rebuilt from the ones the oracle tracks. A validator that was never tracked is only
+// assumed to exist if its subscription failed due to the collateral, since otherwise there
+// is no way to know why it failed.
+func replaySubsValidators(history *OracleState, subs []*contract.ContractSubscribeValidator) []*v1.Validator {
+	vals := make([]*v1.Validator, 0, len(subs))
+	for _, sub := range subs {
+		if tracked, found := history.Validators[sub.ValidatorID]; found {
+			vals = append(vals, replayValidator(sub.ValidatorID, tracked.WithdrawalAddress, tracked.ValidatorKey))
+		} else if sub.SubscriptionCollateral.Cmp(history.CollateralInWei) < 0 {
+			vals = append(vals, replayValidator(sub.ValidatorID, sub.Sender.String(), ""))
+		} else {
+			vals = append(vals, nil)
+		}
+	}
+	return vals
+}
+
+// Same as replaySubsValidators but for unsubscriptions. Untracked validators are not rebuilt
+// since unsubscribing them has no effect.
+func replayUnsubsValidators(history *OracleState, unsubs []*contract.ContractUnsubscribeValidator) []*v1.Validator {
+	vals := make([]*v1.Validator, 0, len(unsubs))
+	for _, unsub := range unsubs {
+		if tracked, found := history.Validators[unsub.ValidatorID]; found {
+			vals = append(vals, replayValidator(unsub.ValidatorID, tracked.WithdrawalAddress, tracked.ValidatorKey))
+		} else {
+			vals = append(vals, nil)
+		}
+	}
+	return vals
+}
+
+// Creates an active validator with 0x01 withdrawal credentials
+func replayValidator(valIndex uint64, withdrawalAddress string, validatorKey string) *v1.Validator {
+	credentials := make([]byte, 12)
+	credentials[0] = 0x01
+	credentials = append(credentials, common.HexToAddress(withdrawalAddress).Bytes()...)
+
+	var pubKey phase0.BLSPubKey
+	if key, err := hexutil.Decode(validatorKey); err == nil {
+		copy(pubKey[:], key)
+	}
+
+	return &v1.Validator{
+		Index:  phase0.ValidatorIndex(valIndex),
+		Status: v1.ValidatorStateActiveOngoing,
+		Validator: &phase0.Validator{
+			PublicKey:             pubKey,
+			WithdrawalCredentials: credentials,
+		},
+	}
+}
+
+// Fetches once the effective balances of every validator that may take part in the replay, and
+// returns a function that serves them. Validators not found are assumed to have 32 ETH.
+func snapshotEffectiveBalances(
+	getSetOfValidators GetSetOfValidatorsFunc,
+	history *OracleState) (GetSetOfValidatorsFunc, error) {
+
+	indexes := make(map[phase0.ValidatorIndex]bool)
+	for valIndex := range history.Validators {
+		indexes[phase0.ValidatorIndex(valIndex)] = true
+	}
+	for _, sub := range history.SubscriptionEvents {
+		indexes[phase0.ValidatorIndex(sub.ValidatorID)] = true
+	}
+	for _, block := range history.ProposedBlocks {
+		indexes[phase0.ValidatorIndex(block.ValidatorIndex)] = true
+	}
+	valIndices := make([]phase0.ValidatorIndex, 0, len(indexes))
+	for valIndex := range indexes {
+		valIndices = append(valIndices, valIndex)
+	}
+
+	snapshot, err := getSetOfValidators(valIndices, strconv.FormatUint(history.LatestProcessedSlot, 10))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(valIndices []phase0.ValidatorIndex, slot string, opts ...retry.Option) (map[phase0.ValidatorIndex]*v1.Validator, error) {
+		validators := make(map[phase0.ValidatorIndex]*v1.Validator, len(valIndices))
+		for _, valIndex := range valIndices {
+			if validator, found := snapshot[valIndex]; found {
+				validators[valIndex] = validator
+				continue
+			}
+			validators[valIndex] = &v1.Validator{
+				Index:     valIndex,
+				Validator: &phase0.Validator{EffectiveBalance: simulationDefaultEffectiveBalance},
+			}
+		}
+		return validators, nil
+	}, nil
+}
+
+// Aggregates the accumulated and pending rewards of both states by withdrawal address,
+// sorted by address
+func compareWithdrawalBalances(baseline *OracleState, simulated *OracleState) []SimulatedAddress {
+	byAddress := make(map[string]*SimulatedAddress)
+	getAddress := func(withdrawalAddress string) *SimulatedAddress {
+		address := strings.ToLower(withdrawalAddress)
+		if _, found := byAddress[address]; !found {
+			byAddress[address] = &SimulatedAddress{
+				WithdrawalAddress:       address,
+				BaselineAccumulatedWei:  big.NewInt(0),
+				SimulatedAccumulatedWei: big.NewInt(0),
+				BaselinePendingWei:      big.NewInt(0),
+				SimulatedPendingWei:     big.NewInt(0),
+			}
+		}
+		return byAddress[address]
+	}
+
+	for _, validator := range baseline.Validators {
+		address := getAddress(validator.WithdrawalAddress)
+		address.BaselineAccumulatedWei.Add(address.BaselineAccumulatedWei, validator.AccumulatedRewardsWei)
+		address.BaselinePendingWei.Add(address.BaselinePendingWei, validator.PendingRewardsWei)
+	}
+	for _, validator := range simulated.Validators {
+		address := getAddress(validator.WithdrawalAddress)
+		address.SimulatedAccumulatedWei.Add(address.SimulatedAccumulatedWei, validator.AccumulatedRewardsWei)
+		address.SimulatedPendingWei.Add(address.SimulatedPendingWei, validator.PendingRewardsWei)
+	}
+
+	addresses := make([]SimulatedAddress, 0, len(byAddress))
+	for _, address := range byAddress {
+		address.DeltaAccumulatedWei = new(big.Int).Sub(address.SimulatedAccumulatedWei, address.BaselineAccumulatedWei)
+		address.DeltaPendingWei = new(big.Int).Sub(address.SimulatedPendingWei, address.BaselinePendingWei)
+		addresses = append(addresses, *address)
+	}
+	sort.Slice(addresses, func(i, j int) bool {
+		return addresses[i].WithdrawalAddress < addresses[j].WithdrawalAddress
+	})
+	return addresses
+}
@@ -0,0 +1,272 @@
+package oracle
+
+import (
+	"math/big"
+	"testing"
+
+	v1 "github.com/attestantio/go-eth2-client/api/v1"
+	"github.com/dappnode/mev-sp-oracle/contract"
+	"github.com/dappnode/mev-sp-oracle/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	simAddressA = common.HexToAddress("0x9427a30991170f917d7b83def6e44d26577871ed")
+	simAddressC = common.HexToAddress("0x2222222222222222222222222222222222222222")
+)
+
+// Helper that builds an oracle with some history (between fork1 and electra in mainnet) by
+// running the same handlers that AdvanceStateToNextSlot uses:
+// - slot 10500010 (block 100): validator 1 subscribes with enough collateral
+// - slot 10500020 (block 110): validator 2 proposes a block, auto subscribing
+// - block 115: a donation is received
+// - block 125: validator 3 subscribes with not enough collateral
+// - slot 10500050 (block 130): validator 1 proposes a block
+func simulationTestOracle() *Oracle {
+	cfg := testConfig(Mainnet, 1000)
+	cfg.DeployedSlot = 10500000
+	cfg.DeployedBlock = 90
+	oracle := NewOracle(cfg)
+
+	sub1 := &contract.ContractSubscribeValidator{
+		ValidatorID:            1,
+		SubscriptionCollateral: big.NewInt(1000),
+		Sender:                 simAddressA,
+		Raw:                    types.Log{BlockNumber: 100, TxHash: [32]byte{0x1}},
+	}
+	sub3 := &contract.ContractSubscribeValidator{
+		ValidatorID:            3,
+		SubscriptionCollateral: big.NewInt(500),
+		Sender:                 simAddressC,
+		Raw:                    types.Log{BlockNumber: 125, TxHash: [32]byte{0x2}},
+	}
+	donation := &contract.ContractEtherReceived{
+		Sender:         simAddressC,
+		DonationAmount: big.NewInt(10000),
+		Raw:            types.Log{BlockNumber: 115, TxHash: [32]byte{0x3}},
+	}
+
+	oracle.state.NextSlotToProcess = 10500010
+	oracle.state.SubscriptionEvents = append(oracle.state.SubscriptionEvents, sub1)
+	oracle.handleManualSubscriptions([]*contract.ContractSubscribeValidator{sub1},
+		[]*v1.Validator{replayValidator(1, simAddressA.String(), "")})
+
+	oracle.state.NextSlotToProcess = 10500020
+	oracle.handleCorrectBlockProposal(SummarizedBlock{
+		Slot:              10500020,
+		Block:             110,
+		ValidatorIndex:    2,
+		BlockType:         OkPoolProposal,
+		Reward:            big.NewInt(1000000),
+		RewardType:        MevBlock,
+		WithdrawalAddress: "0x1111111111111111111111111111111111111111",
+	})
+
+	oracle.state.NextSlotToProcess = 10500025
+	oracle.handleDonations([]*contract.ContractEtherReceived{donation})
+
+	oracle.state.NextSlotToProcess = 10500040
+	oracle.state.SubscriptionEvents = append(oracle.state.SubscriptionEvents, sub3)
+	oracle.handleManualSubscriptions([]*contract.ContractSubscribeValidator{sub3},
+		[]*v1.Validator{replayValidator(3, simAddressC.String(), "")})
+
+	oracle.state.NextSlotToProcess = 10500050
+	oracle.handleCorrectBlockProposal(SummarizedBlock{
+		Slot:              10500050,
+		Block:             130,
+		ValidatorIndex:    1,
+		BlockType:         OkPoolProposal,
+		Reward:            big.NewInt(2000000),
+		RewardType:        VanilaBlock,
+		WithdrawalAddress: "0x9427a30991170f917d7b83def6e44d26577871ed",
+	})
+
+	oracle.state.LatestProcessedSlot = 10500060
+	oracle.state.NextSlotToProcess = 10500061
+	return oracle
+}
+
+// Sums the pool fees and the accumulated and pending rewards of all addresses
+func simulationTotal(poolFees *big.Int, addresses []SimulatedAddress, simulated bool) *big.Int {
+	total := new(big.Int).Set(poolFees)
+	for _, address := range addresses {
+		if simulated {
+			total.Add(total, address.SimulatedAccumulatedWei)
+			total.Add(total, address.SimulatedPendingWei)
+		} else {
+			total.Add(total, address.BaselineAccumulatedWei)
+			total.Add(total, address.BaselinePendingWei)
+		}
+	}
+	return total
+}
+
+func Test_buildReplaySteps(t *testing.T) {
+	oracle := simulationTestOracle()
+	steps := buildReplaySteps(oracle.state)
+
+	require.Equal(t, 5, len(steps))
+
+	// Subscription in a block not proposed by the pool, estimated from the deployment
+	require.Equal(t, uint64(10500010), steps[0].slot)
+	require.Equal(t, 1, len(steps[0].subs))
+
+	require.Equal(t, uint64(10500020), steps[1].slot)
+	require.Equal(t, 1, len(steps[1].proposed))
+
+	// Donation and subscription estimated from the block 110 proposal
+	require.Equal(t, uint64(10500025), steps[2].slot)
+	require.Equal(t, 1, len(steps[2].donations))
+	require.Equal(t, uint64(10500035), steps[3].slot)
+	require.Equal(t, 1, len(steps[3].subs))
+
+	require.Equal(t, uint64(10500050), steps[4].slot)
+	require.Equal(t, uint64(130), steps[4].block)
+}
+
+func Test_Simulate_NoChanges(t *testing.T) {
+	oracle := simulationTestOracle()
+
+	liveBefore := make(map[uint64]*ValidatorInfo)
+	utils.DeepCopy(oracle.state.Validators, &liveBefore)
+	poolFeesBefore := new(big.Int).Set(oracle.state.PoolAccumulatedFees)
+
+	result, err := oracle.Simulate(SimulationParams{})
+	require.NoError(t, err)
+
+	// Replaying with the same config matches the real state
+	require.Equal(t, oracle.state.PoolAccumulatedFees, result.RealPoolFeesWei)
+	require.Equal(t, result.RealPoolFeesWei, result.BaselinePoolFeesWei)
+	require.Equal(t, 0, result.DeltaPoolFeesWei.Sign())
+	require.Equal(t, uint64(10500010), result.FromSlot)
+	require.Equal(t, uint64(10500060), result.ToSlot)
+
+	require.Equal(t, 2, len(result.Addresses))
+	require.Equal(t, "0x1111111111111111111111111111111111111111", result.Addresses[0].WithdrawalAddress)
+	require.Equal(t, "0x9427a30991170f917d7b83def6e44d26577871ed", result.Addresses[1].WithdrawalAddress)
+	require.Equal(t, oracle.state.Validators[2].AccumulatedRewardsWei, result.Addresses[0].BaselineAccumulatedWei)
+	require.Equal(t, oracle.state.Validators[2].PendingRewardsWei, result.Addresses[0].BaselinePendingWei)
+	require.Equal(t, oracle.state.Validators[1].AccumulatedRewardsWei, result.Addresses[1].BaselineAccumulatedWei)
+	for _, address := range result.Addresses {
+		require.Equal(t, 0, address.DeltaAccumulatedWei.Sign())
+		require.Equal(t, 0, address.DeltaPendingWei.Sign())
+	}
+
+	// The live oracle is not modified
+	require.Equal(t, liveBefore, oracle.state.Validators)
+	require.Equal(t, poolFeesBefore, oracle.state.PoolAccumulatedFees)
+	require.Equal(t, 2, len(oracle.state.ProposedBlocks))
+	require.Equal(t, 1, len(oracle.state.Donations))
+}
+
+func Test_Simulate_PoolFee(t *testing.T) {
+	oracle := simulationTestOracle()
+
+	fee := 0
+	result, err := oracle.Simulate(SimulationParams{PoolFeesPercentOver10000: &fee})
+	require.NoError(t, err)
+
+	// Without fees the pool only gets the collateral of the failed subscription and the roundings
+	require.Equal(t, big.NewInt(500), result.SimulatedPoolFeesWei)
+	require.Equal(t, -1, result.DeltaPoolFeesWei.Sign())
+	for _, address := range result.Addresses {
+		require.Equal(t, 1, new(big.Int).Add(address.DeltaAccumulatedWei, address.DeltaPendingWei).Sign())
+	}
+
+	// Nothing is created nor lost
+	require.Equal(t,
+		simulationTotal(result.BaselinePoolFeesWei, result.Addresses, false),
+		simulationTotal(result.SimulatedPoolFeesWei, result.Addresses, true))
+}
+
+func Test_Simulate_Collateral(t *testing.T) {
+	oracle := simulationTestOracle()
+
+	// Validator 3 subscription is now valid
+	result, err := oracle.Simulate(SimulationParams{CollateralInWei: big.NewInt(500)})
+	require.NoError(t, err)
+
+	require.Equal(t, 3, len(result.Addresses))
+	require.Equal(t, "0x2222222222222222222222222222222222222222", result.Addresses[1].WithdrawalAddress)
+	require.Equal(t, 0, result.Addresses[1].BaselinePendingWei.Sign())
+	require.Equal(t, 1, result.Addresses[1].SimulatedPendingWei.Sign())
+	require.Equal(t, -1, result.DeltaPoolFeesWei.Sign())
+
+	// A collateral too high makes validator 1 subscription fail, its collateral goes to the pool
+	result, err = oracle.Simulate(SimulationParams{CollateralInWei: big.NewInt(2000)})
+	require.NoError(t, err)
+	require.Equal(t, 1, result.DeltaPoolFeesWei.Sign())
+	require.Equal(t,
+		simulationTotal(result.BaselinePoolFeesWei, result.Addresses, false),
+		simulationTotal(result.SimulatedPoolFeesWei, result.Addresses, true))
+}
+
+func Test_Simulate_RewardMethod(t *testing.T) {
+	oracle := simulationTestOracle()
+
+	result, err := oracle.Simulate(SimulationParams{RewardMethod: RewardMethodPreFork1})
+	require.NoError(t, err)
+	require.Equal(t,
+		simulationTotal(result.BaselinePoolFeesWei, result.Addresses, false),
+		simulationTotal(result.SimulatedPoolFeesWei, result.Addresses, true))
+
+	// Electra needs the effective balances from the beacon chain
+	_, err = oracle.Simulate(SimulationParams{RewardMethod: RewardMethodElectra})
+	require.Error(t, err)
+	require.NotEqual(t, ErrInvalidSimulationParams, errors.Cause(err))
+}
+
+func Test_Simulate_InvalidParams(t *testing.T) {
+	oracle := simulationTestOracle()
+
+	fee := 10001
+	_, err := oracle.Simulate(SimulationParams{PoolFeesPercentOver10000: &fee})
+	require.Equal(t, ErrInvalidSimulationParams, errors.Cause(err))
+
+	_, err = oracle.Simulate(SimulationParams{CollateralInWei: big.NewInt(-1)})
+	require.Equal(t, ErrInvalidSimulationParams, errors.Cause(err))
+
+	_, err = oracle.Simulate(SimulationParams{RewardMethod: "unknown"})
+	require.Equal(t, ErrInvalidSimulationParams, errors.Cause(err))
+
+	// Nothing to replay, which is not caused by the params
+	_, err = NewOracle(testConfig(Mainnet, 1000)).Simulate(SimulationParams{})
+	require.Error(t, err)
+	require.NotEqual(t, ErrInvalidSimulationParams, errors.Cause(err))
+}
+
+func Test_Simulate_Cached(t *testing.T) {
+	oracle := simulationTestOracle()
+
+	fee := 500
+	result, err := oracle.Simulate(SimulationParams{PoolFeesPercentOver10000: &fee, CollateralInWei: big.NewInt(10)})
+	require.NoError(t, err)
+
+	// Same params, reused until a new slot is processed
+	cached, err := oracle.Simulate(SimulationParams{PoolFeesPercentOver10000: &fee, CollateralInWei: big.NewInt(10)})
+	require.NoError(t, err)
+	require.Same(t, result, cached)
+
+	other, err := oracle.Simulate(SimulationParams{PoolFeesPercentOver10000: &fee})
+	require.NoError(t, err)
+	require.NotSame(t, result, other)
+
+	// While a simulation runs, cached results are served and new ones rejected
+	oracle.simulationMutex.Lock()
+	cached, err = oracle.Simulate(SimulationParams{PoolFeesPercentOver10000: &fee})
+	require.NoError(t, err)
+	require.Same(t, other, cached)
+	_, err = oracle.Simulate(SimulationParams{})
+	require.Equal(t, ErrSimulationRunning, err)
+	oracle.simulationMutex.Unlock()
+
+	oracle.state.LatestProcessedSlot++
+	updated, err := oracle.Simulate(SimulationParams{PoolFeesPercentOver10000: &fee, CollateralInWei: big.NewInt(10)})
+	require.NoError(t, err)
+	require.NotSame(t, result, updated)
+	require.Equal(t, uint64(10500061), updated.ToSlot)
+	require.Equal(t, 1, len(oracle.simulations))
+}
@@ -0,0 +1,101 @@
+package oracle
+
+import (
+	"maps"
+	"math/big"
+	"slices"
+)
+
+// Returns an immutable copy of the state as of the latest change, that can be read while
+// the oracle keeps processing. Its only copied when read after a change, and while the
+// oracle is processing a slot the copy of the previous change is returned without taking
+// the lock. It must not be modified
+func (or *Oracle) Snapshot() *OracleState {
+	published := or.snapshot.Load()
+	if published != nil && published.version == or.stateVersion.Load() {
+		return published.state
+	}
+	if !or.mutex.TryRLock() {
+		if published != nil {
+			return published.state
+		}
+		or.mutex.RLock()
+	}
+	defer or.mutex.RUnlock()
+	return or.copySnapshotLockFree()
+}
+
+// Copy of the state at a given version. See Snapshot
+type stateSnapshot struct {
+	version uint64
+	state   *OracleState
+}
+
+// Publishes a new version of the state, that is copied the next time its read. Must be
+// called with the write lock held
+func (or *Oracle) updateSnapshotLockFree() {
+	or.stateVersion.Add(1)
+}
+
+// Returns the copy of the current version of the state, copying it if it was not yet. Must
+// be called with a lock held, so the version cant change while its copied
+func (or *Oracle) copySnapshotLockFree() *OracleState {
+	version := or.stateVersion.Load()
+	if published := or.snapshot.Load(); published != nil && published.version == version {
+		return published.state
+	}
+	published := &stateSnapshot{version: version, state: snapshotState(or.state)}
+	or.snapshot.Store(published)
+	return published.state
+}
+
+// Copies the state without copying what the oracle only appends to. The copy keeps its own
+// slice headers so the appends dont reach it, and the entries the oracle modifies in place
+// (validators, balances, consolidations and bls proposers) are copied. Commited states
+// are never modified once frozen, so they are shared
+func snapshotState(state *OracleState) *OracleState {
+	snapshot := *state
+	snapshot.PoolAccumulatedFees = copyBigInt(state.PoolAccumulatedFees)
+	snapshot.CollateralInWei = copyBigInt(state.CollateralInWei)
+	snapshot.Validators = CopyValidators(state.Validators)
+	snapshot.CommitedStates = maps.Clone(state.CommitedStates)
+
+	snapshot.SubscriptionEvents = appendOnly(state.SubscriptionEvents)
+	snapshot.UnsubscriptionEvents = appendOnly(state.UnsubscriptionEvents)
+	snapshot.EtherReceivedEvents = appendOnly(state.EtherReceivedEvents)
+	snapshot.Donations = appendOnly(state.Donations)
+	snapshot.ProposedBlocks = appendOnly(state.ProposedBlocks)
+	snapshot.MissedBlocks = appendOnly(state.MissedBlocks)
+	snapshot.WrongFeeBlocks = appendOnly(state.WrongFeeBlocks)
+	snapshot.ConfigHistory = appendOnly(state.ConfigHistory)
+	snapshot.RewardRecipientHistory = appendOnly(state.RewardRecipientHistory)
+	snapshot.Claims = appendOnly(state.Claims)
+
+	snapshot.RewardRecipients = maps.Clone(state.RewardRecipients)
+	snapshot.Consolidations = slices.Clone(state.Consolidations)
+	if state.ClaimedRewards != nil {
+		snapshot.ClaimedRewards = make(map[string]*big.Int, len(state.ClaimedRewards))
+		for withdrawalAddress, claimed := range state.ClaimedRewards {
+			snapshot.ClaimedRewards[withdrawalAddress] = copyBigInt(claimed)
+		}
+	}
+	if state.BlsProposers != nil {
+		snapshot.BlsProposers = make(map[uint64]*BlsProposerInfo, len(state.BlsProposers))
+		for valIndex, proposer := range state.BlsProposers {
+			proposerCopy := copyBlsProposer(proposer)
+			snapshot.BlsProposers[valIndex] = &proposerCopy
+		}
+	}
+	if state.ValidatorTransitions != nil {
+		snapshot.ValidatorTransitions = make(map[uint64][]StateTransition, len(state.ValidatorTransitions))
+		for valIndex, transitions := range state.ValidatorTransitions {
+			snapshot.ValidatorTransitions[valIndex] = appendOnly(transitions)
+		}
+	}
+	return &snapshot
+}
+
+// Caps the capacity, so that appending to the copy never writes to the original array
+func appendOnly[T any](values []T) []T {
+	return values[:len(values):len(values)]
+}
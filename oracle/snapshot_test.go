@@ -0,0 +1,79 @@
+package oracle
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Snapshot(t *testing.T) {
+	address := "0x00000000000000000000000000000000000000a1"
+	oracle := testOracle(Mainnet, 0)
+	oracle.state.Validators[1] = trackedValidator(1, Active, 0, address)
+	oracle.state.Consolidations = []Consolidation{{SourceIndex: 1, TargetIndex: 2, Status: ConsolidationPending}}
+	oracle.applyClaim(Claim{WithdrawalAddress: address, AmountWei: big.NewInt(10)})
+	oracle.state.BlsProposers = addBlsProposal(nil, blsProposalBlock(900, 5, 10))
+
+	// Changes are only seen once a new snapshot is published
+	require.Empty(t, oracle.Snapshot().Validators)
+	_, err := oracle.AdvanceStateToNextSlot(missedFullBlock(1000))
+	require.NoError(t, err)
+	snapshot := oracle.Snapshot()
+	require.Equal(t, uint64(1000), snapshot.LatestProcessedSlot)
+	require.Len(t, snapshot.Validators, 1)
+
+	// Whatever the oracle modifies afterwards does not reach it
+	oracle.state.Validators[1].PendingRewardsWei.SetInt64(50)
+	oracle.state.Validators[1].ValidatorStatus = YellowCard
+	oracle.state.Validators[2] = trackedValidator(2, Active, 0, address)
+	oracle.state.Consolidations[0].Status = ConsolidationCompleted
+	oracle.applyClaim(Claim{WithdrawalAddress: address, AmountWei: big.NewInt(5)})
+	oracle.state.BlsProposers[5].RewardsWei.SetInt64(20)
+	oracle.state.PoolAccumulatedFees.SetInt64(7)
+	oracle.state.MissedBlocks = append(oracle.state.MissedBlocks, SummarizedBlock{Slot: 1001})
+
+	require.Equal(t, big.NewInt(0), snapshot.Validators[1].PendingRewardsWei)
+	require.Equal(t, Active, snapshot.Validators[1].ValidatorStatus)
+	require.Len(t, snapshot.Validators, 1)
+	require.Equal(t, ConsolidationPending, snapshot.Consolidations[0].Status)
+	require.Len(t, snapshot.Claims, 1)
+	require.Equal(t, big.NewInt(10), snapshot.ClaimedRewards[address])
+	require.Equal(t, big.NewInt(10), snapshot.BlsProposers[5].RewardsWei)
+	require.Equal(t, big.NewInt(0), snapshot.PoolAccumulatedFees)
+	require.Empty(t, snapshot.MissedBlocks)
+
+	// And appending to it does not reach the state
+	snapshot.Claims = append(snapshot.Claims, Claim{})
+	require.Len(t, oracle.state.Claims, 2)
+	require.Equal(t, big.NewInt(5), oracle.state.Claims[1].AmountWei)
+
+	// The state with hash is a hashed copy of the snapshot
+	hashed, err := oracle.StateWithHash()
+	require.NoError(t, err)
+	require.NotEmpty(t, hashed.StateHash)
+	require.Empty(t, oracle.Snapshot().StateHash)
+	require.Equal(t, oracle.Snapshot().LatestProcessedSlot, hashed.LatestProcessedSlot)
+}
+
+func Test_Snapshot_CopiedWhenRead(t *testing.T) {
+	oracle := testOracle(Mainnet, 0)
+
+	// Processing slots does not copy the state until its read
+	for slot := uint64(1000); slot < 1010; slot++ {
+		_, err := oracle.AdvanceStateToNextSlot(missedFullBlock(slot))
+		require.NoError(t, err)
+	}
+	require.Equal(t, uint64(999), oracle.snapshot.Load().state.LatestProcessedSlot)
+	snapshot := oracle.Snapshot()
+	require.Equal(t, uint64(1009), snapshot.LatestProcessedSlot)
+	require.Same(t, snapshot, oracle.Snapshot())
+
+	// While a slot is being processed the previous copy is returned without waiting
+	oracle.mutex.Lock()
+	oracle.state.LatestProcessedSlot = 1010
+	oracle.updateSnapshotLockFree()
+	require.Same(t, snapshot, oracle.Snapshot())
+	oracle.mutex.Unlock()
+	require.Equal(t, uint64(1010), oracle.Snapshot().LatestProcessedSlot)
+}
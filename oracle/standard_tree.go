@@ -0,0 +1,153 @@
+package oracle
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/pkg/errors"
+)
+
+// Format of the tree dumps. Its not "standard-v1" since the leafs are hashed once with
+// abi.encodePacked, as the contract does, and not twice with abi.encode as OpenZeppelin does
+const StandardTreeFormat = "smoothing-pool-v1"
+
+// Tree of a commited state. Its fields are named as in OpenZeppelin's StandardMerkleTree dumps,
+// but its built as the contract expects so OpenZeppelin's tooling cant load it. Tree contains
+// the nodes level by level from the root down, each level being twice the one above: levels
+// with an odd amount of nodes are padded repeating their last node. Each value contains its
+// leaf ([address, amount in wei]), its index in Tree and its proof.
+type StandardTreeDump struct {
+	Format       string              `json:"format"`
+	LeafEncoding []string            `json:"leafEncoding"`
+	Slot         uint64              `json:"slot"`
+	Root         string              `json:"root"`
+	Tree         []string            `json:"tree"`
+	Values       []StandardTreeValue `json:"values"`
+}
+
+type StandardTreeValue struct {
+	Value     []string `json:"value"`
+	TreeIndex int      `json:"treeIndex"`
+	Proof     []string `json:"proof"`
+}
+
+// Returns the tree of the state commited at the given slot, ensuring it matches its root
+func (or *Oracle) StandardTree(slot uint64) (*StandardTreeDump, error) {
+	or.mutex.RLock()
+	commited, found := or.state.CommitedStates[slot]
+	or.mutex.RUnlock()
+	if !found {
+		return nil, errors.New(fmt.Sprintf("no commited state at slot %d", slot))
+	}
+	// Old states that could not be upgraded dont know the order of their leafs
+	if len(commited.OrderedLeafs) < 2 {
+		return nil, errors.New(fmt.Sprintf("commited state at slot %d has no ordered leafs", slot))
+	}
+
+	levels := treeLevels(commited.OrderedLeafs)
+	root := hexutil.Encode(levels[len(levels)-1][0])
+	if !strings.EqualFold(root, commited.MerkleRoot) {
+		return nil, errors.New(fmt.Sprintf("rebuilt root does not match the commited one at slot %d: %s vs %s",
+			slot, root, commited.MerkleRoot))
+	}
+
+	dump := &StandardTreeDump{
+		Format:       StandardTreeFormat,
+		LeafEncoding: []string{"address", "uint256"},
+		Slot:         slot,
+		Root:         root,
+		Tree:         make([]string, 0),
+		Values:       make([]StandardTreeValue, 0, len(commited.OrderedLeafs)),
+	}
+	for level := len(levels) - 1; level >= 0; level-- {
+		for _, node := range levels[level] {
+			dump.Tree = append(dump.Tree, hexutil.Encode(node))
+		}
+	}
+	firstLeafIndex := len(dump.Tree) - len(levels[0])
+	for i, leaf := range commited.OrderedLeafs {
+		dump.Values = append(dump.Values, StandardTreeValue{
+			Value:     []string{leaf.WithdrawalAddress, leaf.AccumulatedBalanceWei.String()},
+			TreeIndex: firstLeafIndex + i,
+			Proof:     levelsProof(levels, i),
+		})
+	}
+	return dump, nil
+}
+
+// Returns true if the proof shows that the leaf of the withdrawal address and amount is
+// part of the tree with the given root. Mirrors the contract, that hashes the leaf as
+// keccak256(abi.encodePacked(address, uint256)) and uses OpenZeppelin's MerkleProof.verify
+func VerifyProof(root string, withdrawalAddress string, amountWei *big.Int, siblings []string) (bool, error) {
+	rootBytes, err := hexutil.Decode(root)
+	if err != nil || len(rootBytes) != 32 {
+		return false, errors.New("invalid root: " + root)
+	}
+	if !common.IsHexAddress(withdrawalAddress) {
+		return false, errors.New("invalid withdrawal address: " + withdrawalAddress)
+	}
+	if amountWei == nil || amountWei.Sign() < 0 {
+		return false, errors.New("invalid amount")
+	}
+
+	computed := LeafHash(withdrawalAddress, amountWei)
+	for _, sibling := range siblings {
+		siblingBytes, err := hexutil.Decode(sibling)
+		if err != nil || len(siblingBytes) != 32 {
+			return false, errors.New("invalid proof element: " + sibling)
+		}
+		computed = hashPair(computed, siblingBytes)
+	}
+	return bytes.Equal(computed, rootBytes), nil
+}
+
+// Keccak of both nodes concatenated, the smaller first
+func hashPair(a []byte, b []byte) []byte {
+	pair := make([]byte, 0, len(a)+len(b))
+	if bytes.Compare(a, b) < 0 {
+		pair = append(append(pair, a...), b...)
+	} else {
+		pair = append(append(pair, b...), a...)
+	}
+	hash, _ := KeccakHash(pair)
+	return hash
+}
+
+// Returns the nodes of the tree level by level from the leafs up to the root, built as
+// GenerateTreeFromLeafs does: odd levels are padded repeating their last node
+func treeLevels(orderedLeafs []RawLeaf) [][][]byte {
+	level := make([][]byte, 0, len(orderedLeafs)+1)
+	for _, leaf := range orderedLeafs {
+		level = append(level, LeafHash(leaf.WithdrawalAddress, leaf.AccumulatedBalanceWei))
+	}
+	levels := make([][][]byte, 0)
+	for {
+		if len(level) == 1 {
+			return append(levels, level)
+		}
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		levels = append(levels, level)
+		parents := make([][]byte, 0, len(level)/2+1)
+		for i := 0; i < len(level); i += 2 {
+			parents = append(parents, hashPair(level[i], level[i+1]))
+		}
+		level = parents
+	}
+}
+
+// Returns the siblings of the leaf at the given index, from the leafs up to the root
+func levelsProof(levels [][][]byte, leafIndex int) []string {
+	proof := make([]string, 0, len(levels)-1)
+	index := leafIndex
+	for _, level := range levels[:len(levels)-1] {
+		proof = append(proof, hexutil.Encode(level[index^1]))
+		index /= 2
+	}
+	return proof
+}
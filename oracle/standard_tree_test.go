@@ -0,0 +1,92 @@
+package oracle
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_StandardTree(t *testing.T) {
+	// Pool fees leaf plus 4 addresses, so that the leafs and one level above are padded
+	oracle := testOracle(Mainnet, 0)
+	for i := uint64(1); i <= 4; i++ {
+		oracle.state.Validators[i] = trackedValidator(i, Active, 0, fmt.Sprintf("0x00000000000000000000000000000000000000a%d", i))
+		oracle.state.Validators[i].AccumulatedRewardsWei = big.NewInt(int64(i * 100))
+	}
+	oracle.state.ProposedBlocks = []SummarizedBlock{{RewardType: VanilaBlock, Reward: big.NewInt(1000)}}
+	oracle.state.LatestProcessedSlot = 1100
+	require.True(t, oracle.FreezeCheckpoint())
+	commited := oracle.state.CommitedStates[1100]
+
+	dump, err := oracle.StandardTree(1100)
+	require.NoError(t, err)
+	require.Equal(t, StandardTreeFormat, dump.Format)
+	require.Equal(t, []string{"address", "uint256"}, dump.LeafEncoding)
+	require.Equal(t, commited.MerkleRoot, dump.Root)
+	require.Equal(t, dump.Root, dump.Tree[0])
+	// 1 root + 2 + 4 + 6 leafs
+	require.Equal(t, 13, len(dump.Tree))
+	require.Equal(t, 5, len(dump.Values))
+
+	for i, value := range dump.Values {
+		leaf := commited.OrderedLeafs[i]
+		require.Equal(t, []string{leaf.WithdrawalAddress, leaf.AccumulatedBalanceWei.String()}, value.Value)
+		require.Equal(t, 7+i, value.TreeIndex)
+
+		// Same proofs as the ones served by the api
+		_, proof, found, err := oracle.Proof(1100, leaf.WithdrawalAddress)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, proof, value.Proof)
+
+		valid, err := VerifyProof(dump.Root, leaf.WithdrawalAddress, leaf.AccumulatedBalanceWei, value.Proof)
+		require.NoError(t, err)
+		require.True(t, valid)
+	}
+
+	_, err = oracle.StandardTree(1200)
+	require.Error(t, err)
+}
+
+func Test_VerifyProof(t *testing.T) {
+	oracle := proofsTestOracle(t, 1100)
+	root := oracle.state.CommitedStates[1100].MerkleRoot
+	address := "0x00000000000000000000000000000000000000a1"
+	leaf, proof, _, err := oracle.Proof(1100, address)
+	require.NoError(t, err)
+
+	valid, err := VerifyProof(root, address, leaf.AccumulatedBalanceWei, proof)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	// Checksummed addresses are the same leaf
+	valid, err = VerifyProof(root, "0x00000000000000000000000000000000000000A1", leaf.AccumulatedBalanceWei, proof)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	// Wrong amount, address, root or proof
+	valid, err = VerifyProof(root, address, big.NewInt(101), proof)
+	require.NoError(t, err)
+	require.False(t, valid)
+	valid, err = VerifyProof(root, "0x00000000000000000000000000000000000000a2", leaf.AccumulatedBalanceWei, proof)
+	require.NoError(t, err)
+	require.False(t, valid)
+	valid, err = VerifyProof("0x0000000000000000000000000000000000000000000000000000000000000001", address, leaf.AccumulatedBalanceWei, proof)
+	require.NoError(t, err)
+	require.False(t, valid)
+	valid, err = VerifyProof(root, address, leaf.AccumulatedBalanceWei, proof[:1])
+	require.NoError(t, err)
+	require.False(t, valid)
+
+	// Malformed inputs
+	_, err = VerifyProof("0x1234", address, leaf.AccumulatedBalanceWei, proof)
+	require.Error(t, err)
+	_, err = VerifyProof(root, "0x1234", leaf.AccumulatedBalanceWei, proof)
+	require.Error(t, err)
+	_, err = VerifyProof(root, address, big.NewInt(-1), proof)
+	require.Error(t, err)
+	_, err = VerifyProof(root, address, leaf.AccumulatedBalanceWei, []string{"0xzz"})
+	require.Error(t, err)
+}
@@ -35,6 +35,8 @@ const (
 	NotSubscribed ValidatorStatus = 4
 	Banned        ValidatorStatus = 5
 	Untracked     ValidatorStatus = 6
+	// Not tracked but proposed to the pool with BLS credentials. Only used by the api
+	BlsProposer ValidatorStatus = 7
 )
 
 // Events in the state machine that trigger transition
@@ -80,6 +82,9 @@ const (
 	Auto   SubscriptionType = 1
 )
 
+// Config of the oracle, shared with the api and never modified once created. The parameters
+// that governance can change (pool fees, pool fees address, checkpoint size and collateral)
+// are the ones the contract was deployed with. The ones in effect are in the state
 type Config struct {
 	ConsensusEndpoint        string   `json:"consensus_endpoint"`
 	ExecutionEndpoint        string   `json:"execution_endpoint"`
@@ -93,10 +98,127 @@ type Config struct {
 	DryRun                   bool     `json:"dry_run"`
 	NumRetries               int      `json:"num_retries"`
 	CollateralInWei          *big.Int `json:"collateral_in_wei"`
+	RequireReconciliation    bool     `json:"require_reconciliation"`
+	BlsCreditSlot            uint64   `json:"bls_credit_slot"`
 	UpdaterKeyPass           string   `json:"-"`
 	UpdaterKeyFile           string   `json:"-"`
 }
 
+// Snapshot of the config parameters that governance can change in the contract,
+// in effect from Slot onwards. Checkpoints are created every CheckPointSizeInSlots
+// counting from CheckpointAnchorSlot. BlsCredit is set from the bls credit slot of
+// the config, see Config.BlsCreditSlot.
+type ConfigChange struct {
+	Slot                     uint64   `json:"slot"`
+	Block                    uint64   `json:"block"`
+	PoolFeesPercentOver10000 int      `json:"pool_fees_percent_over_10000"`
+	PoolFeesAddress          string   `json:"pool_fees_address"`
+	CheckPointSizeInSlots    uint64   `json:"check_point_size_in_slots"`
+	CheckpointAnchorSlot     uint64   `json:"checkpoint_anchor_slot"`
+	CollateralInWei          *big.Int `json:"collateral_in_wei"`
+	BlsCredit                bool     `json:"bls_credit,omitempty"`
+}
+
+// Result of comparing what the pool owes (liabilities) with what the pool contract
+// holds (assets) at a given block. Inputs are captured at the latest processed block.
+// - assets: balance of the pool contract
+// - liabilities: accumulated + pending rewards of all validators + pool fees - already claimed
+// - delta: assets - liabilities, which must be zero
+type ReconciliationReport struct {
+	Slot           uint64   `json:"slot"`
+	Block          uint64   `json:"block"`
+	Timestamp      int64    `json:"timestamp"`
+	Success        bool     `json:"success"`
+	Error          string   `json:"error,omitempty"`
+	RewardsWei     *big.Int `json:"rewards_wei,omitempty"`
+	ClaimedWei     *big.Int `json:"claimed_wei,omitempty"`
+	AssetsWei      *big.Int `json:"assets_wei,omitempty"`
+	LiabilitiesWei *big.Int `json:"liabilities_wei,omitempty"`
+	DeltaWei       *big.Int `json:"delta_wei,omitempty"`
+}
+
+// A withdrawal address setting who receives its rewards when claiming. A zero
+// RewardRecipient removes the mapping, so rewards go to the withdrawal address again.
+// Slot is 0 for changes backfilled from states that did not track them.
+type RewardRecipientChange struct {
+	Slot              uint64 `json:"slot"`
+	Block             uint64 `json:"block"`
+	TxHash            string `json:"tx_hash"`
+	WithdrawalAddress string `json:"withdrawal_address"`
+	RewardRecipient   string `json:"reward_recipient"`
+}
+
+// Rewards claimed from the contract by a withdrawal address, sent to RewardAddress.
+// AmountWei is what was transferred in this claim, not the accumulated balance.
+// Slot is 0 for claims backfilled from states that did not track them.
+type Claim struct {
+	Slot              uint64   `json:"slot"`
+	Block             uint64   `json:"block"`
+	TxHash            string   `json:"tx_hash"`
+	WithdrawalAddress string   `json:"withdrawal_address"`
+	RewardAddress     string   `json:"reward_address"`
+	AmountWei         *big.Int `json:"amount_wei"`
+}
+
+// Validator that proposed blocks to the pool with BLS credentials, whose rewards went to the
+// pool. Once it rotates to execution credentials it is auto subscribed, and if configured its
+// past rewards are credited back from the pool fees as pending rewards.
+type BlsProposerInfo struct {
+	ValidatorIndex    uint64   `json:"validator_index"`
+	ValidatorKey      string   `json:"validator_key"`
+	ProposalSlots     []uint64 `json:"proposal_slots"`
+	RewardsWei        *big.Int `json:"rewards_wei"`
+	RotationSlot      uint64   `json:"rotation_slot,omitempty"`
+	WithdrawalAddress string   `json:"withdrawal_address,omitempty"`
+	CreditedWei       *big.Int `json:"credited_wei,omitempty"`
+}
+
+// Status of an EIP-7251 consolidation in the beacon chain
+type ConsolidationStatus string
+
+const (
+	// Seen in a block, not yet accepted by the beacon chain
+	ConsolidationRequested ConsolidationStatus = "requested"
+	// In the pending consolidations of the beacon chain
+	ConsolidationPending ConsolidationStatus = "pending"
+	// The balance of the source was moved to the target
+	ConsolidationCompleted ConsolidationStatus = "completed"
+	// The request was not accepted by the beacon chain
+	ConsolidationRejected ConsolidationStatus = "rejected"
+	// The source was slashed while pending, so its balance was not moved
+	ConsolidationCancelled ConsolidationStatus = "cancelled"
+)
+
+// What the target of a consolidation was when the pending rewards of the source were transferred
+type ConsolidationTarget string
+
+const (
+	TargetSubscribed    ConsolidationTarget = "subscribed"
+	TargetNotSubscribed ConsolidationTarget = "not_subscribed"
+	TargetUntracked     ConsolidationTarget = "untracked"
+	TargetBanned        ConsolidationTarget = "banned"
+)
+
+// Consolidation of a tracked source validator into a target. The pending rewards of the
+// source follow its balance to the target, or go to the pool if the target is banned.
+// RequestSlot is 0 for consolidations found in the beacon chain without seeing the request.
+type Consolidation struct {
+	SourceIndex                uint64              `json:"source_index"`
+	SourceKey                  string              `json:"source_key"`
+	TargetIndex                uint64              `json:"target_index"`
+	TargetKey                  string              `json:"target_key,omitempty"`
+	Status                     ConsolidationStatus `json:"status"`
+	RequestSlot                uint64              `json:"request_slot"`
+	RequestBlock               uint64              `json:"request_block"`
+	CompletedSlot              uint64              `json:"completed_slot,omitempty"`
+	TransferSlot               uint64              `json:"transfer_slot,omitempty"`
+	TransferredPendingWei      *big.Int            `json:"transferred_pending_wei,omitempty"`
+	Target                     ConsolidationTarget `json:"target,omitempty"`
+	SourceWithdrawalAddress    string              `json:"source_withdrawal_address,omitempty"`
+	TargetWithdrawalAddress    string              `json:"target_withdrawal_address,omitempty"`
+	DifferentWithdrawalAddress bool                `json:"different_withdrawal_address,omitempty"`
+}
+
 // All the events that the contract can emit
 type Events struct {
 	EtherReceived                []*contract.ContractEtherReceived                `json:"ether_received_events"`
@@ -179,8 +301,14 @@ type OnchainState struct {
 	TxHash     string                    `json:"tx_hash"`
 	MerkleRoot string                    `json:"merkle_root"`
 	Validators map[uint64]*ValidatorInfo `json:"validators"`
-	Leafs      map[string]RawLeaf        `json:"leafs"`
-	Proofs     map[string][]string       `json:"proofs"`
+
+	// Leafs of the tree in order, the pool fees one first. Proofs are generated from them
+	OrderedLeafs []RawLeaf `json:"ordered_leafs,omitempty"`
+
+	// Only in states created by older versions, that stored the proof of every address.
+	// Replaced by OrderedLeafs when loaded, unless they dont rebuild the same root
+	Leafs  map[string]RawLeaf  `json:"leafs,omitempty"`
+	Proofs map[string][]string `json:"proofs,omitempty"`
 }
 
 type OracleState struct {
@@ -220,6 +348,58 @@ type OracleState struct {
 	DeployedBlock            uint64   `json:"deployed_block"`
 	DeployedSlot             uint64   `json:"deployed_slot"`
 	CollateralInWei          *big.Int `json:"collateral_in_wei"`
+
+	// History of the config parameters, starting with the ones the contract was deployed
+	// with and followed by every governance change. Omitted if empty to keep the hash of
+	// states created before it existed.
+	ConfigHistory []ConfigChange `json:"config_history,omitempty"`
+
+	// Withdrawal address to the address that receives its rewards when claiming, as set
+	// with SetRewardRecipient. Withdrawal addresses without a recipient are not stored.
+	RewardRecipients map[string]string `json:"reward_recipients,omitempty"`
+
+	// Every SetRewardRecipient change, oldest first
+	RewardRecipientHistory []RewardRecipientChange `json:"reward_recipient_history,omitempty"`
+
+	// False for states created before reward recipients were tracked, which need
+	// to be backfilled with the events they processed. See BackfillRewardRecipients
+	RewardRecipientsIndexed bool `json:"reward_recipients_indexed,omitempty"`
+
+	// Total rewards claimed by each withdrawal address, as ClaimedBalance in the contract
+	ClaimedRewards map[string]*big.Int `json:"claimed_rewards,omitempty"`
+
+	// Every ClaimRewards event, oldest first
+	Claims []Claim `json:"claims,omitempty"`
+
+	// False for states created before claims were tracked. See BackfillClaims
+	ClaimsIndexed bool `json:"claims_indexed,omitempty"`
+
+	// Consolidations of tracked validators, oldest request first
+	Consolidations []Consolidation `json:"consolidations,omitempty"`
+
+	// Validators that proposed to the pool with BLS credentials, by index
+	BlsProposers map[uint64]*BlsProposerInfo `json:"bls_proposers,omitempty"`
+
+	// Status changes of each validator, oldest first. Only the ones since this was tracked
+	ValidatorTransitions map[uint64][]StateTransition `json:"validator_transitions,omitempty"`
+}
+
+// What caused a change in the status of a validator. Only the fields that apply are set: the
+// block proposed, missed or with a wrong fee, the tx of the subscription, unsubscription, ban
+// or unban, and for unsubscriptions not triggered by the validator, why it happened
+type TransitionCause struct {
+	Block  uint64 `json:"block,omitempty"`
+	TxHash string `json:"tx_hash,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Change in the status of a validator, at the slot it was processed
+type StateTransition struct {
+	Slot  uint64          `json:"slot"`
+	Event Event           `json:"event"`
+	From  ValidatorStatus `json:"from"`
+	To    ValidatorStatus `json:"to"`
+	TransitionCause
 }
 
 type RawLeaf struct {
@@ -260,6 +440,48 @@ func (s *RewardType) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+func (e Event) String() string {
+	switch e {
+	case ProposalOk:
+		return "proposalok"
+	case ProposalMissed:
+		return "proposalmissed"
+	case ProposalWrongFee:
+		return "proposalwrongfee"
+	case ManualSubscription:
+		return "manualsubscription"
+	case AutoSubscription:
+		return "autosubscription"
+	case Unsubscribe:
+		return "unsubscribe"
+	case ManualBan:
+		return "manualban"
+	case ManualUnban:
+		return "manualunban"
+	case UnknownEvent:
+		return "unknownevent"
+	}
+	return ""
+}
+
+func (e *Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.String())
+}
+
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var event string
+	if err := json.Unmarshal(b, &event); err != nil {
+		return errors.Wrap(err, "unmarshaling event")
+	}
+	for candidate := UnknownEvent; candidate <= ManualUnban; candidate++ {
+		if candidate.String() == event {
+			*e = candidate
+			return nil
+		}
+	}
+	return errors.New("unknown event")
+}
+
 func (v ValidatorStatus) String() string {
 	if v == Active {
 		return "active"
@@ -273,6 +495,8 @@ func (v ValidatorStatus) String() string {
 		return "banned"
 	} else if v == Untracked {
 		return "untracked"
+	} else if v == BlsProposer {
+		return "blsproposer"
 	} else if v == UnknownState {
 		return "unknownstate"
 	}
@@ -300,6 +524,8 @@ func (s *ValidatorStatus) UnmarshalJSON(b []byte) error {
 		*s = Banned
 	} else if status == "untracked" {
 		*s = Untracked
+	} else if status == "blsproposer" {
+		*s = BlsProposer
 	} else if status == "unknownstate" {
 		*s = UnknownState
 	} else {
@@ -0,0 +1,212 @@
+package oracle
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Outcome of verifying a checkpoint against the onchain roots
+type VerificationResult string
+
+const (
+	VerificationPass VerificationResult = "pass"
+	VerificationFail VerificationResult = "fail"
+	// Checkpoint that was not consolidated onchain, eg not enough data to create a tree
+	VerificationSkipped VerificationResult = "skipped"
+)
+
+// Result of comparing the root frozen at a checkpoint with the one consolidated onchain
+type CheckpointVerification struct {
+	Slot        uint64             `json:"slot"`
+	OracleRoot  string             `json:"oracle_root"`
+	OnchainRoot string             `json:"onchain_root"`
+	Result      VerificationResult `json:"result"`
+	Reason      string             `json:"reason,omitempty"`
+
+	// Leaves that changed since the last checkpoint that passed, only on failures
+	LeafDiffs []LeafDiff `json:"leaf_diffs,omitempty"`
+}
+
+// Change in the leaf of a withdrawal address between two checkpoints. Nil
+// balances mean that the leaf didnt exist in that checkpoint
+type LeafDiff struct {
+	WithdrawalAddress string   `json:"withdrawal_address"`
+	PreviousWei       *big.Int `json:"previous_wei"`
+	CurrentWei        *big.Int `json:"current_wei"`
+}
+
+// Replays the blocks from the next slot to process up to toSlot, freezing a tree at every
+// checkpoint and comparing its root with the one consolidated onchain for that slot. Stops
+// at the first divergence, whose result contains the leaves that changed since the last
+// checkpoint that passed. onResult, if any, is called as soon as each checkpoint is verified.
+func (or *Oracle) VerifyCheckpoints(
+	fetchBlock func(slot uint64) (*FullBlock, error),
+	onchainRoots map[uint64]string,
+	toSlot uint64,
+	onResult func(CheckpointVerification)) ([]CheckpointVerification, error) {
+
+	results := make([]CheckpointVerification, 0)
+	lastPassedLeafs := make(map[string]RawLeaf)
+
+	for or.state.NextSlotToProcess <= toSlot {
+		slot := or.state.NextSlotToProcess
+		fullBlock, err := fetchBlock(slot)
+		if err != nil {
+			return results, errors.Wrap(err, fmt.Sprintf("could not get block at slot %d", slot))
+		}
+		_, err = or.AdvanceStateToNextSlot(fullBlock)
+		if err != nil {
+			return results, errors.Wrap(err, fmt.Sprintf("could not process slot %d", slot))
+		}
+
+		isCheckpoint, err := or.IsCheckpoint()
+		if err != nil {
+			return results, errors.Wrap(err, "could not check if we are at a checkpoint")
+		}
+		onchainRoot, reported := onchainRoots[slot]
+		if !isCheckpoint && !reported {
+			continue
+		}
+
+		result := CheckpointVerification{Slot: slot, OnchainRoot: onchainRoot}
+		currentLeafs := make(map[string]RawLeaf)
+
+		if !isCheckpoint {
+			result.Result = VerificationFail
+			result.Reason = "root consolidated onchain for a slot that is not a checkpoint"
+		} else {
+			err = or.RunOffchainReconciliation()
+			if err != nil {
+				return results, errors.Wrap(err, fmt.Sprintf("offchain reconciliation failed at slot %d", slot))
+			}
+			enoughData := or.FreezeCheckpoint()
+			if enoughData {
+				result.OracleRoot = or.state.CommitedStates[slot].MerkleRoot
+				currentLeafs = or.state.CommitedStates[slot].LeafsByAddress()
+			}
+
+			switch {
+			case !reported:
+				result.Result = VerificationSkipped
+				result.Reason = "no root consolidated onchain for this checkpoint"
+			case !enoughData:
+				result.Result = VerificationFail
+				result.Reason = "not enough data to create a tree but a root was consolidated onchain"
+			case !strings.EqualFold(result.OracleRoot, onchainRoot):
+				result.Result = VerificationFail
+				result.Reason = "oracle and onchain roots dont match"
+			default:
+				result.Result = VerificationPass
+			}
+		}
+
+		if result.Result == VerificationFail {
+			result.LeafDiffs = diffLeafs(lastPassedLeafs, currentLeafs)
+		}
+		if result.Result == VerificationPass {
+			lastPassedLeafs = currentLeafs
+		}
+
+		log.WithFields(log.Fields{
+			"Slot":        result.Slot,
+			"OracleRoot":  result.OracleRoot,
+			"OnchainRoot": result.OnchainRoot,
+			"Result":      result.Result,
+		}).Info("Verified checkpoint")
+
+		results = append(results, result)
+		if onResult != nil {
+			onResult(result)
+		}
+		if result.Result == VerificationFail {
+			return results, nil
+		}
+	}
+	return results, nil
+}
+
+// Returns the leaves that differ between two checkpoints, sorted by withdrawal address
+func diffLeafs(previous map[string]RawLeaf, current map[string]RawLeaf) []LeafDiff {
+	diffs := make([]LeafDiff, 0)
+	for address, currentLeaf := range current {
+		previousLeaf, found := previous[address]
+		if found && previousLeaf.AccumulatedBalanceWei.Cmp(currentLeaf.AccumulatedBalanceWei) == 0 {
+			continue
+		}
+		diff := LeafDiff{WithdrawalAddress: address, CurrentWei: currentLeaf.AccumulatedBalanceWei}
+		if found {
+			diff.PreviousWei = previousLeaf.AccumulatedBalanceWei
+		}
+		diffs = append(diffs, diff)
+	}
+	for address, previousLeaf := range previous {
+		if _, found := current[address]; !found {
+			diffs = append(diffs, LeafDiff{WithdrawalAddress: address, PreviousWei: previousLeaf.AccumulatedBalanceWei})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].WithdrawalAddress < diffs[j].WithdrawalAddress })
+	return diffs
+}
+
+// Full blocks recorded in a directory, one json file per slot. Blocks that are not
+// recorded are fetched with fetchBlock, if any, and recorded for the next runs.
+type BlockCache struct {
+	dir        string
+	fetchBlock func(slot uint64) *FullBlock
+}
+
+func NewBlockCache(dir string, fetchBlock func(slot uint64) *FullBlock) (*BlockCache, error) {
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create block cache folder")
+	}
+	return &BlockCache{dir: dir, fetchBlock: fetchBlock}, nil
+}
+
+// Returns the full block at the given slot, from the directory if recorded
+func (c *BlockCache) FullBlock(slot uint64) (*FullBlock, error) {
+	path := filepath.Join(c.dir, fmt.Sprintf("fullblock_slot_%d.json", slot))
+
+	rawBytes, err := ioutil.ReadFile(path)
+	if err == nil {
+		var fullBlock FullBlock
+		err = json.Unmarshal(rawBytes, &fullBlock)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not unmarshal recorded block "+path)
+		}
+		return &fullBlock, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "could not read recorded block "+path)
+	}
+	if c.fetchBlock == nil {
+		return nil, errors.New(fmt.Sprintf("block at slot %d is not recorded in %s", slot, c.dir))
+	}
+
+	fullBlock := c.fetchBlock(slot)
+	rawBytes, err = json.Marshal(fullBlock)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not marshal block")
+	}
+
+	// Written to a temporary file first so that an interrupted run doesnt leave half a block
+	tmpPath := path + ".tmp"
+	err = ioutil.WriteFile(tmpPath, rawBytes, 0644)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not write block")
+	}
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not write block")
+	}
+	return fullBlock, nil
+}
@@ -0,0 +1,114 @@
+package oracle
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/attestantio/go-eth2-client/spec/phase0"
+	"github.com/stretchr/testify/require"
+)
+
+// Oracle with one subscribed validator whose pending rewards are consolidated at slot 1050,
+// as if it proposed a block, so the leaves change between the checkpoints 1000 and 1100
+func verifyTestOracle() (*Oracle, func(slot uint64) (*FullBlock, error)) {
+	oracle := testOracle(Mainnet, 0)
+	oracle.state.Validators[1] = trackedValidator(1, Active, 100, "0x00000000000000000000000000000000000000a1")
+	oracle.state.ProposedBlocks = []SummarizedBlock{{RewardType: VanilaBlock, Reward: big.NewInt(100)}}
+
+	fetchBlock := func(slot uint64) (*FullBlock, error) {
+		if slot == 1050 {
+			oracle.consolidateBalance(1)
+		}
+		return missedFullBlock(slot), nil
+	}
+	return oracle, fetchBlock
+}
+
+func Test_VerifyCheckpoints(t *testing.T) {
+	// Roots reported by the oracles
+	reference, fetchBlock := verifyTestOracle()
+	for slot := uint64(1000); slot <= 1150; slot++ {
+		block, _ := fetchBlock(slot)
+		_, err := reference.AdvanceStateToNextSlot(block)
+		require.NoError(t, err)
+		isCheckpoint, err := reference.IsCheckpoint()
+		require.NoError(t, err)
+		if isCheckpoint {
+			require.True(t, reference.FreezeCheckpoint())
+		}
+	}
+	onchainRoots := map[uint64]string{
+		1000: reference.state.CommitedStates[1000].MerkleRoot,
+		1100: reference.state.CommitedStates[1100].MerkleRoot,
+	}
+
+	t.Run("All roots match", func(t *testing.T) {
+		oracle, fetchBlock := verifyTestOracle()
+		seen := make([]uint64, 0)
+		results, err := oracle.VerifyCheckpoints(fetchBlock, onchainRoots, 1150, func(result CheckpointVerification) {
+			seen = append(seen, result.Slot)
+		})
+		require.NoError(t, err)
+		require.Equal(t, []uint64{1000, 1100}, seen)
+		require.Equal(t, VerificationPass, results[0].Result)
+		require.Equal(t, VerificationPass, results[1].Result)
+		require.Equal(t, uint64(1151), oracle.state.NextSlotToProcess)
+	})
+
+	t.Run("Stops at the first divergence", func(t *testing.T) {
+		wrongRoots := map[uint64]string{
+			1000: onchainRoots[1000],
+			1100: "0x0000000000000000000000000000000000000000000000000000000000000001",
+		}
+		oracle, fetchBlock := verifyTestOracle()
+		results, err := oracle.VerifyCheckpoints(fetchBlock, wrongRoots, 1300, nil)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(results))
+		require.Equal(t, VerificationFail, results[1].Result)
+		require.Equal(t, onchainRoots[1100], results[1].OracleRoot)
+		require.Equal(t, uint64(1101), oracle.state.NextSlotToProcess)
+
+		// Only the leaf of the validator that got its rewards consolidated changed
+		require.Equal(t, []LeafDiff{{
+			WithdrawalAddress: "0x00000000000000000000000000000000000000a1",
+			PreviousWei:       big.NewInt(0),
+			CurrentWei:        big.NewInt(100),
+		}}, results[1].LeafDiffs)
+	})
+
+	t.Run("Root reported for a slot that is not a checkpoint", func(t *testing.T) {
+		oracle, fetchBlock := verifyTestOracle()
+		results, err := oracle.VerifyCheckpoints(fetchBlock, map[uint64]string{1050: onchainRoots[1100]}, 1150, nil)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(results))
+		require.Equal(t, VerificationSkipped, results[0].Result)
+		require.Equal(t, VerificationFail, results[1].Result)
+		require.Equal(t, uint64(1050), results[1].Slot)
+	})
+}
+
+func Test_BlockCache(t *testing.T) {
+	dir := t.TempDir()
+	fetched := 0
+	recorder, err := NewBlockCache(dir, func(slot uint64) *FullBlock {
+		fetched++
+		return missedFullBlock(slot)
+	})
+	require.NoError(t, err)
+
+	block, err := recorder.FullBlock(1000)
+	require.NoError(t, err)
+	require.Equal(t, phase0.Slot(1000), block.ConsensusDuty.Slot)
+	_, err = recorder.FullBlock(1000)
+	require.NoError(t, err)
+	require.Equal(t, 1, fetched)
+
+	// Replaying only reads recorded blocks
+	replay, err := NewBlockCache(dir, nil)
+	require.NoError(t, err)
+	block, err = replay.FullBlock(1000)
+	require.NoError(t, err)
+	require.Equal(t, phase0.Slot(1000), block.ConsensusDuty.Slot)
+	_, err = replay.FullBlock(1001)
+	require.Error(t, err)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/dappnode/mev-sp-oracle/config"
+	"github.com/dappnode/mev-sp-oracle/oracle"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Replays the pool from the deployed slot and checks the root of every checkpoint against
+// the ones consolidated onchain. Returns the exit code: 0 if all checkpoints passed, 1 on
+// the first divergence.
+func runVerify(args []string) int {
+	verifyCfg, err := config.NewVerifyCliConfig(args)
+	if err != nil {
+		log.Fatal("error parsing the verify cli config: ", err)
+	}
+
+	logLevel, err := log.ParseLevel(verifyCfg.LogLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.SetLevel(logLevel)
+
+	onchain, err := oracle.NewOnchain(&verifyCfg.CliConfig, nil)
+	if err != nil {
+		log.Fatal("Could not create new onchain object: ", err)
+	}
+	cfg := onchain.GetConfigFromContract(&verifyCfg.CliConfig)
+
+	// Roots consolidated onchain since the contract was deployed
+	latestBlock, err := onchain.ExecutionClient.BlockNumber(context.Background())
+	if err != nil {
+		log.Fatal("Could not get latest block: ", err)
+	}
+	events, err := onchain.GetReportConsolidatedEventsInRange(cfg.DeployedBlock, latestBlock, verifyCfg.BlocksPerQuery)
+	if err != nil {
+		log.Fatal("Could not get consolidated reports: ", err)
+	}
+	onchainRoots := make(map[uint64]string)
+	for _, event := range events {
+		onchainRoots[event.SlotNumber.Uint64()] = hexutil.Encode(event.NewRewardsRoot[:])
+	}
+
+	toSlot := verifyCfg.ToSlot
+	if toSlot == 0 {
+		toSlot, err = onchain.GetLastConsolidatedSlot()
+		if err != nil {
+			log.Fatal("Could not get last consolidated slot: ", err)
+		}
+	}
+	if toSlot < cfg.DeployedSlot {
+		fmt.Println("Nothing to verify, no roots were consolidated onchain yet")
+		return 0
+	}
+
+	oracleInstance := oracle.NewOracle(cfg)
+	oracleInstance.SetGetSetOfValidatorsFunc(onchain.GetSetOfValidators)
+	oracleInstance.GetPendingConsolidationsFunc(onchain.GetPendingConsolidations)
+
+	fetchBlock := func(slot uint64) (*oracle.FullBlock, error) {
+		return onchain.FetchFullBlock(slot, oracleInstance), nil
+	}
+	if verifyCfg.BlocksDir != "" {
+		var fetchMissing func(slot uint64) *oracle.FullBlock
+		if !verifyCfg.Replay {
+			fetchMissing = func(slot uint64) *oracle.FullBlock {
+				return onchain.FetchFullBlock(slot, oracleInstance)
+			}
+		}
+		blockCache, err := oracle.NewBlockCache(verifyCfg.BlocksDir, fetchMissing)
+		if err != nil {
+			log.Fatal("Could not create block cache: ", err)
+		}
+		fetchBlock = blockCache.FullBlock
+	}
+
+	fmt.Printf("Verifying %d onchain roots from slot %d to %d\n", len(onchainRoots), cfg.DeployedSlot, toSlot)
+	fmt.Printf("%-10s %-66s %-66s %s\n", "SLOT", "ORACLE ROOT", "ONCHAIN ROOT", "RESULT")
+	printRow := func(result oracle.CheckpointVerification) {
+		fmt.Printf("%-10d %-66s %-66s %s\n", result.Slot, orNone(result.OracleRoot), orNone(result.OnchainRoot), result.Result)
+	}
+
+	results, err := oracleInstance.VerifyCheckpoints(fetchBlock, onchainRoots, toSlot, printRow)
+	if err != nil {
+		log.Fatal("Could not verify checkpoints: ", err)
+	}
+
+	passed := 0
+	for _, result := range results {
+		if result.Result == oracle.VerificationPass {
+			passed++
+		}
+		if result.Result != oracle.VerificationFail {
+			continue
+		}
+		fmt.Printf("\nDivergence at slot %d: %s\n", result.Slot, result.Reason)
+		fmt.Printf("Leaves that changed since the last checkpoint that passed (%d):\n", len(result.LeafDiffs))
+		fmt.Printf("%-42s %-30s %s\n", "WITHDRAWAL ADDRESS", "PREVIOUS WEI", "CURRENT WEI")
+		for _, diff := range result.LeafDiffs {
+			previous, current := "none", "none"
+			if diff.PreviousWei != nil {
+				previous = diff.PreviousWei.String()
+			}
+			if diff.CurrentWei != nil {
+				current = diff.CurrentWei.String()
+			}
+			fmt.Printf("%-42s %-30s %s\n", diff.WithdrawalAddress, previous, current)
+		}
+		return 1
+	}
+
+	fmt.Printf("\nAll checkpoints verified: %d passed, %d skipped\n", passed, len(results)-passed)
+	return 0
+}
+
+func orNone(root string) string {
+	if root == "" {
+		return "none"
+	}
+	return root
+}
+
+// Exits with the result of the verify subcommand if it was requested
+func maybeRunVerify() {
+	if len(os.Args) > 1 && os.Args[1] == "verify" {
+		os.Exit(runVerify(os.Args[2:]))
+	}
+}